@@ -0,0 +1,49 @@
+package apitest
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestTester loads config, written to a temporary file, into a tester for baseURL whose
+// output is discarded
+func newTestTester(t *testing.T, baseURL, config string) *APITester {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test_cases.json")
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tester := NewAPITester(path, baseURL, false)
+	tester.out = io.Discard
+	if err := tester.LoadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	return tester
+}
+
+func TestPreservedHeaderCaseKeepsTestHeaders(t *testing.T) {
+	tester := newTestTester(t, "http://example.com", `{"test_case": []}`)
+	tester.Logs = &LogQuery{}
+	tester.requestID = "generated"
+	testCase := TestCase{
+		PreserveHeaderCase: true,
+		Headers:            map[string]HeaderValues{"accept-encoding": {"identity"}, "x-request-id": {"mine"}},
+	}
+
+	req, err := tester.createHTTPRequest(context.Background(), http.MethodGet, "http://example.com/", nil, testCase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Accept-Encoding", "X-Request-Id"} {
+		if values := req.Header.Values(name); len(values) != 0 {
+			t.Errorf("%s was added as %q next to the test's own header", name, values)
+		}
+	}
+	if got := headerValue(req.Header, "X-Request-Id"); got != "mine" {
+		t.Errorf("request id = %q, want the test's own", got)
+	}
+}
@@ -0,0 +1,91 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Assertion group severities; a group without one fails the test like any other check
+const (
+	SeveritySoft = "soft"
+	SeverityHard = "hard"
+)
+
+// AssertionGroup is a named set of checks with its own severity. Soft groups only warn when they
+// fail, so aspirational checks can be tracked without failing the test; a failing hard group skips
+// every check after it.
+type AssertionGroup struct {
+	Name               string                 `json:"name"`
+	Severity           string                 `json:"severity"`
+	ExpectedStatusCode int                    `json:"expected_status_code"`
+	ExpectedHeaders    map[string]string      `json:"expected_headers"`
+	ExpectedResponse   map[string]interface{} `json:"expected_response"`
+}
+
+// checkAssertionGroups validates the severities of a test's assertion groups
+func checkAssertionGroups(testCase TestCase) error {
+	for i, group := range testCase.Assertions {
+		if group.Severity != "" && group.Severity != SeveritySoft && group.Severity != SeverityHard {
+			return fmt.Errorf("assertions[%d]: severity %q must be %s or %s", i, group.Severity, SeveritySoft, SeverityHard)
+		}
+	}
+	return nil
+}
+
+// label names a group in messages: its name, or its position
+func (g AssertionGroup) label(index int) string {
+	if g.Name != "" {
+		return fmt.Sprintf("assertions[%d] (%s)", index, g.Name)
+	}
+	return fmt.Sprintf("assertions[%d]", index)
+}
+
+// check runs the group's checks against the response
+func (g AssertionGroup) check(t *APITester, result *TestResult, resp *http.Response, responseData interface{}) []assertionError {
+	var errors []assertionError
+	if g.ExpectedStatusCode != 0 && result.ResponseStatusCode != g.ExpectedStatusCode {
+		errors = append(errors, assertionError{CategoryStatus,
+			fmt.Sprintf("HTTP Status: Expected %d, got %d", g.ExpectedStatusCode, result.ResponseStatusCode)})
+	}
+	errors = append(errors, validateHeaders(TestCase{ExpectedHeaders: g.ExpectedHeaders}, resp)...)
+	if g.ExpectedResponse != nil {
+		if responseData == nil {
+			errors = append(errors, assertionError{CategorySchema,
+				fmt.Sprintf("Response body: Expected a body, got none (HTTP %d)", result.ResponseStatusCode)})
+		} else {
+			errors = append(errors, t.validate(g.ExpectedResponse, responseData, "")...)
+		}
+	}
+	return errors
+}
+
+// validateAssertionGroups runs a test's assertion groups in order, recording soft failures as
+// warnings. It returns false when a hard group failed, so the test's remaining checks are skipped.
+func (t *APITester) validateAssertionGroups(testCase TestCase, result *TestResult, resp *http.Response, responseData interface{}) bool {
+	for i, group := range testCase.Assertions {
+		errors := group.check(t, result, resp, responseData)
+		label := group.label(i)
+		for _, err := range errors {
+			if group.Severity == SeveritySoft {
+				result.Warnings = append(result.Warnings, label+": "+err.Message)
+			} else {
+				result.addError(err.Category, label+": "+err.Message)
+			}
+		}
+		if len(errors) > 0 && group.Severity == SeverityHard {
+			return false
+		}
+	}
+	return true
+}
+
+// countSoftFailures counts the results that passed with failing soft assertions, and those assertions
+func countSoftFailures(results []TestResult) (tests, warnings int) {
+	for _, result := range results {
+		if len(result.Warnings) > 0 {
+			tests++
+			warnings += len(result.Warnings)
+		}
+	}
+	return tests, warnings
+}
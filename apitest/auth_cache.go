@@ -0,0 +1,186 @@
+package apitest
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// AuthCacheExpiryMargin is subtracted from token expiry so cached tokens aren't used right before they lapse
+const AuthCacheExpiryMargin = 30 * time.Second
+
+// AuthCacheEntry stores the variables a cacheable test extracted and when they expire
+type AuthCacheEntry struct {
+	TestCaseName string                 `json:"test_case_name"`
+	Variables    map[string]interface{} `json:"variables"`
+	ExpiresAt    time.Time              `json:"expires_at"`
+}
+
+// AuthCache persists extracted auth variables between runs
+type AuthCache struct {
+	Path    string
+	Entries map[string]AuthCacheEntry
+}
+
+// LoadAuthCache reads the cache file, starting empty if it doesn't exist yet
+func LoadAuthCache(path string) (*AuthCache, error) {
+	cache := &AuthCache{Path: expandHome(path), Entries: make(map[string]AuthCacheEntry)}
+
+	data, err := os.ReadFile(cache.Path)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read auth cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &cache.Entries); err != nil {
+		return nil, fmt.Errorf("failed to parse auth cache %s: %w", cache.Path, err)
+	}
+	return cache, nil
+}
+
+// Lookup returns the unexpired entry for a key
+func (c *AuthCache) Lookup(key string) (AuthCacheEntry, bool) {
+	entry, ok := c.Entries[key]
+	if !ok || time.Now().After(entry.ExpiresAt) {
+		return AuthCacheEntry{}, false
+	}
+	return entry, true
+}
+
+// Store saves an entry, drops expired ones and writes the cache file
+func (c *AuthCache) Store(key string, entry AuthCacheEntry) error {
+	now := time.Now()
+	for k, e := range c.Entries {
+		if now.After(e.ExpiresAt) {
+			delete(c.Entries, k)
+		}
+	}
+	c.Entries[key] = entry
+
+	data, err := json.MarshalIndent(c.Entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal auth cache: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return fmt.Errorf("failed to create auth cache directory: %w", err)
+	}
+	// Tokens are credentials, so keep the file private
+	if err := os.WriteFile(c.Path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write auth cache: %w", err)
+	}
+	return nil
+}
+
+// expandHome expands a leading ~ to the user's home directory
+func expandHome(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, strings.TrimPrefix(path, "~"))
+}
+
+// authCacheKey identifies a cacheable request by method, URL, rendered headers and rendered body,
+// so a login sent with other credentials in a header doesn't reuse the cached variables
+func (t *APITester) authCacheKey(testCase TestCase, method, url string) string {
+	headers, _ := json.Marshal(t.replaceInHeaders(testCase.Headers))
+	body, _ := json.Marshal(t.replaceInInterface(testCase.Body))
+	sum := sha256.Sum256([]byte(method + "\n" + url + "\n" + string(headers) + "\n" + string(body)))
+	return hex.EncodeToString(sum[:])
+}
+
+// authCacheExpiry picks the earlier of the configured TTL and any JWT expiry among the variables
+func authCacheExpiry(ttlSeconds int, variables map[string]interface{}) time.Time {
+	expiresAt := time.Now().Add(time.Duration(ttlSeconds) * time.Second)
+	for _, value := range variables {
+		token, ok := value.(string)
+		if !ok {
+			continue
+		}
+		if exp, ok := jwtExpiry(token); ok && exp.Add(-AuthCacheExpiryMargin).Before(expiresAt) {
+			expiresAt = exp.Add(-AuthCacheExpiryMargin)
+		}
+	}
+	return expiresAt
+}
+
+// jwtExpiry reads the exp claim of a JWT without verifying it
+func jwtExpiry(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, false
+	}
+	var claims struct {
+		Exp float64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == 0 {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(claims.Exp), 0), true
+}
+
+// restoreFromAuthCache fills the result from a cached entry, returning false on a cache miss
+func (t *APITester) restoreFromAuthCache(testCase TestCase, result *TestResult) bool {
+	if t.AuthCache == nil || testCase.AuthCacheTTL <= 0 {
+		return false
+	}
+	entry, ok := t.AuthCache.Lookup(t.authCacheKey(testCase, result.Method, result.URL))
+	if !ok {
+		return false
+	}
+
+	for name, value := range entry.Variables {
+		t.setVariable(name, value, "auth cache: "+testCase.TestCaseName)
+		if testCase.Extract[name].Secret {
+			t.addSecret(value)
+			fmt.Fprintf(t.out, "  %s↳ Restored %s = %s%s\n", ColorCyan, name, SecretMask, ColorReset)
+			continue
+		}
+		fmt.Fprintf(t.out, "  %s↳ Restored %s = %v%s\n", ColorCyan, name, value, ColorReset)
+	}
+	result.Status = StatusPassed
+	result.Cached = true
+	fmt.Fprintf(t.out, "  %s✓ PASSED (cached, expires in %s)%s\n",
+		ColorGreen, time.Until(entry.ExpiresAt).Round(time.Second), ColorReset)
+	return true
+}
+
+// saveToAuthCache stores the variables a passing cacheable test extracted. Only those this run
+// extracted are stored: an optional path the response lacked leaves an earlier value in place,
+// which belongs to another request.
+func (t *APITester) saveToAuthCache(testCase TestCase, result TestResult, extracted []string) {
+	if t.AuthCache == nil || testCase.AuthCacheTTL <= 0 || result.Status != StatusPassed {
+		return
+	}
+
+	variables := make(map[string]interface{})
+	for _, name := range extracted {
+		variables[name] = t.Variables[name]
+	}
+	if len(variables) == 0 {
+		return
+	}
+
+	entry := AuthCacheEntry{
+		TestCaseName: testCase.TestCaseName,
+		Variables:    variables,
+		ExpiresAt:    authCacheExpiry(testCase.AuthCacheTTL, variables),
+	}
+	if err := t.AuthCache.Store(t.authCacheKey(testCase, result.Method, result.URL), entry); err != nil {
+		fmt.Fprintf(t.out, "  %s⚠ %v%s\n", ColorYellow, err, ColorReset)
+	}
+}
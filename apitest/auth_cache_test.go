@@ -0,0 +1,56 @@
+package apitest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func TestAuthCache(t *testing.T) {
+	logins := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/admin":
+			w.Write([]byte(`{"refresh": "admin-refresh"}`))
+		case "/login":
+			logins++
+			w.Write([]byte(`{"token": "token-` + r.Header.Get("X-User") + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	cache, err := LoadAuthCache(filepath.Join(t.TempDir(), "tokens.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	run := func(user string) *APITester {
+		tester := newTestTester(t, server.URL, `{"test_case": [
+			{"test_case_name": "Admin", "order": 1, "method": "POST", "api": "/admin", "extract": {"refresh": "refresh"}},
+			{"test_case_name": "Login", "order": 2, "method": "POST", "api": "/login",
+				"headers": {"X-User": "`+user+`"}, "auth_cache_ttl": 60,
+				"extract": {"token": "token", "refresh": "refresh"}}
+		]}`)
+		tester.AuthCache = cache
+		tester.RunAllTests()
+		return tester
+	}
+
+	run("ada")
+	if logins != 1 {
+		t.Fatalf("got %d logins, want 1", logins)
+	}
+	for _, entry := range cache.Entries {
+		if _, ok := entry.Variables["refresh"]; ok {
+			t.Errorf("cached refresh = %v, which the login response didn't provide", entry.Variables["refresh"])
+		}
+	}
+
+	if tester := run("ada"); logins != 1 || tester.Variables["token"] != "token-ada" {
+		t.Errorf("same headers: got %d logins and token %v, want the cached token-ada", logins, tester.Variables["token"])
+	}
+	if tester := run("bob"); logins != 2 || tester.Variables["token"] != "token-bob" {
+		t.Errorf("other headers: got %d logins and token %v, want a new login as bob", logins, tester.Variables["token"])
+	}
+}
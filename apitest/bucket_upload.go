@@ -0,0 +1,138 @@
+package apitest
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Bucket sinks, which upload through the cloud CLIs and their usual credentials
+const (
+	SinkS3  = "s3"
+	SinkGCS = "gs"
+)
+
+// UploadTimeout bounds each upload command, which may copy a whole snapshot directory
+const UploadTimeout = 5 * time.Minute
+
+// parseBucketSpec checks an s3://bucket/prefix or gs://bucket/prefix spec, whose optional
+// ?profile= names the AWS profile or gcloud configuration to use
+func parseBucketSpec(spec string) (*url.URL, error) {
+	location, err := url.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("report %q: %w", spec, err)
+	}
+	if location.Host == "" {
+		return nil, fmt.Errorf("report %q: needs a bucket, e.g. %s://bucket/prefix", spec, location.Scheme)
+	}
+	for key := range location.Query() {
+		if key != "profile" {
+			return nil, fmt.Errorf("report %q: unknown option %q (available: profile)", spec, key)
+		}
+	}
+	return location, nil
+}
+
+// bucketSink uploads the JSON report, the files of the run's other file sinks and the snapshot
+// directory to <bucket>/<prefix>/<run id>/, so CI runs with throwaway disks keep their artifacts
+type bucketSink struct {
+	tester   *APITester
+	location *url.URL
+	// artifacts are local files and directories uploaded next to the report when they exist
+	artifacts []string
+}
+
+// Deliver uploads the report and artifacts, returning the run's folder in the bucket
+func (s *bucketSink) Deliver(report TestReport) (string, error) {
+	destination := s.location.Scheme + "://" + path.Join(s.location.Host, s.location.Path, s.tester.RunID)
+
+	data, err := formatJSONReport(report)
+	if err != nil {
+		return "", err
+	}
+	tmp, err := os.CreateTemp("", "apitest-report-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to write report for upload: %w", err)
+	}
+	if err := s.upload(tmp.Name(), destination+"/report.json", false); err != nil {
+		return "", err
+	}
+
+	for _, artifact := range s.artifacts {
+		info, err := os.Stat(artifact)
+		if err != nil {
+			continue
+		}
+		if err := s.upload(artifact, destination+"/"+filepath.Base(artifact), info.IsDir()); err != nil {
+			return "", err
+		}
+	}
+	return destination, nil
+}
+
+// upload copies a file, or a directory's tree, to a bucket URL with the aws or gcloud CLI
+func (s *bucketSink) upload(source, target string, recursive bool) error {
+	profile := s.location.Query().Get("profile")
+	var command string
+	var args []string
+	switch s.location.Scheme {
+	case SinkS3:
+		command = "aws"
+		args = []string{"s3", "cp", "--only-show-errors"}
+		if profile != "" {
+			args = append(args, "--profile", profile)
+		}
+	case SinkGCS:
+		command = "gcloud"
+		args = []string{"storage", "cp", "--quiet"}
+		if profile != "" {
+			args = append(args, "--configuration", profile)
+		}
+	}
+	if recursive {
+		args = append(args, "--recursive")
+		// gcloud copies the directory itself into the target, aws copies its contents
+		if s.location.Scheme == SinkGCS {
+			target = strings.TrimSuffix(target, "/"+filepath.Base(source)) + "/"
+		}
+	}
+	if _, err := runCommandTimeout(UploadTimeout, command, append(args, source, target)...); err != nil {
+		return fmt.Errorf("failed to upload %s to %s: %w", source, target, err)
+	}
+	return nil
+}
+
+// attachArtifacts hands the files written by the file sinks and the snapshot directory to the
+// bucket sinks, and moves those last so the files exist by the time they upload
+func (t *APITester) attachArtifacts(sinks []ReportSink) []ReportSink {
+	var artifacts []string
+	var ordered, buckets []ReportSink
+	for _, sink := range sinks {
+		switch sink := sink.(type) {
+		case fileSink:
+			artifacts = append(artifacts, sink.path)
+			ordered = append(ordered, sink)
+		case *bucketSink:
+			buckets = append(buckets, sink)
+		default:
+			ordered = append(ordered, sink)
+		}
+	}
+	artifacts = append(artifacts, t.SnapshotDir)
+	for _, sink := range buckets {
+		sink.(*bucketSink).artifacts = artifacts
+	}
+	return append(ordered, buckets...)
+}
@@ -0,0 +1,347 @@
+package apitest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheDialTimeout bounds connecting to Redis or memcached
+const cacheDialTimeout = 5 * time.Second
+
+// CacheStep reads a key from Redis or memcached to verify caching and session side effects
+type CacheStep struct {
+	// DSN is redis://[user:password@]host:port/db, rediss:// for TLS, or memcached://host:port.
+	// It defaults to the REDIS_URL or MEMCACHED_URL environment variable.
+	DSN            string `json:"dsn"`
+	Key            string `json:"key"`
+	TTLMin         *int   `json:"ttl_min"`
+	TTLMax         *int   `json:"ttl_max"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+}
+
+// cacheStepConfig returns the config and default DSN variable of a redis or memcached step
+func cacheStepConfig(testCase TestCase) (*CacheStep, string) {
+	if testCase.Type == "memcached" {
+		return testCase.Memcached, "MEMCACHED_URL"
+	}
+	return testCase.Redis, "REDIS_URL"
+}
+
+// cacheStepTarget returns the key the cache step reads
+func cacheStepTarget(t *APITester, testCase TestCase) string {
+	step, _ := cacheStepConfig(testCase)
+	if step == nil {
+		return ""
+	}
+	return t.replaceVariables(step.Key)
+}
+
+// runCacheStep reads the key into {"key", "exists", "type", "value", "json", "ttl"}; with a
+// timeout it waits for the key to appear
+func runCacheStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step, dsnVar := cacheStepConfig(testCase)
+	if step == nil || step.Key == "" {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("%s step: \"%s.key\" is required", testCase.Type, testCase.Type)}}
+	}
+	dsn := t.replaceVariables(step.DSN)
+	if dsn == "" {
+		dsn = os.Getenv(dsnVar)
+	}
+	if dsn == "" {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("%s step: no dsn configured and %s is not set", testCase.Type, dsnVar)}}
+	}
+	key := t.replaceVariables(step.Key)
+
+	read := readRedisKey
+	if testCase.Type == "memcached" {
+		read = readMemcachedKey
+	}
+
+	var data map[string]interface{}
+	var err error
+	if step.Timeout > 0 {
+		timeout, interval := stepDurations(step.Timeout, step.PollIntervalMs)
+		err = poll(t.Context, timeout, interval, func(context.Context) error {
+			if data, err = read(dsn, key); err != nil {
+				return err
+			}
+			if data["exists"] != true {
+				return fmt.Errorf("key %s does not exist", key)
+			}
+			return nil
+		})
+	} else {
+		data, err = read(dsn, key)
+	}
+	if err != nil {
+		if data != nil {
+			return data, []assertionError{{CategoryTimeout, fmt.Sprintf("%s: %v", testCase.Type, err)}}
+		}
+		return nil, []assertionError{{classifyRequestError(err), fmt.Sprintf("%s: %v", testCase.Type, err)}}
+	}
+
+	// Values are often JSON documents; expose them parsed as well
+	if value, ok := data["value"].(string); ok {
+		var parsed interface{}
+		if t.unmarshalJSON([]byte(value), &parsed) == nil {
+			data["json"] = parsed
+		}
+	}
+
+	var errors []assertionError
+	if ttl, ok := data["ttl"].(float64); ok {
+		if step.TTLMin != nil && ttl < float64(*step.TTLMin) {
+			errors = append(errors, assertionError{CategoryStep,
+				fmt.Sprintf("TTL of %s: Expected at least %ds, got %.0fs", key, *step.TTLMin, ttl)})
+		}
+		if step.TTLMax != nil && ttl > float64(*step.TTLMax) {
+			errors = append(errors, assertionError{CategoryStep,
+				fmt.Sprintf("TTL of %s: Expected at most %ds, got %.0fs", key, *step.TTLMax, ttl)})
+		}
+	}
+	return data, errors
+}
+
+// dialCache connects to the host of a DSN, using TLS for rediss://
+func dialCache(dsn *url.URL, defaultPort string) (net.Conn, error) {
+	host := dsn.Host
+	if dsn.Port() == "" {
+		host = net.JoinHostPort(dsn.Hostname(), defaultPort)
+	}
+	dialer := &net.Dialer{Timeout: cacheDialTimeout}
+	var conn net.Conn
+	var err error
+	if dsn.Scheme == "rediss" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", host, &tls.Config{ServerName: dsn.Hostname()})
+	} else {
+		conn, err = dialer.Dial("tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	conn.SetDeadline(time.Now().Add(cacheDialTimeout))
+	return conn, nil
+}
+
+// respClient is a minimal Redis client speaking RESP2
+type respClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// do sends a command and reads its reply
+func (c *respClient) do(args ...string) (interface{}, error) {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&sb, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, sb.String()); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply parses one RESP2 reply; nil bulk strings and arrays become nil
+func (c *respClient) readReply() (interface{}, error) {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, fmt.Errorf("empty reply from redis")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(c.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			if items[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("unexpected reply from redis: %q", line)
+	}
+}
+
+// readRedisKey reads a key's type, value and TTL from Redis
+func readRedisKey(rawDSN, key string) (map[string]interface{}, error) {
+	dsn, err := url.Parse(rawDSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis dsn: %w", err)
+	}
+	conn, err := dialCache(dsn, "6379")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	client := &respClient{conn: conn, reader: bufio.NewReader(conn)}
+
+	if dsn.User != nil {
+		password, hasPassword := dsn.User.Password()
+		args := []string{"AUTH", dsn.User.Username(), password}
+		if !hasPassword {
+			args = []string{"AUTH", dsn.User.Username()}
+		} else if dsn.User.Username() == "" {
+			args = []string{"AUTH", password}
+		}
+		if _, err := client.do(args...); err != nil {
+			return nil, err
+		}
+	}
+	if db := strings.Trim(dsn.Path, "/"); db != "" {
+		if _, err := client.do("SELECT", db); err != nil {
+			return nil, err
+		}
+	}
+
+	keyType, err := client.do("TYPE", key)
+	if err != nil {
+		return nil, err
+	}
+	data := map[string]interface{}{"key": key, "type": keyType, "exists": keyType != "none"}
+	if keyType == "none" {
+		return data, nil
+	}
+
+	ttl, err := client.do("TTL", key)
+	if err != nil {
+		return nil, err
+	}
+	data["ttl"] = float64(ttl.(int64))
+
+	var reply interface{}
+	switch keyType {
+	case "string":
+		reply, err = client.do("GET", key)
+	case "hash":
+		reply, err = client.do("HGETALL", key)
+		if items, ok := reply.([]interface{}); ok {
+			reply = pairsToMap(items, false)
+		}
+	case "list":
+		reply, err = client.do("LRANGE", key, "0", "-1")
+	case "set":
+		reply, err = client.do("SMEMBERS", key)
+		if items, ok := reply.([]interface{}); ok {
+			// Set members come back in no particular order
+			sort.Slice(items, func(i, j int) bool { return fmt.Sprint(items[i]) < fmt.Sprint(items[j]) })
+		}
+	case "zset":
+		reply, err = client.do("ZRANGE", key, "0", "-1", "WITHSCORES")
+		if items, ok := reply.([]interface{}); ok {
+			reply = pairsToMap(items, true)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	data["value"] = reply
+	return data, nil
+}
+
+// pairsToMap turns a flat [field, value, ...] reply into an object, parsing values as numbers
+// when numeric is set (sorted set scores)
+func pairsToMap(items []interface{}, numeric bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(items)/2)
+	for i := 0; i+1 < len(items); i += 2 {
+		value := items[i+1]
+		if numeric {
+			if score, err := strconv.ParseFloat(fmt.Sprint(value), 64); err == nil {
+				value = score
+			}
+		}
+		result[fmt.Sprint(items[i])] = value
+	}
+	return result
+}
+
+// readMemcachedKey reads a key's value, flags and TTL with the memcached meta protocol
+func readMemcachedKey(rawDSN, key string) (map[string]interface{}, error) {
+	dsn, err := url.Parse(rawDSN)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memcached dsn: %w", err)
+	}
+	conn, err := dialCache(dsn, "11211")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	if _, err := fmt.Fprintf(conn, "mg %s v t f\r\n", key); err != nil {
+		return nil, err
+	}
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.Fields(line)
+	data := map[string]interface{}{"key": key, "exists": false}
+	switch {
+	case len(fields) > 0 && fields[0] == "EN":
+		return data, nil
+	case len(fields) < 2 || fields[0] != "VA":
+		return nil, fmt.Errorf("unexpected reply from memcached: %q", strings.TrimSpace(line))
+	}
+
+	size, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("unexpected reply from memcached: %q", strings.TrimSpace(line))
+	}
+	buf := make([]byte, size+2)
+	if _, err := io.ReadFull(reader, buf); err != nil {
+		return nil, err
+	}
+
+	data["exists"] = true
+	data["value"] = string(buf[:size])
+	for _, flag := range fields[2:] {
+		number, err := strconv.ParseFloat(flag[1:], 64)
+		if err != nil {
+			continue
+		}
+		switch flag[0] {
+		case 't':
+			data["ttl"] = number
+		case 'f':
+			data["flags"] = number
+		}
+	}
+	return data, nil
+}
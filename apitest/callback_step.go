@@ -0,0 +1,105 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// CallbackVariable holds the URL of the callback listener
+const CallbackVariable = "callback_url"
+
+// callbackPollIntervalMs is how often callback steps check the listener unless configured
+const callbackPollIntervalMs = 50
+
+// CallbackStep waits for the API to deliver a webhook to the callback listener
+type CallbackStep struct {
+	// Path the webhook must be delivered to, e.g. /orders; any path when empty
+	Path           string `json:"path"`
+	Method         string `json:"method"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+}
+
+// StartCallbackListener starts the webhook listener and exposes its URL as {{callback_url}}
+func (t *APITester) StartCallbackListener(config CatcherConfig) error {
+	listener, err := startCatcher(config)
+	if err != nil {
+		return fmt.Errorf("failed to start callback listener: %w", err)
+	}
+	t.callbackListener = listener
+	t.setVariable(CallbackVariable, listener.URL, SourceBuiltin)
+	fmt.Fprintf(t.out, "%s✓ Callback listener at %s%s\n", ColorGreen, listener.URL, ColorReset)
+	return nil
+}
+
+// callbackStepTarget describes the webhook the step waits for
+func callbackStepTarget(t *APITester, testCase TestCase) string {
+	step := testCase.Callback
+	if step == nil {
+		return ""
+	}
+	method := strings.ToUpper(step.Method)
+	if method == "" {
+		method = "ANY"
+	}
+	path := t.replaceVariables(step.Path)
+	if path == "" {
+		path = "/*"
+	}
+	return method + " " + path
+}
+
+// runCallbackStep waits for the oldest webhook matching the step that no earlier callback step
+// claimed, returning {"method", "path", "query", "headers", "body"}
+func runCallbackStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.Callback
+	if step == nil {
+		return nil, []assertionError{{CategoryRequest, "Callback step: \"callback\" config is required"}}
+	}
+	if t.callbackListener == nil {
+		return nil, []assertionError{{CategoryRequest, "Callback step: configure \"callback_listener\""}}
+	}
+
+	path := t.replaceVariables(step.Path)
+	// The listener is in-process, so checking often costs nothing
+	intervalMs := step.PollIntervalMs
+	if intervalMs == 0 {
+		intervalMs = callbackPollIntervalMs
+	}
+	timeout, interval := stepDurations(step.Timeout, intervalMs)
+	var delivered caughtRequest
+	err := poll(t.Context, timeout, interval, func(context.Context) error {
+		var ok bool
+		delivered, ok = t.callbackListener.claim(func(request caughtRequest) bool {
+			return (path == "" || request.Path == path) &&
+				(step.Method == "" || strings.EqualFold(request.Method, step.Method))
+		})
+		if !ok {
+			return fmt.Errorf("no webhook delivered to %s", callbackStepTarget(t, testCase))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, []assertionError{{CategoryTimeout, fmt.Sprintf("Callback: %v", err)}}
+	}
+	fmt.Fprintf(t.out, "  %s↳ Webhook %s %s (%d bytes)%s\n", ColorCyan, delivered.Method, delivered.Path,
+		len(delivered.Body), ColorReset)
+
+	headers := make(map[string]interface{}, len(delivered.Header))
+	for name, values := range delivered.Header {
+		headers[name] = strings.Join(values, ", ")
+	}
+	var body interface{} = string(delivered.Body)
+	var parsed interface{}
+	if t.unmarshalJSON(delivered.Body, &parsed) == nil {
+		body = parsed
+	}
+	return map[string]interface{}{
+		"method":  delivered.Method,
+		"path":    delivered.Path,
+		"query":   delivered.Query,
+		"headers": headers,
+		"body":    body,
+	}, nil
+}
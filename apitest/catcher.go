@@ -0,0 +1,125 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CatcherConfig configures a built-in HTTP listener that records the requests it receives
+type CatcherConfig struct {
+	// Listen is the address to listen on (default 127.0.0.1:0, a random free port)
+	Listen string `json:"listen"`
+	// PublicURL is the address the API under test should use, when it can't reach Listen directly
+	PublicURL string `json:"public_url"`
+	// ResponseStatus is the status answered to every request (default 200)
+	ResponseStatus int `json:"response_status"`
+	// ResponseBody is the body answered to every request (default {})
+	ResponseBody string `json:"response_body"`
+}
+
+// caughtRequest is a request received by a catcher
+type caughtRequest struct {
+	Method   string
+	Path     string
+	Query    string
+	Header   http.Header
+	Body     []byte
+	Received time.Time
+	claimed  bool
+}
+
+// requestCatcher is an ephemeral HTTP server recording every request it receives
+type requestCatcher struct {
+	URL      string
+	server   *http.Server
+	mu       sync.Mutex
+	requests []caughtRequest
+}
+
+// startCatcher listens according to the config and records requests in the background
+func startCatcher(config CatcherConfig) (*requestCatcher, error) {
+	listen := config.Listen
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	listener, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", listen, err)
+	}
+
+	catcher := &requestCatcher{URL: strings.TrimRight(config.PublicURL, "/")}
+	if catcher.URL == "" {
+		catcher.URL = "http://" + listener.Addr().String()
+	}
+
+	status := config.ResponseStatus
+	if status == 0 {
+		status = http.StatusOK
+	}
+	body := config.ResponseBody
+	if body == "" {
+		body = "{}"
+	}
+	catcher.server = &http.Server{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			payload, _ := io.ReadAll(r.Body)
+			catcher.mu.Lock()
+			catcher.requests = append(catcher.requests, caughtRequest{
+				Method:   r.Method,
+				Path:     r.URL.Path,
+				Query:    r.URL.RawQuery,
+				Header:   r.Header.Clone(),
+				Body:     payload,
+				Received: time.Now(),
+			})
+			catcher.mu.Unlock()
+
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			io.WriteString(w, body)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go catcher.server.Serve(listener)
+	return catcher, nil
+}
+
+// since returns the requests received at or after a time, oldest first
+func (c *requestCatcher) since(start time.Time) []caughtRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var result []caughtRequest
+	for _, request := range c.requests {
+		if !request.Received.Before(start) {
+			result = append(result, request)
+		}
+	}
+	return result
+}
+
+// claim returns the oldest unclaimed request accepted by match and marks it claimed, so each
+// request satisfies only one step
+func (c *requestCatcher) claim(match func(caughtRequest) bool) (caughtRequest, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for i := range c.requests {
+		if !c.requests[i].claimed && match(c.requests[i]) {
+			c.requests[i].claimed = true
+			return c.requests[i], true
+		}
+	}
+	return caughtRequest{}, false
+}
+
+// Close stops the listener
+func (c *requestCatcher) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c.server.Shutdown(ctx)
+}
@@ -0,0 +1,121 @@
+package apitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+)
+
+// Failure categories, so dashboards can tell infrastructure problems from contract breaks
+const (
+	CategoryConnection      = "connection"
+	CategoryTimeout         = "timeout"
+	CategoryDNS             = "dns"
+	CategoryStatus          = "status-mismatch"
+	CategoryHeader          = "header-mismatch"
+	CategoryContentType     = "content-type-mismatch"
+	CategoryTLS             = "tls"
+	CategoryConnectionReuse = "connection-reuse"
+	CategoryLatency         = "latency"
+	CategorySize            = "size"
+	CategoryBody            = "body-mismatch"
+	CategorySchema          = "schema"
+	CategorySnapshot        = "snapshot-mismatch"
+	CategoryJWT             = "jwt"
+	CategoryDecryption      = "decryption"
+	CategoryExtraction      = "extraction"
+	CategoryRequest         = "request" // the request could not be built
+	CategoryHook            = "hook"    // a setup or teardown hook failed
+	CategoryStep            = "step"    // a non-HTTP step's checks didn't hold
+	CategorySideEffect      = "side-effect"
+	CategoryClient          = "client-saturation"  // the tester ran out of its own files or ports
+	CategoryTransport       = "transport-mismatch" // the request didn't fail as expect_transport_error says
+)
+
+// assertionError is a validation failure with its category
+type assertionError struct {
+	Category string
+	Message  string
+}
+
+// addError records an error message and its category on the result
+func (r *TestResult) addError(category, message string) {
+	r.Errors = append(r.Errors, message)
+	for _, existing := range r.Categories {
+		if existing == category {
+			return
+		}
+	}
+	r.Categories = append(r.Categories, category)
+}
+
+// addAssertionErrors records validation failures on the result
+func (r *TestResult) addAssertionErrors(errs []assertionError) {
+	for _, err := range errs {
+		r.addError(err.Category, err.Message)
+	}
+}
+
+// classifyRequestError tells timeouts apart from other transport failures and from the tester
+// running out of its own resources; a body that arrived but couldn't be decoded is the API's
+// fault, not the connection's
+func classifyRequestError(err error) string {
+	if isClientSaturation(err) {
+		return CategoryClient
+	}
+	if errors.Is(err, errContentDecoding) {
+		return CategoryBody
+	}
+	if errors.Is(err, errHostTrust) {
+		return CategoryTLS
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && !dnsErr.IsTimeout {
+		return CategoryDNS
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CategoryTimeout
+	}
+	return CategoryConnection
+}
+
+// countCategories counts failed results per category
+func countCategories(results []TestResult) map[string]int {
+	counts := make(map[string]int)
+	for _, result := range results {
+		if result.Status == StatusPassed || result.Status == StatusFlaky {
+			continue
+		}
+		for _, category := range result.Categories {
+			counts[category]++
+		}
+	}
+	if len(counts) == 0 {
+		return nil
+	}
+	return counts
+}
+
+// formatCategoryCounts renders category counts as "body-mismatch 2, timeout 1", largest first
+func formatCategoryCounts(counts map[string]int) string {
+	categories := make([]string, 0, len(counts))
+	for category := range counts {
+		categories = append(categories, category)
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		if counts[categories[i]] != counts[categories[j]] {
+			return counts[categories[i]] > counts[categories[j]]
+		}
+		return categories[i] < categories[j]
+	})
+
+	parts := make([]string, len(categories))
+	for i, category := range categories {
+		parts[i] = fmt.Sprintf("%s %d", category, counts[category])
+	}
+	return strings.Join(parts, ", ")
+}
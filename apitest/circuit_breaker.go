@@ -0,0 +1,76 @@
+package apitest
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+)
+
+// circuitBreaker skips tests against hosts that failed to connect too many times in a row
+type circuitBreaker struct {
+	threshold int
+	failures  map[string]int
+	open      map[string]string // host → reason the circuit opened
+}
+
+// newCircuitBreaker opens a host's circuit after threshold consecutive connection failures
+func newCircuitBreaker(threshold int) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: threshold,
+		failures:  make(map[string]int),
+		open:      make(map[string]string),
+	}
+}
+
+// EnableCircuitBreaker skips the remaining tests of a host after threshold consecutive connection failures
+func (t *APITester) EnableCircuitBreaker(threshold int) {
+	t.breaker = newCircuitBreaker(threshold)
+}
+
+// resultHost returns the host a result's request went to, or "" for steps and unparsable URLs
+func resultHost(result TestResult) string {
+	parsed, err := url.Parse(result.URL)
+	if err != nil {
+		return ""
+	}
+	return parsed.Host
+}
+
+// isConnectionFailure reports whether a result failed without the host ever answering
+func isConnectionFailure(result TestResult) bool {
+	if !isFailed(result.Status) || result.ResponseStatusCode != 0 {
+		return false
+	}
+	for _, category := range result.Categories {
+		if category == CategoryConnection || category == CategoryDNS || category == CategoryTimeout {
+			return true
+		}
+	}
+	return false
+}
+
+// skipReason returns why tests against a host are skipped, or "" while its circuit is closed
+func (b *circuitBreaker) skipReason(host string) string {
+	if b == nil || host == "" {
+		return ""
+	}
+	return b.open[host]
+}
+
+// record counts a finished test towards its host's consecutive failures; any answer resets the count
+func (b *circuitBreaker) record(out io.Writer, result TestResult) {
+	host := resultHost(result)
+	if b == nil || host == "" || result.Status == StatusSkipped {
+		return
+	}
+	if !isConnectionFailure(result) {
+		b.failures[host] = 0
+		return
+	}
+
+	b.failures[host]++
+	if b.failures[host] >= b.threshold && b.open[host] == "" {
+		b.open[host] = fmt.Sprintf("circuit open for %s after %d consecutive connection failures", host, b.failures[host])
+		fmt.Fprintf(out, "  %s⚡ Circuit open for %s: skipping its remaining tests%s\n", ColorYellow, host, ColorReset)
+	}
+}
@@ -0,0 +1,84 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CommandStep runs a shell command, e.g. a psql query or a queue CLI, and exposes its output for assertions
+type CommandStep struct {
+	Run            string `json:"run"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+	// ExitCode is the exit status to wait for; the command is rerun until it exits with it (default 0)
+	ExitCode *int `json:"exit_code"`
+}
+
+// commandStepTarget returns the command line the step runs
+func commandStepTarget(t *APITester, testCase TestCase) string {
+	if testCase.Command == nil {
+		return ""
+	}
+	return t.maskSecrets(t.shellVariables(testCase.Command.Run))
+}
+
+// runCommandStep reruns the command until it exits with the expected status and returns
+// {"exit_code": ..., "stdout": ..., "stderr": ...}; stdout holding JSON is parsed
+func runCommandStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.Command
+	if step == nil || step.Run == "" {
+		return nil, []assertionError{{CategoryRequest, "Command step: \"command.run\" is required"}}
+	}
+	command := t.shellVariables(step.Run)
+	timeout, interval := stepDurations(step.Timeout, step.PollIntervalMs)
+	want := 0
+	if step.ExitCode != nil {
+		want = *step.ExitCode
+	}
+
+	var exitCode int
+	var stdout, stderr bytes.Buffer
+	err := poll(t.Context, timeout, interval, func(ctx context.Context) error {
+		stdout.Reset()
+		stderr.Reset()
+		cmd := shellCommand(ctx, command)
+		cmd.Env = t.hookEnv(testCase, nil)
+		cmd.Stdout, cmd.Stderr = &stdout, &stderr
+		err := cmd.Run()
+		var exitErr *exec.ExitError
+		switch {
+		case err == nil:
+			exitCode = 0
+		case errors.As(err, &exitErr):
+			exitCode = exitErr.ExitCode()
+		default:
+			return err
+		}
+		if exitCode != want {
+			return fmt.Errorf("exited with %d, waiting for %d", exitCode, want)
+		}
+		return nil
+	})
+	if err != nil {
+		message := fmt.Sprintf("Command: %v", err)
+		if output := strings.TrimSpace(stderr.String()); output != "" {
+			message += "\n" + output
+		}
+		return nil, []assertionError{{CategoryTimeout, message}}
+	}
+
+	var output interface{} = strings.TrimSpace(stdout.String())
+	var parsed interface{}
+	if t.unmarshalJSON(stdout.Bytes(), &parsed) == nil {
+		output = parsed
+	}
+	return map[string]interface{}{
+		"exit_code": float64(exitCode),
+		"stdout":    output,
+		"stderr":    strings.TrimSpace(stderr.String()),
+	}, nil
+}
@@ -0,0 +1,26 @@
+package apitest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCommandStepVariablesAreNotParsedByTheShell(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "pwned")
+	server := injectionServer(t, marker)
+	tester := newTestTester(t, server.URL, `{"test_case": [
+		{"test_case_name": "Get", "order": 1, "method": "GET", "api": "/", "extract": {"name": "name"}},
+		{"test_case_name": "Run", "order": 2, "type": "command",
+			"command": {"run": "echo \"name: {{name}}\""},
+			"expected_response": {"stdout": "name: x; touch `+marker+`; $(touch `+marker+`)"}}
+	]}`)
+	tester.RunAllTests()
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the extracted value ran as a command")
+	}
+	if result := tester.Results[1]; result.Status != StatusPassed {
+		t.Errorf("command step %s: %v", result.Status, result.Errors)
+	}
+}
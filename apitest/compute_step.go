@@ -0,0 +1,198 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// ComputeStep sets variables from JMESPath expressions evaluated over the current variables,
+// e.g. {"offset": "multiply(subtract(page, `1`), page_size)"}, without sending a request.
+// Every expression sees the variables as they were before the step.
+type ComputeStep map[string]string
+
+// checkCompute rejects a compute step without expressions or with one that doesn't compile
+func checkCompute(testCase TestCase) error {
+	if testCase.Type != "compute" {
+		return nil
+	}
+	if len(testCase.Compute) == 0 {
+		return fmt.Errorf("compute step: \"compute\" needs at least one variable")
+	}
+	functions := computeFunctions(nil)
+	for _, name := range testCase.Compute.names() {
+		if _, err := compileJMESPathWith(testCase.Compute[name], functions); err != nil {
+			return fmt.Errorf("compute %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// names returns the variables the step sets, sorted
+func (s ComputeStep) names() []string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// computeVariables returns the variables a compute step's expressions read
+func computeVariables(testCase TestCase) []string {
+	var names []string
+	functions := computeFunctions(nil)
+	for _, name := range testCase.Compute.names() {
+		if node, err := compileJMESPathWith(testCase.Compute[name], functions); err == nil {
+			names = append(names, node.rootFields()...)
+		}
+	}
+	return names
+}
+
+// computeStepTarget lists the variables the step sets
+func computeStepTarget(t *APITester, testCase TestCase) string {
+	return "set " + strings.Join(testCase.Compute.names(), ", ")
+}
+
+// runComputeStep evaluates every expression against the variables, then sets them all. It returns
+// the values set, so expected_response can check them.
+func runComputeStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	// A round trip through JSON gives the expressions the numbers, arrays and objects they expect,
+	// whether a variable came from the config, -set or a response
+	encoded, err := json.Marshal(t.Variables)
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("Compute step: failed to encode variables: %v", err)}}
+	}
+	var variables interface{}
+	if err := t.unmarshalJSON(encoded, &variables); err != nil {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("Compute step: failed to decode variables: %v", err)}}
+	}
+
+	functions := computeFunctions(t)
+	computed := make(map[string]interface{}, len(testCase.Compute))
+	var errors []assertionError
+	for _, name := range testCase.Compute.names() {
+		node, err := compileJMESPathWith(testCase.Compute[name], functions)
+		if err != nil {
+			errors = append(errors, assertionError{CategoryRequest, fmt.Sprintf("Compute %s: %v", name, err)})
+			continue
+		}
+		value, err := node.eval(variables)
+		if err != nil {
+			errors = append(errors, assertionError{CategoryExtraction, fmt.Sprintf("Compute %s: %v", name, err)})
+			continue
+		}
+		if value == nil {
+			errors = append(errors, assertionError{CategoryExtraction, fmt.Sprintf("Compute %s: %s is null; check the variable names it uses", name, testCase.Compute[name])})
+			continue
+		}
+		computed[name] = value
+	}
+	if len(errors) > 0 {
+		return nil, errors
+	}
+
+	for _, name := range testCase.Compute.names() {
+		t.setVariable(name, computed[name], "compute: "+testCase.TestCaseName)
+		fmt.Fprintf(t.out, "  %s↳ Computed %s = %s%s\n", ColorCyan, name,
+			t.maskSecrets(fmt.Sprintf("%v", computed[name])), ColorReset)
+	}
+	return computed, nil
+}
+
+// computeFunctions are the functions compute expressions may call besides JMESPath's own. The
+// random ones draw from the run's seed, so -seed repeats them; t is nil when only compiling.
+func computeFunctions(t *APITester) map[string]jpFunction {
+	return map[string]jpFunction{
+		"add":      computeArithmetic(func(a, b float64) (float64, error) { return a + b, nil }),
+		"subtract": computeArithmetic(func(a, b float64) (float64, error) { return a - b, nil }),
+		"multiply": computeArithmetic(func(a, b float64) (float64, error) { return a * b, nil }),
+		"divide": computeArithmetic(func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return a / b, nil
+		}),
+		"mod": computeArithmetic(func(a, b float64) (float64, error) {
+			if b == 0 {
+				return 0, fmt.Errorf("division by zero")
+			}
+			return math.Mod(a, b), nil
+		}),
+		"concat": computeConcat,
+		"random_item": func(args []interface{}) (interface{}, error) {
+			if err := jpArgs(args, 1); err != nil {
+				return nil, err
+			}
+			array, ok := args[0].([]interface{})
+			if !ok {
+				return nil, jpInvalidType(args[0], "array")
+			}
+			if len(array) == 0 {
+				return nil, fmt.Errorf("the array is empty")
+			}
+			return array[t.random.IntN(len(array))], nil
+		},
+		"random_int": func(args []interface{}) (interface{}, error) {
+			if err := jpArgs(args, 2); err != nil {
+				return nil, err
+			}
+			low, high, err := computeOperands(args)
+			if err != nil {
+				return nil, err
+			}
+			if low != math.Trunc(low) || high != math.Trunc(high) || high < low {
+				return nil, fmt.Errorf("expected whole numbers min <= max, got %v and %v", low, high)
+			}
+			return low + float64(t.random.Int64N(int64(high-low)+1)), nil
+		},
+	}
+}
+
+// computeArithmetic adapts a binary operation to a function taking two numbers. Numeric strings,
+// such as a -set variable, count as numbers.
+func computeArithmetic(op func(a, b float64) (float64, error)) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 2); err != nil {
+			return nil, err
+		}
+		a, b, err := computeOperands(args)
+		if err != nil {
+			return nil, err
+		}
+		return op(a, b)
+	}
+}
+
+// computeOperands returns the two number arguments of a function
+func computeOperands(args []interface{}) (float64, float64, error) {
+	var operands [2]float64
+	for i := range operands {
+		n, err := toNumber(args[i])
+		if err != nil {
+			return 0, 0, jpInvalidType(args[i], "number")
+		}
+		operands[i] = n
+	}
+	return operands[0], operands[1], nil
+}
+
+// computeConcat joins its arguments into one string, JSON-encoding those that aren't strings. A
+// null argument is an error, as it is most likely a missing variable.
+func computeConcat(args []interface{}) (interface{}, error) {
+	var joined strings.Builder
+	for i, arg := range args {
+		if arg == nil {
+			return nil, fmt.Errorf("argument %d is null", i+1)
+		}
+		text, err := jpToString([]interface{}{arg})
+		if err != nil {
+			return nil, err
+		}
+		joined.WriteString(text.(string))
+	}
+	return joined.String(), nil
+}
@@ -0,0 +1,69 @@
+package apitest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ConditionalAssertion validates part of the response only when the response matches a condition
+type ConditionalAssertion struct {
+	If   map[string]interface{} `json:"if"`
+	Then map[string]interface{} `json:"then"`
+	Else map[string]interface{} `json:"else"`
+}
+
+// matches reports whether every path in the condition holds the given value; a JMESPath
+// expression that fails to evaluate doesn't match
+func (c ConditionalAssertion) matches(responseData interface{}) bool {
+	for path, expected := range c.If {
+		actual, err := lookupPath(responseData, path)
+		if err != nil {
+			return false
+		}
+		if expected == NullMarker || expected == AbsentMarker {
+			if presenceMismatch(expected, actual, pathFound(responseData, path, actual)) != "" {
+				return false
+			}
+		} else if !compareValues(expected, actual) {
+			return false
+		}
+	}
+	return true
+}
+
+// describe renders the condition for error messages
+func (c ConditionalAssertion) describe() string {
+	paths := make([]string, 0, len(c.If))
+	for path := range c.If {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	parts := make([]string, len(paths))
+	for i, path := range paths {
+		parts[i] = fmt.Sprintf("%s == %v", path, c.If[path])
+	}
+	return strings.Join(parts, " && ")
+}
+
+// validateConditional runs the then (or else) branch of every assert_if entry whose condition applies
+func (t *APITester) validateConditional(testCase TestCase, responseData interface{}) []assertionError {
+	var errors []assertionError
+	for i, assertion := range testCase.AssertIf {
+		branch, condition := assertion.Then, assertion.describe()
+		if !assertion.matches(responseData) {
+			branch, condition = assertion.Else, "not ("+condition+")"
+		}
+		if branch == nil {
+			continue
+		}
+
+		prefix := fmt.Sprintf("assert_if[%d] (%s)", i, condition)
+		for _, err := range t.validate(branch, responseData, "") {
+			err.Message = prefix + ": " + err.Message
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
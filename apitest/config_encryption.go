@@ -0,0 +1,247 @@
+package apitest
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptedConfigHeader starts a config or data file encrypted with the encrypt command; the rest
+// of the file is base64 nonce||ciphertext||tag, with the header as additional data
+const EncryptedConfigHeader = "APITEST-ENCRYPTED aes-256-gcm\n"
+
+// ConfigKeySize is the length of a config key: AES-256
+const ConfigKeySize = 32
+
+// ConfigKeyEnv names the key file -key-file defaults to
+const ConfigKeyEnv = "APITEST_KEY_FILE"
+
+// isEncryptedConfig reports whether file content was written by the encrypt command
+func isEncryptedConfig(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(EncryptedConfigHeader))
+}
+
+// LoadConfigKey reads a config key file: 32 bytes as base64 or hex text, as written by encrypt -new-key
+func LoadConfigKey(path string) ([]byte, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	text := strings.TrimSpace(string(data))
+	key, err := hex.DecodeString(text)
+	if err != nil {
+		if key, err = decodeBase64(text); err != nil {
+			return nil, fmt.Errorf("key file %s must hold base64 or hex text", path)
+		}
+	}
+	if len(key) != ConfigKeySize {
+		return nil, fmt.Errorf("key file %s holds a %d-byte key, expected %d", path, len(key), ConfigKeySize)
+	}
+	return key, nil
+}
+
+// newConfigKey writes a random key to a new key file readable only by its owner
+func newConfigKey(path string) ([]byte, error) {
+	key := make([]byte, ConfigKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+	file, err := os.OpenFile(expandHome(path), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create key file: %w", err)
+	}
+	defer file.Close()
+	if _, err := fmt.Fprintln(file, base64.StdEncoding.EncodeToString(key)); err != nil {
+		return nil, fmt.Errorf("failed to write key file: %w", err)
+	}
+	return key, nil
+}
+
+// encryptConfig encrypts a config or data file with AES-256-GCM
+func encryptConfig(key, plaintext []byte) ([]byte, error) {
+	gcm, err := configCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := gcm.Seal(nonce, nonce, plaintext, []byte(EncryptedConfigHeader))
+
+	var buf bytes.Buffer
+	buf.WriteString(EncryptedConfigHeader)
+	encoded := base64.StdEncoding.EncodeToString(sealed)
+	for len(encoded) > 76 {
+		buf.WriteString(encoded[:76] + "\n")
+		encoded = encoded[76:]
+	}
+	buf.WriteString(encoded + "\n")
+	return buf.Bytes(), nil
+}
+
+// decryptConfig decrypts the content of a file written by encryptConfig
+func decryptConfig(key, data []byte) ([]byte, error) {
+	gcm, err := configCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	body := strings.Join(strings.Fields(string(data[len(EncryptedConfigHeader):])), "")
+	sealed, err := base64.StdEncoding.DecodeString(body)
+	if err != nil || len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted file is corrupt")
+	}
+	plaintext, err := gcm.Open(nil, sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():], []byte(EncryptedConfigHeader))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: wrong key, or the file was modified")
+	}
+	return plaintext, nil
+}
+
+// configCipher returns the AES-GCM cipher of a config key
+func configCipher(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// readConfigFile reads a config or data file, decrypting it in memory when it is encrypted, so the
+// plaintext never touches the disk
+func (t *APITester) readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if path == t.ConfigPath && t.configData != nil {
+		data, err = t.configData, nil
+	}
+	if err != nil || !isEncryptedConfig(data) {
+		return data, err
+	}
+	if t.ConfigKey == nil {
+		return nil, fmt.Errorf("%s is encrypted; pass its key with -key-file or $%s", path, ConfigKeyEnv)
+	}
+	plaintext, err := decryptConfig(t.ConfigKey, data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return plaintext, nil
+}
+
+// runEncryptCommand implements "encrypt", which writes an encrypted copy of a config or data file
+func runEncryptCommand(args []string) int {
+	fs := flag.NewFlagSet("encrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", os.Getenv(ConfigKeyEnv), "Key file to encrypt with (default $"+ConfigKeyEnv+")")
+	newKey := fs.Bool("new-key", false, "Generate a new key into -key-file, which must not exist yet")
+	output := fs.String("o", "", "Write the encrypted file here (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s encrypt -key-file k [-new-key] [-o suite.enc] <config.json>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) != 1 || *keyFile == "" {
+		fmt.Fprintf(os.Stderr, "%sError: A file to encrypt and -key-file are required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+
+	plaintext, err := os.ReadFile(paths[0])
+	if err == nil && isEncryptedConfig(plaintext) {
+		err = errors.New("it is encrypted already")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to read %s: %v%s\n", ColorRed, paths[0], err, ColorReset)
+		return 1
+	}
+	var key []byte
+	if *newKey {
+		key, err = newConfigKey(*keyFile)
+	} else {
+		key, err = LoadConfigKey(*keyFile)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	encrypted, err := encryptConfig(key, plaintext)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to encrypt: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(encrypted)
+		return 0
+	}
+	if err := os.WriteFile(*output, encrypted, DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write encrypted file: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Printf("%s✓ Encrypted %s to: %s%s\n", ColorGreen, paths[0], *output, ColorReset)
+	if *newKey {
+		fmt.Printf("%s⚠ Keep %s out of the repository; anyone with it can read the config%s\n", ColorYellow, *keyFile, ColorReset)
+	}
+	return 0
+}
+
+// runDecryptCommand implements "decrypt", which restores the plaintext of an encrypted file for editing
+func runDecryptCommand(args []string) int {
+	fs := flag.NewFlagSet("decrypt", flag.ContinueOnError)
+	keyFile := fs.String("key-file", os.Getenv(ConfigKeyEnv), "Key file the file was encrypted with (default $"+ConfigKeyEnv+")")
+	output := fs.String("o", "", "Write the plaintext here, readable only by its owner (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s decrypt -key-file k [-o config.json] <suite.enc>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) != 1 || *keyFile == "" {
+		fmt.Fprintf(os.Stderr, "%sError: A file to decrypt and -key-file are required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+
+	key, err := LoadConfigKey(*keyFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	data, err := os.ReadFile(paths[0])
+	if err == nil && !isEncryptedConfig(data) {
+		err = errors.New("it isn't encrypted")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to read %s: %v%s\n", ColorRed, paths[0], err, ColorReset)
+		return 1
+	}
+	plaintext, err := decryptConfig(key, data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %s: %v%s\n", ColorRed, paths[0], err, ColorReset)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(plaintext)
+		return 0
+	}
+	if err := os.WriteFile(*output, plaintext, 0600); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write decrypted file: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Printf("%s✓ Decrypted %s to: %s%s\n", ColorGreen, paths[0], *output, ColorReset)
+	return 0
+}
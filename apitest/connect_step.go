@@ -0,0 +1,318 @@
+package apitest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// RPC protocols a connect step speaks
+const (
+	ConnectProtocolConnect = "connect"
+	ConnectProtocolGRPCWeb = "grpc-web"
+)
+
+// Flags of an enveloped message: the 5-byte prefix framing streamed messages is a flags byte and
+// a big-endian length
+const (
+	envelopeCompressed = 0x01
+	envelopeEndStream  = 0x02 // Connect's end-of-stream message
+	envelopeTrailers   = 0x80 // gRPC-Web's trailers
+)
+
+// grpcCodeNames are the gRPC status codes by number, named as Connect names them
+var grpcCodeNames = []string{
+	"ok", "canceled", "unknown", "invalid_argument", "deadline_exceeded", "not_found", "already_exists",
+	"permission_denied", "resource_exhausted", "failed_precondition", "aborted", "out_of_range",
+	"unimplemented", "internal", "unavailable", "data_loss", "unauthenticated",
+}
+
+// ConnectStep calls the RPC procedure at the test's api with a JSON message, over the Connect
+// protocol or gRPC-Web
+type ConnectStep struct {
+	// Protocol is connect (default) or grpc-web
+	Protocol string      `json:"protocol"`
+	Message  interface{} `json:"message"`
+	// Stream calls a server-streaming procedure, whose replies are returned as messages
+	Stream bool `json:"stream"`
+}
+
+// connectStepTarget returns the procedure URL and the protocol
+func connectStepTarget(t *APITester, testCase TestCase) string {
+	protocol := ConnectProtocolConnect
+	if testCase.Connect != nil && testCase.Connect.Protocol != "" {
+		protocol = testCase.Connect.Protocol
+	}
+	return fmt.Sprintf("%s (%s)", t.buildURL(testCase), protocol)
+}
+
+// runConnectStep posts the message with the protocol's framing and decodes the reply. A unary call
+// returns {"message": ...} and a streaming one {"messages": [...]}; a failed call adds
+// {"error": {"code": "not_found", "message": ..., "details": [...]}}, with gRPC's numeric codes
+// named as Connect names them, and trailers the server sent are under "trailers". An error fails
+// the test unless the expected response mentions "error".
+func runConnectStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.Connect
+	if step == nil {
+		step = &ConnectStep{}
+	}
+	protocol := step.Protocol
+	if protocol == "" {
+		protocol = ConnectProtocolConnect
+	}
+	if protocol != ConnectProtocolConnect && protocol != ConnectProtocolGRPCWeb {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("connect step: unknown protocol %q (available: %s, %s)",
+			protocol, ConnectProtocolConnect, ConnectProtocolGRPCWeb)}}
+	}
+	var message interface{} = map[string]interface{}{}
+	if step.Message != nil {
+		message = t.replaceInInterface(step.Message)
+	}
+	payload, err := json.Marshal(message)
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("connect step: %v", err)}}
+	}
+
+	// Unary Connect calls are plain JSON posts; everything else is enveloped
+	enveloped := protocol == ConnectProtocolGRPCWeb || step.Stream
+	contentType := "application/json"
+	switch {
+	case protocol == ConnectProtocolGRPCWeb:
+		contentType = "application/grpc-web+json"
+	case step.Stream:
+		contentType = "application/connect+json"
+	}
+	if enveloped {
+		payload = envelope(payload)
+	}
+
+	ctx, cancel := t.requestContext(testCase)
+	defer cancel()
+	req, err := t.createHTTPRequest(ctx, http.MethodPost, t.buildURL(testCase), bytes.NewReader(payload), testCase)
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, err.Error()}}
+	}
+	req.Header.Set("Content-Type", contentType)
+	timeout := testCase.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	if protocol == ConnectProtocolGRPCWeb {
+		req.Header.Set("X-Grpc-Web", "1")
+		req.Header.Set("Grpc-Timeout", fmt.Sprintf("%dS", timeout))
+	} else {
+		req.Header.Set("Connect-Protocol-Version", "1")
+		req.Header.Set("Connect-Timeout-Ms", strconv.Itoa(timeout*1000))
+	}
+
+	resp, _, _, err := t.executeRequest(req)
+	if err != nil {
+		return nil, []assertionError{{classifyRequestError(err), fmt.Sprintf("Request failed: %v", err)}}
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.Header.Get("Content-Encoding") != "" && !resp.Uncompressed {
+		body, err = decodeContent(ctx, resp.Header.Get("Content-Encoding"), body)
+	}
+	if err != nil {
+		return nil, []assertionError{{classifyRequestError(err), fmt.Sprintf("failed to read response: %v", err)}}
+	}
+
+	reply := map[string]interface{}{}
+	var errs []assertionError
+	switch {
+	case enveloped && resp.StatusCode/100 == 2:
+		errs = t.decodeEnvelopes(protocol, resp, body, reply)
+	case protocol == ConnectProtocolConnect:
+		// Connect answers errors before a stream starts like unary ones
+		errs = t.decodeUnaryConnect(resp, body, reply)
+	}
+	if !step.Stream {
+		if messages, ok := reply["messages"].([]interface{}); ok {
+			delete(reply, "messages")
+			if len(messages) > 1 {
+				errs = append(errs, assertionError{CategorySchema, fmt.Sprintf("%s: Expected one reply to a unary call, got %d; set \"stream\": true for a streaming procedure", protocol, len(messages))})
+			}
+			if len(messages) > 0 {
+				reply["message"] = messages[0]
+			}
+		}
+	}
+
+	_, failed := reply["error"]
+	switch expected := testCase.ExpectedStatusCode; {
+	case expected != 0 && resp.StatusCode != expected:
+		errs = append(errs, assertionError{CategoryStatus, fmt.Sprintf("HTTP Status: Expected %d, got %d", expected, resp.StatusCode)})
+	case expected == 0 && resp.StatusCode/100 != 2 && !failed:
+		errs = append(errs, assertionError{CategoryStatus, fmt.Sprintf("HTTP Status: Expected 2xx, got %d", resp.StatusCode)})
+	}
+	if rpcError, ok := reply["error"].(map[string]interface{}); ok {
+		if _, wanted := testCase.ExpectedResponse["error"]; !wanted {
+			errs = append(errs, assertionError{CategoryBody, fmt.Sprintf("%s error %v: %v", protocol, rpcError["code"], rpcError["message"])})
+		}
+	}
+	return reply, errs
+}
+
+// envelope frames one uncompressed message
+func envelope(message []byte) []byte {
+	framed := make([]byte, 5, 5+len(message))
+	binary.BigEndian.PutUint32(framed[1:], uint32(len(message)))
+	return append(framed, message...)
+}
+
+// decodeUnaryConnect decodes a unary Connect reply: the message on success, an error object otherwise
+func (t *APITester) decodeUnaryConnect(resp *http.Response, body []byte, reply map[string]interface{}) []assertionError {
+	var value interface{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := t.unmarshalJSON(body, &value); err != nil && resp.StatusCode/100 == 2 {
+			return []assertionError{{CategorySchema, fmt.Sprintf("connect: Expected a JSON reply, got %s", formatJSONContext(string(body), 1))}}
+		}
+	}
+	if resp.StatusCode/100 == 2 {
+		if value == nil {
+			value = map[string]interface{}{}
+		}
+		reply["message"] = value
+		return nil
+	}
+	// An error reply is {"code": "not_found", "message": ...}; a proxy's error page isn't
+	if object, ok := value.(map[string]interface{}); ok {
+		if _, named := object["code"].(string); named {
+			reply["error"] = object
+		}
+	}
+	return nil
+}
+
+// decodeEnvelopes splits an enveloped reply into its messages and its end-of-stream: a Connect
+// end-stream message carrying error and metadata, or gRPC-Web trailers carrying the status. A
+// gRPC-Web call that fails before any message answers with the status in its headers instead.
+func (t *APITester) decodeEnvelopes(protocol string, resp *http.Response, body []byte, reply map[string]interface{}) []assertionError {
+	// Binary protobuf and gRPC-Web's base64 text encoding can't be decoded without the schema
+	if mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); mediaType != "" && !strings.HasSuffix(mediaType, "+json") {
+		return []assertionError{{CategorySchema, fmt.Sprintf("%s: Expected a JSON-encoded reply, got %s", protocol, mediaType)}}
+	}
+	encoding := resp.Header.Get("Grpc-Encoding")
+	if protocol == ConnectProtocolConnect {
+		encoding = resp.Header.Get("Connect-Content-Encoding")
+	}
+
+	messages := []interface{}{}
+	trailers := textproto.MIMEHeader{}
+	ended := false
+	for len(body) > 0 {
+		if len(body) < 5 || uint64(len(body)-5) < uint64(binary.BigEndian.Uint32(body[1:5])) {
+			return []assertionError{{CategorySchema, fmt.Sprintf("%s: truncated message frame (%d bytes left)", protocol, len(body))}}
+		}
+		flags, size := body[0], binary.BigEndian.Uint32(body[1:5])
+		data := body[5 : 5+size]
+		body = body[5+size:]
+		if flags&envelopeCompressed != 0 {
+			var err error
+			if data, err = decodeContent(resp.Request.Context(), encoding, data); err != nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("%s: %v", protocol, err)}}
+			}
+		}
+
+		switch {
+		case protocol == ConnectProtocolGRPCWeb && flags&envelopeTrailers != 0:
+			parsed, err := textproto.NewReader(bufio.NewReader(bytes.NewReader(append(data, "\r\n\r\n"...)))).ReadMIMEHeader()
+			if err != nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("%s: malformed trailers: %v", protocol, err)}}
+			}
+			trailers, ended = parsed, true
+		case protocol == ConnectProtocolConnect && flags&envelopeEndStream != 0:
+			var end struct {
+				Error    map[string]interface{} `json:"error"`
+				Metadata map[string][]string    `json:"metadata"`
+			}
+			if err := json.Unmarshal(data, &end); err != nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("%s: malformed end-of-stream message: %v", protocol, err)}}
+			}
+			if end.Error != nil {
+				reply["error"] = end.Error
+			}
+			for name, values := range end.Metadata {
+				trailers[textproto.CanonicalMIMEHeaderKey(name)] = values
+			}
+			ended = true
+		default:
+			var value interface{}
+			if err := t.unmarshalJSON(data, &value); err != nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("%s: message %d is not JSON: %v", protocol, len(messages), err)}}
+			}
+			messages = append(messages, value)
+		}
+	}
+	reply["messages"] = messages
+
+	if protocol == ConnectProtocolGRPCWeb {
+		// Trailers-only replies put the status in the headers
+		if !ended && resp.Header.Get("Grpc-Status") != "" {
+			trailers = textproto.MIMEHeader{"Grpc-Status": resp.Header.Values("Grpc-Status"), "Grpc-Message": resp.Header.Values("Grpc-Message")}
+			ended = true
+		}
+		if ended {
+			if rpcError := grpcStatusError(trailers); rpcError != nil {
+				reply["error"] = rpcError
+			}
+			trailers = rpcTrailers(trailers)
+		}
+	}
+	if len(trailers) > 0 {
+		values := make(map[string]interface{}, len(trailers))
+		for name, list := range trailers {
+			values[strings.ToLower(name)] = strings.Join(list, ", ")
+		}
+		reply["trailers"] = values
+	}
+	if !ended && protocol == ConnectProtocolGRPCWeb {
+		return []assertionError{{CategorySchema, protocol + ": the stream ended without trailers"}}
+	}
+	if !ended {
+		return []assertionError{{CategorySchema, protocol + ": the stream ended without an end-of-stream message"}}
+	}
+	return nil
+}
+
+// grpcStatusError turns a non-zero grpc-status into an error object like Connect's
+func grpcStatusError(trailers textproto.MIMEHeader) map[string]interface{} {
+	status := trailers.Get("Grpc-Status")
+	code, err := strconv.Atoi(status)
+	if code == 0 && err == nil {
+		return nil
+	}
+	name := "unknown"
+	if err == nil && code > 0 && code < len(grpcCodeNames) {
+		name = grpcCodeNames[code]
+	}
+	// grpc-message is percent-encoded
+	message := trailers.Get("Grpc-Message")
+	if decoded, err := url.PathUnescape(message); err == nil {
+		message = decoded
+	}
+	return map[string]interface{}{"code": name, "message": message}
+}
+
+// rpcTrailers drops the gRPC status trailers, leaving the application's
+func rpcTrailers(trailers textproto.MIMEHeader) textproto.MIMEHeader {
+	kept := textproto.MIMEHeader{}
+	for name, values := range trailers {
+		lower := strings.ToLower(name)
+		if lower == "grpc-status" || lower == "grpc-message" || lower == "grpc-status-details-bin" {
+			continue
+		}
+		kept[name] = values
+	}
+	return kept
+}
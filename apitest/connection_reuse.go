@@ -0,0 +1,48 @@
+package apitest
+
+import (
+	"fmt"
+)
+
+// connectionReused reports whether a result's request went over a kept-alive connection, or
+// nil when it never got one (a non-HTTP step, or a request that failed to connect)
+func connectionReused(trace *requestTrace) *bool {
+	if !trace.gotConn {
+		return nil
+	}
+	reused := trace.Reused
+	return &reused
+}
+
+// validateConnectionReuse checks expect_connection_reuse: true requires a kept-alive connection,
+// false a freshly dialed one
+func validateConnectionReuse(testCase TestCase, result *TestResult) []assertionError {
+	if testCase.ExpectConnectionReuse == nil || result.ConnectionReused == nil {
+		return nil
+	}
+	expected, reused := *testCase.ExpectConnectionReuse, *result.ConnectionReused
+	if expected == reused {
+		return nil
+	}
+	if expected {
+		return []assertionError{{CategoryConnectionReuse,
+			fmt.Sprintf("Connection: Expected a reused keep-alive connection, got a new connection to %s", result.RemoteAddr)}}
+	}
+	return []assertionError{{CategoryConnectionReuse,
+		fmt.Sprintf("Connection: Expected a new connection, got one reused after %.0fms idle", result.ConnectionIdleMs)}}
+}
+
+// countConnections counts the new and reused connections of the results' requests
+func countConnections(results []TestResult) (opened, reused int) {
+	for _, result := range results {
+		if result.ConnectionReused == nil {
+			continue
+		}
+		if *result.ConnectionReused {
+			reused++
+		} else {
+			opened++
+		}
+	}
+	return opened, reused
+}
@@ -0,0 +1,96 @@
+package apitest
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Media type families compared by the content-type check
+const (
+	familyJSON   = "json"
+	familyNDJSON = "ndjson"
+	familyHTML   = "html"
+	familyXML    = "xml"
+	familyText   = "text"
+	familyBinary = "binary"
+)
+
+// sniffBody names the media type a body actually looks like: JSON when it parsed as JSON, otherwise
+// what the standard library's content sniffing detects
+func sniffBody(body []byte, isJSON, isNDJSON bool) string {
+	switch {
+	case len(body) == 0:
+		return ""
+	case isJSON:
+		return "application/json"
+	case isNDJSON:
+		return "application/x-ndjson"
+	}
+	mediaType, _, _ := mime.ParseMediaType(http.DetectContentType(body))
+	return mediaType
+}
+
+// mediaFamily groups a media type with the types interchangeable with it
+func mediaFamily(mediaType string) string {
+	switch {
+	case ndjsonMediaTypes[mediaType]:
+		return familyNDJSON
+	case mediaType == "application/json" || mediaType == "text/json" || strings.HasSuffix(mediaType, "+json"):
+		return familyJSON
+	case mediaType == "text/html" || mediaType == "application/xhtml+xml":
+		return familyHTML
+	case mediaType == "text/xml" || mediaType == "application/xml" || strings.HasSuffix(mediaType, "+xml"):
+		return familyXML
+	case strings.HasPrefix(mediaType, "text/"):
+		return familyText
+	}
+	return familyBinary
+}
+
+// contentTypeMismatch reports whether a body contradicts its declared Content-Type, such as an HTML
+// error page served as application/json. Loose pairings like JSON served as text/plain pass.
+func contentTypeMismatch(declared, sniffed string) bool {
+	declaredFamily, sniffedFamily := mediaFamily(declared), mediaFamily(sniffed)
+	switch declaredFamily {
+	case familyJSON:
+		return sniffedFamily != familyJSON
+	case familyNDJSON:
+		return sniffedFamily != familyNDJSON && sniffedFamily != familyJSON
+	case familyHTML:
+		return sniffedFamily == familyJSON
+	case familyXML:
+		return sniffedFamily == familyJSON || sniffedFamily == familyHTML
+	case familyText:
+		return sniffedFamily == familyHTML
+	}
+	// Binary types like images and PDFs shouldn't come back as markup or JSON; octet-stream makes no claim
+	return declared != "application/octet-stream" &&
+		(sniffedFamily == familyJSON || sniffedFamily == familyHTML || sniffedFamily == familyXML)
+}
+
+// checksContentType reports whether a test compares its response Content-Type to the sniffed body
+func (t *APITester) checksContentType(testCase TestCase) bool {
+	if testCase.CheckContentType != nil {
+		return *testCase.CheckContentType
+	}
+	return t.CheckContentType
+}
+
+// validateContentType flags a body whose sniffed type contradicts the declared Content-Type
+func validateContentType(resp *http.Response, sniffed string) []assertionError {
+	header := resp.Header.Get("Content-Type")
+	if sniffed == "" || header == "" {
+		return nil
+	}
+	declared, _, err := mime.ParseMediaType(header)
+	if err != nil {
+		return []assertionError{{CategoryContentType, fmt.Sprintf("Content-Type: Invalid header %q", header)}}
+	}
+	if !contentTypeMismatch(declared, sniffed) {
+		return nil
+	}
+	return []assertionError{{CategoryContentType,
+		fmt.Sprintf("Content-Type: Declared %s, but the body looks like %s", declared, sniffed)}}
+}
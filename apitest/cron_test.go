@@ -0,0 +1,105 @@
+package apitest
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCronScheduleNext(t *testing.T) {
+	// 2026-01-01 is a Thursday
+	at := func(year int, month time.Month, day, hour, minute, second int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, time.UTC)
+	}
+	tests := []struct {
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"*/15 * * * *", at(2026, 1, 1, 0, 0, 30), at(2026, 1, 1, 0, 15, 0)},
+		{"*/15 * * * *", at(2026, 1, 1, 0, 15, 0), at(2026, 1, 1, 0, 30, 0)},
+		{"5/20 * * * *", at(2026, 1, 1, 0, 30, 0), at(2026, 1, 1, 0, 45, 0)},
+		{"0 9 * * mon-fri", at(2026, 1, 1, 10, 0, 0), at(2026, 1, 2, 9, 0, 0)},
+		{"0 9 * * mon-fri", at(2026, 1, 2, 10, 0, 0), at(2026, 1, 5, 9, 0, 0)},
+		{"30 8 1 jan,jul *", at(2026, 1, 1, 0, 0, 30), at(2026, 1, 1, 8, 30, 0)},
+		{"30 8 1 jan,jul *", at(2026, 1, 1, 9, 0, 0), at(2026, 7, 1, 8, 30, 0)},
+		{"0 0 * * 7", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 4, 0, 0, 0)},
+		{"0 0 * * sun", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 4, 0, 0, 0)},
+		{"@hourly", at(2026, 1, 1, 0, 0, 30), at(2026, 1, 1, 1, 0, 0)},
+		{"@daily", at(2026, 1, 1, 0, 0, 30), at(2026, 1, 2, 0, 0, 0)},
+		{"@weekly", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 4, 0, 0, 0)},
+		{"@yearly", at(2026, 1, 1, 0, 0, 0), at(2027, 1, 1, 0, 0, 0)},
+		{"@every 90s", at(2026, 1, 1, 0, 0, 30), at(2026, 1, 1, 0, 2, 0)},
+
+		// Only the day of month is restricted, so the day of week doesn't matter
+		{"0 0 13 * *", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 13, 0, 0, 0)},
+		{"0 0 13 * ?", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 13, 0, 0, 0)},
+		// Only the day of week is restricted, so the day of month doesn't matter
+		{"0 0 * * fri", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 2, 0, 0, 0)},
+		// Both are restricted, so either may match: Friday the 2nd and 9th, then Tuesday the 13th
+		{"0 0 13 * fri", at(2026, 1, 1, 0, 0, 0), at(2026, 1, 2, 0, 0, 0)},
+		{"0 0 13 * fri", at(2026, 1, 2, 0, 0, 0), at(2026, 1, 9, 0, 0, 0)},
+		{"0 0 13 * fri", at(2026, 1, 9, 0, 0, 0), at(2026, 1, 13, 0, 0, 0)},
+		{"0 0 13 * fri", at(2026, 1, 13, 0, 0, 0), at(2026, 1, 16, 0, 0, 0)},
+
+		// The next February 29th is over two years away
+		{"0 0 29 2 *", at(2026, 1, 1, 0, 0, 0), at(2028, 2, 29, 0, 0, 0)},
+		// April has no 31st, so the schedule never fires
+		{"0 0 31 4 *", at(2026, 1, 1, 0, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		schedule, err := parseCronSchedule(tt.spec)
+		if err != nil {
+			t.Errorf("parseCronSchedule(%q): %v", tt.spec, err)
+			continue
+		}
+		if got := schedule.next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("%q next after %s = %s, want %s", tt.spec, tt.from.Format(time.RFC3339), got.Format(time.RFC3339), tt.want.Format(time.RFC3339))
+		}
+	}
+}
+
+func TestCronScheduleNextKeepsLocation(t *testing.T) {
+	location := time.FixedZone("UTC+7", 7*60*60)
+	schedule, err := parseCronSchedule("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from := time.Date(2026, 1, 1, 10, 0, 0, 0, location)
+	want := time.Date(2026, 1, 2, 9, 0, 0, 0, location)
+	if got := schedule.next(from); !got.Equal(want) || got.Location() != location {
+		t.Errorf("next after %s = %s, want %s", from, got, want)
+	}
+}
+
+func TestParseCronScheduleErrors(t *testing.T) {
+	tests := []struct {
+		spec string
+		want string
+	}{
+		{"* * * *", "expected 5 fields"},
+		{"* * * * * *", "expected 5 fields"},
+		{"60 * * * *", "invalid minute \"60\""},
+		{"* 24 * * *", "invalid hour \"24\""},
+		{"* * 0 * *", "invalid day of month \"0\""},
+		{"* * * 13 *", "invalid month \"13\""},
+		{"* * * foo *", "invalid month \"foo\""},
+		{"* * * * 8", "invalid day of week \"8\""},
+		{"*/0 * * * *", "invalid step \"0\""},
+		{"*/x * * * *", "invalid step \"x\""},
+		{"30-10 * * * *", "invalid range \"30-10\""},
+		{"@every 500ms", "at least 1s"},
+		{"@every soon", "at least 1s"},
+		{"@fortnightly", "expected 5 fields"},
+	}
+	for _, tt := range tests {
+		_, err := parseCronSchedule(tt.spec)
+		if err == nil {
+			t.Errorf("parseCronSchedule(%q) succeeded, want an error containing %q", tt.spec, tt.want)
+			continue
+		}
+		if !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseCronSchedule(%q) = %q, want an error containing %q", tt.spec, err, tt.want)
+		}
+	}
+}
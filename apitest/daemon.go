@@ -0,0 +1,461 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// DefaultDaemonListen is the address of the daemon's status endpoint without -listen
+const DefaultDaemonListen = "127.0.0.1:9464"
+
+// DefaultDaemonKeep is the number of runs the daemon keeps in its history without -keep
+const DefaultDaemonKeep = 100
+
+// Daemon states, from the last run's outcome
+const (
+	DaemonStateUnknown = "unknown" // no run finished yet
+	DaemonStatePassing = "passing"
+	DaemonStateFailing = "failing"
+)
+
+// DaemonRun records one scheduled run of the suite
+type DaemonRun struct {
+	RunID      string         `json:"run_id"`
+	Started    string         `json:"started"`
+	DurationMs float64        `json:"duration_ms"`
+	Passed     bool           `json:"passed"`
+	Summary    map[string]int `json:"summary,omitempty"`
+	// Failures names the failed tests; Error says why the suite couldn't run at all
+	Failures []string `json:"failures,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// DaemonStatus is what the status endpoint answers
+type DaemonStatus struct {
+	Config   string     `json:"config"`
+	Schedule string     `json:"schedule"`
+	State    string     `json:"state"`
+	Since    string     `json:"since,omitempty"`
+	NextRun  string     `json:"next_run,omitempty"`
+	Running  bool       `json:"running"`
+	LastRun  *DaemonRun `json:"last_run,omitempty"`
+}
+
+// DaemonNotification is posted to every -notify webhook when the state changes. Text is a
+// one-line description, so chat webhooks such as Slack's can show it as is.
+type DaemonNotification struct {
+	Event  string    `json:"event"`
+	Config string    `json:"config"`
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Run    DaemonRun `json:"run"`
+	Text   string    `json:"text"`
+}
+
+// notifyFlags collects the repeatable -notify flag
+type notifyFlags []string
+
+// String renders the webhook URLs comma-separated
+func (n *notifyFlags) String() string {
+	return strings.Join(*n, ",")
+}
+
+// Set adds one webhook URL
+func (n *notifyFlags) Set(value string) error {
+	if strings.TrimSpace(value) == "" {
+		return errors.New("webhook URL is empty")
+	}
+	*n = append(*n, value)
+	return nil
+}
+
+// daemonOptions are the daemon's flags that shape each run
+type daemonOptions struct {
+	configPath  string
+	baseURL     string
+	key         []byte
+	params      map[string]string
+	labels      map[string]string
+	services    map[string]string
+	environment string
+	reports     []string
+	notify      []string
+	historyPath string
+	keep        int
+}
+
+// daemon runs a suite on a schedule and tracks its state between runs
+type daemon struct {
+	options  daemonOptions
+	schedule *cronSchedule
+	spec     string
+
+	mu      sync.Mutex
+	history []DaemonRun
+	state   string
+	since   time.Time
+	nextRun time.Time
+	running bool
+}
+
+// runDaemonCommand implements "daemon", which runs a suite on a cron schedule for synthetic monitoring
+func runDaemonCommand(args []string) int {
+	fs := flag.NewFlagSet("daemon", flag.ContinueOnError)
+	scheduleFlag := fs.String("schedule", "", "Cron schedule in local time, e.g. \"*/15 * * * *\", @hourly or \"@every 10m\"")
+	listen := fs.String("listen", DefaultDaemonListen, "Address of the status endpoint (empty to disable)")
+	history := fs.String("history", "", "Keep the run history in this JSON file across restarts")
+	keep := fs.Int("keep", DefaultDaemonKeep, "Runs kept in the history")
+	runNow := fs.Bool("run-now", false, "Run once at start instead of waiting for the first scheduled time")
+	baseURL := fs.String("base-url", "", "Base URL for all API endpoints")
+	keyFile := fs.String("key-file", os.Getenv(ConfigKeyEnv), "Key file decrypting an encrypted config (default $"+ConfigKeyEnv+")")
+	envFlag := fs.String("env", os.Getenv("APITEST_ENV"), "Name of the environment under test (default $APITEST_ENV)")
+	var notify notifyFlags
+	var reports reportFlags
+	fs.Var(&notify, "notify", "Post to this webhook when the suite starts failing or recovers, e.g. https://hooks.slack.com/... (repeatable)")
+	fs.Var(&reports, "report", "Send each run's report to a sink, as with the run command (repeatable)")
+	params := setFlags{}
+	fs.Var(params, "set", "Set a {{variable}} before the first test, as name=value (repeatable)")
+	labels := labelFlags{}
+	fs.Var(labels, "label", "Attach a key=value label to the reports (repeatable)")
+	services := serviceFlags{}
+	fs.Var(services, "service", "Set the base URL of a named service, as name=url (repeatable)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s daemon -schedule \"*/15 * * * *\" [-listen %s] [-history h.json] [-notify url] <config.json>\n\n", os.Args[0], DefaultDaemonListen)
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) != 1 || *scheduleFlag == "" {
+		fmt.Fprintf(os.Stderr, "%sError: A config file and -schedule are required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	schedule, err := parseCronSchedule(*scheduleFlag)
+	if err == nil && *keep < 1 {
+		err = errors.New("-keep must be at least 1")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n\n", ColorRed, err, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	d := &daemon{
+		options: daemonOptions{
+			configPath:  paths[0],
+			baseURL:     *baseURL,
+			params:      params,
+			labels:      labels,
+			services:    services,
+			environment: *envFlag,
+			reports:     reports,
+			notify:      notify,
+			historyPath: *history,
+			keep:        *keep,
+		},
+		schedule: schedule,
+		spec:     *scheduleFlag,
+		state:    DaemonStateUnknown,
+	}
+	if *keyFile != "" {
+		if d.options.key, err = LoadConfigKey(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+	}
+	if err := d.loadHistory(); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if *listen != "" {
+		listener, err := net.Listen("tcp", *listen)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: failed to listen on %s: %v%s\n", ColorRed, *listen, err, ColorReset)
+			return 1
+		}
+		server := &http.Server{Handler: d, ReadHeaderTimeout: 10 * time.Second}
+		go server.Serve(listener)
+		defer server.Close()
+		fmt.Printf("%s✓ Status endpoint: http://%s/status%s\n", ColorGreen, listener.Addr(), ColorReset)
+	}
+
+	d.loop(ctx, *runNow)
+	return 0
+}
+
+// loop runs the suite at every scheduled time until ctx is done. A run that overruns its slot
+// skips the times it missed rather than running them back to back.
+func (d *daemon) loop(ctx context.Context, runNow bool) {
+	for {
+		next := time.Now()
+		if !runNow {
+			next = d.schedule.next(next)
+			if next.IsZero() {
+				fmt.Fprintf(os.Stderr, "%sError: schedule %q never fires%s\n", ColorRed, d.spec, ColorReset)
+				return
+			}
+		}
+		runNow = false
+		d.mu.Lock()
+		d.nextRun = next
+		d.mu.Unlock()
+		fmt.Printf("%s↳ Next run: %s%s\n", ColorCyan, next.Format("2006-01-02 15:04:05"), ColorReset)
+
+		select {
+		case <-time.After(time.Until(next)):
+		case <-ctx.Done():
+			return
+		}
+		run := d.runSuite(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		d.record(run)
+	}
+}
+
+// runSuite loads the config afresh, so edits apply from the next run, and runs it once
+func (d *daemon) runSuite(ctx context.Context) (run DaemonRun) {
+	d.mu.Lock()
+	d.running = true
+	d.mu.Unlock()
+	defer func() {
+		d.mu.Lock()
+		d.running = false
+		d.mu.Unlock()
+	}()
+
+	tester := NewAPITester(d.options.configPath, d.options.baseURL, false)
+	tester.Context = ctx
+	tester.ConfigKey = d.options.key
+	tester.Params = d.options.params
+	tester.Services = d.options.services
+	if len(d.options.labels) > 0 {
+		tester.Labels = d.options.labels
+	}
+	tester.Environment = d.options.environment
+	run = DaemonRun{RunID: tester.RunID, Started: tester.startedAt.Format(time.RFC3339)}
+	defer func() {
+		run.DurationMs = durationMs(time.Since(tester.startedAt))
+	}()
+
+	fail := func(err error) DaemonRun {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		run.Error = err.Error()
+		return run
+	}
+	var sinks []ReportSink
+	for _, spec := range d.options.reports {
+		sink, err := tester.newReportSink(spec)
+		if err != nil {
+			return fail(err)
+		}
+		sinks = append(sinks, sink)
+	}
+	if err := tester.LoadConfig(); err != nil {
+		return fail(err)
+	}
+	if tester.OTPCatcherConfig != nil {
+		if err := tester.StartOTPCatcher(*tester.OTPCatcherConfig); err != nil {
+			return fail(err)
+		}
+		defer tester.otpCatcher.Close()
+	}
+	if tester.CallbackConfig != nil {
+		if err := tester.StartCallbackListener(*tester.CallbackConfig); err != nil {
+			return fail(err)
+		}
+		defer tester.callbackListener.Close()
+	}
+	if err := tester.PushStubs(); err != nil {
+		return fail(err)
+	}
+
+	tester.RunAllTests()
+	tester.RemoveStubs()
+	run.Passed = tester.PrintSummary()
+	if tester.SuiteAsserts != nil {
+		run.Passed = tester.CheckSuiteAsserts()
+	}
+	tester.DeliverReport(sinks)
+
+	run.Summary = summaryMap(tester.Results)
+	for _, result := range tester.Results {
+		if isFailed(result.Status) {
+			run.Failures = append(run.Failures, result.TestCaseName)
+		}
+	}
+	return run
+}
+
+// record adds a run to the history and notifies when it changed the state
+func (d *daemon) record(run DaemonRun) {
+	d.mu.Lock()
+	d.history = append(d.history, run)
+	if len(d.history) > d.options.keep {
+		d.history = d.history[len(d.history)-d.options.keep:]
+	}
+	previous := d.state
+	d.state = DaemonStateFailing
+	if run.Passed {
+		d.state = DaemonStatePassing
+	}
+	changed := d.state != previous
+	if changed {
+		d.since = time.Now()
+	}
+	history := append([]DaemonRun(nil), d.history...)
+	d.mu.Unlock()
+
+	if d.options.historyPath != "" {
+		if err := writeJSONFile(d.options.historyPath, history); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: failed to write history: %v%s\n", ColorRed, err, ColorReset)
+		}
+	}
+	// Starting out healthy is no news; starting out broken is
+	if changed && !(previous == DaemonStateUnknown && run.Passed) {
+		d.notify(previous, run)
+	}
+}
+
+// notify posts a state change to every -notify webhook
+func (d *daemon) notify(previous string, run DaemonRun) {
+	notification := DaemonNotification{
+		Event:  "state_changed",
+		Config: d.options.configPath,
+		From:   previous,
+		To:     DaemonStateFailing,
+		Run:    run,
+	}
+	switch {
+	case run.Passed:
+		notification.To = DaemonStatePassing
+		notification.Text = fmt.Sprintf("✅ %s recovered: all %d tests passed", d.options.configPath, run.Summary["total"])
+	case run.Error != "":
+		notification.Text = fmt.Sprintf("❌ %s is failing: %s", d.options.configPath, run.Error)
+	default:
+		notification.Text = fmt.Sprintf("❌ %s is failing: %d of %d tests failed (%s)",
+			d.options.configPath, len(run.Failures), run.Summary["total"], strings.Join(run.Failures, ", "))
+	}
+	fmt.Printf("%s↳ State changed: %s → %s%s\n", ColorCyan, previous, notification.To, ColorReset)
+
+	data, err := json.Marshal(notification)
+	if err != nil {
+		return
+	}
+	// Webhook URLs may use resolvers such as {{env:SLACK_WEBHOOK}}
+	tester := NewAPITester(d.options.configPath, "", false)
+	for _, webhook := range d.options.notify {
+		destination, err := tester.postWebhook(webhook, data)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			continue
+		}
+		fmt.Printf("%s✓ Notified %s%s\n", ColorGreen, destination, ColorReset)
+	}
+}
+
+// loadHistory restores the history and state of an earlier daemon, so a restart neither forgets
+// the runs nor repeats a notification
+func (d *daemon) loadHistory() error {
+	if d.options.historyPath == "" {
+		return nil
+	}
+	data, err := os.ReadFile(d.options.historyPath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read history: %w", err)
+	}
+	if err := json.Unmarshal(data, &d.history); err != nil {
+		return fmt.Errorf("failed to parse history %s: %w", d.options.historyPath, err)
+	}
+	if len(d.history) > 0 {
+		last := d.history[len(d.history)-1]
+		d.state = DaemonStateFailing
+		if last.Passed {
+			d.state = DaemonStatePassing
+		}
+		// The state began with the oldest run of the streak ending in the last one
+		for i := len(d.history) - 1; i >= 0 && d.history[i].Passed == last.Passed; i-- {
+			d.since, _ = time.Parse(time.RFC3339, d.history[i].Started)
+		}
+	}
+	return nil
+}
+
+// ServeHTTP answers GET /status, 200 while passing and 503 otherwise so uptime checks can poll
+// it, and GET /history with the kept runs, newest first
+func (d *daemon) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.mu.Lock()
+	var body interface{}
+	code := http.StatusOK
+	switch r.URL.Path {
+	case "/status", "/":
+		status := DaemonStatus{Config: d.options.configPath, Schedule: d.spec, State: d.state, Running: d.running}
+		if !d.since.IsZero() {
+			status.Since = d.since.Format(time.RFC3339)
+		}
+		if !d.nextRun.IsZero() {
+			status.NextRun = d.nextRun.Format(time.RFC3339)
+		}
+		if len(d.history) > 0 {
+			last := d.history[len(d.history)-1]
+			status.LastRun = &last
+		}
+		if d.state != DaemonStatePassing {
+			code = http.StatusServiceUnavailable
+		}
+		body = status
+	case "/history":
+		runs := make([]DaemonRun, len(d.history))
+		for i, run := range d.history {
+			runs[len(runs)-1-i] = run
+		}
+		body = runs
+	default:
+		d.mu.Unlock()
+		http.NotFound(w, r)
+		return
+	}
+	d.mu.Unlock()
+
+	data, err := json.MarshalIndent(body, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	w.Write(data)
+}
+
+// writeJSONFile writes a value as indented JSON
+func writeJSONFile(path string, value interface{}) error {
+	data, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, DefaultFileMode)
+}
@@ -0,0 +1,287 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// rowPlaceholder matches {{row.field}} placeholders filled from a data row
+var rowPlaceholder = regexp.MustCompile(`\{\{row\.([^{}]+)\}\}`)
+
+// rowValuePlaceholder matches a JSON string that is nothing but a row placeholder, which is
+// replaced by the row value itself so numbers and booleans keep their type
+var rowValuePlaceholder = regexp.MustCompile(`"\{\{row\.([^{}"]+)\}\}"`)
+
+// arraySourcePattern matches the {{variable}} a data_from or for_each test loops over
+var arraySourcePattern = regexp.MustCompile(`^\{\{[^{}:]+\}\}$`)
+
+// configRelativePath resolves a path from the config against the config file's directory
+func (t *APITester) configRelativePath(path string) string {
+	path = expandHome(path)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(t.ConfigPath), path)
+	}
+	return path
+}
+
+// expandDataRows turns a test with data rows into one test per row, grouped under the original name
+func (t *APITester) expandDataRows(testCase TestCase) ([]TestCase, error) {
+	if testCase.DataFrom != "" && testCase.ForEach != "" {
+		return nil, fmt.Errorf("data_from and for_each are mutually exclusive")
+	}
+	if source, field := arraySource(testCase); source != "" && !arraySourcePattern.MatchString(source) {
+		return nil, fmt.Errorf("%s must name an array variable as {{name}}, got %q", field, source)
+	}
+	rows := testCase.Data
+	if testCase.DataFile != "" {
+		if rows != nil {
+			return nil, fmt.Errorf("data and data_file are mutually exclusive")
+		}
+		var err error
+		if rows, err = t.loadDataFile(testCase.DataFile); err != nil {
+			return nil, err
+		}
+	}
+	if rows == nil {
+		return []TestCase{testCase}, nil
+	}
+	if source, field := arraySource(testCase); source != "" {
+		return nil, fmt.Errorf("%s can't be combined with data or data_file", field)
+	}
+	return t.expandRows(testCase, rows)
+}
+
+// expandRows makes one test per row from a test using {{row.field}} placeholders
+func (t *APITester) expandRows(testCase TestCase, rows []map[string]interface{}) ([]TestCase, error) {
+	group := testCase.Group
+	if group == "" {
+		group = rowGroupName(testCase.TestCaseName)
+	}
+	name := testCase.TestCaseName
+	testCase.Data = nil
+	testCase.DataFile = ""
+	testCase.DataFrom = ""
+	testCase.ForEach = ""
+	template, err := json.Marshal(testCase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal test: %w", err)
+	}
+
+	expanded := make([]TestCase, 0, len(rows))
+	for i, row := range rows {
+		rowCase, err := t.applyRow(template, row)
+		if err != nil {
+			return nil, fmt.Errorf("data row %d: %w", i+1, err)
+		}
+		rowCase.Group = group
+		// Keep ids unique per row unless the row already makes them distinct
+		if rowCase.ID != "" && rowCase.ID == testCase.ID {
+			rowCase.ID = fmt.Sprintf("%s[%d]", testCase.ID, i+1)
+		}
+		if rowCase.TestCaseName == name {
+			rowCase.TestCaseName = fmt.Sprintf("%s [%d]", name, i+1)
+		}
+		expanded = append(expanded, rowCase)
+	}
+	return expanded, nil
+}
+
+// arraySource returns the array variable a test loops over at run time and the field naming it
+func arraySource(testCase TestCase) (source, field string) {
+	if testCase.ForEach != "" {
+		return testCase.ForEach, "for_each"
+	}
+	if testCase.DataFrom != "" {
+		return testCase.DataFrom, "data_from"
+	}
+	return "", ""
+}
+
+// isLoopPlaceholder reports whether a placeholder of a test is filled by its own run-time loop
+// rather than a variable: {{item}} of a for_each test
+func isLoopPlaceholder(testCase TestCase, name string) bool {
+	return testCase.ForEach != "" && (name == "item" || strings.HasPrefix(name, "item."))
+}
+
+// expandArrayRows turns a data_from or for_each test into one test per item of the array variable
+// it names, when the test is about to run. For data_from, object items are rows as they are and
+// other items are {{row.value}}; for_each exposes each item as {{item}}, and an object's fields as
+// {{item.field}}. An empty array leaves no tests.
+func (t *APITester) expandArrayRows(testCase TestCase) ([]TestCase, error) {
+	source, field := arraySource(testCase)
+	name := strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(source), "{{"), "}}")
+	value, ok := t.Variables[name]
+	if !ok {
+		return nil, fmt.Errorf("%s: {{%s}} is not set", field, name)
+	}
+	items, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s: {{%s}} is not an array, got %T", field, name, value)
+	}
+	// Rows are spliced into the test before its placeholders are replaced, so an array from a
+	// response could otherwise have its own {{exec:...}} resolved
+	if source := t.variableSources[name]; source != SourceConfig && source != SourceSet {
+		encoded, err := json.Marshal(items)
+		if err != nil {
+			return nil, fmt.Errorf("%s: failed to encode {{%s}}: %w", field, name, err)
+		}
+		if placeholder := t.resolverPlaceholder(string(encoded)); placeholder != "" {
+			return nil, fmt.Errorf("%s: {{%s}} holds %s, which is only resolved when written in the config", field, name, placeholder)
+		}
+	}
+
+	rows := make([]map[string]interface{}, len(items))
+	for i, item := range items {
+		if row, isObject := item.(map[string]interface{}); isObject && testCase.ForEach == "" {
+			rows[i] = row
+		} else if testCase.ForEach == "" {
+			rows[i] = map[string]interface{}{"value": item}
+		} else {
+			rows[i] = map[string]interface{}{"item": item}
+		}
+	}
+
+	if testCase.ForEach != "" {
+		// {{item}} is the row's "item" field, so the rows fill it like any row placeholder
+		template, err := json.Marshal(testCase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal test: %w", err)
+		}
+		template = bytes.ReplaceAll(template, []byte("{{item}}"), []byte("{{row.item}}"))
+		template = bytes.ReplaceAll(template, []byte("{{item."), []byte("{{row.item."))
+		testCase = TestCase{}
+		if err := t.unmarshalJSON(template, &testCase); err != nil {
+			return nil, fmt.Errorf("failed to apply for_each: %w", err)
+		}
+	}
+	return t.expandRows(testCase, rows)
+}
+
+// expandArrayRowsAt replaces a data_from or for_each test at index i by its rows, now that earlier
+// tests have run; a test without rows stays, for RunTest to fail or skip
+func (t *APITester) expandArrayRowsAt(testCases []TestCase, i int) []TestCase {
+	source, _ := arraySource(testCases[i])
+	if source == "" {
+		return testCases
+	}
+	rows, err := t.expandArrayRows(testCases[i])
+	if err != nil || len(rows) == 0 {
+		return testCases
+	}
+	fmt.Fprintf(t.out, "\n%s↳ %s: %d rows from %s%s\n", ColorCyan, testCases[i].TestCaseName, len(rows), source, ColorReset)
+	return slices.Concat(testCases[:i:i], rows, testCases[i+1:])
+}
+
+// rowGroupName names a data-driven test's rows after the part of its name before the first row placeholder
+func rowGroupName(name string) string {
+	if loc := rowPlaceholder.FindStringIndex(name); loc != nil {
+		if prefix := strings.TrimSpace(name[:loc[0]]); prefix != "" {
+			return prefix
+		}
+	}
+	return name
+}
+
+// applyRow fills the {{row.field}} placeholders of a marshalled test from one data row
+func (t *APITester) applyRow(template []byte, row map[string]interface{}) (TestCase, error) {
+	var missing []string
+	lookup := func(field string) (interface{}, bool) {
+		value := getNestedValue(row, field)
+		if value == nil {
+			missing = append(missing, field)
+			return nil, false
+		}
+		return value, true
+	}
+
+	filled := rowValuePlaceholder.ReplaceAllFunc(template, func(match []byte) []byte {
+		value, ok := lookup(string(rowValuePlaceholder.FindSubmatch(match)[1]))
+		if !ok {
+			return match
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return match
+		}
+		return encoded
+	})
+	filled = rowPlaceholder.ReplaceAllFunc(filled, func(match []byte) []byte {
+		value, ok := lookup(string(rowPlaceholder.FindSubmatch(match)[1]))
+		if !ok {
+			return match
+		}
+		// Escape for the JSON string the placeholder sits in
+		encoded, _ := json.Marshal(fmt.Sprintf("%v", value))
+		return encoded[1 : len(encoded)-1]
+	})
+	if len(missing) > 0 {
+		return TestCase{}, fmt.Errorf("no value for row.%s", strings.Join(missing, ", row."))
+	}
+
+	var testCase TestCase
+	if err := t.unmarshalJSON(filled, &testCase); err != nil {
+		return TestCase{}, fmt.Errorf("failed to apply row: %w", err)
+	}
+	return testCase, nil
+}
+
+// printGroupSummary prints pass counts per data-driven or matrix test, in the order the groups
+// ran, followed by the outcome of each matrix cell
+func (t *APITester) printGroupSummary() {
+	var groups []string
+	results := make(map[string][]TestResult)
+	for _, result := range t.Results {
+		if result.Group == "" {
+			continue
+		}
+		if _, seen := results[result.Group]; !seen {
+			groups = append(groups, result.Group)
+		}
+		results[result.Group] = append(results[result.Group], result)
+	}
+
+	for _, group := range groups {
+		total, passed, failed := summarizeResults(results[group])
+		color := ColorGreen
+		if failed > 0 {
+			color = ColorRed
+		}
+		cells := ""
+		if matrix := formatMatrixCells(results[group]); matrix != "" {
+			cells = "  " + matrix
+		}
+		fmt.Fprintf(t.out, "  %s%s: %d/%d passed%s%s\n", color, group, passed, total, ColorReset, cells)
+	}
+}
+
+// loadDataFile reads data rows from a CSV file with a header line or a JSON array of objects
+func (t *APITester) loadDataFile(path string) ([]map[string]interface{}, error) {
+	resolved := t.configRelativePath(path)
+	content, err := t.readConfigFile(resolved)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read data_file: %w", err)
+	}
+
+	if strings.EqualFold(filepath.Ext(resolved), ".csv") {
+		records, err := parseCSV(content)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse data_file %s: %w", path, err)
+		}
+		rows := make([]map[string]interface{}, len(records))
+		for i, record := range records {
+			rows[i] = record.(map[string]interface{})
+		}
+		return rows, nil
+	}
+
+	var rows []map[string]interface{}
+	if err := t.unmarshalJSON(content, &rows); err != nil {
+		return nil, fmt.Errorf("failed to parse data_file %s: %w", path, err)
+	}
+	return rows, nil
+}
@@ -0,0 +1,371 @@
+package apitest
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"os"
+	"strings"
+)
+
+// DecryptionConfig describes how encrypted response payloads are decrypted before assertions
+type DecryptionConfig struct {
+	// Mode is "jwe" (compact serialization) or "aes-gcm"
+	Mode string `json:"mode"`
+	// Path is where the encrypted value sits in the response; empty means the whole body
+	Path string `json:"path"`
+	// Key is the symmetric key (AES-GCM, JWE "dir" and AES key wrap); placeholders are applied
+	Key string `json:"key"`
+	// KeyEncoding is base64 (default), hex or raw
+	KeyEncoding string `json:"key_encoding"`
+	// KeyFile is a PEM private key for JWE RSA-OAEP
+	KeyFile string `json:"key_file"`
+	// IVPath holds the AES-GCM nonce when it isn't prefixed to the ciphertext
+	IVPath string `json:"iv_path"`
+	// AAD is additional authenticated data for AES-GCM
+	AAD string `json:"aad"`
+}
+
+// decryptionFor returns the decryption config of a test: its own, or the suite's. A mode of
+// "none" disables decryption for the test.
+func (t *APITester) decryptionFor(testCase TestCase) *DecryptionConfig {
+	decryption := t.Decryption
+	if testCase.Decryption != nil {
+		decryption = testCase.Decryption
+	}
+	if decryption == nil || decryption.Mode == "none" {
+		return nil
+	}
+	return decryption
+}
+
+// decryptResponse replaces the encrypted value in the response with its decrypted content,
+// parsed as JSON when possible
+func (t *APITester) decryptResponse(config *DecryptionConfig, responseData interface{}) (interface{}, error) {
+	encrypted := responseData
+	if config.Path != "" {
+		encrypted = getNestedValue(responseData, config.Path)
+	}
+	ciphertext, ok := encrypted.(string)
+	if !ok {
+		location := config.Path
+		if location == "" {
+			location = "response body"
+		}
+		return nil, fmt.Errorf("no encrypted string at %s", location)
+	}
+
+	var plaintext []byte
+	var err error
+	switch strings.ToLower(config.Mode) {
+	case "jwe":
+		plaintext, err = t.decryptJWE(config, strings.TrimSpace(ciphertext))
+	case "aes-gcm":
+		plaintext, err = t.decryptAESGCM(config, ciphertext, responseData)
+	default:
+		return nil, fmt.Errorf("unknown decryption mode %q (use jwe or aes-gcm)", config.Mode)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var decrypted interface{} = string(plaintext)
+	var parsed interface{}
+	if t.unmarshalJSON(plaintext, &parsed) == nil {
+		decrypted = parsed
+	}
+	if config.Path == "" {
+		return decrypted, nil
+	}
+	if !setNestedValue(responseData, config.Path, decrypted) {
+		return nil, fmt.Errorf("cannot replace value at %s", config.Path)
+	}
+	return responseData, nil
+}
+
+// setNestedValue replaces the value at a dot path inside objects and arrays
+func setNestedValue(data interface{}, path string, value interface{}) bool {
+	parentPath, key := "", path
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		parentPath, key = path[:i], path[i+1:]
+	}
+	parent := data
+	if parentPath != "" {
+		parent = getNestedValue(data, parentPath)
+	}
+
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[key] = value
+		return true
+	case []interface{}:
+		var index int
+		if _, err := fmt.Sscanf(key, "%d", &index); err != nil || index < 0 || index >= len(p) {
+			return false
+		}
+		p[index] = value
+		return true
+	}
+	return false
+}
+
+// symmetricKey decodes the configured key
+func (t *APITester) symmetricKey(config *DecryptionConfig) ([]byte, error) {
+	key := t.replaceVariables(config.Key)
+	if key == "" {
+		return nil, fmt.Errorf("\"key\" is required")
+	}
+	switch config.KeyEncoding {
+	case "", "base64":
+		return decodeBase64(key)
+	case "hex":
+		return hex.DecodeString(key)
+	case "raw":
+		return []byte(key), nil
+	default:
+		return nil, fmt.Errorf("unknown key_encoding %q (use base64, hex or raw)", config.KeyEncoding)
+	}
+}
+
+// decodeBase64 accepts standard and URL-safe base64, padded or not
+func decodeBase64(value string) ([]byte, error) {
+	value = strings.TrimRight(strings.TrimSpace(value), "=")
+	if strings.ContainsAny(value, "-_") {
+		return base64.RawURLEncoding.DecodeString(value)
+	}
+	return base64.RawStdEncoding.DecodeString(value)
+}
+
+// decryptAESGCM decrypts base64 nonce||ciphertext||tag, or ciphertext||tag with the nonce at iv_path
+func (t *APITester) decryptAESGCM(config *DecryptionConfig, ciphertext string, responseData interface{}) ([]byte, error) {
+	key, err := t.symmetricKey(config)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := decodeBase64(ciphertext)
+	if err != nil {
+		return nil, fmt.Errorf("invalid ciphertext encoding: %w", err)
+	}
+	var nonce []byte
+	if config.IVPath != "" {
+		iv, ok := getNestedValue(responseData, config.IVPath).(string)
+		if !ok {
+			return nil, fmt.Errorf("no IV at %s", config.IVPath)
+		}
+		if nonce, err = decodeBase64(iv); err != nil {
+			return nil, fmt.Errorf("invalid IV encoding: %w", err)
+		}
+		if len(nonce) != gcm.NonceSize() {
+			if gcm, err = cipher.NewGCMWithNonceSize(block, len(nonce)); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		if len(data) < gcm.NonceSize() {
+			return nil, fmt.Errorf("ciphertext too short")
+		}
+		nonce, data = data[:gcm.NonceSize()], data[gcm.NonceSize():]
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, data, []byte(t.replaceVariables(config.AAD)))
+	if err != nil {
+		return nil, fmt.Errorf("AES-GCM: %w", err)
+	}
+	return plaintext, nil
+}
+
+// decryptJWE decrypts a compact JWE: header.encrypted_key.iv.ciphertext.tag
+func (t *APITester) decryptJWE(config *DecryptionConfig, token string) ([]byte, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 {
+		return nil, fmt.Errorf("not a compact JWE (expected 5 parts, got %d)", len(parts))
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Enc string `json:"enc"`
+		Zip string `json:"zip"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("invalid JWE header: %w", err)
+	}
+	if header.Zip != "" {
+		return nil, fmt.Errorf("compressed JWE (zip %q) is not supported", header.Zip)
+	}
+	decoded := make([][]byte, 4)
+	for i, part := range parts[1:] {
+		var err error
+		if decoded[i], err = base64.RawURLEncoding.DecodeString(part); err != nil {
+			return nil, fmt.Errorf("invalid JWE encoding: %w", err)
+		}
+	}
+	encryptedKey, iv, ciphertext, tag := decoded[0], decoded[1], decoded[2], decoded[3]
+
+	// Recover the content encryption key
+	var cek []byte
+	var err error
+	switch header.Alg {
+	case "dir":
+		cek, err = t.symmetricKey(config)
+	case "A128KW", "A192KW", "A256KW":
+		var kek []byte
+		if kek, err = t.symmetricKey(config); err == nil {
+			cek, err = aesKeyUnwrap(kek, encryptedKey)
+		}
+	case "RSA-OAEP", "RSA-OAEP-256":
+		var key *rsa.PrivateKey
+		if key, err = loadRSAPrivateKey(config.KeyFile); err == nil {
+			var h hash.Hash = sha1.New()
+			if header.Alg == "RSA-OAEP-256" {
+				h = sha256.New()
+			}
+			cek, err = rsa.DecryptOAEP(h, nil, key, encryptedKey, nil)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported JWE alg %q", header.Alg)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("JWE key (%s): %w", header.Alg, err)
+	}
+
+	aad := []byte(parts[0])
+	switch header.Enc {
+	case "A128GCM", "A192GCM", "A256GCM":
+		block, err := aes.NewCipher(cek)
+		if err != nil {
+			return nil, err
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		plaintext, err := gcm.Open(nil, iv, append(ciphertext, tag...), aad)
+		if err != nil {
+			return nil, fmt.Errorf("JWE %s: %w", header.Enc, err)
+		}
+		return plaintext, nil
+	case "A128CBC-HS256", "A192CBC-HS384", "A256CBC-HS512":
+		return decryptCBCHMAC(header.Enc, cek, iv, ciphertext, tag, aad)
+	default:
+		return nil, fmt.Errorf("unsupported JWE enc %q", header.Enc)
+	}
+}
+
+// decryptCBCHMAC implements the AES_CBC_HMAC_SHA2 content encryption of RFC 7518 section 5.2
+func decryptCBCHMAC(enc string, cek, iv, ciphertext, tag, aad []byte) ([]byte, error) {
+	newHash := map[string]func() hash.Hash{"A128CBC-HS256": sha256.New, "A192CBC-HS384": sha512.New384, "A256CBC-HS512": sha512.New}[enc]
+	if len(cek)%2 != 0 {
+		return nil, fmt.Errorf("JWE %s: invalid key length", enc)
+	}
+	macKey, encKey := cek[:len(cek)/2], cek[len(cek)/2:]
+
+	mac := hmac.New(newHash, macKey)
+	mac.Write(aad)
+	mac.Write(iv)
+	mac.Write(ciphertext)
+	aadBits := make([]byte, 8)
+	binary.BigEndian.PutUint64(aadBits, uint64(len(aad))*8)
+	mac.Write(aadBits)
+	if !hmac.Equal(mac.Sum(nil)[:len(macKey)], tag) {
+		return nil, fmt.Errorf("JWE %s: authentication tag mismatch", enc)
+	}
+
+	block, err := aes.NewCipher(encKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext)%block.BlockSize() != 0 || len(iv) != block.BlockSize() {
+		return nil, fmt.Errorf("JWE %s: invalid ciphertext length", enc)
+	}
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	// Strip PKCS#7 padding
+	padding := int(plaintext[len(plaintext)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, fmt.Errorf("JWE %s: invalid padding", enc)
+	}
+	return plaintext[:len(plaintext)-padding], nil
+}
+
+// aesKeyUnwrap implements the AES key unwrap algorithm of RFC 3394
+func aesKeyUnwrap(kek, wrapped []byte) ([]byte, error) {
+	if len(wrapped)%8 != 0 || len(wrapped) < 24 {
+		return nil, fmt.Errorf("invalid wrapped key length")
+	}
+	block, err := aes.NewCipher(kek)
+	if err != nil {
+		return nil, err
+	}
+
+	n := len(wrapped)/8 - 1
+	a := make([]byte, 8)
+	copy(a, wrapped[:8])
+	r := make([]byte, n*8)
+	copy(r, wrapped[8:])
+
+	buf := make([]byte, 16)
+	for j := 5; j >= 0; j-- {
+		for i := n; i >= 1; i-- {
+			counter := uint64(n*j + i)
+			binary.BigEndian.PutUint64(buf[:8], binary.BigEndian.Uint64(a)^counter)
+			copy(buf[8:], r[(i-1)*8:i*8])
+			block.Decrypt(buf, buf)
+			copy(a, buf[:8])
+			copy(r[(i-1)*8:i*8], buf[8:])
+		}
+	}
+
+	defaultIV := []byte{0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6, 0xA6}
+	if subtle.ConstantTimeCompare(a, defaultIV) != 1 {
+		return nil, fmt.Errorf("key unwrap integrity check failed")
+	}
+	return r, nil
+}
+
+// loadRSAPrivateKey reads a PKCS#1 or PKCS#8 RSA private key from a PEM file
+func loadRSAPrivateKey(path string) (*rsa.PrivateKey, error) {
+	if path == "" {
+		return nil, fmt.Errorf("\"key_file\" is required")
+	}
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", path)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key in %s: %w", path, err)
+	}
+	key, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("%s does not hold an RSA private key", path)
+	}
+	return key, nil
+}
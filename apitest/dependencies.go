@@ -0,0 +1,229 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// placeholderPattern matches {{variable}} placeholders in test case fields
+var placeholderPattern = regexp.MustCompile(`\{\{([^{}]+)\}\}`)
+
+// usedVariables returns the variable names referenced by a test case
+func usedVariables(testCase TestCase) []string {
+	data, err := json.Marshal(testCase)
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(string(data), -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	// Compute expressions name variables without braces
+	for _, name := range computeVariables(testCase) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// isVariablePlaceholder reports whether a placeholder names a variable rather than a resolver
+// lookup like {{env:KEY}}, a marker like {{null}} or a field of a data_from row
+func isVariablePlaceholder(name string) bool {
+	return !strings.Contains(name, ":") && !strings.HasPrefix(name, "row.") &&
+		"{{"+name+"}}" != NullMarker && "{{"+name+"}}" != AbsentMarker
+}
+
+// producedVariables returns the variable names a test case sets for later tests
+func producedVariables(testCase TestCase) []string {
+	names := make([]string, 0, len(testCase.Extract)+len(testCase.Compute))
+	for name := range testCase.Extract {
+		names = append(names, name)
+	}
+	for name := range testCase.Compute {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// dependencyChains groups test cases connected through produced and consumed variables or compared responses.
+// Each chain lists test indices in execution order; chains are ordered by their first test.
+func dependencyChains(testCases []TestCase) [][]int {
+	parent := make([]int, len(testCases))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i, dependencies := range dependencyGraph(testCases) {
+		for _, producer := range dependencies {
+			parent[find(i)] = find(producer)
+		}
+	}
+
+	chainIndex := make(map[int]int)
+	var chains [][]int
+	for i := range testCases {
+		root := find(i)
+		idx, ok := chainIndex[root]
+		if !ok {
+			idx = len(chains)
+			chainIndex[root] = idx
+			chains = append(chains, nil)
+		}
+		chains[idx] = append(chains[idx], i)
+	}
+	return chains
+}
+
+// includeDependencies marks the tests that produce the variables of the marked tests, transitively.
+// Each test depends on the latest test before it that produced a variable it uses.
+func includeDependencies(testCases []TestCase, keep []bool) {
+	dependsOn := dependencyGraph(testCases)
+
+	// Producers always come before their consumers, so one backward pass pulls in whole chains
+	for i := len(testCases) - 1; i >= 0; i-- {
+		if keep[i] {
+			for _, producer := range dependsOn[i] {
+				keep[producer] = true
+			}
+		}
+	}
+}
+
+// dependencyGraph lists for each test the indexes of the earlier tests producing the variables it
+// uses, or the responses it compares against with {{from:...}}. A consumer depends on the latest
+// test that produced the variable before it.
+func dependencyGraph(testCases []TestCase) [][]int {
+	producers := make(map[string]int)
+	ids := make(map[string]int)
+	dependsOn := make([][]int, len(testCases))
+	for i, testCase := range testCases {
+		for _, name := range usedVariables(testCase) {
+			if producer, ok := producers[name]; ok {
+				dependsOn[i] = append(dependsOn[i], producer)
+			}
+		}
+		for _, id := range responseReferences(testCase) {
+			if producer, ok := ids[id]; ok {
+				dependsOn[i] = append(dependsOn[i], producer)
+			}
+		}
+		for _, name := range producedVariables(testCase) {
+			producers[name] = i
+		}
+		if testCase.ID != "" {
+			ids[testCase.ID] = i
+		}
+	}
+	return dependsOn
+}
+
+// builtinVariables returns the variables the tester sets itself rather than extracting from a test,
+// including the config's variables and -set overrides
+func (t *APITester) builtinVariables() map[string]bool {
+	builtins := make(map[string]bool)
+	for name := range t.Seeded {
+		builtins[name] = true
+	}
+	if t.OTPCatcherConfig != nil {
+		builtins[OTPCatcherVariable] = true
+	}
+	if t.CallbackConfig != nil {
+		builtins[CallbackVariable] = true
+	}
+	return builtins
+}
+
+// checkVariableChains verifies before anything runs that every variable a test uses is extracted
+// by an earlier test, and warns about extracted variables nothing uses
+func (t *APITester) checkVariableChains() error {
+	builtins := t.builtinVariables()
+	firstProducer := make(map[string]string)
+	for _, testCase := range t.TestCases {
+		for _, name := range producedVariables(testCase) {
+			if _, ok := firstProducer[name]; !ok {
+				firstProducer[name] = testCase.TestCaseName
+			}
+		}
+	}
+
+	var problems []string
+	produced := make(map[string]bool)
+	used := make(map[string]bool)
+	check := func(testCase TestCase, names []string) {
+		for _, name := range names {
+			if !isVariablePlaceholder(name) || isLoopPlaceholder(testCase, name) {
+				continue
+			}
+			used[name] = true
+			if produced[name] || builtins[name] {
+				continue
+			}
+			if producer, ok := firstProducer[name]; ok {
+				problems = append(problems, fmt.Sprintf("test %q uses {{%s}} before %q extracts it", testCase.TestCaseName, name, producer))
+			} else {
+				problems = append(problems, fmt.Sprintf("test %q uses {{%s}}, which no test extracts", testCase.TestCaseName, name))
+			}
+		}
+	}
+	for _, testCase := range t.TestCases {
+		// Side effects are verified after the test's own extraction, so they may use its variables
+		request := testCase
+		request.SideEffects = nil
+		check(testCase, usedVariables(request))
+		for _, name := range producedVariables(testCase) {
+			produced[name] = true
+		}
+		check(testCase, usedVariables(TestCase{SideEffects: testCase.SideEffects}))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("broken variable chains:\n  %s", strings.Join(problems, "\n  "))
+	}
+
+	// Side-effect templates use variables too, whenever they run
+	for _, template := range t.Verifications {
+		for _, name := range usedVariables(template) {
+			used[name] = true
+		}
+	}
+	var unused []string
+	for name, producer := range firstProducer {
+		if !used[name] {
+			unused = append(unused, fmt.Sprintf("{{%s}} (%s)", name, producer))
+		}
+	}
+	if len(unused) > 0 {
+		sort.Strings(unused)
+		fmt.Fprintf(t.out, "%s⚠ Extracted but never used: %s%s\n", ColorYellow, strings.Join(unused, ", "), ColorReset)
+	}
+	// A -set nothing uses is most likely a typo
+	var unusedParams []string
+	for name := range t.Params {
+		if !used[name] {
+			unusedParams = append(unusedParams, name)
+		}
+	}
+	if len(unusedParams) > 0 {
+		sort.Strings(unusedParams)
+		fmt.Fprintf(t.out, "%s⚠ Set but never used: %s%s\n", ColorYellow, strings.Join(unusedParams, ", "), ColorReset)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package apitest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"syscall"
+)
+
+// isFailed reports whether a status counts as a failure: a failed assertion, or a request that timed
+// out or never reached the API
+func isFailed(status string) bool {
+	return status == StatusFailed || status == StatusTimeout || status == StatusError
+}
+
+// requestErrorStatus is the status of a test whose request got no response: TIMEOUT when the client
+// gave up waiting, ERROR when the API couldn't be reached or the tester ran out of resources, and
+// FAILED when the API's answer itself was broken
+func requestErrorStatus(err error) string {
+	switch classifyRequestError(err) {
+	case CategoryTimeout:
+		return StatusTimeout
+	case CategoryConnection, CategoryDNS, CategoryClient:
+		return StatusError
+	}
+	return StatusFailed
+}
+
+// diagnoseRequestError suggests where to look when a request got no response; connected tells a
+// timeout while connecting from one waiting for the answer
+func diagnoseRequestError(err error, connected bool) string {
+	address := "the API"
+	var opErr *net.OpError
+	var urlErr *url.Error
+	if errors.As(err, &opErr) && opErr.Addr != nil {
+		address = opErr.Addr.String()
+	} else if errors.As(err, &urlErr) {
+		if parsed, parseErr := url.Parse(urlErr.URL); parseErr == nil && parsed.Host != "" {
+			address = parsed.Host
+		}
+	}
+
+	var dnsErr *net.DNSError
+	switch {
+	case errors.Is(err, errInjectedFault):
+		return "The failure was injected by a fault middleware in the config"
+	case isClientSaturation(err):
+		return "The tester ran out of open files or local ports; raise ulimit -n, and -max-open-files if set"
+	case errors.As(err, &dnsErr) && dnsErr.IsNotFound:
+		return fmt.Sprintf("%s does not resolve; check the host in base_url, or whether it only resolves inside a VPN or cluster", dnsErr.Name)
+	case errors.As(err, &dnsErr):
+		return fmt.Sprintf("Looking up %s failed; check the DNS resolver, or resolve once with -pin-dns", dnsErr.Name)
+	case classifyRequestError(err) == CategoryTimeout && !connected:
+		return fmt.Sprintf("Connecting to %s timed out; a firewall or security group may be dropping the packets", address)
+	case classifyRequestError(err) == CategoryTimeout:
+		return "Connected, but no response arrived within the timeout; raise \"timeout\" if the endpoint is expected to be this slow, or check the server for slow queries or locks"
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return fmt.Sprintf("Nothing is listening on %s; check that the service is running and the port in base_url", address)
+	case errors.Is(err, syscall.EHOSTUNREACH) || errors.Is(err, syscall.ENETUNREACH):
+		return fmt.Sprintf("No route to %s; check network access and VPN, or try -ip-version", address)
+	case errors.Is(err, syscall.ECONNRESET) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF):
+		return "The connection was closed before a response; check proxies and load balancers in front of the API, or whether the server crashed"
+	}
+	return ""
+}
+
+// formatFailureBreakdown renders how many failures were timeouts and errors as " (2 TIMEOUT, 1 ERROR)",
+// or "" when all failures were assertions
+func formatFailureBreakdown(results []TestResult) string {
+	var parts []string
+	for _, status := range []string{StatusTimeout, StatusError} {
+		if count := countStatus(results, status); count > 0 {
+			parts = append(parts, fmt.Sprintf("%d %s", count, status))
+		}
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(parts, ", ") + ")"
+}
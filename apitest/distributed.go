@@ -0,0 +1,487 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AgentTokenEnv names the shared secret a controller and its agents authenticate with
+const AgentTokenEnv = "APITEST_AGENT_TOKEN"
+
+// DefaultAgentListen is the address an agent listens on without -listen: loopback only, so an
+// agent is reachable from other machines only when -listen says so
+const DefaultAgentListen = "127.0.0.1:7070"
+
+// localAccessResolvers are the placeholder prefixes that run commands on an agent or read its
+// files, environment or secret stores with its credentials, refused in a controller's config
+// unless the agent is started with -allow-local
+var localAccessResolvers = []string{"exec", "file", "env", "vault", "ssm"}
+
+// maxAgentJobSize caps the job an agent accepts, config included
+const maxAgentJobSize = 64 << 20
+
+// agentJob is the share of a distributed run one agent generates: its virtual users, numbered
+// after those of the agents before it, and the config and options of the controller's run
+type agentJob struct {
+	RunID            string            `json:"run_id"`
+	ConfigName       string            `json:"config_name"`
+	Config           []byte            `json:"config"`
+	BaseURL          string            `json:"base_url"`
+	Services         map[string]string `json:"services,omitempty"`
+	Params           map[string]string `json:"params,omitempty"`
+	Labels           map[string]string `json:"labels,omitempty"`
+	Environment      string            `json:"environment,omitempty"`
+	Seed             uint64            `json:"seed"`
+	StopOnFailure    bool              `json:"stop_on_failure,omitempty"`
+	RerunFailed      int               `json:"rerun_failed,omitempty"`
+	ExactNumbers     bool              `json:"exact_numbers,omitempty"`
+	CheckContentType bool              `json:"check_content_type,omitempty"`
+	MatchKeys        string            `json:"match_keys,omitempty"`
+	VersionHeader    string            `json:"version_header,omitempty"`
+	Pace             bool              `json:"pace,omitempty"`
+	CircuitBreaker   int               `json:"circuit_breaker,omitempty"`
+	FirstUser        int               `json:"first_user"`
+	VirtualUsers     int               `json:"virtual_users"`
+	Iterations       int               `json:"iterations"`
+	ThinkTime        time.Duration     `json:"think_time_ns"`
+	RampUp           time.Duration     `json:"ramp_up_ns"`
+}
+
+// JourneyAgent records the share of a distributed run one agent generated
+type JourneyAgent struct {
+	URL          string `json:"url"`
+	VirtualUsers int    `json:"virtual_users"`
+	Journeys     int    `json:"journeys"`
+	Passed       int    `json:"passed"`
+	Error        string `json:"error,omitempty"`
+}
+
+// agentFlags collects the repeatable -agent flag
+type agentFlags []string
+
+// String renders the agent URLs comma-separated
+func (a *agentFlags) String() string {
+	return strings.Join(*a, ",")
+}
+
+// Set adds one agent URL
+func (a *agentFlags) Set(value string) error {
+	*a = append(*a, strings.TrimRight(value, "/"))
+	return nil
+}
+
+// RunDistributed runs journeys like RunJourneys, but has agents generate them: the users are
+// split evenly between the agents, which all start at once and send back their reports. Their
+// results and journeys are combined into t as if one instance had generated the whole load.
+func (t *APITester) RunDistributed(agents []string, token string, users, iterations int, thinkTime, rampUp time.Duration) {
+	t.Journeys = &JourneyReport{VirtualUsers: users, Iterations: iterations, ThinkTime: thinkTime.String()}
+	if rampUp > 0 {
+		t.Journeys.RampUp = rampUp.String()
+	}
+	t.printTestHeader()
+	t.Results = []TestResult{}
+
+	// No agent starts unless all of them can, so a typo doesn't cut the load short
+	config, readErr := os.ReadFile(t.ConfigPath)
+	ready := true
+	for _, agent := range agents {
+		err := readErr
+		if err == nil {
+			err = checkAgent(t.Context, agent, token)
+		}
+		t.Journeys.Agents = append(t.Journeys.Agents, JourneyAgent{URL: agent})
+		if err != nil {
+			t.Journeys.Agents[len(t.Journeys.Agents)-1].Error = err.Error()
+			fmt.Fprintf(t.out, "%s✗ Agent %s: %v%s\n", ColorRed, agent, err, ColorReset)
+			ready = false
+		}
+	}
+	if !ready {
+		t.Journeys.summarize(t.Results)
+		return
+	}
+
+	reports := make([]TestReport, len(agents))
+	errs := make([]error, len(agents))
+	var wg sync.WaitGroup
+	firstUser := 0
+	for i, agent := range agents {
+		share := users / len(agents)
+		if i < users%len(agents) {
+			share++
+		}
+		t.Journeys.Agents[i].VirtualUsers = share
+		job := agentJob{
+			RunID:            t.RunID,
+			ConfigName:       filepath.Base(t.ConfigPath),
+			Config:           config,
+			BaseURL:          t.BaseURL,
+			Services:         t.Services,
+			Params:           t.Params,
+			Labels:           t.Labels,
+			Environment:      t.Environment,
+			Seed:             t.Seed,
+			StopOnFailure:    t.StopOnFailure,
+			RerunFailed:      t.RerunFailed,
+			ExactNumbers:     t.ExactNumbers,
+			CheckContentType: t.CheckContentType,
+			MatchKeys:        t.MatchKeys,
+			Pace:             t.pacer != nil,
+			FirstUser:        firstUser,
+			VirtualUsers:     share,
+			Iterations:       iterations,
+			ThinkTime:        thinkTime,
+			RampUp:           rampUp,
+		}
+		if t.breaker != nil {
+			job.CircuitBreaker = t.breaker.threshold
+		}
+		if t.versions != nil {
+			job.VersionHeader = t.versions.header
+		}
+		firstUser += share
+		if share == 0 {
+			continue
+		}
+		fmt.Fprintf(t.out, "%s↳ Users %d-%d on %s%s\n", ColorCyan, job.FirstUser+1, job.FirstUser+share, agent, ColorReset)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			reports[i], errs[i] = sendAgentJob(t.Context, agent, token, job)
+		}()
+	}
+	wg.Wait()
+
+	for i, report := range reports {
+		agent := &t.Journeys.Agents[i]
+		if errs[i] != nil {
+			agent.Error = errs[i].Error()
+			if t.Context.Err() == nil {
+				fmt.Fprintf(t.out, "%s✗ Agent %s: %v%s\n", ColorRed, agent.URL, errs[i], ColorReset)
+			}
+			continue
+		}
+		t.Results = append(t.Results, report.Results...)
+		t.versions.merge(report.Versions)
+		if report.Journeys != nil {
+			t.Journeys.Samples = append(t.Journeys.Samples, report.Journeys.Samples...)
+			agent.Journeys, agent.Passed = report.Journeys.Journeys, report.Journeys.Passed
+		}
+		color, mark := ColorGreen, "✓"
+		if agent.Passed < agent.Journeys {
+			color, mark = ColorRed, "✗"
+		}
+		fmt.Fprintf(t.out, "%s%s Agent %s: %d/%d journeys passed%s\n", color, mark, agent.URL, agent.Passed, agent.Journeys, ColorReset)
+	}
+
+	t.Journeys.summarize(t.Results)
+}
+
+// agentsFailed counts the agents that could not generate their share of a distributed run
+func (r *JourneyReport) agentsFailed() int {
+	failed := 0
+	for _, agent := range r.Agents {
+		if agent.Error != "" {
+			failed++
+		}
+	}
+	return failed
+}
+
+// checkAgent makes sure an agent is up, accepts the token and is not busy with another run
+func checkAgent(ctx context.Context, agent, token string) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, agent+"/health", nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s", agentError(resp))
+	}
+	return nil
+}
+
+// sendAgentJob has an agent run its share and waits for its report; cancelling ctx cancels the
+// agent's run too
+func sendAgentJob(ctx context.Context, agent, token string, job agentJob) (TestReport, error) {
+	var report TestReport
+	body, err := json.Marshal(job)
+	if err != nil {
+		return report, fmt.Errorf("failed to encode job: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, agent+"/run", bytes.NewReader(body))
+	if err != nil {
+		return report, err
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return report, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return report, fmt.Errorf("%s", agentError(resp))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&report); err != nil {
+		return report, fmt.Errorf("failed to parse report: %w", err)
+	}
+	return report, nil
+}
+
+// agentError describes an agent's error response by its status and message
+func agentError(resp *http.Response) string {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if text := strings.TrimSpace(string(message)); text != "" {
+		return fmt.Sprintf("HTTP %d: %s", resp.StatusCode, text)
+	}
+	return fmt.Sprintf("HTTP %d", resp.StatusCode)
+}
+
+// agent runs the jobs a controller sends, one at a time
+type agent struct {
+	token      string
+	key        []byte
+	dir        string
+	allowLocal bool
+	busy       sync.Mutex
+}
+
+// ServeHTTP answers GET /health and POST /run for requests bearing the agent's token
+func (a *agent) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	bearer, _ := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(bearer), []byte(a.token)) != 1 {
+		http.Error(w, "invalid agent token", http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/health":
+		if !a.busy.TryLock() {
+			http.Error(w, "busy with another run", http.StatusConflict)
+			return
+		}
+		a.busy.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"status":"ok"}`)
+	case r.Method == http.MethodPost && r.URL.Path == "/run":
+		if !a.busy.TryLock() {
+			http.Error(w, "busy with another run", http.StatusConflict)
+			return
+		}
+		defer a.busy.Unlock()
+		var job agentJob
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxAgentJobSize)).Decode(&job); err != nil {
+			http.Error(w, fmt.Sprintf("invalid job: %v", err), http.StatusBadRequest)
+			return
+		}
+		fmt.Printf("%s↳ Run %s from %s: users %d-%d%s\n", ColorCyan, job.RunID, r.RemoteAddr, job.FirstUser+1, job.FirstUser+job.VirtualUsers, ColorReset)
+		report, err := a.run(r.Context(), job)
+		if err != nil {
+			fmt.Printf("%s✗ Run %s: %v%s\n", ColorRed, job.RunID, err, ColorReset)
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		data, err := json.Marshal(report)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to encode report: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(data)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// run generates a job's share of the load and returns its report
+func (a *agent) run(ctx context.Context, job agentJob) (TestReport, error) {
+	if job.VirtualUsers < 1 || job.Iterations < 1 {
+		return TestReport{}, fmt.Errorf("a job needs at least one virtual user and iteration")
+	}
+	// Data files and other relative paths of the config resolve against the agent's -dir
+	tester := NewAPITester(filepath.Join(a.dir, filepath.Base(job.ConfigName)), job.BaseURL, job.StopOnFailure)
+	tester.Context = ctx
+	tester.configData = job.Config
+	tester.ConfigKey = a.key
+	if err := tester.SetRunID(job.RunID); err != nil {
+		return TestReport{}, err
+	}
+	tester.SetSeed(job.Seed)
+	tester.Params = job.Params
+	tester.Services = job.Services
+	tester.Labels = job.Labels
+	tester.Environment = job.Environment
+	tester.RerunFailed = job.RerunFailed
+	tester.ExactNumbers = job.ExactNumbers
+	tester.CheckContentType = job.CheckContentType
+	tester.MatchKeys = job.MatchKeys
+	if job.VersionHeader != "" {
+		tester.TrackVersionHeader(job.VersionHeader)
+	}
+	if job.Pace {
+		tester.EnablePacing()
+	}
+	if job.CircuitBreaker > 0 {
+		tester.EnableCircuitBreaker(job.CircuitBreaker)
+	}
+	tester.userOffset = job.FirstUser
+	if !a.allowLocal {
+		// Also covers placeholders that only appear once variables are substituted
+		for _, prefix := range localAccessResolvers {
+			tester.RegisterResolver(prefix, ResolverFunc(func(string) (string, error) {
+				return "", fmt.Errorf("disabled on this agent; start it with -allow-local")
+			}))
+		}
+	}
+
+	if err := tester.LoadConfig(); err != nil {
+		return TestReport{}, err
+	}
+	if !a.allowLocal {
+		if err := checkLocalAccess(tester); err != nil {
+			return TestReport{}, fmt.Errorf("%w; start the agent with -allow-local to allow it", err)
+		}
+	}
+	if tester.OTPCatcherConfig != nil {
+		if err := tester.StartOTPCatcher(*tester.OTPCatcherConfig); err != nil {
+			return TestReport{}, err
+		}
+		defer tester.otpCatcher.Close()
+	}
+	if tester.CallbackConfig != nil {
+		if err := tester.StartCallbackListener(*tester.CallbackConfig); err != nil {
+			return TestReport{}, err
+		}
+		defer tester.callbackListener.Close()
+	}
+
+	tester.RunJourneys(job.VirtualUsers, job.Iterations, job.ThinkTime, job.RampUp)
+	if ctx.Err() != nil {
+		return TestReport{}, fmt.Errorf("cancelled by the controller")
+	}
+	fmt.Printf("%s✓ Run %s: %d/%d journeys passed%s\n", ColorGreen, job.RunID, tester.Journeys.Passed, tester.Journeys.Journeys, ColorReset)
+	return tester.buildReport(), nil
+}
+
+// checkLocalAccess rejects a config that runs commands on the agent or reads its files: command
+// and file steps, setup and teardown hooks, and the localAccessResolvers placeholders
+func checkLocalAccess(t *APITester) error {
+	for _, testCase := range t.TestCases {
+		if testCase.Type == "command" || testCase.Type == "file" {
+			return fmt.Errorf("test %q: %s steps are disabled on this agent", testCase.TestCaseName, testCase.Type)
+		}
+		if testCase.Setup != "" || testCase.Teardown != "" {
+			return fmt.Errorf("test %q: setup and teardown hooks are disabled on this agent", testCase.TestCaseName)
+		}
+		encoded, err := json.Marshal(testCase)
+		if err != nil {
+			return fmt.Errorf("test %q: %w", testCase.TestCaseName, err)
+		}
+		if placeholder := localAccessPlaceholder(string(encoded)); placeholder != "" {
+			return fmt.Errorf("test %q: %s placeholders are disabled on this agent", testCase.TestCaseName, placeholder)
+		}
+	}
+	encoded, err := json.Marshal(t.Variables)
+	if err != nil {
+		return fmt.Errorf("variables: %w", err)
+	}
+	if placeholder := localAccessPlaceholder(string(encoded)); placeholder != "" {
+		return fmt.Errorf("variables: %s placeholders are disabled on this agent", placeholder)
+	}
+	return nil
+}
+
+// localAccessPlaceholder returns the prefix of the first localAccessResolvers placeholder in
+// text, such as "{{exec:...}}", or "" if there is none
+func localAccessPlaceholder(text string) string {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		prefix, _, ok := strings.Cut(match[1], ":")
+		if ok && slices.Contains(localAccessResolvers, prefix) {
+			return "{{" + prefix + ":...}}"
+		}
+	}
+	return ""
+}
+
+// runAgentCommand implements "agent", which generates load for a controller run with -agent
+func runAgentCommand(args []string) int {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	listen := fs.String("listen", DefaultAgentListen, "Address to accept controller requests on")
+	token := fs.String("token", os.Getenv(AgentTokenEnv), "Token the controller must present (default $"+AgentTokenEnv+")")
+	keyFile := fs.String("key-file", os.Getenv(ConfigKeyEnv), "Key file decrypting encrypted configs the controller sends (default $"+ConfigKeyEnv+")")
+	dir := fs.String("dir", ".", "Directory the data files of the controller's config are read from")
+	tlsCert := fs.String("tls-cert", "", "PEM certificate to serve HTTPS with; needs -tls-key")
+	tlsKey := fs.String("tls-key", "", "PEM private key of -tls-cert")
+	allowLocal := fs.Bool("allow-local", false, "Accept configs with command or file steps, setup/teardown hooks or {{exec|file|env|vault|ssm:...}} placeholders")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s agent -token t [-listen 127.0.0.1:7070] [-tls-cert c -tls-key k] [-allow-local] [-dir suite/] [-key-file k]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	if _, err := parseInterleaved(fs, args); err != nil {
+		return 1
+	}
+	// An agent sends whatever requests a config holds, so it never runs without a token
+	if *token == "" {
+		fmt.Fprintf(os.Stderr, "%sError: -token or $%s is required%s\n\n", ColorRed, AgentTokenEnv, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	if (*tlsCert == "") != (*tlsKey == "") {
+		fmt.Fprintf(os.Stderr, "%sError: -tls-cert and -tls-key must be set together%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	a := &agent{token: *token, dir: *dir, allowLocal: *allowLocal}
+	if *keyFile != "" {
+		key, err := LoadConfigKey(*keyFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+		a.key = key
+	}
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to listen on %s: %v%s\n", ColorRed, *listen, err, ColorReset)
+		return 1
+	}
+	server := &http.Server{Handler: a, ReadHeaderTimeout: 10 * time.Second}
+	if *tlsCert != "" {
+		fmt.Printf("%s✓ Agent listening on https://%s%s\n", ColorGreen, listener.Addr(), ColorReset)
+		err = server.ServeTLS(listener, *tlsCert, *tlsKey)
+	} else {
+		fmt.Printf("%s✓ Agent listening on http://%s%s\n", ColorGreen, listener.Addr(), ColorReset)
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok && !addr.IP.IsLoopback() {
+			fmt.Printf("%s⚠ The token and configs cross the network unencrypted; serve HTTPS with -tls-cert and -tls-key%s\n", ColorYellow, ColorReset)
+		}
+		err = server.Serve(listener)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,58 @@
+package apitest
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestAgentRefusesLocalAccess(t *testing.T) {
+	tests := []struct {
+		name     string
+		testCase string
+		want     string
+	}{
+		{"command step", `{"type": "command", "command": {"run": "id"}}`, "command steps are disabled"},
+		{"file step", `{"type": "file", "file": {"path": "/etc/passwd"}}`, "file steps are disabled"},
+		{"setup hook", `{"api": "/", "setup": "id"}`, "setup and teardown hooks are disabled"},
+		{"teardown hook", `{"api": "/", "teardown": "id"}`, "setup and teardown hooks are disabled"},
+		{"exec placeholder", `{"api": "/", "headers": {"X": "{{exec:id}}"}}`, "{{exec:...}} placeholders are disabled"},
+		{"file placeholder", `{"api": "/{{file:/etc/passwd}}"}`, "{{file:...}} placeholders are disabled"},
+		{"env placeholder", `{"api": "/", "headers": {"X": "{{env:AWS_SECRET_ACCESS_KEY}}"}}`, "{{env:...}} placeholders are disabled"},
+		{"vault placeholder", `{"api": "/", "body": {"p": "{{vault:secret/data/app#password}}"}}`, "{{vault:...}} placeholders are disabled"},
+		{"ssm placeholder", `{"api": "/", "params": {"k": "{{ssm:/app/key}}"}}`, "{{ssm:...}} placeholders are disabled"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testCase := `{"test_case_name": "t", "method": "GET", ` + strings.TrimPrefix(tt.testCase, "{")
+			job := agentJob{
+				RunID:        "20260101T000000Z-00000000",
+				ConfigName:   "test_cases.json",
+				Config:       []byte(`{"test_case": [` + testCase + `]}`),
+				BaseURL:      "http://127.0.0.1:1",
+				VirtualUsers: 1,
+				Iterations:   1,
+			}
+			a := &agent{token: "t", dir: t.TempDir()}
+			_, err := a.run(context.Background(), job)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("got %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAgentRefusesLocalAccessInVariables(t *testing.T) {
+	job := agentJob{
+		RunID:        "20260101T000000Z-00000000",
+		ConfigName:   "test_cases.json",
+		Config:       []byte(`{"variables": {"key": "{{env:HOME}}"}, "test_case": [{"test_case_name": "t", "method": "GET", "api": "/"}]}`),
+		BaseURL:      "http://127.0.0.1:1",
+		VirtualUsers: 1,
+		Iterations:   1,
+	}
+	a := &agent{token: "t", dir: t.TempDir()}
+	if _, err := a.run(context.Background(), job); err == nil || !strings.Contains(err.Error(), "variables: {{env:...}}") {
+		t.Fatalf("got %v, want the variable refused", err)
+	}
+}
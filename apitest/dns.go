@@ -0,0 +1,152 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// requestTrace collects connection-level details of a single request
+type requestTrace struct {
+	dnsStart time.Time
+	DNS      time.Duration
+	// RemoteAddr is the address of the connection the request went over
+	RemoteAddr string
+	// Reused is set when the connection was kept alive from an earlier request, idle for IdleTime
+	Reused   bool
+	IdleTime time.Duration
+	gotConn  bool
+}
+
+// withTrace attaches the trace hooks to a request
+func (rt *requestTrace) withTrace(req *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			rt.dnsStart = time.Now()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			if !rt.dnsStart.IsZero() {
+				rt.DNS += time.Since(rt.dnsStart)
+			}
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			rt.RemoteAddr = info.Conn.RemoteAddr().String()
+			rt.Reused, rt.IdleTime, rt.gotConn = info.Reused, info.IdleTime, true
+		},
+	}
+	return req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+}
+
+// dnsPinner resolves each host once and dials the same addresses for the rest of the run
+type dnsPinner struct {
+	mu     sync.Mutex
+	pinned map[string][]string
+	dialer *net.Dialer
+	out    io.Writer
+}
+
+// newDNSPinner creates an empty pinner that reports new pins to out
+func newDNSPinner(out io.Writer) *dnsPinner {
+	return &dnsPinner{
+		out:    out,
+		pinned: make(map[string][]string),
+		dialer: &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// resolve returns the pinned addresses for a host, resolving it on first use
+func (p *dnsPinner) resolve(ctx context.Context, host string) ([]string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if addrs, ok := p.pinned[host]; ok {
+		return addrs, nil
+	}
+	if net.ParseIP(host) != nil {
+		return []string{host}, nil
+	}
+
+	// Report the lookup through the request's trace so DNS timing is still recorded
+	trace := httptrace.ContextClientTrace(ctx)
+	if trace != nil && trace.DNSStart != nil {
+		trace.DNSStart(httptrace.DNSStartInfo{Host: host})
+	}
+	ipAddrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if trace != nil && trace.DNSDone != nil {
+		trace.DNSDone(httptrace.DNSDoneInfo{Addrs: ipAddrs, Err: err})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, len(ipAddrs))
+	for i, ipAddr := range ipAddrs {
+		addrs[i] = ipAddr.IP.String()
+	}
+	p.pinned[host] = addrs
+	fmt.Fprintf(p.out, "  %s↳ Pinned %s → %v%s\n", ColorCyan, host, addrs, ColorReset)
+	return addrs, nil
+}
+
+// DialContext dials the pinned addresses of the target host in order
+func (p *dnsPinner) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := p.resolve(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		conn, err := p.dialer.DialContext(ctx, network, net.JoinHostPort(addr, port))
+		if err == nil {
+			return conn, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// Pins returns a copy of the pinned host addresses
+func (p *dnsPinner) Pins() map[string][]string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	pins := make(map[string][]string, len(p.pinned))
+	for host, addrs := range p.pinned {
+		pins[host] = append([]string(nil), addrs...)
+	}
+	return pins
+}
+
+// EnableDNSPinning makes the tester resolve each host once and reuse the result for the whole run
+func (t *APITester) EnableDNSPinning() {
+	t.dnsPinner = newDNSPinner(t.out)
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = t.dnsPinner.DialContext
+	t.HTTPClient.Transport = transport
+}
+
+// calculateAverageDNSTime computes the average DNS time over requests that performed a lookup
+func (t *APITester) calculateAverageDNSTime() float64 {
+	var totalTime float64
+	var count int
+	for _, result := range t.Results {
+		if result.DNSTimeMs > 0 {
+			totalTime += result.DNSTimeMs
+			count++
+		}
+	}
+	if count == 0 {
+		return 0
+	}
+	return totalTime / float64(count)
+}
@@ -0,0 +1,527 @@
+package apitest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultCodePattern finds verification codes in emails unless the step sets code_pattern
+const DefaultCodePattern = `\b\d{4,8}\b`
+
+// emailClockSkew widens the "received since the run started" window for mail server clock drift
+const emailClockSkew = time.Minute
+
+// emailScanLimit is how many of the newest messages are checked per poll
+const emailScanLimit = 50
+
+// linkPattern finds URLs in email bodies
+var linkPattern = regexp.MustCompile(`https?://[^\s"'<>)\]]+`)
+
+// htmlTagPattern strips tags when an email has only an HTML part
+var htmlTagPattern = regexp.MustCompile(`<[^>]*>`)
+
+// EmailStep waits for a message in a test mailbox and exposes it, with its links and code
+type EmailStep struct {
+	// URL is a Mailpit or MailHog base URL, or imap(s)://user:password@host:port/MAILBOX.
+	// It defaults to the EMAIL_URL environment variable.
+	URL string `json:"url"`
+	// Provider is mailpit (default) or mailhog for HTTP URLs
+	Provider       string `json:"provider"`
+	To             string `json:"to"`
+	From           string `json:"from"`
+	Subject        string `json:"subject"`
+	Body           string `json:"body"`
+	CodePattern    string `json:"code_pattern"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+}
+
+// emailMessage is a received message reduced to what tests assert on
+type emailMessage struct {
+	From     string
+	To       []string
+	Subject  string
+	Received time.Time
+	Text     string
+	HTML     string
+}
+
+// emailStepTarget describes the mailbox and filter of the email step
+func emailStepTarget(t *APITester, testCase TestCase) string {
+	step := testCase.Email
+	if step == nil {
+		return ""
+	}
+	var filters []string
+	for name, pattern := range map[string]string{"to": step.To, "from": step.From, "subject": step.Subject} {
+		if pattern != "" {
+			filters = append(filters, fmt.Sprintf("%s~%s", name, t.replaceVariables(pattern)))
+		}
+	}
+	if len(filters) == 0 {
+		return "(any message)"
+	}
+	sort.Strings(filters)
+	return strings.Join(filters, " ")
+}
+
+// runEmailStep polls the mailbox for the newest matching message received since the run started,
+// returning {"from", "to", "subject", "text", "html", "links", "code"}
+func runEmailStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.Email
+	if step == nil {
+		return nil, []assertionError{{CategoryRequest, "Email step: \"email\" config is required"}}
+	}
+	mailbox := t.replaceVariables(step.URL)
+	if mailbox == "" {
+		mailbox = os.Getenv("EMAIL_URL")
+	}
+	if mailbox == "" {
+		return nil, []assertionError{{CategoryRequest, "Email step: no url configured and EMAIL_URL is not set"}}
+	}
+
+	filters := make(map[string]*regexp.Regexp)
+	patterns := map[string]string{"to": step.To, "from": step.From, "subject": step.Subject, "body": step.Body, "code": step.CodePattern}
+	if patterns["code"] == "" {
+		patterns["code"] = DefaultCodePattern
+	}
+	for name, pattern := range patterns {
+		if pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile("(?i)" + t.replaceVariables(pattern))
+		if err != nil {
+			return nil, []assertionError{{CategoryRequest, fmt.Sprintf("Email step: invalid %s pattern: %v", name, err)}}
+		}
+		filters[name] = re
+	}
+
+	since := t.startedAt.Add(-emailClockSkew)
+	timeout, interval := stepDurations(step.Timeout, step.PollIntervalMs)
+	var found *emailMessage
+	err := poll(t.Context, timeout, interval, func(ctx context.Context) error {
+		messages, err := fetchEmails(ctx, mailbox, step.Provider, since)
+		if err != nil {
+			return err
+		}
+		for i := range messages {
+			if messages[i].matches(filters) && (found == nil || messages[i].Received.After(found.Received)) {
+				found = &messages[i]
+			}
+		}
+		if found == nil {
+			return fmt.Errorf("no matching message among %d received", len(messages))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, []assertionError{{CategoryTimeout, fmt.Sprintf("Email: %v", err)}}
+	}
+	fmt.Fprintf(t.out, "  %s↳ Received \"%s\" from %s%s\n", ColorCyan, found.Subject, found.From, ColorReset)
+
+	body := found.Text
+	if body == "" {
+		body = htmlTagPattern.ReplaceAllString(found.HTML, " ")
+	}
+	links := []interface{}{}
+	for _, link := range linkPattern.FindAllString(found.Text+"\n"+found.HTML, -1) {
+		if !containsValue(links, link) {
+			links = append(links, link)
+		}
+	}
+	to := make([]interface{}, len(found.To))
+	for i, address := range found.To {
+		to[i] = address
+	}
+
+	data := map[string]interface{}{
+		"from":    found.From,
+		"to":      to,
+		"subject": found.Subject,
+		"text":    body,
+		"html":    found.HTML,
+		"links":   links,
+	}
+	if code := filters["code"].FindString(body); code != "" {
+		data["code"] = code
+	}
+	return data, nil
+}
+
+// matches reports whether a message satisfies every filter
+func (m emailMessage) matches(filters map[string]*regexp.Regexp) bool {
+	checks := map[string]string{
+		"to":      strings.Join(m.To, ", "),
+		"from":    m.From,
+		"subject": m.Subject,
+		"body":    m.Text + "\n" + m.HTML,
+	}
+	for name, value := range checks {
+		if re, ok := filters[name]; ok && !re.MatchString(value) {
+			return false
+		}
+	}
+	return true
+}
+
+// containsValue reports whether a list holds a value
+func containsValue(list []interface{}, value interface{}) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
+
+// fetchEmails lists the newest messages received since a time from a mailbox
+func fetchEmails(ctx context.Context, mailbox, provider string, since time.Time) ([]emailMessage, error) {
+	location, err := url.Parse(mailbox)
+	if err != nil {
+		return nil, fmt.Errorf("invalid email url: %w", err)
+	}
+
+	var messages []emailMessage
+	switch {
+	case location.Scheme == "imap" || location.Scheme == "imaps":
+		messages, err = fetchIMAPEmails(ctx, location, since)
+	case provider == "mailhog":
+		messages, err = fetchMailHogEmails(ctx, mailbox)
+	case provider == "" || provider == "mailpit":
+		messages, err = fetchMailpitEmails(ctx, mailbox)
+	default:
+		return nil, fmt.Errorf("unknown email provider %q (use mailpit, mailhog or an imap:// url)", provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recent := messages[:0]
+	for _, message := range messages {
+		if !message.Received.Before(since) {
+			recent = append(recent, message)
+		}
+	}
+	return recent, nil
+}
+
+// getJSON fetches a JSON document from a mail API
+func getJSON(ctx context.Context, address string, target interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, address, nil)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: HTTP %d", address, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(target)
+}
+
+// fetchMailpitEmails reads the newest messages through the Mailpit API
+func fetchMailpitEmails(ctx context.Context, baseURL string) ([]emailMessage, error) {
+	baseURL = strings.TrimRight(baseURL, "/")
+	var list struct {
+		Messages []struct {
+			ID string `json:"ID"`
+		} `json:"messages"`
+	}
+	if err := getJSON(ctx, fmt.Sprintf("%s/api/v1/messages?limit=%d", baseURL, emailScanLimit), &list); err != nil {
+		return nil, err
+	}
+
+	var messages []emailMessage
+	for _, summary := range list.Messages {
+		var message struct {
+			From    struct{ Address string }   `json:"From"`
+			To      []struct{ Address string } `json:"To"`
+			Subject string                     `json:"Subject"`
+			Date    time.Time                  `json:"Date"`
+			Text    string                     `json:"Text"`
+			HTML    string                     `json:"HTML"`
+		}
+		if err := getJSON(ctx, baseURL+"/api/v1/message/"+url.PathEscape(summary.ID), &message); err != nil {
+			return nil, err
+		}
+		parsed := emailMessage{From: message.From.Address, Subject: message.Subject,
+			Received: message.Date, Text: message.Text, HTML: message.HTML}
+		for _, to := range message.To {
+			parsed.To = append(parsed.To, to.Address)
+		}
+		messages = append(messages, parsed)
+	}
+	return messages, nil
+}
+
+// fetchMailHogEmails reads the newest messages through the MailHog API
+func fetchMailHogEmails(ctx context.Context, baseURL string) ([]emailMessage, error) {
+	var list struct {
+		Items []struct {
+			Created time.Time `json:"Created"`
+			Raw     struct {
+				Data string `json:"Data"`
+			} `json:"Raw"`
+		} `json:"items"`
+	}
+	address := fmt.Sprintf("%s/api/v2/messages?limit=%d", strings.TrimRight(baseURL, "/"), emailScanLimit)
+	if err := getJSON(ctx, address, &list); err != nil {
+		return nil, err
+	}
+
+	var messages []emailMessage
+	for _, item := range list.Items {
+		message, err := parseRawEmail([]byte(item.Raw.Data))
+		if err != nil {
+			continue
+		}
+		message.Received = item.Created
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// parseRawEmail decodes an RFC 5322 message, including multipart and transfer encodings
+func parseRawEmail(raw []byte) (emailMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return emailMessage{}, err
+	}
+
+	decoder := new(mime.WordDecoder)
+	decode := func(value string) string {
+		if decoded, err := decoder.DecodeHeader(value); err == nil {
+			return decoded
+		}
+		return value
+	}
+
+	message := emailMessage{Subject: decode(msg.Header.Get("Subject"))}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		message.From = from.Address
+	} else {
+		message.From = decode(msg.Header.Get("From"))
+	}
+	if to, err := msg.Header.AddressList("To"); err == nil {
+		for _, address := range to {
+			message.To = append(message.To, address.Address)
+		}
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		message.Received = date
+	}
+
+	err = collectEmailParts(&message, msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	return message, err
+}
+
+// collectEmailParts walks a MIME body, keeping the first text/plain and text/html parts
+func collectEmailParts(message *emailMessage, contentType, encoding string, body io.Reader) error {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := collectEmailParts(message, part.Header.Get("Content-Type"),
+				part.Header.Get("Content-Transfer-Encoding"), part); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+	switch {
+	case mediaType == "text/plain" && message.Text == "":
+		message.Text = string(content)
+	case mediaType == "text/html" && message.HTML == "":
+		message.HTML = string(content)
+	}
+	return nil
+}
+
+// fetchIMAPEmails reads messages received since a time from an IMAP mailbox
+func fetchIMAPEmails(ctx context.Context, location *url.URL, since time.Time) ([]emailMessage, error) {
+	host := location.Host
+	if location.Port() == "" {
+		port := "143"
+		if location.Scheme == "imaps" {
+			port = "993"
+		}
+		host = net.JoinHostPort(location.Hostname(), port)
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	var conn net.Conn
+	var err error
+	if location.Scheme == "imaps" {
+		tlsDialer := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: location.Hostname()}}
+		conn, err = tlsDialer.DialContext(ctx, "tcp", host)
+	} else {
+		conn, err = dialer.DialContext(ctx, "tcp", host)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(30 * time.Second))
+
+	client := &imapClient{conn: conn, reader: bufio.NewReader(conn)}
+	if _, err := client.reader.ReadString('\n'); err != nil {
+		return nil, err
+	}
+	defer client.command("LOGOUT")
+
+	if location.User != nil {
+		password, _ := location.User.Password()
+		if _, err := client.command(fmt.Sprintf("LOGIN %s %s",
+			imapQuote(location.User.Username()), imapQuote(password))); err != nil {
+			return nil, err
+		}
+	}
+	mailbox := strings.Trim(location.Path, "/")
+	if mailbox == "" {
+		mailbox = "INBOX"
+	}
+	if _, err := client.command("SELECT " + imapQuote(mailbox)); err != nil {
+		return nil, err
+	}
+
+	responses, err := client.command("UID SEARCH SINCE " + since.UTC().Format("02-Jan-2006"))
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, response := range responses {
+		if strings.HasPrefix(response.text, "* SEARCH") {
+			uids = append(uids, strings.Fields(strings.TrimPrefix(response.text, "* SEARCH"))...)
+		}
+	}
+	if len(uids) > emailScanLimit {
+		uids = uids[len(uids)-emailScanLimit:]
+	}
+	if len(uids) == 0 {
+		return nil, nil
+	}
+
+	responses, err = client.command("UID FETCH " + strings.Join(uids, ",") + " (INTERNALDATE BODY.PEEK[])")
+	if err != nil {
+		return nil, err
+	}
+	var messages []emailMessage
+	for _, response := range responses {
+		if response.literal == nil {
+			continue
+		}
+		message, err := parseRawEmail(response.literal)
+		if err != nil {
+			continue
+		}
+		if match := imapInternalDate.FindStringSubmatch(response.text); match != nil {
+			if received, err := time.Parse("02-Jan-2006 15:04:05 -0700", strings.TrimSpace(match[1])); err == nil {
+				message.Received = received
+			}
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// imapInternalDate finds the server receive time in a FETCH response
+var imapInternalDate = regexp.MustCompile(`INTERNALDATE "([^"]+)"`)
+
+// imapClient sends tagged IMAP commands
+type imapClient struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	tag    int
+}
+
+// imapResponse is one untagged response line with its literal, if any
+type imapResponse struct {
+	text    string
+	literal []byte
+}
+
+// command sends a command and collects the responses up to its tagged completion
+func (c *imapClient) command(command string) ([]imapResponse, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	if _, err := fmt.Fprintf(c.conn, "%s %s\r\n", tag, command); err != nil {
+		return nil, err
+	}
+
+	var responses []imapResponse
+	for {
+		line, err := c.reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, tag+" ") {
+			status := strings.TrimPrefix(line, tag+" ")
+			if !strings.HasPrefix(status, "OK") {
+				return nil, fmt.Errorf("imap %s: %s", strings.Fields(command)[0], status)
+			}
+			return responses, nil
+		}
+
+		response := imapResponse{text: line}
+		// A line ending in {n} is followed by n bytes of literal data
+		if open := strings.LastIndex(line, "{"); open >= 0 && strings.HasSuffix(line, "}") {
+			if size, err := strconv.Atoi(line[open+1 : len(line)-1]); err == nil {
+				response.literal = make([]byte, size)
+				if _, err := io.ReadFull(c.reader, response.literal); err != nil {
+					return nil, err
+				}
+			}
+		}
+		responses = append(responses, response)
+	}
+}
+
+// imapQuote quotes an IMAP string argument
+func imapQuote(value string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value) + `"`
+}
@@ -0,0 +1,107 @@
+package apitest
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+)
+
+// DefaultAcceptEncoding is advertised when a test doesn't set Accept-Encoding, matching Go's default
+// so the body can be decoded here and its compressed size recorded
+const DefaultAcceptEncoding = "gzip"
+
+// errContentDecoding marks bodies whose Content-Encoding couldn't be undone
+var errContentDecoding = errors.New("content decoding failed")
+
+// contentDecoder undoes one Content-Encoding
+type contentDecoder func(ctx context.Context, body []byte) ([]byte, error)
+
+// contentDecoders maps Content-Encoding tokens to their decoder; the standard library has no
+// brotli or zstd decoder, so those go through the brotli and zstd command-line tools
+var contentDecoders = map[string]contentDecoder{
+	"identity": func(_ context.Context, body []byte) ([]byte, error) { return body, nil },
+	"gzip":     gunzip,
+	"x-gzip":   gunzip,
+	"deflate":  inflate,
+	"br":       commandDecoder("brotli"),
+	"zstd":     commandDecoder("zstd"),
+}
+
+// setAcceptEncoding asks for gzip unless the test chose its own encodings; like Go's transport,
+// it leaves HEAD and Range requests alone
+func setAcceptEncoding(req *http.Request) {
+	if headerValue(req.Header, "Accept-Encoding") != "" || headerValue(req.Header, "Range") != "" || req.Method == http.MethodHead {
+		return
+	}
+	req.Header.Set("Accept-Encoding", DefaultAcceptEncoding)
+}
+
+// decodeContent undoes the codings of a Content-Encoding header, last applied first
+func decodeContent(ctx context.Context, contentEncoding string, body []byte) ([]byte, error) {
+	codings := strings.Split(contentEncoding, ",")
+	for i := len(codings) - 1; i >= 0; i-- {
+		coding := strings.ToLower(strings.TrimSpace(codings[i]))
+		if coding == "" {
+			continue
+		}
+		decode, ok := contentDecoders[coding]
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported Content-Encoding %q", errContentDecoding, coding)
+		}
+		var err error
+		if body, err = decode(ctx, body); err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", errContentDecoding, coding, err)
+		}
+	}
+	return body, nil
+}
+
+// gunzip decompresses a gzip body
+func gunzip(_ context.Context, body []byte) ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// inflate decompresses a deflate body, which is zlib-wrapped by spec but raw DEFLATE from some servers
+func inflate(_ context.Context, body []byte) ([]byte, error) {
+	if reader, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+		defer reader.Close()
+		return io.ReadAll(reader)
+	}
+	reader := flate.NewReader(bytes.NewReader(body))
+	defer reader.Close()
+	return io.ReadAll(reader)
+}
+
+// commandDecoder decompresses through a tool that reads stdin and writes stdout with -dc
+func commandDecoder(tool string) contentDecoder {
+	return func(ctx context.Context, body []byte) ([]byte, error) {
+		if _, err := exec.LookPath(tool); err != nil {
+			return nil, fmt.Errorf("the %s tool is required to decode this response", tool)
+		}
+		cmd := exec.CommandContext(ctx, tool, "-dc")
+		cmd.Stdin = bytes.NewReader(body)
+		var stderr bytes.Buffer
+		cmd.Stderr = &stderr
+		output, err := cmd.Output()
+		if err != nil {
+			if message := strings.TrimSpace(stderr.String()); message != "" {
+				return nil, fmt.Errorf("%w: %s", err, message)
+			}
+			return nil, err
+		}
+		return output, nil
+	}
+}
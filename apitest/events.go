@@ -0,0 +1,109 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Event names written to the event stream
+const (
+	EventTestStarted  = "test_started"
+	EventTestFinished = "test_finished"
+	EventRunFinished  = "run_finished"
+)
+
+// Event is one NDJSON line of the event stream
+type Event struct {
+	Event        string            `json:"event"`
+	Time         string            `json:"time"`
+	RunID        string            `json:"run_id"`
+	Labels       map[string]string `json:"labels,omitempty"`
+	ID           string            `json:"id,omitempty"`
+	TestCaseName string            `json:"test_case_name,omitempty"`
+	Order        int               `json:"order,omitempty"`
+	Index        int               `json:"index,omitempty"`
+	Total        int               `json:"total,omitempty"`
+	Result       *TestResult       `json:"result,omitempty"`
+	Summary      map[string]int    `json:"summary,omitempty"`
+	Passed       *bool             `json:"passed,omitempty"`
+	DurationMs   float64           `json:"duration_ms,omitempty"`
+}
+
+// EventStream writes events as newline-delimited JSON while the run progresses
+type EventStream struct {
+	// Labels are attached to every event
+	Labels map[string]string
+	mu     sync.Mutex
+	writer io.WriteCloser
+}
+
+// OpenEventFD streams events to an inherited file descriptor, e.g. 3 for `3>events.ndjson`
+func OpenEventFD(fd int) (*EventStream, error) {
+	if fd < 0 {
+		return nil, fmt.Errorf("invalid events fd %d", fd)
+	}
+	file := os.NewFile(uintptr(fd), fmt.Sprintf("fd%d", fd))
+	if _, err := file.Stat(); err != nil {
+		return nil, fmt.Errorf("failed to open events fd %d: %w", fd, err)
+	}
+	return &EventStream{writer: file}, nil
+}
+
+// OpenEventSocket streams events to a unix socket path, or to host:port with a tcp:// prefix
+func OpenEventSocket(address string) (*EventStream, error) {
+	network := "unix"
+	if strings.HasPrefix(address, "tcp://") {
+		network, address = "tcp", strings.TrimPrefix(address, "tcp://")
+	}
+	conn, err := net.DialTimeout(network, address, 5*time.Second)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect events socket: %w", err)
+	}
+	return &EventStream{writer: conn}, nil
+}
+
+// Emit writes one event; after a write error the stream is dropped, with a warning to out,
+// so the run itself isn't affected
+func (s *EventStream) Emit(out io.Writer, event Event) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer == nil {
+		return
+	}
+
+	event.Time = time.Now().UTC().Format(time.RFC3339Nano)
+	event.Labels = s.Labels
+	data, err := json.Marshal(event)
+	if err == nil {
+		_, err = s.writer.Write(append(data, '\n'))
+	}
+	if err != nil {
+		fmt.Fprintf(out, "%s⚠ Event stream stopped: %v%s\n", ColorYellow, err, ColorReset)
+		s.writer.Close()
+		s.writer = nil
+	}
+}
+
+// Close closes the underlying writer
+func (s *EventStream) Close() error {
+	if s == nil {
+		return nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.writer == nil {
+		return nil
+	}
+	err := s.writer.Close()
+	s.writer = nil
+	return err
+}
@@ -0,0 +1,197 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileStep waits for a file produced by an export and exposes its content for assertions
+type FileStep struct {
+	// Path is a local path or glob, s3://bucket/key (aws CLI) or sftp://user@host:port/path (scp)
+	Path           string `json:"path"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+	MinSize        *int64 `json:"min_size"`
+	MaxSize        *int64 `json:"max_size"`
+	// Format is csv, json or text; by default it follows the file extension
+	Format string `json:"format"`
+}
+
+// fileStepTarget returns the location the file step polls
+func fileStepTarget(t *APITester, testCase TestCase) string {
+	if testCase.File == nil {
+		return ""
+	}
+	return t.replaceVariables(testCase.File.Path)
+}
+
+// runFileStep polls for the file and parses it into
+// {"path": ..., "size": ..., "row_count": ..., "content": ...}
+func runFileStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.File
+	if step == nil || step.Path == "" {
+		return nil, []assertionError{{CategoryRequest, "File step: \"file.path\" is required"}}
+	}
+	location := t.replaceVariables(step.Path)
+	timeout, interval := stepDurations(step.Timeout, step.PollIntervalMs)
+
+	var path string
+	var content []byte
+	err := poll(t.Context, timeout, interval, func(context.Context) error {
+		var err error
+		path, content, err = fetchFile(location, interval)
+		return err
+	})
+	if err != nil {
+		return nil, []assertionError{{CategoryTimeout, fmt.Sprintf("File %s: %v", location, err)}}
+	}
+	fmt.Fprintf(t.out, "  %s↳ Found %s (%d bytes)%s\n", ColorCyan, path, len(content), ColorReset)
+
+	var errors []assertionError
+	size := int64(len(content))
+	if step.MinSize != nil && size < *step.MinSize {
+		errors = append(errors, assertionError{CategoryStep,
+			fmt.Sprintf("File size: Expected at least %d bytes, got %d", *step.MinSize, size)})
+	}
+	if step.MaxSize != nil && size > *step.MaxSize {
+		errors = append(errors, assertionError{CategoryStep,
+			fmt.Sprintf("File size: Expected at most %d bytes, got %d", *step.MaxSize, size)})
+	}
+
+	data := map[string]interface{}{"path": path, "size": float64(size)}
+	format := step.Format
+	if format == "" {
+		format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+	}
+	switch format {
+	case "csv":
+		rows, err := parseCSV(content)
+		if err != nil {
+			return data, append(errors, assertionError{CategorySchema, fmt.Sprintf("File content: %v", err)})
+		}
+		data["content"] = rows
+		data["row_count"] = float64(len(rows))
+	case "json":
+		var parsed interface{}
+		if err := t.unmarshalJSON(content, &parsed); err != nil {
+			return data, append(errors, assertionError{CategorySchema, fmt.Sprintf("File content: invalid JSON: %v", err)})
+		}
+		data["content"] = parsed
+	case "ndjson", "jsonl":
+		lines, err := t.parseNDJSON(content)
+		if err != nil {
+			return data, append(errors, assertionError{CategorySchema, fmt.Sprintf("File content: invalid NDJSON %v", err)})
+		}
+		data["content"] = lines
+		data["row_count"] = float64(len(lines))
+	default:
+		data["content"] = string(content)
+	}
+	return data, errors
+}
+
+// fetchFile reads the file at a location, returning the resolved path and its content
+func fetchFile(location string, interval time.Duration) (string, []byte, error) {
+	parsed, err := url.Parse(location)
+	if err == nil && (parsed.Scheme == "s3" || parsed.Scheme == "sftp") {
+		content, err := fetchRemoteFile(parsed)
+		return location, content, err
+	}
+	return fetchLocalFile(location, interval)
+}
+
+// fetchLocalFile reads the newest file matching a path or glob once its size stops changing,
+// so a file still being written isn't picked up half-way
+func fetchLocalFile(pattern string, interval time.Duration) (string, []byte, error) {
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return "", nil, err
+	}
+	var newest string
+	var newestInfo os.FileInfo
+	for _, match := range matches {
+		info, err := os.Stat(match)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		if newestInfo == nil || info.ModTime().After(newestInfo.ModTime()) {
+			newest, newestInfo = match, info
+		}
+	}
+	if newestInfo == nil {
+		return "", nil, fmt.Errorf("not found")
+	}
+
+	time.Sleep(min(interval, time.Second))
+	info, err := os.Stat(newest)
+	if err != nil {
+		return "", nil, err
+	}
+	if info.Size() != newestInfo.Size() {
+		return "", nil, fmt.Errorf("still being written")
+	}
+	content, err := os.ReadFile(newest)
+	return newest, content, err
+}
+
+// fetchRemoteFile downloads an S3 object with the aws CLI or an SFTP file with scp
+func fetchRemoteFile(location *url.URL) ([]byte, error) {
+	tmp, err := os.CreateTemp("", "apitest-file-*")
+	if err != nil {
+		return nil, err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	switch location.Scheme {
+	case "s3":
+		_, err = runCommand("aws", "s3", "cp", "--only-show-errors", location.String(), tmp.Name())
+	case "sftp":
+		source := location.Host
+		if location.User != nil {
+			source = location.User.Username() + "@" + location.Hostname()
+		}
+		args := []string{"-q", "-B"}
+		if port := location.Port(); port != "" {
+			args = append(args, "-P", port)
+			if location.User == nil {
+				source = location.Hostname()
+			}
+		}
+		_, err = runCommand("scp", append(args, source+":"+location.Path, tmp.Name())...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(tmp.Name())
+}
+
+// parseCSV turns CSV content with a header row into objects keyed by column name
+func parseCSV(content []byte) ([]interface{}, error) {
+	records, err := csv.NewReader(bytes.NewReader(content)).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+	rows := []interface{}{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+	header := records[0]
+	for _, record := range records[1:] {
+		row := make(map[string]interface{}, len(header))
+		for i, column := range header {
+			if i < len(record) {
+				row[column] = record[i]
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
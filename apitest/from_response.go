@@ -0,0 +1,87 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// FromMarkerPrefix starts an expected value taken from an earlier test's response, e.g.
+// "{{from:list_users:data[0].email}}", so two endpoints can be checked for agreeing
+const FromMarkerPrefix = "{{from:"
+
+// fromMarkerPattern matches the test id of every from marker in a test
+var fromMarkerPattern = regexp.MustCompile(`\{\{from:([^:{}]+):[^{}]+\}\}`)
+
+// isFromMarker reports whether an expected value is a "{{from:<id>:<path>}}" matcher
+func isFromMarker(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && strings.HasPrefix(text, FromMarkerPrefix) && strings.HasSuffix(text, "}}")
+}
+
+// responseReferences returns the ids of the tests whose responses a test compares against
+func responseReferences(testCase TestCase) []string {
+	data, err := json.Marshal(testCase)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, match := range fromMarkerPattern.FindAllStringSubmatch(string(data), -1) {
+		ids = append(ids, match[1])
+	}
+	return ids
+}
+
+// checkResponseReferences verifies before anything runs that every from marker names an earlier test
+func (t *APITester) checkResponseReferences() error {
+	var problems []string
+	seen := make(map[string]bool)
+	for _, testCase := range t.TestCases {
+		for _, id := range responseReferences(testCase) {
+			if !seen[id] {
+				problems = append(problems, fmt.Sprintf("test %q compares with {{from:%s:...}}, but no earlier test has id %q",
+					testCase.TestCaseName, id, id))
+			}
+		}
+		if testCase.ID != "" {
+			seen[testCase.ID] = true
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("broken response references:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// fromMismatches compares an actual value with the value at a path of an earlier test's response.
+// Objects and arrays are compared like expected_response, so the actual value may have more fields.
+func (t *APITester) fromMismatches(marker string, actual interface{}, path string) []assertionError {
+	spec := marker[len(FromMarkerPrefix) : len(marker)-2]
+	id, sourcePath, ok := strings.Cut(spec, ":")
+	if !ok || id == "" || sourcePath == "" {
+		return []assertionError{{CategoryBody, fmt.Sprintf("%s: invalid matcher %s, expected {{from:<test id>:<path>}}", path, marker)}}
+	}
+
+	var source *TestResult
+	for i := len(t.Results) - 1; i >= 0; i-- {
+		if t.Results[i].ID == id {
+			source = &t.Results[i]
+			break
+		}
+	}
+	if source == nil || source.ResponseBody == nil {
+		return []assertionError{{CategoryBody, fmt.Sprintf("%s: Expected %s of test %s, but it has no response in this run", path, sourcePath, id)}}
+	}
+	expected, err := extractValue(source.ResponseBody, sourcePath)
+	if err != nil {
+		return []assertionError{{CategoryBody, fmt.Sprintf("%s: Expected %s of test %s, but %v", path, sourcePath, id, err)}}
+	}
+	if !isLeaf(expected) {
+		return t.validate(expected, actual, path)
+	}
+	if !compareValues(expected, actual) {
+		return []assertionError{{CategoryBody, fmt.Sprintf("%s: Expected '%v' (%s of test %s), got '%v'", path, expected, sourcePath, id, actual)}}
+	}
+	return nil
+}
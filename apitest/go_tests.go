@@ -0,0 +1,128 @@
+package apitest
+
+import (
+	"strings"
+)
+
+// TestingT is the part of *testing.T that RunAsGoTests uses, T being *testing.T itself. Taking
+// it as a type parameter keeps the testing package out of binaries that only import apitest.
+type TestingT[T any] interface {
+	Helper()
+	Fatal(args ...any)
+	Fatalf(format string, args ...any)
+	Error(args ...any)
+	Log(args ...any)
+	Logf(format string, args ...any)
+	Skip(args ...any)
+	Fail()
+	Cleanup(f func())
+	Run(name string, f func(T)) bool
+}
+
+// RunAsGoTests loads the config at configPath and runs each test case of t as a subtest of tt,
+// so a suite runs under go test with its -run filters, -json output and IDE integration. A test
+// the -run filter leaves out still runs, outside any subtest, when a selected test uses a
+// variable it extracts. Results are kept on the tester, so a report can still be delivered
+// afterwards.
+func RunAsGoTests[T TestingT[T]](t *APITester, tt T, configPath string) {
+	tt.Helper()
+	t.ConfigPath = configPath
+	if err := t.LoadConfig(); err != nil {
+		tt.Fatal(err)
+	}
+	if t.OTPCatcherConfig != nil {
+		if err := t.StartOTPCatcher(*t.OTPCatcherConfig); err != nil {
+			tt.Fatal(err)
+		}
+		tt.Cleanup(t.otpCatcher.Close)
+	}
+	if t.CallbackConfig != nil {
+		if err := t.StartCallbackListener(*t.CallbackConfig); err != nil {
+			tt.Fatal(err)
+		}
+		tt.Cleanup(t.callbackListener.Close)
+	}
+	if err := t.PushStubs(); err != nil {
+		tt.Fatal(err)
+	}
+	tt.Cleanup(t.RemoveStubs)
+
+	t.Results = []TestResult{}
+	dependsOn := dependencyGraph(t.TestCases)
+	ran := make([]bool, len(t.TestCases))
+	for i, testCase := range t.TestCases {
+		tt.Run(testCase.TestCaseName, func(st T) {
+			runGoDependencies(t, st, dependsOn, ran, i)
+			ran[i] = true
+			// A data_from or for_each test's rows are subtests of their own, once earlier tests set the array
+			rows := t.expandArrayRowsAt([]TestCase{testCase}, 0)
+			if source, _ := arraySource(rows[0]); source != "" {
+				reportGoTest(t, st, t.recordGoTest(testCase))
+				return
+			}
+			for _, row := range rows {
+				st.Run(row.TestCaseName, func(rt T) {
+					reportGoTest(t, rt, t.recordGoTest(row))
+				})
+			}
+		})
+		if t.Context.Err() != nil {
+			tt.Fatal(t.Context.Err())
+		}
+	}
+
+	if t.SuiteAsserts != nil && !t.CheckSuiteAsserts() {
+		tt.Error("suite assertions failed")
+	}
+}
+
+// runGoDependencies runs the producers of a subtest's variables that were filtered out, earliest first
+func runGoDependencies[T TestingT[T]](t *APITester, st T, dependsOn [][]int, ran []bool, i int) {
+	for _, producer := range dependsOn[i] {
+		if ran[producer] {
+			continue
+		}
+		runGoDependencies(t, st, dependsOn, ran, producer)
+		ran[producer] = true
+		dependency := t.TestCases[producer]
+		if result := t.recordGoTest(dependency); isFailed(result.Status) {
+			st.Fatalf("dependency %q failed: %s", dependency.TestCaseName,
+				strings.Join(t.maskStrings(result.Errors), "; "))
+		}
+	}
+}
+
+// recordGoTest runs a test and records its result the way RunAllTests does
+func (t *APITester) recordGoTest(testCase TestCase) TestResult {
+	result := t.runWithReruns(testCase)
+	t.Results = append(t.Results, result)
+	if _, isStep := stepTypes[testCase.Type]; !isStep {
+		t.breaker.record(t.out, result)
+	}
+	return result
+}
+
+// reportGoTest turns a test result into the outcome of its subtest
+func reportGoTest[T TestingT[T]](t *APITester, st T, result TestResult) {
+	result = t.maskResult(result)
+	if result.RequestID != "" {
+		st.Logf("request id: %s", result.RequestID)
+	}
+	switch result.Status {
+	case StatusFailed, StatusTimeout, StatusError:
+		for _, line := range result.Logs {
+			st.Log(line)
+		}
+		for _, message := range result.Errors {
+			st.Error(message)
+		}
+		if result.Hint != "" {
+			st.Log(result.Hint)
+		}
+		st.Fail()
+	case StatusSkipped:
+		st.Skip(result.SkipReason)
+	case StatusFlaky:
+		st.Logf("flaky, passed on rerun %d after: %v", result.Reruns, result.FlakyErrors)
+	}
+}
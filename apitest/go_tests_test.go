@@ -0,0 +1,125 @@
+package apitest_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/pawatthir/auto-test-api/apitest"
+)
+
+func TestRunAsGoTests(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/login":
+			w.Write([]byte(`{"token": "abc"}`))
+		case "/profile":
+			if r.Header.Get("Authorization") != "Bearer abc" {
+				w.WriteHeader(http.StatusUnauthorized)
+			}
+			w.Write([]byte(`{"name": "Ada"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	config := filepath.Join(t.TempDir(), "test_cases.json")
+	err := os.WriteFile(config, []byte(`{"test_case": [
+		{"test_case_name": "Login", "order": 1, "method": "POST", "api": "/login", "extract": {"token": "token"}},
+		{"test_case_name": "Get profile", "order": 2, "method": "GET", "api": "/profile",
+			"headers": {"Authorization": "Bearer {{token}}"}, "expected_status_code": 200, "expected_response": {"name": "Ada"}}
+	]}`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tester := apitest.NewAPITester("", server.URL, false)
+	apitest.RunAsGoTests(tester, t, config)
+	if len(tester.Results) != 2 {
+		t.Errorf("got %d results, want 2", len(tester.Results))
+	}
+
+	t.Run("failure", func(t *testing.T) {
+		config := filepath.Join(t.TempDir(), "test_cases.json")
+		err := os.WriteFile(config, []byte(`{"test_case": [
+			{"test_case_name": "Login", "order": 1, "method": "POST", "api": "/login", "extract": {"token": "token"}},
+			{"test_case_name": "Get profile", "order": 2, "method": "GET", "api": "/profile",
+				"headers": {"Authorization": "Bearer wrong"}, "expected_status_code": 200}
+		]}`), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		root := &recordingT{}
+		apitest.RunAsGoTests(apitest.NewAPITester("", server.URL, false), root, config)
+		root.runCleanups()
+		if len(root.subtests) != 2 {
+			t.Fatalf("got %d subtests, want 2", len(root.subtests))
+		}
+		if login := root.subtests[0]; login.failed {
+			t.Errorf("Login failed: %v", login.errors)
+		}
+		// Each test runs its rows as subtests of their own, so the row reports the errors
+		profile := root.subtests[1]
+		if !profile.failed || len(profile.subtests) != 1 {
+			t.Fatalf("Get profile: failed %v with %d rows, want a failure from 1 row", profile.failed, len(profile.subtests))
+		}
+		row := profile.subtests[0]
+		if !row.failed || len(row.errors) != 1 || !strings.Contains(row.errors[0], "Expected 200, got 401") {
+			t.Errorf("Get profile row: failed %v with errors %q, want the status mismatch", row.failed, row.errors)
+		}
+	})
+}
+
+// recordingT is a TestingT that records outcomes instead of failing the running test
+type recordingT struct {
+	name     string
+	failed   bool
+	skipped  bool
+	errors   []string
+	subtests []*recordingT
+	cleanups []func()
+}
+
+func (r *recordingT) Helper()             {}
+func (r *recordingT) Log(args ...any)     {}
+func (r *recordingT) Logf(string, ...any) {}
+func (r *recordingT) Cleanup(f func())    { r.cleanups = append(r.cleanups, f) }
+func (r *recordingT) Fail()               { r.failed = true }
+func (r *recordingT) Error(args ...any) {
+	r.failed, r.errors = true, append(r.errors, fmt.Sprint(args...))
+}
+func (r *recordingT) Fatal(args ...any)         { r.Error(args...); runtime.Goexit() }
+func (r *recordingT) Fatalf(f string, a ...any) { r.Fatal(fmt.Sprintf(f, a...)) }
+func (r *recordingT) Skip(args ...any)          { r.skipped = true; runtime.Goexit() }
+
+// Run runs f on its own goroutine, as testing does, so Fatal and Skip end only that subtest
+func (r *recordingT) Run(name string, f func(*recordingT)) bool {
+	sub := &recordingT{name: name}
+	r.subtests = append(r.subtests, sub)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer sub.runCleanups()
+		f(sub)
+	}()
+	<-done
+	if sub.failed {
+		r.failed = true
+	}
+	return !sub.failed
+}
+
+// runCleanups calls the registered cleanups, last registered first
+func (r *recordingT) runCleanups() {
+	for i := len(r.cleanups) - 1; i >= 0; i-- {
+		r.cleanups[i]()
+	}
+}
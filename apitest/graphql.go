@@ -0,0 +1,443 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// DefaultGraphQLEndpoint is the path GraphQL tests are recognized by when the config names none
+const DefaultGraphQLEndpoint = "/graphql"
+
+// GraphQLConfig enables checking GraphQL responses against the service's schema. Tests posting a
+// {"query": ...} body to the endpoint have their data checked for the types and nullability the
+// schema gives each selected field, besides their own expectations.
+type GraphQLConfig struct {
+	Endpoint string `json:"endpoint"`
+	// SchemaFile is an introspection result, {"data": {"__schema": ...}}; without it, the schema
+	// is introspected from the endpoint before the first GraphQL test, with that test's headers
+	SchemaFile string `json:"schema_file"`
+}
+
+// graphQLIntrospectionQuery asks for what response checks need: each type's fields, enum
+// values and possible types, with type references nested deep enough for [[T!]!]!
+const graphQLIntrospectionQuery = `query IntrospectionQuery {
+  __schema {
+    queryType { name }
+    mutationType { name }
+    subscriptionType { name }
+    types {
+      kind name
+      fields(includeDeprecated: true) { name type { ...TypeRef } }
+      enumValues(includeDeprecated: true) { name }
+      possibleTypes { name }
+    }
+  }
+}
+fragment TypeRef on __Type {
+  kind name
+  ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name ofType { kind name } } } } } }
+}`
+
+// graphQLTypeRef is an introspected type reference, e.g. NON_NULL of LIST of NON_NULL of User
+type graphQLTypeRef struct {
+	Kind   string          `json:"kind"`
+	Name   string          `json:"name"`
+	OfType *graphQLTypeRef `json:"ofType"`
+}
+
+// String renders the reference in SDL notation, e.g. "[User!]!"
+func (r *graphQLTypeRef) String() string {
+	switch {
+	case r == nil:
+		return "?"
+	case r.Kind == "NON_NULL":
+		return r.OfType.String() + "!"
+	case r.Kind == "LIST":
+		return "[" + r.OfType.String() + "]"
+	}
+	return r.Name
+}
+
+// graphQLType is an introspected named type
+type graphQLType struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Fields []struct {
+		Name string          `json:"name"`
+		Type *graphQLTypeRef `json:"type"`
+	} `json:"fields"`
+	EnumValues []struct {
+		Name string `json:"name"`
+	} `json:"enumValues"`
+	PossibleTypes []struct {
+		Name string `json:"name"`
+	} `json:"possibleTypes"`
+}
+
+// graphQLSchema is an introspected schema, indexed for checking responses
+type graphQLSchema struct {
+	roots map[string]string
+	types map[string]*graphQLType
+}
+
+// parseGraphQLSchema reads an introspection result, with or without its {"data": ...} envelope
+func parseGraphQLSchema(data []byte) (*graphQLSchema, error) {
+	var result struct {
+		Data struct {
+			Schema *graphQLIntrospection `json:"__schema"`
+		} `json:"data"`
+		Schema *graphQLIntrospection `json:"__schema"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("invalid introspection result: %w", err)
+	}
+	introspection := result.Data.Schema
+	if introspection == nil {
+		introspection = result.Schema
+	}
+	if introspection == nil || introspection.QueryType == nil {
+		return nil, fmt.Errorf("introspection result has no __schema with a queryType")
+	}
+
+	schema := &graphQLSchema{roots: map[string]string{"query": introspection.QueryType.Name},
+		types: make(map[string]*graphQLType)}
+	if introspection.MutationType != nil {
+		schema.roots["mutation"] = introspection.MutationType.Name
+	}
+	if introspection.SubscriptionType != nil {
+		schema.roots["subscription"] = introspection.SubscriptionType.Name
+	}
+	for i := range introspection.Types {
+		schema.types[introspection.Types[i].Name] = &introspection.Types[i]
+	}
+	return schema, nil
+}
+
+// graphQLIntrospection is the __schema of an introspection result
+type graphQLIntrospection struct {
+	QueryType        *struct{ Name string } `json:"queryType"`
+	MutationType     *struct{ Name string } `json:"mutationType"`
+	SubscriptionType *struct{ Name string } `json:"subscriptionType"`
+	Types            []graphQLType          `json:"types"`
+}
+
+// loadGraphQL checks the config's GraphQL settings and reads its schema file
+func (t *APITester) loadGraphQL(config *GraphQLConfig) error {
+	t.GraphQL, t.graphQLSchema = config, nil
+	if config == nil {
+		return nil
+	}
+	if config.Endpoint == "" {
+		config.Endpoint = DefaultGraphQLEndpoint
+	}
+	if config.SchemaFile == "" {
+		return nil
+	}
+	data, err := t.readConfigFile(t.configRelativePath(config.SchemaFile))
+	if err != nil {
+		return fmt.Errorf("graphql: failed to read schema_file: %w", err)
+	}
+	if t.graphQLSchema, err = parseGraphQLSchema(data); err != nil {
+		return fmt.Errorf("graphql: schema_file %s: %w", config.SchemaFile, err)
+	}
+	return nil
+}
+
+// graphQLRequest returns the query and operation name of a test posting to the GraphQL endpoint
+func (t *APITester) graphQLRequest(testCase TestCase) (query, operationName string, ok bool) {
+	if t.GraphQL == nil {
+		return "", "", false
+	}
+	query, ok = testCase.Body["query"].(string)
+	if !ok {
+		return "", "", false
+	}
+	path := t.replaceVariables(testCase.API)
+	if parsed, err := url.Parse(path); err == nil {
+		path = parsed.Path
+	}
+	if strings.TrimRight(path, "/") != strings.TrimRight(t.GraphQL.Endpoint, "/") {
+		return "", "", false
+	}
+	operationName, _ = testCase.Body["operationName"].(string)
+	return t.replaceVariables(query), operationName, true
+}
+
+// introspectGraphQL fetches the schema from the endpoint, sending the introspection query with a
+// test's headers, so the same authentication applies
+func (t *APITester) introspectGraphQL(testCase TestCase) (*graphQLSchema, error) {
+	introspection := testCase
+	introspection.Body = map[string]interface{}{"query": graphQLIntrospectionQuery}
+	resp, _, _, err := t.sendAttempt(introspection, http.MethodPost, t.buildURL(testCase))
+	if err != nil {
+		return nil, fmt.Errorf("introspection failed: %w", err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err == nil {
+		data, err = decodeContent(t.Context, resp.Header.Get("Content-Encoding"), data)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("introspection failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("introspection failed: HTTP %d", resp.StatusCode)
+	}
+	schema, err := parseGraphQLSchema(data)
+	if err != nil {
+		return nil, fmt.Errorf("introspection failed (is it disabled? set schema_file): %w", err)
+	}
+	fmt.Fprintf(t.out, "  %s↳ Introspected GraphQL schema: %d types%s\n", ColorCyan, len(schema.types), ColorReset)
+	return schema, nil
+}
+
+// validateGraphQL checks the data of a GraphQL response against the schema: every field the
+// query selects is present with its schema type, non-null fields aren't null, and nothing else is
+func (t *APITester) validateGraphQL(testCase TestCase, responseData interface{}) []assertionError {
+	query, operationName, ok := t.graphQLRequest(testCase)
+	if !ok {
+		return nil
+	}
+	response, _ := responseData.(map[string]interface{})
+	data, _ := response["data"].(map[string]interface{})
+	if data == nil {
+		// A request that failed as a whole has only errors, which the test's expectations cover
+		return nil
+	}
+
+	if t.graphQLSchema == nil {
+		schema, err := t.introspectGraphQL(testCase)
+		if err != nil {
+			return []assertionError{{CategorySchema, fmt.Sprintf("GraphQL schema: %v", err)}}
+		}
+		t.graphQLSchema = schema
+	}
+	document, err := parseGraphQLDocument(query)
+	if err != nil {
+		return []assertionError{{CategorySchema, fmt.Sprintf("GraphQL query: %v", err)}}
+	}
+	operation, err := document.operation(operationName)
+	if err != nil {
+		return []assertionError{{CategorySchema, fmt.Sprintf("GraphQL query: %v", err)}}
+	}
+	root, ok := t.graphQLSchema.roots[operation.Type]
+	if !ok {
+		return []assertionError{{CategorySchema, fmt.Sprintf("GraphQL query: the schema has no %s type", operation.Type)}}
+	}
+	return t.graphQLSchema.checkObject(document, root, operation.Selections, data, "data")
+}
+
+// graphQLField is a field the query selects on an object, as the schema types it
+type graphQLField struct {
+	ref        *graphQLTypeRef
+	selections []graphQLSelection
+	// optional fields may be left out: those under @skip or @include, or in a fragment on a type
+	// the object may not be
+	optional bool
+}
+
+// checkObject checks an object against the fields selected on its type
+func (s *graphQLSchema) checkObject(document *graphQLDocument, typeName string, selections []graphQLSelection,
+	value map[string]interface{}, path string) []assertionError {
+	var errors []assertionError
+	fail := func(format string, args ...interface{}) {
+		errors = append(errors, assertionError{CategorySchema, fmt.Sprintf(format, args...)})
+	}
+
+	// The concrete type of an interface or union is known from __typename, when it was selected
+	runtime := typeName
+	if s.isAbstract(typeName) {
+		runtime = ""
+		if name, ok := value["__typename"].(string); ok {
+			if !s.isPossibleType(typeName, name) {
+				fail("%s.__typename: GraphQL type %s is not a possible type of %s", path, name, typeName)
+				return errors
+			}
+			runtime = name
+		}
+	}
+
+	fields := make(map[string]*graphQLField)
+	var collect func(selections []graphQLSelection, on string, optional bool)
+	collect = func(selections []graphQLSelection, on string, optional bool) {
+		for _, selection := range selections {
+			if selection.Field == "" {
+				condition := selection.TypeCondition
+				if selection.Fragment != "" {
+					fragment, ok := document.fragments[selection.Fragment]
+					if !ok {
+						fail("%s: GraphQL fragment %s is not defined", path, selection.Fragment)
+						continue
+					}
+					condition, selection.Selections = fragment.TypeCondition, fragment.Selections
+				}
+				switch {
+				case condition == "" || condition == on:
+					collect(selection.Selections, on, optional || selection.Optional)
+				case runtime != "" && s.isPossibleType(condition, runtime):
+					collect(selection.Selections, condition, optional || selection.Optional)
+				case runtime == "" && s.isPossibleType(typeName, condition):
+					// Without __typename, a fragment on a narrower type may or may not apply
+					collect(selection.Selections, condition, true)
+				case runtime == "" && s.isAbstract(condition):
+					collect(selection.Selections, condition, true)
+				}
+				continue
+			}
+
+			ref := s.fieldType(on, selection.Field)
+			if ref == nil {
+				fail("%s.%s: GraphQL type %s has no field %s", path, selection.Key(), on, selection.Field)
+				continue
+			}
+			field, seen := fields[selection.Key()]
+			if !seen {
+				field = &graphQLField{ref: ref, optional: true}
+				fields[selection.Key()] = field
+			}
+			// Selections of the same key merge; it's required if any of them is
+			field.selections = append(field.selections, selection.Selections...)
+			field.optional = field.optional && (optional || selection.Optional)
+		}
+	}
+	collect(selections, typeName, false)
+
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		field := fields[key]
+		actual, exists := value[key]
+		if !exists {
+			if !field.optional {
+				fail("%s.%s: Expected GraphQL field of type %s, but it is missing", path, key, field.ref)
+			}
+			continue
+		}
+		errors = append(errors, s.checkValue(document, field.ref, field.selections, actual, path+"."+key)...)
+	}
+
+	unexpected := make([]string, 0)
+	for key := range value {
+		if _, selected := fields[key]; !selected {
+			unexpected = append(unexpected, key)
+		}
+	}
+	sort.Strings(unexpected)
+	for _, key := range unexpected {
+		fail("%s.%s: Unexpected field, not selected by the GraphQL query", path, key)
+	}
+	return errors
+}
+
+// checkValue checks a value against a type reference
+func (s *graphQLSchema) checkValue(document *graphQLDocument, ref *graphQLTypeRef, selections []graphQLSelection,
+	value interface{}, path string) []assertionError {
+	fail := func(format string, args ...interface{}) []assertionError {
+		return []assertionError{{CategorySchema, fmt.Sprintf("%s: "+format, append([]interface{}{path}, args...)...)}}
+	}
+
+	if ref.Kind == "NON_NULL" {
+		if value == nil {
+			return fail("Expected GraphQL %s, got null", ref)
+		}
+		return s.checkValue(document, ref.OfType, selections, value, path)
+	}
+	if value == nil {
+		return nil
+	}
+	if ref.Kind == "LIST" {
+		items, ok := value.([]interface{})
+		if !ok {
+			return fail("Expected GraphQL %s, got %s", ref, formatJSONContext(value, 0))
+		}
+		var errors []assertionError
+		for i, item := range items {
+			errors = append(errors, s.checkValue(document, ref.OfType, selections, item, fmt.Sprintf("%s[%d]", path, i))...)
+		}
+		return errors
+	}
+
+	named := s.types[ref.Name]
+	if named == nil {
+		return fail("GraphQL type %s is not in the schema", ref.Name)
+	}
+	switch named.Kind {
+	case "OBJECT", "INTERFACE", "UNION":
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return fail("Expected GraphQL %s object, got %s", ref.Name, formatJSONContext(value, 0))
+		}
+		return s.checkObject(document, ref.Name, selections, object, path)
+	case "ENUM":
+		text, _ := value.(string)
+		for _, enumValue := range named.EnumValues {
+			if enumValue.Name == text {
+				return nil
+			}
+		}
+		return fail("Expected a value of GraphQL enum %s, got '%v'", ref.Name, value)
+	}
+
+	// Custom scalars can be serialized as anything
+	valid := true
+	switch ref.Name {
+	case "Int":
+		rat, ok := numberRat(value)
+		valid = ok && rat.IsInt() && rat.Num().IsInt64() && rat.Num().Int64() >= math.MinInt32 && rat.Num().Int64() <= math.MaxInt32
+	case "Float":
+		_, valid = numberRat(value)
+	case "String", "ID":
+		_, valid = value.(string)
+	case "Boolean":
+		_, valid = value.(bool)
+	}
+	if !valid {
+		return fail("Expected GraphQL %s, got %s", ref.Name, formatJSONContext(value, 0))
+	}
+	return nil
+}
+
+// fieldType returns the type of a field of a named type, or nil when it has no such field
+func (s *graphQLSchema) fieldType(typeName, field string) *graphQLTypeRef {
+	if field == "__typename" {
+		return &graphQLTypeRef{Kind: "NON_NULL", OfType: &graphQLTypeRef{Kind: "SCALAR", Name: "String"}}
+	}
+	if named := s.types[typeName]; named != nil {
+		for _, candidate := range named.Fields {
+			if candidate.Name == field {
+				return candidate.Type
+			}
+		}
+	}
+	return nil
+}
+
+// isAbstract reports whether a type is an interface or union
+func (s *graphQLSchema) isAbstract(typeName string) bool {
+	named := s.types[typeName]
+	return named != nil && (named.Kind == "INTERFACE" || named.Kind == "UNION")
+}
+
+// isPossibleType reports whether an object of type name can be returned where typeName is expected
+func (s *graphQLSchema) isPossibleType(typeName, name string) bool {
+	if typeName == name {
+		return true
+	}
+	if named := s.types[typeName]; named != nil {
+		for _, possible := range named.PossibleTypes {
+			if possible.Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
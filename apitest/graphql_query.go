@@ -0,0 +1,306 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// graphQLSelection is one selection of a query: a field, a fragment spread or an inline fragment
+type graphQLSelection struct {
+	// Field and Alias are set for fields; the response key is the alias when there is one
+	Field string
+	Alias string
+	// Fragment names a spread fragment; TypeCondition is an inline fragment's "on" type
+	Fragment      string
+	TypeCondition string
+	Selections    []graphQLSelection
+	// Optional selections have @skip or @include, so the response may leave them out
+	Optional bool
+}
+
+// Key is the response key of a field
+func (s graphQLSelection) Key() string {
+	if s.Alias != "" {
+		return s.Alias
+	}
+	return s.Field
+}
+
+// graphQLOperation is a query, mutation or subscription of a document
+type graphQLOperation struct {
+	Type       string
+	Name       string
+	Selections []graphQLSelection
+}
+
+// graphQLDocument is a parsed query document; only what response checks need is kept, so
+// arguments, variable definitions and other directives are skipped
+type graphQLDocument struct {
+	operations []graphQLOperation
+	fragments  map[string]graphQLSelection
+}
+
+// operation picks the operation a request runs: the one named by operationName, or the only one
+func (d *graphQLDocument) operation(name string) (graphQLOperation, error) {
+	if name == "" {
+		if len(d.operations) != 1 {
+			return graphQLOperation{}, fmt.Errorf("the document has %d operations, so operationName is required", len(d.operations))
+		}
+		return d.operations[0], nil
+	}
+	for _, operation := range d.operations {
+		if operation.Name == name {
+			return operation, nil
+		}
+	}
+	return graphQLOperation{}, fmt.Errorf("no operation named %s", name)
+}
+
+// graphQLParser reads a query document token by token
+type graphQLParser struct {
+	tokens []string
+	pos    int
+}
+
+// parseGraphQLDocument parses a GraphQL query document
+func parseGraphQLDocument(query string) (*graphQLDocument, error) {
+	tokens, err := lexGraphQL(query)
+	if err != nil {
+		return nil, err
+	}
+	p := &graphQLParser{tokens: tokens}
+	document := &graphQLDocument{fragments: make(map[string]graphQLSelection)}
+	for p.peek() != "" {
+		switch token := p.peek(); token {
+		case "{":
+			selections, err := p.selectionSet()
+			if err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, graphQLOperation{Type: "query", Selections: selections})
+		case "query", "mutation", "subscription":
+			p.next()
+			operation := graphQLOperation{Type: token}
+			if isGraphQLName(p.peek()) {
+				operation.Name = p.next()
+			}
+			if err := p.skipUntil("{"); err != nil {
+				return nil, err
+			}
+			if operation.Selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			document.operations = append(document.operations, operation)
+		case "fragment":
+			p.next()
+			name := p.next()
+			if p.next() != "on" || !isGraphQLName(p.peek()) {
+				return nil, fmt.Errorf("fragment %s needs a type condition", name)
+			}
+			fragment := graphQLSelection{Fragment: name, TypeCondition: p.next()}
+			if err := p.skipUntil("{"); err != nil {
+				return nil, err
+			}
+			if fragment.Selections, err = p.selectionSet(); err != nil {
+				return nil, err
+			}
+			document.fragments[name] = fragment
+		default:
+			return nil, fmt.Errorf("unexpected %q", token)
+		}
+	}
+	if len(document.operations) == 0 {
+		return nil, fmt.Errorf("no operation in the document")
+	}
+	return document, nil
+}
+
+// selectionSet parses "{ selection... }"
+func (p *graphQLParser) selectionSet() ([]graphQLSelection, error) {
+	if p.next() != "{" {
+		return nil, fmt.Errorf("expected {")
+	}
+	var selections []graphQLSelection
+	for p.peek() != "}" {
+		if p.peek() == "" {
+			return nil, fmt.Errorf("unclosed {")
+		}
+		selection, err := p.selection()
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, selection)
+	}
+	p.next()
+	return selections, nil
+}
+
+// selection parses a field, "...Fragment" or "... on Type { }"
+func (p *graphQLParser) selection() (graphQLSelection, error) {
+	var selection graphQLSelection
+	var err error
+	if p.peek() == "..." {
+		p.next()
+		if p.peek() == "on" {
+			p.next()
+			selection.TypeCondition = p.next()
+		} else if isGraphQLName(p.peek()) {
+			selection.Fragment = p.next()
+			selection.Optional = p.directives()
+			return selection, nil
+		}
+		selection.Optional = p.directives()
+		selection.Selections, err = p.selectionSet()
+		return selection, err
+	}
+
+	name := p.next()
+	if !isGraphQLName(name) {
+		return selection, fmt.Errorf("expected a field, got %q", name)
+	}
+	selection.Field = name
+	if p.peek() == ":" {
+		p.next()
+		selection.Alias, selection.Field = name, p.next()
+		if !isGraphQLName(selection.Field) {
+			return selection, fmt.Errorf("expected a field after alias %s", name)
+		}
+	}
+	if p.peek() == "(" {
+		if err := p.skipBalanced("(", ")"); err != nil {
+			return selection, err
+		}
+	}
+	selection.Optional = p.directives()
+	if p.peek() == "{" {
+		selection.Selections, err = p.selectionSet()
+	}
+	return selection, err
+}
+
+// directives skips directives, reporting whether @skip or @include makes the selection conditional
+func (p *graphQLParser) directives() bool {
+	conditional := false
+	for p.peek() == "@" {
+		p.next()
+		name := p.next()
+		conditional = conditional || name == "skip" || name == "include"
+		if p.peek() == "(" {
+			p.skipBalanced("(", ")")
+		}
+	}
+	return conditional
+}
+
+// skipUntil skips variable definitions and directives up to a token
+func (p *graphQLParser) skipUntil(token string) error {
+	for p.peek() != token {
+		if p.peek() == "" {
+			return fmt.Errorf("expected %s", token)
+		}
+		if p.peek() == "(" {
+			if err := p.skipBalanced("(", ")"); err != nil {
+				return err
+			}
+			continue
+		}
+		p.next()
+	}
+	return nil
+}
+
+// skipBalanced skips from an opening token to its matching closing one
+func (p *graphQLParser) skipBalanced(open, close string) error {
+	depth := 0
+	for {
+		switch p.next() {
+		case open:
+			depth++
+		case close:
+			if depth--; depth == 0 {
+				return nil
+			}
+		case "":
+			return fmt.Errorf("unclosed %s", open)
+		}
+	}
+}
+
+// peek returns the next token without consuming it, "" at the end
+func (p *graphQLParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+// next consumes the next token, "" at the end
+func (p *graphQLParser) next() string {
+	token := p.peek()
+	if token != "" {
+		p.pos++
+	}
+	return token
+}
+
+// isGraphQLName reports whether a token is a name rather than punctuation or a value
+func isGraphQLName(token string) bool {
+	return token != "" && (token[0] == '_' || unicode.IsLetter(rune(token[0])))
+}
+
+// lexGraphQL splits a document into names, punctuators and values; commas, whitespace and
+// comments are insignificant. Strings keep their quotes, so they are never taken for names.
+func lexGraphQL(source string) ([]string, error) {
+	var tokens []string
+	source = strings.TrimPrefix(source, "\ufeff")
+	for i := 0; i < len(source); {
+		c := source[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+		case c == '#':
+			for i < len(source) && source[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(source[i:], "..."):
+			tokens = append(tokens, "...")
+			i += 3
+		case strings.HasPrefix(source[i:], `"""`):
+			end := strings.Index(source[i+3:], `"""`)
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated block string")
+			}
+			tokens = append(tokens, source[i:i+end+6])
+			i += end + 6
+		case c == '"':
+			j := i + 1
+			for j < len(source) && source[j] != '"' {
+				if source[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(source) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, source[i:j+1])
+			i = j + 1
+		case strings.ContainsRune("{}()[]:!$@=|&", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			j := i
+			for j < len(source) && (source[j] == '_' || source[j] == '-' || source[j] == '.' || source[j] == '+' ||
+				unicode.IsLetter(rune(source[j])) || unicode.IsDigit(rune(source[j]))) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character %q", c)
+			}
+			tokens = append(tokens, source[i:j])
+			i = j
+		}
+	}
+	return tokens, nil
+}
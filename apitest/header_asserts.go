@@ -0,0 +1,56 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// validateHeaders checks expected_headers, expected_content_length and expected_allow
+func validateHeaders(testCase TestCase, resp *http.Response) []assertionError {
+	var errors []assertionError
+
+	for name, expected := range testCase.ExpectedHeaders {
+		values, ok := resp.Header[http.CanonicalHeaderKey(name)]
+		if !ok {
+			errors = append(errors, assertionError{CategoryHeader,
+				fmt.Sprintf("Header %s: Not found in response", name)})
+			continue
+		}
+		if actual := strings.Join(values, ", "); actual != expected {
+			errors = append(errors, assertionError{CategoryHeader,
+				fmt.Sprintf("Header %s: Expected '%s', got '%s'", name, expected, actual)})
+		}
+	}
+
+	if testCase.ExpectedContentLength != nil {
+		// HEAD responses describe the length of the body a GET would return
+		actual := resp.Header.Get("Content-Length")
+		if actual != strconv.FormatInt(*testCase.ExpectedContentLength, 10) {
+			if actual == "" {
+				actual = "none"
+			}
+			errors = append(errors, assertionError{CategoryHeader,
+				fmt.Sprintf("Content-Length: Expected %d, got %s", *testCase.ExpectedContentLength, actual)})
+		}
+	}
+
+	if testCase.ExpectedAllow != nil {
+		allowed := make(map[string]bool)
+		for _, value := range resp.Header.Values("Allow") {
+			for _, method := range strings.Split(value, ",") {
+				allowed[strings.ToUpper(strings.TrimSpace(method))] = true
+			}
+		}
+		for _, method := range testCase.ExpectedAllow {
+			if !allowed[strings.ToUpper(method)] {
+				errors = append(errors, assertionError{CategoryHeader,
+					fmt.Sprintf("Allow: Expected %s to be allowed, got '%s'",
+						strings.ToUpper(method), strings.Join(resp.Header.Values("Allow"), ", "))})
+			}
+		}
+	}
+
+	return errors
+}
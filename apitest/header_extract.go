@@ -0,0 +1,132 @@
+package apitest
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderExtractPrefix starts an extract path that reads a response header instead of the body,
+// e.g. "header:Link.next" or "header:X-Total-Count"
+const HeaderExtractPrefix = "header:"
+
+// extractHeader evaluates a header: extract path. The header is parsed into a typed value first:
+// Link into an object of URLs by relation, Retry-After into seconds, integers into numbers, and a
+// repeated header into an array. The rest of the path and any transforms apply to that value.
+func extractHeader(resp *http.Response, expression string) (interface{}, error) {
+	spec := strings.TrimSpace(strings.TrimPrefix(expression, HeaderExtractPrefix))
+	name := spec
+	if end := strings.IndexAny(spec, ".|[ "); end >= 0 {
+		name = spec[:end]
+	}
+	if resp == nil {
+		return nil, fmt.Errorf("Header %s: only HTTP responses have headers", name)
+	}
+	values := resp.Header.Values(name)
+	if len(values) == 0 {
+		return nil, noValueError(fmt.Sprintf("Header %s not found", name))
+	}
+
+	var parsed interface{}
+	switch http.CanonicalHeaderKey(name) {
+	case "Link":
+		var base *url.URL
+		if resp.Request != nil {
+			base = resp.Request.URL
+		}
+		links, err := parseLinkHeader(values, base)
+		if err != nil {
+			return nil, fmt.Errorf("Header %s: %w", name, err)
+		}
+		parsed = links
+	case "Retry-After":
+		delay, ok := parseRetryAfter(values[0], time.Now())
+		if !ok {
+			return nil, fmt.Errorf("Header %s: %q is neither seconds nor an HTTP date", name, values[0])
+		}
+		parsed = math.Ceil(delay.Seconds())
+	default:
+		if len(values) == 1 {
+			parsed = headerScalar(values[0])
+		} else {
+			items := make([]interface{}, len(values))
+			for i, value := range values {
+				items[i] = headerScalar(value)
+			}
+			parsed = items
+		}
+	}
+	return extractValue(map[string]interface{}{name: parsed}, spec)
+}
+
+// headerScalar turns an integer header value into a number, so it compares and counts like one
+func headerScalar(value string) interface{} {
+	value = strings.TrimSpace(value)
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil && strconv.FormatInt(n, 10) == value && n >= -1<<53 && n <= 1<<53 {
+		return float64(n)
+	}
+	return value
+}
+
+// parseLinkHeader parses RFC 8288 (formerly RFC 5988) Link headers into the target URL of each
+// relation, e.g. {"next": "https://api.example.com/items?page=3", "last": ...}. Relative targets
+// are resolved against the request URL; the first link of a relation wins.
+func parseLinkHeader(values []string, base *url.URL) (map[string]interface{}, error) {
+	links := make(map[string]interface{})
+	for _, value := range values {
+		for _, link := range splitOutsideQuotes(value, ',') {
+			link = strings.TrimSpace(link)
+			if link == "" {
+				continue
+			}
+			if !strings.HasPrefix(link, "<") || !strings.Contains(link, ">") {
+				return nil, fmt.Errorf("link %q must start with <target>", link)
+			}
+			target, params, _ := strings.Cut(link[1:], ">")
+			if base != nil {
+				if ref, err := base.Parse(target); err == nil {
+					target = ref.String()
+				}
+			}
+			for _, param := range splitOutsideQuotes(params, ';') {
+				key, rel, _ := strings.Cut(strings.TrimSpace(param), "=")
+				if !strings.EqualFold(strings.TrimSpace(key), "rel") {
+					continue
+				}
+				// A link may have several space-separated relations, e.g. rel="last end"
+				for _, relation := range strings.Fields(strings.Trim(strings.TrimSpace(rel), `"`)) {
+					relation = strings.ToLower(relation)
+					if _, seen := links[relation]; !seen {
+						links[relation] = target
+					}
+				}
+			}
+		}
+	}
+	return links, nil
+}
+
+// splitOutsideQuotes splits s at sep, except inside double quotes or a <target>
+func splitOutsideQuotes(s string, sep byte) []string {
+	var parts []string
+	inQuotes, inTarget := false, false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '"' && !inTarget:
+			inQuotes = !inQuotes
+		case c == '<' && !inQuotes:
+			inTarget = true
+		case c == '>' && !inQuotes:
+			inTarget = false
+		case c == sep && !inQuotes && !inTarget:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
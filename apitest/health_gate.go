@@ -0,0 +1,87 @@
+package apitest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// DefaultWaitTimeout bounds -wait-healthy without -wait-timeout
+const DefaultWaitTimeout = 120 * time.Second
+
+// Readiness checks start frequent, so a service that is already up costs no wait, and back off
+// to maxWaitInterval for one that takes its time
+const (
+	firstWaitInterval = 250 * time.Millisecond
+	maxWaitInterval   = 5 * time.Second
+	waitCheckTimeout  = 10 * time.Second // for one check, so a hanging endpoint is retried
+)
+
+// WaitHealthy polls a readiness URL with GET until it answers 2xx, so a freshly deployed service
+// can finish starting before the suite runs. A relative URL is joined to the base URL, and the
+// run's proxy, TLS and socket settings apply, so it is reached the way the tests reach the API.
+// It fails once timeout has passed, reporting the last answer or error.
+func (t *APITester) WaitHealthy(target string, timeout time.Duration) error {
+	target = t.replaceVariables(target)
+	if t.BaseURL != "" && !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") &&
+		!strings.HasPrefix(target, UnixScheme) {
+		target = t.BaseURL + target
+	}
+	if _, err := url.Parse(target); err != nil {
+		return fmt.Errorf("invalid readiness url: %w", err)
+	}
+	if strings.HasPrefix(target, UnixScheme) {
+		t.enableUnixSockets()
+	}
+
+	fmt.Fprintf(t.out, "%s↻ Waiting for %s to become healthy (up to %s)%s\n", ColorCyan, target, timeout, ColorReset)
+	ctx, cancel := context.WithTimeout(t.Context, timeout)
+	defer cancel()
+	start := time.Now()
+	interval := firstWaitInterval
+	var last error
+	for checks := 1; ; checks++ {
+		if last = t.checkHealthy(ctx, target); last == nil {
+			fmt.Fprintf(t.out, "%s✓ Healthy after %s (%d checks)%s\n", ColorGreen, time.Since(start).Round(time.Millisecond), checks, ColorReset)
+			return nil
+		}
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			if t.Context.Err() != nil {
+				return t.Context.Err()
+			}
+			return fmt.Errorf("%s not healthy after %s: %w", target, timeout, last)
+		}
+		interval = min(interval*2, maxWaitInterval)
+	}
+}
+
+// checkHealthy sends one readiness check, returning nil for a 2xx answer
+func (t *APITester) checkHealthy(ctx context.Context, target string) error {
+	ctx, cancel := context.WithTimeout(ctx, waitCheckTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.HTTPClient.Do(req)
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("last answer was HTTP %d", resp.StatusCode)
+	}
+	return nil
+}
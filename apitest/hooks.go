@@ -0,0 +1,116 @@
+package apitest
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultHookTimeout bounds how long a setup or teardown hook may run
+const DefaultHookTimeout = 60 * time.Second
+
+// envNameInvalidChars matches characters not allowed in environment variable names
+var envNameInvalidChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// newRunID returns a unique identifier for a run, sortable by start time
+func newRunID() string {
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix)
+}
+
+// hookEnv builds the environment passed to hooks: the current environment plus run state
+func (t *APITester) hookEnv(testCase TestCase, result *TestResult) []string {
+	env := append(os.Environ(),
+		"APITEST_RUN_ID="+t.RunID,
+		"APITEST_CONFIG="+t.ConfigPath,
+		"APITEST_BASE_URL="+t.BaseURL,
+		"APITEST_TEST_NAME="+testCase.TestCaseName,
+		"APITEST_TEST_ORDER="+strconv.Itoa(testCase.Order),
+	)
+	for name, value := range t.Variables {
+		env = append(env, fmt.Sprintf("%s=%v", variableEnvName(name), value))
+	}
+	if result != nil {
+		env = append(env,
+			"APITEST_STATUS="+result.Status,
+			"APITEST_STATUS_CODE="+strconv.Itoa(result.ResponseStatusCode))
+	}
+	return env
+}
+
+// variableEnvName names the environment variable hookEnv passes a variable in, e.g.
+// APITEST_VAR_USER_ID for user-id
+func variableEnvName(name string) string {
+	return "APITEST_VAR_" + strings.ToUpper(envNameInvalidChars.ReplaceAllString(name, "_"))
+}
+
+// shellVariables replaces the {{variable}} placeholders of a command line with references to
+// their APITEST_VAR_* environment variables, so the shell never parses a value and one taken
+// from a response can't inject commands. {{prefix:key}} placeholders, written in the config,
+// are resolved in place.
+func (t *APITester) shellVariables(command string) string {
+	return placeholderPattern.ReplaceAllStringFunc(command, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-2]
+		if _, ok := t.Variables[name]; !ok {
+			return t.resolvePlaceholders(placeholder)
+		}
+		if runtime.GOOS == "windows" {
+			// Delayed expansion, which shellCommand turns on, happens after cmd parses the line
+			return "!" + variableEnvName(name) + "!"
+		}
+		// Quoted, so the value isn't split or globbed either, even inside a double-quoted string
+		return `"${` + variableEnvName(name) + `}"`
+	})
+}
+
+// runHook runs a shell hook with the run state in its environment, printing its output
+func (t *APITester) runHook(kind, command string, testCase TestCase, result *TestResult) error {
+	command = t.shellVariables(command)
+	fmt.Fprintf(t.out, "  %s⚙ %s: %s%s\n", ColorCyan, kind, t.maskSecrets(command), ColorReset)
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultHookTimeout)
+	defer cancel()
+
+	cmd := shellCommand(ctx, command)
+	cmd.Env = t.hookEnv(testCase, result)
+
+	output, err := cmd.CombinedOutput()
+	for _, line := range strings.Split(strings.TrimRight(string(output), "\n"), "\n") {
+		if line != "" {
+			fmt.Fprintf(t.out, "    %s│ %s%s\n", ColorCyan, t.maskSecrets(line), ColorReset)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %w", kind, err)
+	}
+	return nil
+}
+
+// shellCommand runs a command line through the platform's shell
+func shellCommand(ctx context.Context, command string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/V:ON", "/C", command)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", command)
+}
+
+// runTeardown runs the teardown hook after a test, failing the test if the hook fails
+func (t *APITester) runTeardown(testCase TestCase, result *TestResult) {
+	if testCase.Teardown == "" {
+		return
+	}
+	if err := t.runHook("teardown", testCase.Teardown, testCase, result); err != nil {
+		result.addError(CategoryHook, err.Error())
+		result.Status = StatusFailed
+		fmt.Fprintf(t.out, "  %s✗ FAILED - %v%s\n", ColorRed, err, ColorReset)
+	}
+}
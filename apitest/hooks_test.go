@@ -0,0 +1,59 @@
+package apitest
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// injectionServer answers every request with a name that runs a command if a shell parses it,
+// and a secret token
+func injectionServer(t *testing.T, marker string) *httptest.Server {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands are POSIX shell")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "x; touch ` + marker + `; $(touch ` + marker + `)", "token": "s3cr3t-t0ken"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHookVariablesAreNotParsedByTheShell(t *testing.T) {
+	dir := t.TempDir()
+	marker, written := filepath.Join(dir, "pwned"), filepath.Join(dir, "name")
+	server := injectionServer(t, marker)
+	tester := newTestTester(t, server.URL, `{"test_case": [
+		{"test_case_name": "Get", "order": 1, "method": "GET", "api": "/",
+			"extract": {"name": "name", "token": {"path": "token", "secret": true}}},
+		{"test_case_name": "Hook", "order": 2, "method": "GET", "api": "/",
+			"setup": "printf '%s' {{name}} > `+written+` && echo token={{token}}"}
+	]}`)
+	var out bytes.Buffer
+	tester.out = &out
+	tester.RunAllTests()
+
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("the extracted value ran as a command")
+	}
+	got, err := os.ReadFile(written)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "x; touch " + marker + "; $(touch " + marker + ")"; string(got) != want {
+		t.Errorf("hook got %q, want %q", got, want)
+	}
+	if strings.Contains(out.String(), "s3cr3t-t0ken") {
+		t.Errorf("hook output shows the secret:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "token="+SecretMask) {
+		t.Errorf("hook output doesn't show the masked secret:\n%s", out.String())
+	}
+}
@@ -0,0 +1,82 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+)
+
+// IP versions a test can be forced to connect over; "any" lets the dialer choose (the default)
+const (
+	IPVersion4   = "4"
+	IPVersion6   = "6"
+	IPVersionAny = "any"
+)
+
+// ipNetworks maps forced IP versions to the network the dialer uses
+var ipNetworks = map[string]string{IPVersion4: "tcp4", IPVersion6: "tcp6"}
+
+// ipVersionKey carries a request's forced network through its context to the dialer
+type ipVersionKey struct{}
+
+// checkIPVersion validates an ip_version value
+func checkIPVersion(version string) error {
+	if _, ok := ipNetworks[version]; !ok && version != "" && version != IPVersionAny {
+		return fmt.Errorf("ip_version %q must be 4, 6 or any", version)
+	}
+	return nil
+}
+
+// ipVersionFor returns the IP version a test is forced to: its own, or the run's
+func (t *APITester) ipVersionFor(testCase TestCase) string {
+	if testCase.IPVersion != "" {
+		return testCase.IPVersion
+	}
+	return t.IPVersion
+}
+
+// withIPVersion marks a request context with the network its connection must use
+func withIPVersion(ctx context.Context, version string) context.Context {
+	if network, ok := ipNetworks[version]; ok {
+		return context.WithValue(ctx, ipVersionKey{}, network)
+	}
+	return ctx
+}
+
+// ipVersionTransport sends requests forced to an IP version through a transport of their own,
+// so pooled connections of one family are never reused for the other
+type ipVersionTransport struct {
+	base     http.RoundTripper
+	families map[string]http.RoundTripper
+}
+
+// RoundTrip picks the transport for the request's forced network
+func (t *ipVersionTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if network, ok := req.Context().Value(ipVersionKey{}).(string); ok {
+		return t.families[network].RoundTrip(req)
+	}
+	return t.base.RoundTrip(req)
+}
+
+// enableIPVersions routes requests forced to an IP version through transports that only dial
+// that family, keeping any dialer already installed (such as DNS pinning)
+func (t *APITester) enableIPVersions() {
+	if _, ok := t.HTTPClient.Transport.(*ipVersionTransport); ok {
+		return
+	}
+	base := t.baseTransport()
+	families := make(map[string]http.RoundTripper)
+	for _, network := range ipNetworks {
+		transport := base.Clone()
+		dial, forced := transport.DialContext, network
+		transport.DialContext = func(ctx context.Context, network, address string) (net.Conn, error) {
+			if network == "tcp" {
+				network = forced
+			}
+			return dial(ctx, network, address)
+		}
+		families[network] = transport
+	}
+	t.HTTPClient.Transport = &ipVersionTransport{base: base, families: families}
+}
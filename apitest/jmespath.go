@@ -0,0 +1,1525 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// JMESPathPrefix selects JMESPath instead of dot paths for one expression, e.g.
+// "jmespath:items[?status=='active'] | length(@)"
+const JMESPathPrefix = "jmespath:"
+
+// lookupPath evaluates a response path: a JMESPath expression when prefixed, a dot path otherwise
+func lookupPath(data interface{}, path string) (interface{}, error) {
+	if expression, ok := strings.CutPrefix(path, JMESPathPrefix); ok {
+		return searchJMESPath(expression, data)
+	}
+	return getNestedValue(data, path), nil
+}
+
+// searchJMESPath compiles and evaluates a JMESPath expression against a value
+func searchJMESPath(expression string, data interface{}) (interface{}, error) {
+	node, err := compileJMESPath(expression)
+	if err != nil {
+		return nil, err
+	}
+	result, err := node.eval(data)
+	if err != nil {
+		return nil, fmt.Errorf("JMESPath %q: %w", expression, err)
+	}
+	return result, nil
+}
+
+// compileJMESPath parses a JMESPath expression, so a config's expressions can be checked up front
+func compileJMESPath(expression string) (*jpNode, error) {
+	return compileJMESPathWith(expression, nil)
+}
+
+// compileJMESPathWith parses an expression that may also call the given functions, e.g. the
+// arithmetic of compute steps, besides the standard ones
+func compileJMESPathWith(expression string, functions map[string]jpFunction) (*jpNode, error) {
+	tokens, err := lexJMESPath(expression)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JMESPath %q: %w", expression, err)
+	}
+	parser := &jpParser{tokens: tokens, functions: functions}
+	node, err := parser.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid JMESPath %q: %w", expression, err)
+	}
+	return node, nil
+}
+
+// jpTokenKind identifies a JMESPath token
+type jpTokenKind int
+
+const (
+	jpEOF jpTokenKind = iota
+	jpIdentifier
+	jpQuotedIdentifier
+	jpRawString
+	jpLiteral
+	jpInt
+	jpDot
+	jpStar
+	jpLBracket
+	jpRBracket
+	jpFilter
+	jpFlatten
+	jpLBrace
+	jpRBrace
+	jpLParen
+	jpRParen
+	jpComma
+	jpColon
+	jpPipe
+	jpOr
+	jpAnd
+	jpNot
+	jpCurrent
+	jpAmpersand
+	jpEQ
+	jpNE
+	jpLT
+	jpLTE
+	jpGT
+	jpGTE
+)
+
+// jpBindingPowers gives the precedence of tokens that continue an expression
+var jpBindingPowers = map[jpTokenKind]int{
+	jpPipe:     1,
+	jpOr:       2,
+	jpAnd:      3,
+	jpEQ:       5,
+	jpNE:       5,
+	jpLT:       5,
+	jpLTE:      5,
+	jpGT:       5,
+	jpGTE:      5,
+	jpFlatten:  9,
+	jpStar:     20,
+	jpFilter:   21,
+	jpDot:      40,
+	jpNot:      45,
+	jpLBrace:   50,
+	jpLBracket: 55,
+	jpLParen:   60,
+}
+
+// jpToken is one lexed token; value holds identifiers, strings, literals and numbers
+type jpToken struct {
+	kind     jpTokenKind
+	value    interface{}
+	position int
+}
+
+// jpSimpleTokens maps single-character tokens to their kind
+var jpSimpleTokens = map[byte]jpTokenKind{
+	'.': jpDot,
+	'*': jpStar,
+	']': jpRBracket,
+	'{': jpLBrace,
+	'}': jpRBrace,
+	'(': jpLParen,
+	')': jpRParen,
+	',': jpComma,
+	':': jpColon,
+	'@': jpCurrent,
+}
+
+// lexJMESPath splits an expression into tokens
+func lexJMESPath(expression string) ([]jpToken, error) {
+	var tokens []jpToken
+	for i := 0; i < len(expression); {
+		c := expression[i]
+		start := i
+		if kind, ok := jpSimpleTokens[c]; ok {
+			tokens = append(tokens, jpToken{kind: kind, position: start})
+			i++
+			continue
+		}
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '_' || c < utf8.RuneSelf && unicode.IsLetter(rune(c)):
+			for i < len(expression) && (expression[i] == '_' || expression[i] < utf8.RuneSelf &&
+				(unicode.IsLetter(rune(expression[i])) || unicode.IsDigit(rune(expression[i])))) {
+				i++
+			}
+			tokens = append(tokens, jpToken{kind: jpIdentifier, value: expression[start:i], position: start})
+		case c == '-' || c >= '0' && c <= '9':
+			i++
+			for i < len(expression) && expression[i] >= '0' && expression[i] <= '9' {
+				i++
+			}
+			number, err := strconv.Atoi(expression[start:i])
+			if err != nil {
+				return nil, fmt.Errorf("invalid number at %d", start)
+			}
+			tokens = append(tokens, jpToken{kind: jpInt, value: number, position: start})
+		case c == '[':
+			kind := jpLBracket
+			if strings.HasPrefix(expression[i:], "[?") {
+				kind = jpFilter
+			} else if strings.HasPrefix(expression[i:], "[]") {
+				kind = jpFlatten
+			}
+			if kind != jpLBracket {
+				i++
+			}
+			i++
+			tokens = append(tokens, jpToken{kind: kind, position: start})
+		case c == '"', c == '\'', c == '`':
+			end, err := jpDelimited(expression, i, c)
+			if err != nil {
+				return nil, err
+			}
+			token, err := jpQuotedToken(expression[i+1:end], c, start)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token)
+			i = end + 1
+		default:
+			kind, width := jpOperator(expression[i:])
+			if width == 0 {
+				return nil, fmt.Errorf("unexpected character %q at %d", c, start)
+			}
+			tokens = append(tokens, jpToken{kind: kind, position: start})
+			i += width
+		}
+	}
+	return append(tokens, jpToken{kind: jpEOF, position: len(expression)}), nil
+}
+
+// jpDelimited returns the index of the delimiter closing the string starting at start, skipping escapes
+func jpDelimited(expression string, start int, delimiter byte) (int, error) {
+	for i := start + 1; i < len(expression); i++ {
+		switch expression[i] {
+		case '\\':
+			i++
+		case delimiter:
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("unterminated %c at %d", delimiter, start)
+}
+
+// jpQuotedToken decodes a quoted identifier, raw string or JSON literal
+func jpQuotedToken(content string, delimiter byte, position int) (jpToken, error) {
+	switch delimiter {
+	case '"':
+		var name string
+		if err := json.Unmarshal([]byte(`"`+content+`"`), &name); err != nil {
+			return jpToken{}, fmt.Errorf("invalid quoted identifier at %d", position)
+		}
+		return jpToken{kind: jpQuotedIdentifier, value: name, position: position}, nil
+	case '\'':
+		return jpToken{kind: jpRawString, value: strings.ReplaceAll(content, `\'`, `'`), position: position}, nil
+	default:
+		var literal interface{}
+		if err := json.Unmarshal([]byte(strings.ReplaceAll(content, "\\`", "`")), &literal); err != nil {
+			return jpToken{}, fmt.Errorf("invalid literal at %d: %v", position, err)
+		}
+		return jpToken{kind: jpLiteral, value: literal, position: position}, nil
+	}
+}
+
+// jpOperator lexes comparison and logical operators, returning the width consumed
+func jpOperator(rest string) (jpTokenKind, int) {
+	for _, op := range []struct {
+		text string
+		kind jpTokenKind
+	}{
+		{"==", jpEQ}, {"!=", jpNE}, {"<=", jpLTE}, {">=", jpGTE}, {"||", jpOr}, {"&&", jpAnd},
+		{"<", jpLT}, {">", jpGT}, {"!", jpNot}, {"|", jpPipe}, {"&", jpAmpersand},
+	} {
+		if strings.HasPrefix(rest, op.text) {
+			return op.kind, len(op.text)
+		}
+	}
+	return jpEOF, 0
+}
+
+// jpNodeKind identifies an AST node
+type jpNodeKind int
+
+const (
+	jpNodeIdentity jpNodeKind = iota
+	jpNodeField
+	jpNodeLiteral
+	jpNodeSubexpression
+	jpNodeIndex
+	jpNodeSlice
+	jpNodeProjection
+	jpNodeValueProjection
+	jpNodeFilterProjection
+	jpNodeFlatten
+	jpNodeComparator
+	jpNodeOr
+	jpNodeAnd
+	jpNodeNot
+	jpNodePipe
+	jpNodeMultiSelectList
+	jpNodeMultiSelectHash
+	jpNodeFunction
+	jpNodeExpref
+)
+
+// jpNode is a parsed expression; value holds field names, literals, indices, operators and function names
+type jpNode struct {
+	kind     jpNodeKind
+	value    interface{}
+	keys     []string
+	children []*jpNode
+	// function is the function a function node calls
+	function jpFunction
+}
+
+// jpParser is a top-down operator precedence parser over the lexed tokens; functions are
+// callable in addition to jpFunctions
+type jpParser struct {
+	tokens    []jpToken
+	position  int
+	functions map[string]jpFunction
+}
+
+// parse parses the whole expression
+func (p *jpParser) parse() (*jpNode, error) {
+	node, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != jpEOF {
+		return nil, p.unexpected(p.peek())
+	}
+	return node, nil
+}
+
+// peek returns the next token without consuming it
+func (p *jpParser) peek() jpToken {
+	return p.tokens[p.position]
+}
+
+// peekAt returns the token offset places after the next one
+func (p *jpParser) peekAt(offset int) jpToken {
+	if p.position+offset >= len(p.tokens) {
+		return p.tokens[len(p.tokens)-1]
+	}
+	return p.tokens[p.position+offset]
+}
+
+// next consumes the next token; the end token is never consumed
+func (p *jpParser) next() jpToken {
+	token := p.tokens[p.position]
+	if token.kind != jpEOF {
+		p.position++
+	}
+	return token
+}
+
+// expect consumes a token of the given kind
+func (p *jpParser) expect(kind jpTokenKind) error {
+	if token := p.next(); token.kind != kind {
+		return p.unexpected(token)
+	}
+	return nil
+}
+
+// unexpected describes a token the grammar doesn't allow where it appeared
+func (p *jpParser) unexpected(token jpToken) error {
+	if token.kind == jpEOF {
+		return fmt.Errorf("unexpected end of expression")
+	}
+	return fmt.Errorf("unexpected token at %d", token.position)
+}
+
+// expression parses operators binding tighter than bindingPower
+func (p *jpParser) expression(bindingPower int) (*jpNode, error) {
+	left, err := p.nud(p.next())
+	if err != nil {
+		return nil, err
+	}
+	for bindingPower < jpBindingPowers[p.peek().kind] {
+		if left, err = p.led(p.next(), left); err != nil {
+			return nil, err
+		}
+	}
+	return left, nil
+}
+
+// nud parses a token that starts an expression
+func (p *jpParser) nud(token jpToken) (*jpNode, error) {
+	identity := &jpNode{kind: jpNodeIdentity}
+	switch token.kind {
+	case jpLiteral, jpRawString:
+		return &jpNode{kind: jpNodeLiteral, value: token.value}, nil
+	case jpIdentifier:
+		return &jpNode{kind: jpNodeField, value: token.value}, nil
+	case jpQuotedIdentifier:
+		if p.peek().kind == jpLParen {
+			return nil, fmt.Errorf("quoted identifier at %d can't name a function", token.position)
+		}
+		return &jpNode{kind: jpNodeField, value: token.value}, nil
+	case jpCurrent:
+		return identity, nil
+	case jpStar:
+		right, err := p.projectionRHS(jpBindingPowers[jpStar])
+		if err != nil {
+			return nil, err
+		}
+		return &jpNode{kind: jpNodeValueProjection, children: []*jpNode{identity, right}}, nil
+	case jpFilter:
+		return p.filter(identity)
+	case jpFlatten:
+		return p.flatten(identity)
+	case jpLBrace:
+		return p.multiSelectHash()
+	case jpLBracket:
+		switch next := p.peek().kind; {
+		case next == jpInt || next == jpColon:
+			return p.indexOrSlice(identity)
+		case next == jpStar && p.peekAt(1).kind == jpRBracket:
+			p.next()
+			p.next()
+			return p.projection(identity)
+		}
+		return p.multiSelectList()
+	case jpAmpersand:
+		child, err := p.expression(jpBindingPowers[jpAmpersand])
+		if err != nil {
+			return nil, err
+		}
+		return &jpNode{kind: jpNodeExpref, children: []*jpNode{child}}, nil
+	case jpNot:
+		child, err := p.expression(jpBindingPowers[jpNot])
+		if err != nil {
+			return nil, err
+		}
+		return &jpNode{kind: jpNodeNot, children: []*jpNode{child}}, nil
+	case jpLParen:
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		return child, p.expect(jpRParen)
+	}
+	return nil, p.unexpected(token)
+}
+
+// led parses a token that continues the expression on its left
+func (p *jpParser) led(token jpToken, left *jpNode) (*jpNode, error) {
+	binary := func(kind jpNodeKind, value interface{}) (*jpNode, error) {
+		right, err := p.expression(jpBindingPowers[token.kind])
+		if err != nil {
+			return nil, err
+		}
+		return &jpNode{kind: kind, value: value, children: []*jpNode{left, right}}, nil
+	}
+
+	switch token.kind {
+	case jpDot:
+		if p.peek().kind == jpStar {
+			p.next()
+			right, err := p.projectionRHS(jpBindingPowers[jpDot])
+			if err != nil {
+				return nil, err
+			}
+			return &jpNode{kind: jpNodeValueProjection, children: []*jpNode{left, right}}, nil
+		}
+		right, err := p.dotRHS(jpBindingPowers[jpDot])
+		if err != nil {
+			return nil, err
+		}
+		return &jpNode{kind: jpNodeSubexpression, children: []*jpNode{left, right}}, nil
+	case jpPipe:
+		return binary(jpNodePipe, nil)
+	case jpOr:
+		return binary(jpNodeOr, nil)
+	case jpAnd:
+		return binary(jpNodeAnd, nil)
+	case jpEQ, jpNE, jpLT, jpLTE, jpGT, jpGTE:
+		return binary(jpNodeComparator, token.kind)
+	case jpLParen:
+		if left.kind != jpNodeField {
+			return nil, fmt.Errorf("invalid function call at %d", token.position)
+		}
+		return p.function(left.value.(string))
+	case jpFilter:
+		return p.filter(left)
+	case jpFlatten:
+		return p.flatten(left)
+	case jpLBracket:
+		if next := p.peek().kind; next == jpInt || next == jpColon {
+			return p.indexOrSlice(left)
+		}
+		if err := p.expect(jpStar); err != nil {
+			return nil, err
+		}
+		if err := p.expect(jpRBracket); err != nil {
+			return nil, err
+		}
+		return p.projection(left)
+	}
+	return nil, p.unexpected(token)
+}
+
+// projection parses the right side of a list projection over left
+func (p *jpParser) projection(left *jpNode) (*jpNode, error) {
+	right, err := p.projectionRHS(jpBindingPowers[jpStar])
+	if err != nil {
+		return nil, err
+	}
+	return &jpNode{kind: jpNodeProjection, children: []*jpNode{left, right}}, nil
+}
+
+// flatten parses a [] flatten of left and the projection that follows it
+func (p *jpParser) flatten(left *jpNode) (*jpNode, error) {
+	right, err := p.projectionRHS(jpBindingPowers[jpFlatten])
+	if err != nil {
+		return nil, err
+	}
+	flattened := &jpNode{kind: jpNodeFlatten, children: []*jpNode{left}}
+	return &jpNode{kind: jpNodeProjection, children: []*jpNode{flattened, right}}, nil
+}
+
+// filter parses a [?condition] filter projection over left
+func (p *jpParser) filter(left *jpNode) (*jpNode, error) {
+	condition, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(jpRBracket); err != nil {
+		return nil, err
+	}
+	right := &jpNode{kind: jpNodeIdentity}
+	if p.peek().kind != jpFlatten {
+		if right, err = p.projectionRHS(jpBindingPowers[jpFilter]); err != nil {
+			return nil, err
+		}
+	}
+	return &jpNode{kind: jpNodeFilterProjection, children: []*jpNode{left, right, condition}}, nil
+}
+
+// indexOrSlice parses [n] or [start:stop:step] after the opening bracket
+func (p *jpParser) indexOrSlice(left *jpNode) (*jpNode, error) {
+	var parts [3]*int
+	part := 0
+	for p.peek().kind != jpRBracket {
+		token := p.next()
+		switch {
+		case token.kind == jpInt && parts[part] == nil:
+			number := token.value.(int)
+			parts[part] = &number
+		case token.kind == jpColon && part < 2:
+			part++
+		default:
+			return nil, p.unexpected(token)
+		}
+	}
+	p.next()
+
+	if part == 0 {
+		if parts[0] == nil {
+			return nil, fmt.Errorf("empty index")
+		}
+		index := &jpNode{kind: jpNodeIndex, value: *parts[0]}
+		return &jpNode{kind: jpNodeSubexpression, children: []*jpNode{left, index}}, nil
+	}
+	slice := &jpNode{kind: jpNodeSlice, value: parts}
+	sliced := &jpNode{kind: jpNodeSubexpression, children: []*jpNode{left, slice}}
+	return p.projection(sliced)
+}
+
+// multiSelectList parses [expr, ...] after the opening bracket
+func (p *jpParser) multiSelectList() (*jpNode, error) {
+	node := &jpNode{kind: jpNodeMultiSelectList}
+	for {
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+		if p.peek().kind == jpRBracket {
+			p.next()
+			return node, nil
+		}
+		if err := p.expect(jpComma); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// multiSelectHash parses {key: expr, ...} after the opening brace
+func (p *jpParser) multiSelectHash() (*jpNode, error) {
+	node := &jpNode{kind: jpNodeMultiSelectHash}
+	for {
+		key := p.next()
+		if key.kind != jpIdentifier && key.kind != jpQuotedIdentifier {
+			return nil, p.unexpected(key)
+		}
+		if err := p.expect(jpColon); err != nil {
+			return nil, err
+		}
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		node.keys = append(node.keys, key.value.(string))
+		node.children = append(node.children, child)
+		if p.peek().kind == jpRBrace {
+			p.next()
+			return node, nil
+		}
+		if err := p.expect(jpComma); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// function parses the arguments of a function call after the opening parenthesis
+func (p *jpParser) function(name string) (*jpNode, error) {
+	function, ok := p.functions[name]
+	if !ok {
+		function, ok = jpFunctions[name]
+	}
+	if !ok {
+		return nil, fmt.Errorf("unknown function %s()", name)
+	}
+	node := &jpNode{kind: jpNodeFunction, value: name, function: function}
+	for p.peek().kind != jpRParen {
+		if len(node.children) > 0 {
+			if err := p.expect(jpComma); err != nil {
+				return nil, err
+			}
+		}
+		child, err := p.expression(0)
+		if err != nil {
+			return nil, err
+		}
+		node.children = append(node.children, child)
+	}
+	p.next()
+	return node, nil
+}
+
+// dotRHS parses what may follow a dot: an identifier, a multi-select or a wildcard
+func (p *jpParser) dotRHS(bindingPower int) (*jpNode, error) {
+	switch p.peek().kind {
+	case jpIdentifier, jpQuotedIdentifier, jpStar:
+		return p.expression(bindingPower)
+	case jpLBracket:
+		p.next()
+		return p.multiSelectList()
+	case jpLBrace:
+		p.next()
+		return p.multiSelectHash()
+	}
+	return nil, p.unexpected(p.peek())
+}
+
+// projectionRHS parses the expression applied to each element of a projection
+func (p *jpParser) projectionRHS(bindingPower int) (*jpNode, error) {
+	switch next := p.peek().kind; {
+	case jpBindingPowers[next] < 10:
+		return &jpNode{kind: jpNodeIdentity}, nil
+	case next == jpLBracket || next == jpFilter:
+		return p.expression(bindingPower)
+	case next == jpDot:
+		p.next()
+		return p.dotRHS(bindingPower)
+	}
+	return nil, p.unexpected(p.peek())
+}
+
+// jpExpref is an expression passed unevaluated to functions such as sort_by
+type jpExpref struct {
+	node *jpNode
+}
+
+// eval evaluates the node against a value
+func (n *jpNode) eval(value interface{}) (interface{}, error) {
+	switch n.kind {
+	case jpNodeIdentity:
+		return value, nil
+	case jpNodeLiteral:
+		return n.value, nil
+	case jpNodeField:
+		if object, ok := value.(map[string]interface{}); ok {
+			return object[n.value.(string)], nil
+		}
+		return nil, nil
+	case jpNodeSubexpression, jpNodePipe:
+		left, err := n.children[0].eval(value)
+		if err != nil || (left == nil && n.kind == jpNodeSubexpression) {
+			return nil, err
+		}
+		return n.children[1].eval(left)
+	case jpNodeIndex:
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		index := n.value.(int)
+		if index < 0 {
+			index += len(array)
+		}
+		if index < 0 || index >= len(array) {
+			return nil, nil
+		}
+		return array[index], nil
+	case jpNodeSlice:
+		array, ok := value.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return jpSlice(array, n.value.([3]*int))
+	case jpNodeProjection:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		array, ok := left.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return jpProject(array, n.children[1], nil)
+	case jpNodeValueProjection:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		object, ok := left.(map[string]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return jpProject(jpValues(object), n.children[1], nil)
+	case jpNodeFilterProjection:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		array, ok := left.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		return jpProject(array, n.children[1], n.children[2])
+	case jpNodeFlatten:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		array, ok := left.([]interface{})
+		if !ok {
+			return nil, nil
+		}
+		flattened := []interface{}{}
+		for _, element := range array {
+			if inner, isArray := element.([]interface{}); isArray {
+				flattened = append(flattened, inner...)
+			} else {
+				flattened = append(flattened, element)
+			}
+		}
+		return flattened, nil
+	case jpNodeComparator:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		right, err := n.children[1].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		return jpCompare(n.value.(jpTokenKind), left, right), nil
+	case jpNodeOr, jpNodeAnd:
+		left, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		if jpTruthy(left) == (n.kind == jpNodeOr) {
+			return left, nil
+		}
+		return n.children[1].eval(value)
+	case jpNodeNot:
+		child, err := n.children[0].eval(value)
+		if err != nil {
+			return nil, err
+		}
+		return !jpTruthy(child), nil
+	case jpNodeMultiSelectList:
+		if value == nil {
+			return nil, nil
+		}
+		list := make([]interface{}, len(n.children))
+		for i, child := range n.children {
+			var err error
+			if list[i], err = child.eval(value); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case jpNodeMultiSelectHash:
+		if value == nil {
+			return nil, nil
+		}
+		object := make(map[string]interface{}, len(n.children))
+		for i, child := range n.children {
+			result, err := child.eval(value)
+			if err != nil {
+				return nil, err
+			}
+			object[n.keys[i]] = result
+		}
+		return object, nil
+	case jpNodeExpref:
+		return jpExpref{n.children[0]}, nil
+	case jpNodeFunction:
+		args := make([]interface{}, len(n.children))
+		for i, child := range n.children {
+			var err error
+			if args[i], err = child.eval(value); err != nil {
+				return nil, err
+			}
+		}
+		name := n.value.(string)
+		result, err := n.function(args)
+		if err != nil {
+			return nil, fmt.Errorf("%s(): %w", name, err)
+		}
+		return result, nil
+	}
+	return nil, fmt.Errorf("unknown expression")
+}
+
+// rootFields returns the fields an expression reads from the value it is evaluated against, leaving
+// out those read from the elements of a projection or by an expression reference
+func (n *jpNode) rootFields() []string {
+	if n == nil {
+		return nil
+	}
+	switch n.kind {
+	case jpNodeField:
+		return []string{n.value.(string)}
+	case jpNodeSubexpression, jpNodePipe, jpNodeProjection, jpNodeValueProjection, jpNodeFilterProjection, jpNodeFlatten:
+		return n.children[0].rootFields()
+	case jpNodeComparator, jpNodeOr, jpNodeAnd, jpNodeNot, jpNodeMultiSelectList, jpNodeMultiSelectHash, jpNodeFunction:
+		var fields []string
+		for _, child := range n.children {
+			fields = append(fields, child.rootFields()...)
+		}
+		return fields
+	}
+	return nil
+}
+
+// jpProject applies right to every element that passes the optional condition, dropping null results
+func jpProject(elements []interface{}, right, condition *jpNode) (interface{}, error) {
+	projected := []interface{}{}
+	for _, element := range elements {
+		if condition != nil {
+			matched, err := condition.eval(element)
+			if err != nil {
+				return nil, err
+			}
+			if !jpTruthy(matched) {
+				continue
+			}
+		}
+		result, err := right.eval(element)
+		if err != nil {
+			return nil, err
+		}
+		if result != nil {
+			projected = append(projected, result)
+		}
+	}
+	return projected, nil
+}
+
+// jpSlice implements [start:stop:step] with Python slice semantics
+func jpSlice(array []interface{}, parts [3]*int) (interface{}, error) {
+	step := 1
+	if parts[2] != nil {
+		step = *parts[2]
+	}
+	if step == 0 {
+		return nil, fmt.Errorf("slice step can't be 0")
+	}
+	length := len(array)
+	bound := func(part *int, fallback int) int {
+		if part == nil {
+			return fallback
+		}
+		index := *part
+		if index < 0 {
+			index += length
+			if index < 0 {
+				index = 0
+				if step < 0 {
+					index = -1
+				}
+			}
+		} else if index >= length {
+			index = length
+			if step < 0 {
+				index = length - 1
+			}
+		}
+		return index
+	}
+
+	sliced := []interface{}{}
+	if step > 0 {
+		for i := bound(parts[0], 0); i < bound(parts[1], length); i += step {
+			sliced = append(sliced, array[i])
+		}
+	} else {
+		for i := bound(parts[0], length-1); i > bound(parts[1], -1); i += step {
+			sliced = append(sliced, array[i])
+		}
+	}
+	return sliced, nil
+}
+
+// jpValues returns the values of an object ordered by key, so projections are deterministic
+func jpValues(object map[string]interface{}) []interface{} {
+	keys := jpKeys(object)
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		values[i] = object[key.(string)]
+	}
+	return values
+}
+
+// jpKeys returns the sorted keys of an object
+func jpKeys(object map[string]interface{}) []interface{} {
+	names := make([]string, 0, len(object))
+	for key := range object {
+		names = append(names, key)
+	}
+	sort.Strings(names)
+	keys := make([]interface{}, len(names))
+	for i, name := range names {
+		keys[i] = name
+	}
+	return keys
+}
+
+// jpTruthy applies JMESPath truthiness: null, false and empty strings, arrays and objects are false
+func jpTruthy(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// jpNumber returns a JSON number as float64, accepting json.Number from exact decoding
+func jpNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		n, err := v.Float64()
+		return n, err == nil
+	}
+	return 0, false
+}
+
+// jpEqual compares JSON values structurally
+func jpEqual(a, b interface{}) bool {
+	if x, ok := jpNumber(a); ok {
+		y, isNumber := jpNumber(b)
+		return isNumber && x == y
+	}
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !jpEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		y, ok := b.(map[string]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for key, value := range x {
+			other, exists := y[key]
+			if !exists || !jpEqual(value, other) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// jpCompare evaluates a comparator; ordering comparisons of non-numbers yield null
+func jpCompare(op jpTokenKind, left, right interface{}) interface{} {
+	switch op {
+	case jpEQ:
+		return jpEqual(left, right)
+	case jpNE:
+		return !jpEqual(left, right)
+	}
+	x, leftOK := jpNumber(left)
+	y, rightOK := jpNumber(right)
+	if !leftOK || !rightOK {
+		return nil
+	}
+	switch op {
+	case jpLT:
+		return x < y
+	case jpLTE:
+		return x <= y
+	case jpGT:
+		return x > y
+	}
+	return x >= y
+}
+
+// jpTypeName returns the JMESPath type of a value
+func jpTypeName(value interface{}) string {
+	if _, ok := jpNumber(value); ok {
+		return "number"
+	}
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	case jpExpref:
+		return "expref"
+	}
+	return "unknown"
+}
+
+// jpFunction implements a built-in function over evaluated arguments
+type jpFunction func(args []interface{}) (interface{}, error)
+
+// jpFunctions are the built-in functions of the JMESPath specification
+var jpFunctions map[string]jpFunction
+
+// The functions table is filled in init because map and sort_by evaluate expressions that may call functions
+func init() {
+	jpFunctions = map[string]jpFunction{
+		"abs":         jpNumeric(math.Abs),
+		"ceil":        jpNumeric(math.Ceil),
+		"floor":       jpNumeric(math.Floor),
+		"avg":         jpAvg,
+		"sum":         jpSum,
+		"contains":    jpContains,
+		"starts_with": jpStrings(strings.HasPrefix),
+		"ends_with":   jpStrings(strings.HasSuffix),
+		"join":        jpJoin,
+		"keys":        jpObject(jpKeys),
+		"values":      jpObject(jpValues),
+		"length":      jpLength,
+		"map":         jpMap,
+		"max":         jpExtreme(1),
+		"min":         jpExtreme(-1),
+		"max_by":      jpExtremeBy(1),
+		"min_by":      jpExtremeBy(-1),
+		"merge":       jpMerge,
+		"not_null":    jpNotNull,
+		"reverse":     jpReverse,
+		"sort":        jpSort,
+		"sort_by":     jpSortBy,
+		"to_array":    jpToArray,
+		"to_string":   jpToString,
+		"to_number":   jpToNumber,
+		"type":        jpType,
+	}
+}
+
+// jpArgs checks the number of arguments a function received
+func jpArgs(args []interface{}, count int) error {
+	if len(args) != count {
+		return fmt.Errorf("expected %d arguments, got %d", count, len(args))
+	}
+	return nil
+}
+
+// jpInvalidType reports an argument of the wrong type
+func jpInvalidType(value interface{}, expected string) error {
+	return fmt.Errorf("expected %s, got %s", expected, jpTypeName(value))
+}
+
+// jpNumeric adapts a math function to abs, ceil and floor
+func jpNumeric(fn func(float64) float64) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 1); err != nil {
+			return nil, err
+		}
+		n, ok := jpNumber(args[0])
+		if !ok {
+			return nil, jpInvalidType(args[0], "number")
+		}
+		return fn(n), nil
+	}
+}
+
+// jpNumbers returns an array argument whose elements are all numbers
+func jpNumbers(value interface{}) ([]float64, error) {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, jpInvalidType(value, "array of numbers")
+	}
+	numbers := make([]float64, len(array))
+	for i, element := range array {
+		if numbers[i], ok = jpNumber(element); !ok {
+			return nil, jpInvalidType(element, "number")
+		}
+	}
+	return numbers, nil
+}
+
+// jpSum adds up an array of numbers
+func jpSum(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	numbers, err := jpNumbers(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return sumOf(numbers)
+}
+
+// jpAvg averages an array of numbers; an empty array yields null
+func jpAvg(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	numbers, err := jpNumbers(args[0])
+	if err != nil || len(numbers) == 0 {
+		return nil, err
+	}
+	total, _ := sumOf(numbers)
+	return total / float64(len(numbers)), nil
+}
+
+// jpContains searches an array for an element or a string for a substring
+func jpContains(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 2); err != nil {
+		return nil, err
+	}
+	switch subject := args[0].(type) {
+	case string:
+		search, ok := args[1].(string)
+		return ok && strings.Contains(subject, search), nil
+	case []interface{}:
+		for _, element := range subject {
+			if jpEqual(element, args[1]) {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+	return nil, jpInvalidType(args[0], "array or string")
+}
+
+// jpStrings adapts a string predicate to starts_with and ends_with
+func jpStrings(fn func(s, affix string) bool) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 2); err != nil {
+			return nil, err
+		}
+		subject, ok := args[0].(string)
+		if !ok {
+			return nil, jpInvalidType(args[0], "string")
+		}
+		affix, ok := args[1].(string)
+		if !ok {
+			return nil, jpInvalidType(args[1], "string")
+		}
+		return fn(subject, affix), nil
+	}
+}
+
+// jpJoin joins an array of strings with a separator
+func jpJoin(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 2); err != nil {
+		return nil, err
+	}
+	separator, ok := args[0].(string)
+	if !ok {
+		return nil, jpInvalidType(args[0], "string")
+	}
+	array, ok := args[1].([]interface{})
+	if !ok {
+		return nil, jpInvalidType(args[1], "array of strings")
+	}
+	parts := make([]string, len(array))
+	for i, element := range array {
+		if parts[i], ok = element.(string); !ok {
+			return nil, jpInvalidType(element, "string")
+		}
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// jpObject adapts a function of an object to keys and values
+func jpObject(fn func(map[string]interface{}) []interface{}) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 1); err != nil {
+			return nil, err
+		}
+		object, ok := args[0].(map[string]interface{})
+		if !ok {
+			return nil, jpInvalidType(args[0], "object")
+		}
+		return fn(object), nil
+	}
+}
+
+// jpLength counts the characters of a string or the elements of an array or object
+func jpLength(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case string:
+		return float64(utf8.RuneCountInString(v)), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	}
+	return nil, jpInvalidType(args[0], "string, array or object")
+}
+
+// jpExprefArg returns an expression reference argument
+func jpExprefArg(value interface{}) (*jpNode, error) {
+	expref, ok := value.(jpExpref)
+	if !ok {
+		return nil, jpInvalidType(value, "expression reference (&expr)")
+	}
+	return expref.node, nil
+}
+
+// jpMap applies an expression reference to every element, keeping nulls
+func jpMap(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 2); err != nil {
+		return nil, err
+	}
+	node, err := jpExprefArg(args[0])
+	if err != nil {
+		return nil, err
+	}
+	array, ok := args[1].([]interface{})
+	if !ok {
+		return nil, jpInvalidType(args[1], "array")
+	}
+	mapped := make([]interface{}, len(array))
+	for i, element := range array {
+		if mapped[i], err = node.eval(element); err != nil {
+			return nil, err
+		}
+	}
+	return mapped, nil
+}
+
+// jpSortKeys evaluates sort keys, which must be all numbers or all strings
+func jpSortKeys(keys []interface{}) (func(i, j int) int, error) {
+	if len(keys) == 0 {
+		return func(i, j int) int { return 0 }, nil
+	}
+	if _, ok := keys[0].(string); ok {
+		for _, key := range keys {
+			if _, isString := key.(string); !isString {
+				return nil, jpInvalidType(key, "string")
+			}
+		}
+		return func(i, j int) int { return strings.Compare(keys[i].(string), keys[j].(string)) }, nil
+	}
+	numbers := make([]float64, len(keys))
+	for i, key := range keys {
+		var ok bool
+		if numbers[i], ok = jpNumber(key); !ok {
+			return nil, jpInvalidType(key, "number or string")
+		}
+	}
+	return func(i, j int) int {
+		switch {
+		case numbers[i] < numbers[j]:
+			return -1
+		case numbers[i] > numbers[j]:
+			return 1
+		}
+		return 0
+	}, nil
+}
+
+// jpKeysBy evaluates an optional expression reference against each element
+func jpKeysBy(array []interface{}, expref interface{}) ([]interface{}, error) {
+	if expref == nil {
+		return array, nil
+	}
+	node, err := jpExprefArg(expref)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]interface{}, len(array))
+	for i, element := range array {
+		if keys[i], err = node.eval(element); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// jpSorted returns the array ordered by the keys of its elements
+func jpSorted(array []interface{}, expref interface{}) ([]interface{}, error) {
+	keys, err := jpKeysBy(array, expref)
+	if err != nil {
+		return nil, err
+	}
+	compare, err := jpSortKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	order := make([]int, len(array))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return compare(order[i], order[j]) < 0 })
+	sorted := make([]interface{}, len(array))
+	for i, index := range order {
+		sorted[i] = array[index]
+	}
+	return sorted, nil
+}
+
+// jpSort sorts an array of numbers or strings
+func jpSort(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	array, ok := args[0].([]interface{})
+	if !ok {
+		return nil, jpInvalidType(args[0], "array")
+	}
+	return jpSorted(array, nil)
+}
+
+// jpSortBy sorts an array by the key an expression reference selects
+func jpSortBy(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 2); err != nil {
+		return nil, err
+	}
+	array, ok := args[0].([]interface{})
+	if !ok {
+		return nil, jpInvalidType(args[0], "array")
+	}
+	return jpSorted(array, args[1])
+}
+
+// jpExtreme returns max (sign 1) or min (sign -1) of an array of numbers or strings
+func jpExtreme(sign int) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 1); err != nil {
+			return nil, err
+		}
+		return jpExtremeOf(args[0], nil, sign)
+	}
+}
+
+// jpExtremeBy returns the element with the max (sign 1) or min (sign -1) key
+func jpExtremeBy(sign int) jpFunction {
+	return func(args []interface{}) (interface{}, error) {
+		if err := jpArgs(args, 2); err != nil {
+			return nil, err
+		}
+		return jpExtremeOf(args[0], args[1], sign)
+	}
+}
+
+// jpExtremeOf finds the element with the extreme key; an empty array yields null
+func jpExtremeOf(value, expref interface{}, sign int) (interface{}, error) {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, jpInvalidType(value, "array")
+	}
+	if len(array) == 0 {
+		return nil, nil
+	}
+	keys, err := jpKeysBy(array, expref)
+	if err != nil {
+		return nil, err
+	}
+	compare, err := jpSortKeys(keys)
+	if err != nil {
+		return nil, err
+	}
+	best := 0
+	for i := 1; i < len(array); i++ {
+		if compare(i, best)*sign > 0 {
+			best = i
+		}
+	}
+	return array[best], nil
+}
+
+// jpMerge merges objects, later keys winning
+func jpMerge(args []interface{}) (interface{}, error) {
+	merged := make(map[string]interface{})
+	for _, arg := range args {
+		object, ok := arg.(map[string]interface{})
+		if !ok {
+			return nil, jpInvalidType(arg, "object")
+		}
+		for key, value := range object {
+			merged[key] = value
+		}
+	}
+	return merged, nil
+}
+
+// jpNotNull returns the first argument that isn't null
+func jpNotNull(args []interface{}) (interface{}, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("expected at least 1 argument")
+	}
+	for _, arg := range args {
+		if arg != nil {
+			return arg, nil
+		}
+	}
+	return nil, nil
+}
+
+// jpReverse reverses an array or string
+func jpReverse(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	switch v := args[0].(type) {
+	case string:
+		runes := []rune(v)
+		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+			runes[i], runes[j] = runes[j], runes[i]
+		}
+		return string(runes), nil
+	case []interface{}:
+		reversed := make([]interface{}, len(v))
+		for i, element := range v {
+			reversed[len(v)-1-i] = element
+		}
+		return reversed, nil
+	}
+	return nil, jpInvalidType(args[0], "array or string")
+}
+
+// jpToArray wraps a non-array value in an array
+func jpToArray(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	if array, ok := args[0].([]interface{}); ok {
+		return array, nil
+	}
+	return []interface{}{args[0]}, nil
+}
+
+// jpToString returns strings as they are and JSON-encodes anything else
+func jpToString(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	if s, ok := args[0].(string); ok {
+		return s, nil
+	}
+	encoded, err := json.Marshal(args[0])
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// jpToNumber converts numeric strings to numbers; anything else yields null
+func jpToNumber(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	if n, ok := jpNumber(args[0]); ok {
+		return n, nil
+	}
+	if s, ok := args[0].(string); ok {
+		if n, err := strconv.ParseFloat(s, 64); err == nil {
+			return n, nil
+		}
+	}
+	return nil, nil
+}
+
+// jpType returns the JMESPath type name of a value
+func jpType(args []interface{}) (interface{}, error) {
+	if err := jpArgs(args, 1); err != nil {
+		return nil, err
+	}
+	return jpTypeName(args[0]), nil
+}
+
+// validateQueries checks the "jmespath:" keys of an expected object against the values they select,
+// returning the remaining keys for the usual field-by-field validation
+func (t *APITester) validateQueries(expected map[string]interface{}, actual interface{}, path string) (map[string]interface{}, []assertionError) {
+	var rest map[string]interface{}
+	var errors []assertionError
+	for key, expVal := range expected {
+		if !strings.HasPrefix(key, JMESPathPrefix) {
+			continue
+		}
+		if rest == nil {
+			rest = make(map[string]interface{}, len(expected))
+			for k, v := range expected {
+				rest[k] = v
+			}
+		}
+		delete(rest, key)
+
+		currentPath := key
+		if path != "" {
+			currentPath = path + "." + key
+		}
+		value, err := lookupPath(actual, key)
+		if err != nil {
+			errors = append(errors, assertionError{CategorySchema, fmt.Sprintf("%s: %v", currentPath, err)})
+			continue
+		}
+		errors = append(errors, t.validate(expVal, value, currentPath)...)
+	}
+	if rest == nil {
+		return expected, nil
+	}
+	return rest, errors
+}
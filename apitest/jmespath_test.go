@@ -0,0 +1,173 @@
+package apitest
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// Most cases are examples from the JMESPath specification and its compliance suite
+
+const (
+	peopleJSON = `{"people": [
+		{"first": "James", "last": "d", "age": 30},
+		{"first": "Jacob", "last": "e", "age": 20},
+		{"first": "Jayden", "last": "f", "age": 40},
+		{"missing": "different"}
+	], "foo": {"bar": "baz"}}`
+	reservationsJSON = `{"reservations": [
+		{"instances": [{"state": "running"}, {"state": "stopped"}]},
+		{"instances": [{"state": "terminated"}, {"state": "running"}]}
+	]}`
+	machinesJSON = `{"machines": [
+		{"name": "a", "state": "running", "cpu": 2},
+		{"name": "b", "state": "stopped", "cpu": 4},
+		{"name": "c", "state": "running", "cpu": 8}
+	]}`
+	numbersJSON = `[0, 1, 2, 3, 4, 5, 6, 7, 8, 9]`
+)
+
+// decodeJSON decodes a test fixture the way responses are decoded
+func decodeJSON(t *testing.T, text string) interface{} {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatalf("invalid fixture %s: %v", text, err)
+	}
+	return value
+}
+
+func TestJMESPath(t *testing.T) {
+	tests := []struct {
+		name       string
+		given      string
+		expression string
+		want       string
+	}{
+		// Basic expressions
+		{"field", `{"foo": {"bar": "baz"}}`, `foo.bar`, `"baz"`},
+		{"missing field", `{"foo": {"bar": "baz"}}`, `foo.qux`, `null`},
+		{"quoted identifier", `{"with space": 1}`, `"with space"`, `1`},
+		{"index", numbersJSON, `[1]`, `1`},
+		{"negative index", numbersJSON, `[-1]`, `9`},
+		{"index out of range", numbersJSON, `[10]`, `null`},
+		{"current node", `{"foo": 1}`, `@`, `{"foo": 1}`},
+
+		// Projections
+		{"list projection", peopleJSON, `people[*].first`, `["James", "Jacob", "Jayden"]`},
+		{"projection skips nulls", peopleJSON, `people[*].missing`, `["different"]`},
+		{"sliced projection", peopleJSON, `people[:2].first`, `["James", "Jacob"]`},
+		{"object projection", `{"ops": {"functionA": {"numArgs": 2}, "functionB": {"numArgs": 3}, "functionC": {"variadic": true}}}`,
+			`ops.*.numArgs`, `[2, 3]`},
+		{"projection of a non-array", `{"foo": "bar"}`, `foo[*].bar`, `null`},
+		{"nested projections", reservationsJSON, `reservations[*].instances[*].state`, `[["running", "stopped"], ["terminated", "running"]]`},
+		{"flatten", reservationsJSON, `reservations[].instances[].state`, `["running", "stopped", "terminated", "running"]`},
+		{"flatten one level", `[[0, 1], 2, [3], 4, [5, [6, 7]]]`, `[]`, `[0, 1, 2, 3, 4, 5, [6, 7]]`},
+		{"index inside projection", peopleJSON, `people[*].first[0]`, `[]`},
+
+		// Filters
+		{"filter by string", machinesJSON, `machines[?state=='running'].name`, `["a", "c"]`},
+		{"filter by inequality", machinesJSON, `machines[?state!='running'].name`, `["b"]`},
+		{"filter by number", machinesJSON, "machines[?cpu > `2`].name", `["b", "c"]`},
+		{"filter with and", machinesJSON, "machines[?cpu >= `4` && state == 'running'].name", `["c"]`},
+		{"filter with or", machinesJSON, "machines[?cpu < `4` || state == 'stopped'].name", `["a", "b"]`},
+		{"filter with not", machinesJSON, `machines[?!(state == 'running')].name`, `["b"]`},
+		{"filter comparing fields", `{"foo": [{"a": 1, "b": 1}, {"a": 1, "b": 2}]}`, `foo[?a == b]`, `[{"a": 1, "b": 1}]`},
+		{"filter by missing field", peopleJSON, `people[?missing].missing`, `["different"]`},
+		{"ordering a string is null", machinesJSON, "machines[?name > `1`]", `[]`},
+		{"filter with function", machinesJSON, `machines[?starts_with(name, 'b')].cpu`, `[4]`},
+
+		// Pipes
+		{"pipe stops a projection", peopleJSON, `people[*].first | [0]`, `"James"`},
+		{"pipe into function", machinesJSON, `machines[?state=='running'] | length(@)`, `2`},
+		{"filter then pipe", machinesJSON, `machines[?state!='running'] | [0].name`, `"b"`},
+		{"chained pipes", `{"foo": {"bar": {"baz": "qux"}}}`, `foo | bar | baz`, `"qux"`},
+
+		// Slices
+		{"slice start and stop", numbersJSON, `[0:5]`, `[0, 1, 2, 3, 4]`},
+		{"slice from start", numbersJSON, `[5:]`, `[5, 6, 7, 8, 9]`},
+		{"slice with step", numbersJSON, `[::2]`, `[0, 2, 4, 6, 8]`},
+		{"reversed slice", numbersJSON, `[::-1]`, `[9, 8, 7, 6, 5, 4, 3, 2, 1, 0]`},
+		{"negative start", numbersJSON, `[-2:]`, `[8, 9]`},
+		{"negative step", numbersJSON, `[8:2:-2]`, `[8, 6, 4]`},
+		{"slice past the end", numbersJSON, `[8:100]`, `[8, 9]`},
+		{"empty slice", numbersJSON, `[5:2]`, `[]`},
+		{"slice of a non-array", `{"foo": "bar"}`, `foo[0:1]`, `null`},
+
+		// Multiselect
+		{"multiselect list", `{"foo": {"bar": 1, "baz": 2}}`, `foo.[bar, baz]`, `[1, 2]`},
+		{"multiselect hash", `{"foo": {"bar": 1, "baz": 2}}`, `foo.{a: bar, b: baz}`, `{"a": 1, "b": 2}`},
+		{"projected multiselect list", machinesJSON, `machines[*].[name, cpu]`, `[["a", 2], ["b", 4], ["c", 8]]`},
+		{"projected multiselect hash", machinesJSON, `machines[?cpu > ` + "`4`" + `].{n: name, s: state}`, `[{"n": "c", "s": "running"}]`},
+		{"multiselect of missing fields", `{"foo": {}}`, `foo.[bar, baz]`, `[null, null]`},
+		{"multiselect on null", `{"foo": 1}`, `missing.[bar]`, `null`},
+
+		// Functions
+		{"length of array", peopleJSON, `length(people)`, `4`},
+		{"length of string", `{"foo": "bar"}`, `length(foo)`, `3`},
+		{"sort_by", peopleJSON, `sort_by(people[?age], &age)[*].first`, `["Jacob", "James", "Jayden"]`},
+		{"max_by", peopleJSON, `max_by(people[?age], &age).first`, `"Jayden"`},
+		{"sum", machinesJSON, `sum(machines[*].cpu)`, `14`},
+		{"avg", machinesJSON, `avg(machines[*].cpu)`, `4.666666666666667`},
+		{"join", machinesJSON, `join(', ', machines[*].name)`, `"a, b, c"`},
+		{"keys", `{"b": 1, "a": 2}`, `sort(keys(@))`, `["a", "b"]`},
+		{"not_null", `{"b": 1}`, `not_null(a, b)`, `1`},
+		{"to_number of a string", `{"n": "42"}`, `to_number(n)`, `42`},
+		{"to_string of an array", `{"a": [1, 2]}`, `to_string(a)`, `"[1,2]"`},
+		{"type", `{"a": [1]}`, `type(a)`, `"array"`},
+		{"map", machinesJSON, `map(&cpu, machines)`, `[2, 4, 8]`},
+		{"literal argument", `{}`, "contains(`[1, 2]`, `2`)", `true`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchJMESPath(tt.expression, decodeJSON(t, tt.given))
+			if err != nil {
+				t.Fatalf("%s: %v", tt.expression, err)
+			}
+			if want := decodeJSON(t, tt.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s = %#v, want %#v", tt.expression, got, want)
+			}
+		})
+	}
+}
+
+func TestJMESPathErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		given      string
+		expression string
+		want       string
+	}{
+		// Compile errors
+		{"unknown function", `{}`, `foo(@)`, "unknown function foo()"},
+		{"unterminated filter", machinesJSON, `machines[?state=='running'`, "unexpected end of expression"},
+		{"unterminated literal", `{}`, "`[1, 2]", "unterminated `"},
+		{"empty index", numbersJSON, `foo[]bar`, "unexpected token"},
+		{"trailing dot", `{}`, `foo.`, "unexpected end of expression"},
+
+		// Evaluation errors
+		{"slice step of zero", numbersJSON, `[::0]`, "slice step can't be 0"},
+		{"abs of a string", `{"foo": "bar"}`, `abs(foo)`, "abs(): expected number, got string"},
+		{"length of a number", `{}`, "length(`5`)", "length(): expected string, array or object, got number"},
+		{"sum of strings", machinesJSON, `sum(machines[*].name)`, "sum(): expected number, got string"},
+		{"avg of an object", peopleJSON, `avg(foo)`, "avg(): expected array of numbers, got object"},
+		{"keys of an array", numbersJSON, `keys(@)`, "keys(): expected object, got array"},
+		{"max of mixed types", `{}`, "max(`[1, \"a\"]`)", "max(): "},
+		{"sort_by of mixed keys", `{"a": [{"k": 1}, {"k": "x"}]}`, `sort_by(a, &k)`, "sort_by(): "},
+		{"starts_with of a number", `{}`, "starts_with(`1`, 'a')", "starts_with(): expected string, got number"},
+		{"too few arguments", `{}`, `length()`, "length(): expected 1 arguments, got 0"},
+		{"too many arguments", `{}`, `abs(` + "`1`, `2`" + `)`, "abs(): expected 1 arguments, got 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := searchJMESPath(tt.expression, decodeJSON(t, tt.given))
+			if err == nil {
+				t.Fatalf("%s = %#v, want an error containing %q", tt.expression, got, tt.want)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: error %q, want it to contain %q", tt.expression, err, tt.want)
+			}
+		})
+	}
+}
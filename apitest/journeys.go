@@ -0,0 +1,237 @@
+package apitest
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"net/http/cookiejar"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JourneySample records one virtual user's run through the whole suite
+type JourneySample struct {
+	User       int     `json:"user"`
+	Iteration  int     `json:"iteration"`
+	Passed     bool    `json:"passed"`
+	Steps      int     `json:"steps"`
+	FailedStep string  `json:"failed_step,omitempty"`
+	DurationMs float64 `json:"duration_ms"`
+}
+
+// JourneyStep counts how often one test of the suite passed across all journeys, to show where
+// journeys break off
+type JourneyStep struct {
+	TestCaseName string `json:"test_case_name"`
+	Runs         int    `json:"runs"`
+	Failed       int    `json:"failed"`
+}
+
+// JourneyReport summarizes a run of virtual users, each walking the chained suite as one session
+type JourneyReport struct {
+	VirtualUsers  int           `json:"virtual_users"`
+	Iterations    int           `json:"iterations"`
+	ThinkTime     string        `json:"think_time"`
+	RampUp        string        `json:"ramp_up,omitempty"`
+	Journeys      int           `json:"journeys"`
+	Passed        int           `json:"passed"`
+	SuccessRate   float64       `json:"success_rate"`
+	AvgDurationMs float64       `json:"avg_duration_ms"`
+	P95DurationMs float64       `json:"p95_duration_ms"`
+	Steps         []JourneyStep `json:"steps"`
+	// Agents are the instances that generated the journeys of a distributed run
+	Agents  []JourneyAgent  `json:"agents,omitempty"`
+	Samples []JourneySample `json:"samples"`
+}
+
+// RunJourneys runs the suite as users concurrent virtual users, each doing iterations journeys.
+// Every journey is a new session: the seeded variables, an empty cookie jar and its own random
+// think time between steps. Users start evenly spread over rampUp. The console shows one line per
+// journey instead of every test, and t.Results holds the results of all journeys.
+func (t *APITester) RunJourneys(users, iterations int, thinkTime, rampUp time.Duration) {
+	t.Journeys = &JourneyReport{VirtualUsers: users, Iterations: iterations, ThinkTime: thinkTime.String()}
+	if rampUp > 0 {
+		t.Journeys.RampUp = rampUp.String()
+	}
+	t.printTestHeader()
+	t.Results = []TestResult{}
+
+	type finished struct {
+		sample  JourneySample
+		results []TestResult
+	}
+	done := make(chan finished)
+	var wg sync.WaitGroup
+
+	for user := 1; user <= users; user++ {
+		// Users are set up before any starts, since the loop below updates t
+		vu := t.newVirtualUser(t.userOffset+user, thinkTime)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			delay := time.Duration(int64(rampUp) * int64(user-1) / int64(users))
+			select {
+			case <-time.After(delay):
+			case <-t.Context.Done():
+				return
+			}
+			for iteration := 1; iteration <= iterations; iteration++ {
+				started := time.Now()
+				vu.newSession(t.Variables)
+				vu.RunAllTests()
+				// A journey cut short by the interrupt says nothing about the API
+				if t.Context.Err() != nil {
+					return
+				}
+				sample := JourneySample{User: vu.virtualUser, Iteration: iteration, Passed: true, Steps: len(vu.Results), DurationMs: durationMs(time.Since(started))}
+				for _, result := range vu.Results {
+					if isFailed(result.Status) {
+						sample.Passed, sample.FailedStep = false, result.TestCaseName
+						break
+					}
+				}
+				done <- finished{sample, vu.Results}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	for journey := range done {
+		t.Journeys.Samples = append(t.Journeys.Samples, journey.sample)
+		t.Results = append(t.Results, journey.results...)
+		sample := journey.sample
+		if sample.Passed {
+			fmt.Fprintf(t.out, "%s✓ User %d journey %d: %d steps passed in %s%s\n",
+				ColorGreen, sample.User, sample.Iteration, sample.Steps, formatMs(sample.DurationMs), ColorReset)
+		} else {
+			fmt.Fprintf(t.out, "%s✗ User %d journey %d: failed at %q after %s%s\n",
+				ColorRed, sample.User, sample.Iteration, sample.FailedStep, formatMs(sample.DurationMs), ColorReset)
+		}
+	}
+
+	t.Journeys.summarize(t.Results)
+}
+
+// newVirtualUser returns a tester for one virtual user. It shares the configuration, transport and
+// listeners of t, but has its own state, random source and circuit breaker, and no event stream,
+// auth cache or snapshot updates, which can't be shared between concurrent users.
+func (t *APITester) newVirtualUser(user int, thinkTime time.Duration) *APITester {
+	vu := *t
+	vu.virtualUser, vu.thinkTime = user, thinkTime
+	// The tests of concurrent users would interleave, so only the journeys are printed
+	vu.out = io.Discard
+	vu.random = rand.New(rand.NewPCG(t.Seed, uint64(user)))
+	vu.Events = nil
+	vu.AuthCache = nil
+	vu.UpdateSnapshots = false
+	vu.Journeys, vu.Soak = nil, nil
+	if t.breaker != nil {
+		vu.EnableCircuitBreaker(t.breaker.threshold)
+	}
+	if t.pacer != nil {
+		vu.EnablePacing()
+	}
+	if t.resources != nil {
+		vu.resources = &resourceGuard{limit: t.resources.limit}
+	}
+	return &vu
+}
+
+// newSession resets a virtual user to the state before the first test: the seeded variables and
+// no cookies, results or resolved values
+func (t *APITester) newSession(variables map[string]interface{}) {
+	t.Variables = maps.Clone(variables)
+	t.variableSources = maps.Clone(t.variableSources)
+	t.resolved = make(map[string]resolvedValue)
+	t.resolveErrors = nil
+	t.secrets = slices.Clone(t.secrets)
+	t.jwks = nil
+	t.graphQLSchema = nil
+	t.Results = nil
+	client := *t.HTTPClient
+	client.Jar, _ = cookiejar.New(nil)
+	t.HTTPClient = &client
+}
+
+// think pauses a virtual user between two steps of its journey, like a person reading the page:
+// the step's think_time_ms or -think-time, randomly between half and one and a half times that
+func (t *APITester) think(testCase TestCase) {
+	if t.virtualUser == 0 {
+		return
+	}
+	pause := t.thinkTime
+	if testCase.ThinkTimeMs > 0 {
+		pause = time.Duration(testCase.ThinkTimeMs) * time.Millisecond
+	}
+	if pause <= 0 {
+		return
+	}
+	pause = pause/2 + time.Duration(t.random.Int64N(int64(pause)))
+	select {
+	case <-time.After(pause):
+	case <-t.Context.Done():
+	}
+}
+
+// summarize computes the success rate, journey durations and per-step failures
+func (r *JourneyReport) summarize(results []TestResult) {
+	r.Journeys = len(r.Samples)
+	durations := make([]float64, 0, len(r.Samples))
+	for _, sample := range r.Samples {
+		if sample.Passed {
+			r.Passed++
+		}
+		r.AvgDurationMs += sample.DurationMs
+		durations = append(durations, sample.DurationMs)
+	}
+	if r.Journeys > 0 {
+		r.SuccessRate = float64(r.Passed) / float64(r.Journeys) * 100
+		r.AvgDurationMs /= float64(r.Journeys)
+		r.P95DurationMs = baselineStat("p95", durations)
+	}
+
+	steps := make(map[string]int)
+	for _, result := range results {
+		i, ok := steps[result.TestCaseName]
+		if !ok {
+			i = len(r.Steps)
+			steps[result.TestCaseName] = i
+			r.Steps = append(r.Steps, JourneyStep{TestCaseName: result.TestCaseName})
+		}
+		r.Steps[i].Runs++
+		if isFailed(result.Status) {
+			r.Steps[i].Failed++
+		}
+	}
+}
+
+// PrintJourneySummary prints the journey success rate and the steps journeys failed at
+func (t *APITester) PrintJourneySummary() {
+	r := t.Journeys
+	fmt.Fprintf(t.out, "%s  Journey Summary (%d virtual users × %d, think time %s)%s\n", ColorBold, r.VirtualUsers, r.Iterations, r.ThinkTime, ColorReset)
+	color := ColorGreen
+	if r.Passed < r.Journeys {
+		color = ColorRed
+	}
+	fmt.Fprintf(t.out, "  %sJourneys: %d/%d passed (%.1f%% success rate)%s\n", color, r.Passed, r.Journeys, r.SuccessRate, ColorReset)
+	fmt.Fprintf(t.out, "  Duration: avg %s, p95 %s\n", formatMs(r.AvgDurationMs), formatMs(r.P95DurationMs))
+	for _, step := range r.Steps {
+		if step.Failed > 0 {
+			fmt.Fprintf(t.out, "  %s✗ %s: failed %d/%d%s\n", ColorRed, step.TestCaseName, step.Failed, step.Runs, ColorReset)
+		}
+	}
+	for _, agent := range r.Agents {
+		if agent.Error != "" {
+			fmt.Fprintf(t.out, "  %s✗ Agent %s: %s%s\n", ColorRed, agent.URL, agent.Error, ColorReset)
+		} else {
+			fmt.Fprintf(t.out, "  Agent %s: %d users, %d/%d journeys passed\n", agent.URL, agent.VirtualUsers, agent.Passed, agent.Journeys)
+		}
+	}
+	fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+}
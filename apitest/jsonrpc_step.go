@@ -0,0 +1,218 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// JSONRPCVersion is the protocol version sent in and required of every envelope
+const JSONRPCVersion = "2.0"
+
+// JSONRPCCall is one JSON-RPC method call
+type JSONRPCCall struct {
+	Method string      `json:"method"`
+	Params interface{} `json:"params"`
+	// Notification sends the call without an id, so the server must not answer it
+	Notification bool `json:"notification"`
+}
+
+// JSONRPCStep posts a JSON-RPC 2.0 call, or a batch of them, to the test's api
+type JSONRPCStep struct {
+	Method       string        `json:"method"`
+	Params       interface{}   `json:"params"`
+	Notification bool          `json:"notification"`
+	Batch        []JSONRPCCall `json:"batch"`
+}
+
+// calls returns the calls the step sends
+func (s *JSONRPCStep) calls() []JSONRPCCall {
+	if s.Batch != nil {
+		return s.Batch
+	}
+	return []JSONRPCCall{{Method: s.Method, Params: s.Params, Notification: s.Notification}}
+}
+
+// jsonrpcStepTarget returns the endpoint and the method called
+func jsonrpcStepTarget(t *APITester, testCase TestCase) string {
+	step := testCase.JSONRPC
+	if step == nil {
+		return t.buildURL(testCase)
+	}
+	if step.Batch != nil {
+		return fmt.Sprintf("%s (batch of %d)", t.buildURL(testCase), len(step.Batch))
+	}
+	return fmt.Sprintf("%s %s", t.buildURL(testCase), step.Method)
+}
+
+// runJSONRPCStep wraps the calls into envelopes with ids of their own and checks the envelopes of
+// the replies. A single call returns its reply object; a batch returns {"responses": [...]} in call
+// order, with null for notifications. A reply carrying an error fails the test unless the expected
+// response for it mentions "error".
+func runJSONRPCStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.JSONRPC
+	if step == nil || (step.Method == "" && step.Batch == nil) {
+		return nil, []assertionError{{CategoryRequest, "JSON-RPC step: \"jsonrpc.method\" or \"jsonrpc.batch\" is required"}}
+	}
+	calls := step.calls()
+	if len(calls) == 0 {
+		return nil, []assertionError{{CategoryRequest, "JSON-RPC step: \"jsonrpc.batch\" is empty"}}
+	}
+
+	envelopes := make([]map[string]interface{}, len(calls))
+	ids := make([]int, len(calls))
+	for i, call := range calls {
+		if call.Method == "" {
+			return nil, []assertionError{{CategoryRequest, fmt.Sprintf("JSON-RPC step: call %d has no method", i)}}
+		}
+		envelope := map[string]interface{}{"jsonrpc": JSONRPCVersion, "method": t.replaceVariables(call.Method)}
+		if call.Params != nil {
+			envelope["params"] = t.replaceInInterface(call.Params)
+		}
+		if !call.Notification {
+			t.rpcID++
+			ids[i] = t.rpcID
+			envelope["id"] = t.rpcID
+		}
+		envelopes[i] = envelope
+	}
+	var payload interface{} = envelopes[0]
+	if step.Batch != nil {
+		payload = envelopes
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("JSON-RPC step: %v", err)}}
+	}
+
+	reply, errors := t.postJSONRPC(testCase, body)
+	if errors != nil {
+		return nil, errors
+	}
+
+	if step.Batch == nil {
+		if ids[0] == 0 {
+			if reply != nil {
+				return nil, []assertionError{{CategorySchema, fmt.Sprintf("JSON-RPC: Expected no reply to a notification, got %s", formatJSONContext(reply, 1))}}
+			}
+			return map[string]interface{}{}, nil
+		}
+		object, _ := reply.(map[string]interface{})
+		return reply, checkJSONRPCReply(object, ids[0], testCase.ExpectedResponse, "")
+	}
+
+	// Batch replies may come in any order, so they are matched to their calls by id
+	byID := make(map[string]map[string]interface{})
+	replies, ok := reply.([]interface{})
+	if !ok && reply != nil {
+		return reply, []assertionError{{CategorySchema, fmt.Sprintf("JSON-RPC: Expected an array of replies to the batch, got %s", formatJSONContext(reply, 1))}}
+	}
+	for _, entry := range replies {
+		object, ok := entry.(map[string]interface{})
+		if !ok {
+			return reply, []assertionError{{CategorySchema, fmt.Sprintf("JSON-RPC: Expected reply objects, got %s", formatJSONContext(entry, 1))}}
+		}
+		byID[fmt.Sprintf("%v", object["id"])] = object
+	}
+
+	var errs []assertionError
+	expected, _ := testCase.ExpectedResponse["responses"].([]interface{})
+	responses := make([]interface{}, len(calls))
+	for i, id := range ids {
+		if id == 0 {
+			continue
+		}
+		path := fmt.Sprintf("responses[%d]", i)
+		object, found := byID[fmt.Sprintf("%d", id)]
+		if !found {
+			errs = append(errs, assertionError{CategorySchema, fmt.Sprintf("%s: No reply to call %s (id %d)", path, calls[i].Method, id)})
+			continue
+		}
+		responses[i] = object
+		var want map[string]interface{}
+		if i < len(expected) {
+			want, _ = expected[i].(map[string]interface{})
+		}
+		errs = append(errs, checkJSONRPCReply(object, id, want, path+": ")...)
+		delete(byID, fmt.Sprintf("%d", id))
+	}
+	for id := range byID {
+		errs = append(errs, assertionError{CategorySchema, fmt.Sprintf("JSON-RPC: Unexpected reply with id %s", id)})
+	}
+	return map[string]interface{}{"responses": responses}, errs
+}
+
+// postJSONRPC sends the encoded envelope and returns the parsed reply, nil for an empty body
+func (t *APITester) postJSONRPC(testCase TestCase, body []byte) (interface{}, []assertionError) {
+	ctx, cancel := t.requestContext(testCase)
+	defer cancel()
+	req, err := t.createHTTPRequest(ctx, http.MethodPost, t.buildURL(testCase), bytes.NewReader(body), testCase)
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, err.Error()}}
+	}
+	if headerValue(req.Header, "Content-Type") == "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, _, _, err := t.executeRequest(req)
+	if err != nil {
+		return nil, []assertionError{{classifyRequestError(err), fmt.Sprintf("Request failed: %v", err)}}
+	}
+	defer resp.Body.Close()
+
+	reply, err := t.parseResponseBody(resp, &TestResult{})
+	if err != nil {
+		return nil, []assertionError{{classifyRequestError(err), err.Error()}}
+	}
+	expectedStatus := testCase.ExpectedStatusCode
+	if expectedStatus == 0 && resp.StatusCode/100 != 2 {
+		return reply, []assertionError{{CategoryStatus, fmt.Sprintf("HTTP Status: Expected 2xx, got %d", resp.StatusCode)}}
+	}
+	if expectedStatus != 0 && resp.StatusCode != expectedStatus {
+		return reply, []assertionError{{CategoryStatus, fmt.Sprintf("HTTP Status: Expected %d, got %d", expectedStatus, resp.StatusCode)}}
+	}
+	if _, isText := reply.(string); isText {
+		return nil, []assertionError{{CategorySchema, fmt.Sprintf("JSON-RPC: Expected a JSON reply, got %s", formatJSONContext(reply, 1))}}
+	}
+	return reply, nil
+}
+
+// checkJSONRPCReply validates a reply envelope: version, id, exactly one of result and error, and
+// the error's shape. An error reply fails unless expected mentions "error".
+func checkJSONRPCReply(reply map[string]interface{}, id int, expected map[string]interface{}, prefix string) []assertionError {
+	if reply == nil {
+		return []assertionError{{CategorySchema, prefix + "JSON-RPC: Expected a reply object"}}
+	}
+	var errs []assertionError
+	if reply["jsonrpc"] != JSONRPCVersion {
+		errs = append(errs, assertionError{CategorySchema, fmt.Sprintf("%sJSON-RPC: Expected jsonrpc %q, got %v", prefix, JSONRPCVersion, reply["jsonrpc"])})
+	}
+	if !compareValues(id, reply["id"]) {
+		errs = append(errs, assertionError{CategorySchema, fmt.Sprintf("%sJSON-RPC: Expected id %d, got %v", prefix, id, reply["id"])})
+	}
+	_, hasResult := reply["result"]
+	rpcError, hasError := reply["error"]
+	if hasResult == hasError {
+		return append(errs, assertionError{CategorySchema, prefix + "JSON-RPC: Expected exactly one of result and error"})
+	}
+	if !hasError {
+		return errs
+	}
+
+	object, _ := rpcError.(map[string]interface{})
+	code, err := toNumber(object["code"])
+	_, quoted := object["code"].(string)
+	message, isMessage := object["message"].(string)
+	if object == nil || err != nil || quoted || code != float64(int64(code)) || !isMessage {
+		return append(errs, assertionError{CategorySchema, fmt.Sprintf("%sJSON-RPC: Malformed error object %s", prefix, formatJSONContext(rpcError, 1))})
+	}
+	if _, wanted := expected["error"]; !wanted {
+		detail := ""
+		if data, ok := object["data"]; ok {
+			detail = " " + strings.TrimSpace(formatJSONContext(data, 1))
+		}
+		errs = append(errs, assertionError{CategoryBody, fmt.Sprintf("%sJSON-RPC error %v: %s%s", prefix, object["code"], message, detail)})
+	}
+	return errs
+}
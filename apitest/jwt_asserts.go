@@ -0,0 +1,355 @@
+package apitest
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// DefaultJWTClockSkew is the leeway applied to exp, nbf and iat unless configured
+const DefaultJWTClockSkew = 60
+
+// JWTAssertion decodes a JWT from the response, verifies it and checks its claims
+type JWTAssertion struct {
+	// From locates the token: "body:data.access_token", "header:Authorization" or "cookie:session"
+	From string `json:"from"`
+	// JWKSURL is where signing keys are fetched from to verify the signature
+	JWKSURL string `json:"jwks_url"`
+	// Secret verifies HS256/384/512 tokens instead of a JWKS
+	Secret     string   `json:"secret"`
+	Algorithms []string `json:"algorithms"`
+	Issuer     string   `json:"iss"`
+	Audience   string   `json:"aud"`
+	// ClockSkew is the leeway in seconds for time-based claims
+	ClockSkew *int `json:"clock_skew"`
+	// MinLifetime and MaxLifetime bound the seconds until exp
+	MinLifetime *int                   `json:"min_lifetime"`
+	MaxLifetime *int                   `json:"max_lifetime"`
+	Claims      map[string]interface{} `json:"claims"`
+}
+
+// jwk is a JSON Web Key as published in a JWKS document
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// jwtHashes maps algorithm size suffixes to their hash
+var jwtHashes = map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+
+// validateJWT runs the test's JWT assertion against the response
+func (t *APITester) validateJWT(testCase TestCase, resp *http.Response, responseData interface{}) []assertionError {
+	assertion := testCase.JWT
+	token, err := locateJWT(assertion.From, resp, responseData)
+	if err != nil {
+		return []assertionError{{CategoryJWT, fmt.Sprintf("JWT: %v", err)}}
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return []assertionError{{CategoryJWT, "JWT: Not a compact JWS (expected 3 parts)"}}
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return []assertionError{{CategoryJWT, fmt.Sprintf("JWT header: %v", err)}}
+	}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return []assertionError{{CategoryJWT, fmt.Sprintf("JWT claims: %v", err)}}
+	}
+
+	var errors []assertionError
+	fail := func(format string, args ...interface{}) {
+		errors = append(errors, assertionError{CategoryJWT, "JWT " + fmt.Sprintf(format, args...)})
+	}
+
+	// Signature
+	if len(assertion.Algorithms) > 0 && !containsFold(assertion.Algorithms, header.Alg) {
+		fail("alg: Expected one of %s, got %s", strings.Join(assertion.Algorithms, ", "), header.Alg)
+	}
+	if assertion.JWKSURL != "" || assertion.Secret != "" {
+		if err := t.verifyJWTSignature(assertion, header.Alg, header.Kid, parts); err != nil {
+			fail("signature: %v", err)
+		}
+	}
+
+	// Registered claims
+	if assertion.Issuer != "" && claims["iss"] != t.replaceVariables(assertion.Issuer) {
+		fail("iss: Expected '%s', got '%v'", assertion.Issuer, claims["iss"])
+	}
+	if assertion.Audience != "" && !audienceContains(claims["aud"], t.replaceVariables(assertion.Audience)) {
+		fail("aud: Expected to include '%s', got %s", assertion.Audience, formatJSONContext(claims["aud"], 1))
+	}
+
+	skew := float64(DefaultJWTClockSkew)
+	if assertion.ClockSkew != nil {
+		skew = float64(*assertion.ClockSkew)
+	}
+	now := float64(time.Now().Unix())
+	if exp, ok := claims["exp"].(float64); ok {
+		if exp < now-skew {
+			fail("exp: Expired %.0fs ago", now-exp)
+		}
+		if assertion.MinLifetime != nil && exp-now < float64(*assertion.MinLifetime)-skew {
+			fail("exp: Expected at least %ds left, got %.0fs", *assertion.MinLifetime, exp-now)
+		}
+		if assertion.MaxLifetime != nil && exp-now > float64(*assertion.MaxLifetime)+skew {
+			fail("exp: Expected at most %ds left, got %.0fs", *assertion.MaxLifetime, exp-now)
+		}
+	} else if assertion.MinLifetime != nil || assertion.MaxLifetime != nil {
+		fail("exp: Not found in claims")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && nbf > now+skew {
+		fail("nbf: Not valid for another %.0fs", nbf-now)
+	}
+	if iat, ok := claims["iat"].(float64); ok && iat > now+skew {
+		fail("iat: Issued %.0fs in the future", iat-now)
+	}
+
+	// Custom claims, matched like expected_response
+	for _, err := range t.validate(assertion.Claims, claims, "") {
+		err.Category = CategoryJWT
+		err.Message = "JWT claim " + err.Message
+		errors = append(errors, err)
+	}
+	return errors
+}
+
+// locateJWT reads the token from the response body, a header or a cookie
+func locateJWT(from string, resp *http.Response, responseData interface{}) (string, error) {
+	source, location, found := strings.Cut(from, ":")
+	if !found {
+		return "", fmt.Errorf("\"from\" must look like body:path, header:Name or cookie:name, got %q", from)
+	}
+
+	switch source {
+	case "body":
+		value, ok := getNestedValue(responseData, location).(string)
+		if !ok {
+			return "", fmt.Errorf("No token at %s", location)
+		}
+		return value, nil
+	case "header":
+		value := resp.Header.Get(location)
+		if value == "" {
+			return "", fmt.Errorf("Header %s not found", location)
+		}
+		if scheme, token, ok := strings.Cut(value, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return token, nil
+		}
+		return value, nil
+	case "cookie":
+		for _, cookie := range resp.Cookies() {
+			if cookie.Name == location {
+				return cookie.Value, nil
+			}
+		}
+		return "", fmt.Errorf("Cookie %s not found", location)
+	default:
+		return "", fmt.Errorf("unknown token source %q (use body, header or cookie)", source)
+	}
+}
+
+// decodeJWTPart decodes a base64url JSON segment
+func decodeJWTPart(part string, target interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(part, "="))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, target)
+}
+
+// audienceContains reports whether an aud claim (string or list) includes the audience
+func audienceContains(aud interface{}, audience string) bool {
+	switch v := aud.(type) {
+	case string:
+		return v == audience
+	case []interface{}:
+		for _, item := range v {
+			if item == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// containsFold reports whether a list holds a value, ignoring case
+func containsFold(list []string, value string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyJWTSignature checks the token signature with the shared secret or a JWKS key
+func (t *APITester) verifyJWTSignature(assertion *JWTAssertion, alg, kid string, parts []string) error {
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return fmt.Errorf("invalid encoding: %w", err)
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+
+	if len(alg) < 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+	family, size := alg[:2], alg[2:]
+	hash, ok := jwtHashes[size]
+	if alg == "EdDSA" {
+		family, ok = "Ed", true
+	}
+	if !ok {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+
+	if family == "HS" {
+		if assertion.Secret == "" {
+			return fmt.Errorf("%s token needs \"secret\"", alg)
+		}
+		mac := hmac.New(hash.New, []byte(t.replaceVariables(assertion.Secret)))
+		mac.Write(signed)
+		if !hmac.Equal(mac.Sum(nil), signature) {
+			return fmt.Errorf("does not match the secret")
+		}
+		return nil
+	}
+	if assertion.JWKSURL == "" {
+		return fmt.Errorf("%s token needs \"jwks_url\"", alg)
+	}
+
+	keys, err := t.fetchJWKS(t.replaceVariables(assertion.JWKSURL))
+	if err != nil {
+		return err
+	}
+	var digest []byte
+	if family != "Ed" {
+		h := hash.New()
+		h.Write(signed)
+		digest = h.Sum(nil)
+	}
+
+	tried := 0
+	for _, key := range keys {
+		if kid != "" && key.Kid != "" && key.Kid != kid {
+			continue
+		}
+		switch {
+		case (family == "RS" || family == "PS") && key.Kty == "RSA":
+			publicKey, err := key.rsaKey()
+			if err != nil {
+				continue
+			}
+			tried++
+			if family == "RS" && rsa.VerifyPKCS1v15(publicKey, hash, digest, signature) == nil {
+				return nil
+			}
+			if family == "PS" && rsa.VerifyPSS(publicKey, hash, digest, signature, nil) == nil {
+				return nil
+			}
+		case family == "ES" && key.Kty == "EC":
+			publicKey, err := key.ecdsaKey()
+			if err != nil || len(signature)%2 != 0 {
+				continue
+			}
+			tried++
+			half := len(signature) / 2
+			r := new(big.Int).SetBytes(signature[:half])
+			s := new(big.Int).SetBytes(signature[half:])
+			if ecdsa.Verify(publicKey, digest, r, s) {
+				return nil
+			}
+		case family == "Ed" && key.Kty == "OKP" && key.Crv == "Ed25519":
+			x, err := base64.RawURLEncoding.DecodeString(key.X)
+			if err != nil || len(x) != ed25519.PublicKeySize {
+				continue
+			}
+			tried++
+			if ed25519.Verify(ed25519.PublicKey(x), signed, signature) {
+				return nil
+			}
+		}
+	}
+	if tried == 0 {
+		return fmt.Errorf("no %s key with kid %q in %s", alg, kid, assertion.JWKSURL)
+	}
+	return fmt.Errorf("does not match any key in %s", assertion.JWKSURL)
+}
+
+// fetchJWKS downloads a key set once per run
+func (t *APITester) fetchJWKS(url string) ([]jwk, error) {
+	if keys, ok := t.jwks[url]; ok {
+		return keys, nil
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: HTTP %d", resp.StatusCode)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("invalid JWKS: %w", err)
+	}
+	if t.jwks == nil {
+		t.jwks = make(map[string][]jwk)
+	}
+	t.jwks[url] = set.Keys
+	return set.Keys, nil
+}
+
+// rsaKey builds the RSA public key of a JWK
+func (k jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(k.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := base64.RawURLEncoding.DecodeString(k.E)
+	if err != nil {
+		return nil, err
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+}
+
+// ecdsaKey builds the ECDSA public key of a JWK
+func (k jwk) ecdsaKey() (*ecdsa.PublicKey, error) {
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	curve, ok := curves[k.Crv]
+	if !ok {
+		return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+	}
+	x, err := base64.RawURLEncoding.DecodeString(k.X)
+	if err != nil {
+		return nil, err
+	}
+	y, err := base64.RawURLEncoding.DecodeString(k.Y)
+	if err != nil {
+		return nil, err
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+}
@@ -0,0 +1,128 @@
+package apitest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Key matching modes selectable with -match-keys
+const (
+	KeyMatchExact      = "exact"      // expected keys must equal response keys
+	KeyMatchCase       = "case"       // ignore case, e.g. userId matches UserID
+	KeyMatchConvention = "convention" // also ignore _ and -, e.g. userId matches user_id
+)
+
+// maxKeySuggestions caps the keys a "did you mean" lists
+const maxKeySuggestions = 3
+
+// normalizeKey reduces a key to what a matching mode compares
+func normalizeKey(key, mode string) string {
+	switch mode {
+	case KeyMatchCase:
+		return strings.ToLower(key)
+	case KeyMatchConvention:
+		return strings.ToLower(strings.NewReplacer("_", "", "-", "").Replace(key))
+	}
+	return key
+}
+
+// lookupKey finds a key in an object under a matching mode, returning the object's own spelling
+// of it. An exact match wins; otherwise the first matching key in sorted order does, so the
+// choice is the same on every run.
+func lookupKey(object map[string]interface{}, key, mode string) (string, interface{}, bool) {
+	if value, ok := object[key]; ok {
+		return key, value, true
+	}
+	if mode == "" || mode == KeyMatchExact {
+		return "", nil, false
+	}
+	want := normalizeKey(key, mode)
+	for _, candidate := range sortedKeys(object) {
+		if normalizeKey(candidate, mode) == want {
+			return candidate, object[candidate], true
+		}
+	}
+	return "", nil, false
+}
+
+// keySuggestion names the keys of an object a missing key was probably meant to be, as
+// ` (did you mean "user_id"?)`, or returns "". Keys that differ only in case or separators are
+// suggested first; failing those, keys within a few typos.
+func keySuggestion(key string, object map[string]interface{}) string {
+	want := normalizeKey(key, KeyMatchConvention)
+	var conventions []string
+	type candidate struct {
+		key      string
+		distance int
+	}
+	var typos []candidate
+	limit := min(max(len([]rune(want))/3, 1), 3)
+	for _, actual := range sortedKeys(object) {
+		normalized := normalizeKey(actual, KeyMatchConvention)
+		if normalized == want {
+			conventions = append(conventions, actual)
+		} else if distance := editDistance(want, normalized); distance <= limit {
+			typos = append(typos, candidate{actual, distance})
+		}
+	}
+
+	suggestions := conventions
+	if len(suggestions) == 0 {
+		sort.SliceStable(typos, func(i, j int) bool { return typos[i].distance < typos[j].distance })
+		for _, typo := range typos {
+			suggestions = append(suggestions, typo.key)
+		}
+	}
+	if len(suggestions) == 0 {
+		return ""
+	}
+	if len(suggestions) > maxKeySuggestions {
+		suggestions = suggestions[:maxKeySuggestions]
+	}
+	quoted := make([]string, len(suggestions))
+	for i, suggestion := range suggestions {
+		quoted[i] = fmt.Sprintf("%q", suggestion)
+	}
+	if len(quoted) == 1 {
+		return fmt.Sprintf(" (did you mean %s?)", quoted[0])
+	}
+	return fmt.Sprintf(" (did you mean %s or %s?)", strings.Join(quoted[:len(quoted)-1], ", "), quoted[len(quoted)-1])
+}
+
+// editDistance counts the single-rune edits between two strings: insertions, deletions,
+// substitutions and swaps of adjacent runes, the typical typos
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+// sortedKeys returns the keys of an object in sorted order
+func sortedKeys(object map[string]interface{}) []string {
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+package apitest
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// labelNamePattern keeps label names usable as OpenMetrics label names
+var labelNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// reservedLabels are the label names the per-test metrics already use
+var reservedLabels = map[string]bool{"id": true, "test": true, "tags": true, "le": true}
+
+// labelFlags collects repeated -label key=value flags
+type labelFlags map[string]string
+
+// String renders the labels as sorted key=value pairs
+func (l labelFlags) String() string {
+	keys := make([]string, 0, len(l))
+	for key := range l {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + l[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set parses one key=value label
+func (l labelFlags) Set(value string) error {
+	key, val, ok := strings.Cut(value, "=")
+	if !ok {
+		return fmt.Errorf("label %q must be key=value", value)
+	}
+	if !labelNamePattern.MatchString(key) {
+		return fmt.Errorf("label name %q must match %s", key, labelNamePattern)
+	}
+	if reservedLabels[key] {
+		return fmt.Errorf("label name %q is reserved", key)
+	}
+	l[key] = val
+	return nil
+}
+
+// mergeLabels combines the labels of several reports; differing values for a key are joined
+func mergeLabels(reports []TestReport) map[string]string {
+	values := make(map[string]map[string]bool)
+	for _, report := range reports {
+		for key, value := range report.Labels {
+			if values[key] == nil {
+				values[key] = make(map[string]bool)
+			}
+			values[key][value] = true
+		}
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(values))
+	for key, set := range values {
+		merged[key] = joinDistinct(set)
+	}
+	return merged
+}
+
+// formatLabels renders run labels as OpenMetrics label pairs in sorted order
+func formatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var sb strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&sb, ",%s=%s", key, quoteLabelValue(labels[key]))
+	}
+	return sb.String()
+}
@@ -0,0 +1,172 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// latencyLimitPattern matches "baseline_<stat> [* factor] [+|- offset[ms]]", e.g. "baseline_p95 * 1.2"
+var latencyLimitPattern = regexp.MustCompile(`^baseline_(p\d{1,2}(?:\.\d+)?|p100|avg|min|max)\s*(?:\*\s*(\d+(?:\.\d+)?))?\s*(?:([+-])\s*(\d+(?:\.\d+)?)\s*(?:ms)?)?$`)
+
+// LatencyLimit is a max_response_time: fixed milliseconds, or a statistic of the test's response
+// times in the baseline reports scaled by a factor and shifted by an offset
+type LatencyLimit struct {
+	Ms       float64
+	Stat     string
+	Factor   float64
+	OffsetMs float64
+	raw      json.RawMessage
+}
+
+// UnmarshalJSON accepts a number of milliseconds or a baseline expression
+func (l *LatencyLimit) UnmarshalJSON(data []byte) error {
+	*l = LatencyLimit{raw: append(json.RawMessage(nil), data...)}
+	if ms, err := strconv.ParseFloat(string(data), 64); err == nil {
+		l.Ms = ms
+		return nil
+	}
+	var expression string
+	if err := json.Unmarshal(data, &expression); err != nil {
+		return fmt.Errorf("max_response_time must be milliseconds or a baseline expression")
+	}
+	expression = strings.TrimSpace(expression)
+	if ms, err := strconv.ParseFloat(strings.TrimSuffix(expression, "ms"), 64); err == nil {
+		l.Ms = ms
+		return nil
+	}
+	match := latencyLimitPattern.FindStringSubmatch(expression)
+	if match == nil {
+		return fmt.Errorf("max_response_time %q must be milliseconds or like \"baseline_p95 * 1.2\"", expression)
+	}
+	l.Stat, l.Factor = match[1], 1
+	if match[2] != "" {
+		l.Factor, _ = strconv.ParseFloat(match[2], 64)
+	}
+	if match[4] != "" {
+		l.OffsetMs, _ = strconv.ParseFloat(match[4], 64)
+		if match[3] == "-" {
+			l.OffsetMs = -l.OffsetMs
+		}
+	}
+	return nil
+}
+
+// MarshalJSON writes the limit as it was configured, so data-driven rows keep it
+func (l LatencyLimit) MarshalJSON() ([]byte, error) {
+	if l.raw != nil {
+		return l.raw, nil
+	}
+	return json.Marshal(l.Ms)
+}
+
+// durationMs converts a duration to milliseconds with microsecond precision, so fast local
+// endpoints don't all show up as 0ms
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// formatMs renders milliseconds for output: to the hundredth below 10ms, whole above
+func formatMs(ms float64) string {
+	if ms != 0 && math.Abs(ms) < 10 {
+		return fmt.Sprintf("%.2fms", ms)
+	}
+	return fmt.Sprintf("%.0fms", ms)
+}
+
+// describe renders a baseline limit with its numbers, e.g. "baseline_p95 312ms * 1.2 = 374ms"
+func (l LatencyLimit) describe(baseline, limit float64) string {
+	text := fmt.Sprintf("baseline_%s %s", l.Stat, formatMs(baseline))
+	if l.Factor != 1 {
+		text += fmt.Sprintf(" * %g", l.Factor)
+	}
+	if l.OffsetMs > 0 {
+		text += fmt.Sprintf(" + %gms", l.OffsetMs)
+	} else if l.OffsetMs < 0 {
+		text += fmt.Sprintf(" - %gms", -l.OffsetMs)
+	}
+	return fmt.Sprintf("%s = %s", text, formatMs(limit))
+}
+
+// baselineFlags collects repeated -baseline report paths
+type baselineFlags []string
+
+// String renders the report paths comma-separated
+func (b *baselineFlags) String() string {
+	return strings.Join(*b, ",")
+}
+
+// Set adds one report path
+func (b *baselineFlags) Set(value string) error {
+	*b = append(*b, value)
+	return nil
+}
+
+// LoadBaseline collects each test's response times from earlier reports; only passing results
+// count, since timeouts and errors would skew the baseline
+func (t *APITester) LoadBaseline(reports []TestReport) {
+	t.Baseline = make(map[string][]float64)
+	for _, report := range reports {
+		for _, result := range report.Results {
+			if result.Status == StatusPassed || result.Status == StatusFlaky {
+				key := resultKey(result)
+				t.Baseline[key] = append(t.Baseline[key], result.ResponseTimeMs)
+			}
+		}
+	}
+}
+
+// baselineStat computes a statistic over baseline response times; percentiles use the nearest rank
+func baselineStat(stat string, samples []float64) float64 {
+	sorted := append([]float64(nil), samples...)
+	sort.Float64s(sorted)
+	switch stat {
+	case "min":
+		return sorted[0]
+	case "max":
+		return sorted[len(sorted)-1]
+	case "avg":
+		sum := 0.0
+		for _, sample := range sorted {
+			sum += sample
+		}
+		return sum / float64(len(sorted))
+	}
+	p, _ := strconv.ParseFloat(strings.TrimPrefix(stat, "p"), 64)
+	rank := int(math.Ceil(p / 100 * float64(len(sorted))))
+	return sorted[min(max(rank, 1), len(sorted))-1]
+}
+
+// validateLatency checks a response time against the test's max_response_time. A baseline limit
+// without baseline data for the test only warns, so new tests don't fail before they have one.
+func (t *APITester) validateLatency(testCase TestCase, result *TestResult) {
+	limit := testCase.MaxResponseTime
+	if limit == nil {
+		return
+	}
+	if limit.Stat == "" {
+		if result.ResponseTimeMs > limit.Ms {
+			result.addError(CategoryLatency, fmt.Sprintf("Response time: Expected at most %gms, got %s",
+				limit.Ms, formatMs(result.ResponseTimeMs)))
+		}
+		return
+	}
+
+	samples := t.Baseline[testCaseKey(testCase)]
+	if len(samples) == 0 {
+		fmt.Fprintf(t.out, "  %s⚠ No baseline response times for max_response_time %s, not checked%s\n",
+			ColorYellow, limit.raw, ColorReset)
+		return
+	}
+	baseline := baselineStat(limit.Stat, samples)
+	allowed := baseline*limit.Factor + limit.OffsetMs
+	if result.ResponseTimeMs > allowed {
+		result.addError(CategoryLatency, fmt.Sprintf("Response time: Expected at most %s, got %s",
+			limit.describe(baseline, allowed), formatMs(result.ResponseTimeMs)))
+	}
+}
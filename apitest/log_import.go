@@ -0,0 +1,342 @@
+package apitest
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Access log formats read by import-logs
+const (
+	LogFormatAuto     = "auto"
+	LogFormatCombined = "combined"
+	LogFormatJSON     = "json"
+)
+
+// DefaultImportTop is how many endpoints import-logs writes tests for by default
+const DefaultImportTop = 20
+
+// ImportedTag marks the tests written by import-logs, so they stand out in results until reviewed
+const ImportedTag = "imported"
+
+// commonLogPattern matches the common log format and its combined extension:
+// host ident user [time] "METHOD target PROTOCOL" status bytes ["referer" "user-agent"]
+var commonLogPattern = regexp.MustCompile(`^\S+ \S+ .*?\[[^\]]+\] "([A-Z]+) (\S+)(?: [^"]*)?" (\d{3}) `)
+
+// idSegmentPattern matches path segments that are ids rather than names: numbers, UUIDs and long hex
+var idSegmentPattern = regexp.MustCompile(`^(\d+|[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}|[0-9a-fA-F]{16,})$`)
+
+// sensitiveParamPattern matches query parameters left out of imported tests, since their logged
+// values are credentials that mustn't end up in a config
+var sensitiveParamPattern = regexp.MustCompile(`(?i)token|key|secret|pass|auth|sig|session`)
+
+// Where JSON access logs keep the request, by logger: plain fields, nginx, ECS and Google Cloud
+var (
+	jsonLogMethodPaths = []string{"method", "request_method", "http_method", "http.request.method", "httpRequest.requestMethod"}
+	jsonLogTargetPaths = []string{"path", "uri", "request_uri", "url", "url.original", "httpRequest.requestUrl"}
+	jsonLogStatusPaths = []string{"status", "status_code", "response_status", "http.response.status_code", "httpRequest.status"}
+)
+
+// loggedRequest is one request read from an access log
+type loggedRequest struct {
+	Method string
+	Target string
+	Status int
+}
+
+// endpointTraffic counts the requests to one method and path template
+type endpointTraffic struct {
+	Method   string
+	Template string
+	Count    int
+	statuses map[int]int
+	targets  map[string]int
+}
+
+// runImportLogsCommand implements `import-logs`, which writes candidate tests for the busiest
+// endpoints in access logs
+func runImportLogsCommand(args []string) int {
+	fs := flag.NewFlagSet("import-logs", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the config to file (default: stdout)")
+	top := fs.Int("top", DefaultImportTop, "Write tests for this many endpoints, busiest first")
+	format := fs.String("format", LogFormatAuto, "Log format: auto, combined (also common) or json (one object per line)")
+	exclude := fs.String("exclude", "", "Leave out request paths matching this regular expression, e.g. \\.(css|js|png)$")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s import-logs [-top 20] [-format auto|combined|json] [-exclude regex] [-o test_cases.json] <access.log>...\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "%sError: Access log path required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	if *format != LogFormatAuto && *format != LogFormatCombined && *format != LogFormatJSON {
+		fmt.Fprintf(os.Stderr, "%sError: -format must be %s, %s or %s%s\n\n", ColorRed, LogFormatAuto, LogFormatCombined, LogFormatJSON, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	if *top < 1 {
+		fmt.Fprintf(os.Stderr, "%sError: -top must be at least 1%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	var excluded *regexp.Regexp
+	if *exclude != "" {
+		if excluded, err = regexp.Compile(*exclude); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: invalid -exclude: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+	}
+
+	traffic := make(map[string]*endpointTraffic)
+	total, unparsed := 0, 0
+	for _, path := range paths {
+		requests, skipped, err := readAccessLog(path, *format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+		unparsed += skipped
+		for _, request := range requests {
+			template := pathTemplate(request.Target)
+			if excluded != nil && excluded.MatchString(strings.SplitN(request.Target, "?", 2)[0]) {
+				continue
+			}
+			key := request.Method + " " + template
+			endpoint, ok := traffic[key]
+			if !ok {
+				endpoint = &endpointTraffic{Method: request.Method, Template: template,
+					statuses: make(map[int]int), targets: make(map[string]int)}
+				traffic[key] = endpoint
+			}
+			endpoint.Count++
+			endpoint.statuses[request.Status]++
+			endpoint.targets[request.Target]++
+			total++
+		}
+	}
+	if total == 0 {
+		fmt.Fprintf(os.Stderr, "%sError: no requests found in the logs (%d lines not understood)%s\n", ColorRed, unparsed, ColorReset)
+		return 1
+	}
+
+	endpoints := make([]*endpointTraffic, 0, len(traffic))
+	for _, endpoint := range traffic {
+		endpoints = append(endpoints, endpoint)
+	}
+	sort.Slice(endpoints, func(i, j int) bool {
+		if endpoints[i].Count != endpoints[j].Count {
+			return endpoints[i].Count > endpoints[j].Count
+		}
+		return endpoints[i].Method+" "+endpoints[i].Template < endpoints[j].Method+" "+endpoints[j].Template
+	})
+	if len(endpoints) > *top {
+		endpoints = endpoints[:*top]
+	}
+
+	config := generatedConfig{Schema: ConfigSchemaID}
+	var warnings []string
+	for i, endpoint := range endpoints {
+		test, warning := endpoint.test(i + 1)
+		config.TestCases = append(config.TestCases, test)
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to marshal config: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	data = append(data, '\n')
+
+	// Progress goes to stderr so a config written to stdout stays clean
+	for _, endpoint := range endpoints {
+		fmt.Fprintf(os.Stderr, "  %6d  %5.1f%%  %s %s\n", endpoint.Count, float64(endpoint.Count)*100/float64(total),
+			endpoint.Method, endpoint.Template)
+	}
+	for _, message := range warnings {
+		fmt.Fprintf(os.Stderr, "%s⚠ %s%s\n", ColorYellow, message, ColorReset)
+	}
+	if unparsed > 0 {
+		fmt.Fprintf(os.Stderr, "%s⚠ %d log lines were not understood and were left out%s\n", ColorYellow, unparsed, ColorReset)
+	}
+	fmt.Fprintf(os.Stderr, "%s✓ Generated %d tests from %d requests to %d endpoints%s\n",
+		ColorGreen, len(config.TestCases), total, len(traffic), ColorReset)
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write config: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s✓ Config written to: %s%s\n", ColorGreen, *output, ColorReset)
+	return 0
+}
+
+// readAccessLog reads the requests of an access log, returning how many lines it couldn't parse
+func readAccessLog(path, format string) ([]loggedRequest, int, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read access log: %w", err)
+	}
+	defer file.Close()
+
+	var requests []loggedRequest
+	skipped := 0
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var request loggedRequest
+		var ok bool
+		if format == LogFormatJSON || (format == LogFormatAuto && strings.HasPrefix(line, "{")) {
+			request, ok = parseJSONLogLine(line)
+		} else {
+			request, ok = parseCommonLogLine(line)
+		}
+		if !ok {
+			skipped++
+			continue
+		}
+		requests = append(requests, request)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to read access log %s: %w", path, err)
+	}
+	return requests, skipped, nil
+}
+
+// parseCommonLogLine reads a line in common or combined log format
+func parseCommonLogLine(line string) (loggedRequest, bool) {
+	match := commonLogPattern.FindStringSubmatch(line + " ")
+	if match == nil {
+		return loggedRequest{}, false
+	}
+	status, _ := strconv.Atoi(match[3])
+	return newLoggedRequest(match[1], match[2], status)
+}
+
+// parseJSONLogLine reads a JSON access log line, finding its fields under the names common loggers use.
+// A "request" field like "GET /users HTTP/1.1" stands in for the method and path.
+func parseJSONLogLine(line string) (loggedRequest, bool) {
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(line), &entry); err != nil {
+		return loggedRequest{}, false
+	}
+	first := func(paths []string) string {
+		for _, path := range paths {
+			if value := getNestedValue(entry, path); value != nil {
+				return fmt.Sprintf("%v", value)
+			}
+		}
+		return ""
+	}
+	method, target := first(jsonLogMethodPaths), first(jsonLogTargetPaths)
+	if request, ok := entry["request"].(string); ok && (method == "" || target == "") {
+		if fields := strings.Fields(request); len(fields) >= 2 {
+			method, target = fields[0], fields[1]
+		}
+	}
+	status, _ := strconv.ParseFloat(first(jsonLogStatusPaths), 64)
+	return newLoggedRequest(method, target, int(status))
+}
+
+// newLoggedRequest checks a parsed request and reduces an absolute target to its path and query
+func newLoggedRequest(method, target string, status int) (loggedRequest, bool) {
+	if parsed, err := url.Parse(target); err == nil && parsed.IsAbs() {
+		target = parsed.RequestURI()
+	}
+	if method == "" || !strings.HasPrefix(target, "/") || status < 100 || status > 599 {
+		return loggedRequest{}, false
+	}
+	return loggedRequest{Method: strings.ToUpper(method), Target: target, Status: status}, true
+}
+
+// pathTemplate groups requests by endpoint: the path without its query, with id segments as {id}
+func pathTemplate(target string) string {
+	path := strings.SplitN(target, "?", 2)[0]
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if idSegmentPattern.MatchString(segment) {
+			segments[i] = "{id}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// test writes the candidate test of an endpoint: its most requested concrete path, expecting
+// the status it answered most often. The warning says what needs a look before the test runs.
+func (e *endpointTraffic) test(order int) (generatedTest, string) {
+	name := e.Method + " " + e.Template
+	target := mostFrequent(e.targets)
+	path, query, _ := strings.Cut(target, "?")
+	test := generatedTest{
+		Order:              order,
+		TestCaseName:       name,
+		API:                path,
+		Method:             e.Method,
+		ExpectedStatusCode: mostFrequent(e.statuses),
+		Tags:               []string{ImportedTag},
+	}
+
+	var dropped []string
+	if values, err := url.ParseQuery(query); err == nil {
+		for param, value := range values {
+			if sensitiveParamPattern.MatchString(param) {
+				dropped = append(dropped, param)
+				continue
+			}
+			if test.Params == nil {
+				test.Params = make(map[string]string)
+			}
+			test.Params[param] = value[0]
+		}
+	}
+	sort.Strings(dropped)
+
+	var notes []string
+	switch e.Method {
+	case "POST", "PUT", "PATCH":
+		notes = append(notes, "access logs don't hold request bodies, so add its body")
+	}
+	if test.ExpectedStatusCode >= 500 {
+		notes = append(notes, fmt.Sprintf("it mostly answered %d, so check the expected status", test.ExpectedStatusCode))
+	}
+	if len(dropped) > 0 {
+		notes = append(notes, "query parameters that look like credentials were left out: "+strings.Join(dropped, ", "))
+	}
+	if len(notes) == 0 {
+		return test, ""
+	}
+	return test, fmt.Sprintf("%s: %s", name, strings.Join(notes, "; "))
+}
+
+// mostFrequent returns the key seen most often, the smallest one on a tie so output is stable
+func mostFrequent[K int | string](counts map[K]int) K {
+	var best K
+	bestCount := 0
+	for key, count := range counts {
+		if count > bestCount || (count == bestCount && key < best) {
+			best, bestCount = key, count
+		}
+	}
+	return best
+}
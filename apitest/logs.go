@@ -0,0 +1,232 @@
+package apitest
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Log query defaults
+const (
+	DefaultRequestIDHeader = "X-Request-Id"
+	DefaultLogLimit        = 50
+	LogQueryTimeout        = 10 * time.Second
+)
+
+// LogQuery fetches the backend log lines of a failed test from a log store such as Loki or
+// Elasticsearch. Its url, params, headers and body may use {{request_id}}, {{start}} and {{end}}
+// (RFC 3339), {{start_ns}} and {{end_ns}} (Unix nanoseconds), besides variables and resolvers.
+type LogQuery struct {
+	URL     string            `json:"url"`
+	Method  string            `json:"method"`
+	Headers map[string]string `json:"headers"`
+	Params  map[string]string `json:"params"`
+	Body    interface{}       `json:"body"`
+	// Lines is the path to the log lines in a JSON answer; without it the answer is split into lines
+	Lines string `json:"lines"`
+	Limit int    `json:"limit"`
+	// DelayMs waits for the log store to ingest the lines before querying
+	DelayMs int `json:"delay_ms"`
+	// RequestIDHeader carries the request id; a test setting it keeps its own value, and the
+	// server's value wins when the response has the header too
+	RequestIDHeader string `json:"request_id_header"`
+}
+
+// check validates a log query when the config loads
+func (q *LogQuery) check() error {
+	if q == nil {
+		return nil
+	}
+	if !strings.HasPrefix(q.URL, "http://") && !strings.HasPrefix(q.URL, "https://") && !strings.HasPrefix(q.URL, "{{") {
+		return fmt.Errorf("logs: url must be an http(s) URL")
+	}
+	if q.Limit < 0 || q.DelayMs < 0 {
+		return fmt.Errorf("logs: limit and delay_ms must not be negative")
+	}
+	return nil
+}
+
+// requestIDHeader returns the header carrying request ids, or "" when logs aren't queried
+func (t *APITester) requestIDHeader() string {
+	if t.Logs == nil {
+		return ""
+	}
+	if t.Logs.RequestIDHeader != "" {
+		return t.Logs.RequestIDHeader
+	}
+	return DefaultRequestIDHeader
+}
+
+// newRequestID returns a random UUIDv4 to correlate one test's requests with backend logs
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	id[6] = id[6]&0x0f | 0x40
+	id[8] = id[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:])
+}
+
+// fetchLogs queries the log store for a failed test's request id and attaches the lines to its result
+func (t *APITester) fetchLogs(result *TestResult) {
+	if t.Logs == nil || result.RequestID == "" {
+		return
+	}
+	if t.Logs.DelayMs > 0 {
+		select {
+		case <-time.After(time.Duration(t.Logs.DelayMs) * time.Millisecond):
+		case <-t.Context.Done():
+			return
+		}
+	}
+
+	lines, err := t.queryLogs(result.RequestID, result.startedAt, time.Now())
+	if err != nil {
+		fmt.Fprintf(t.out, "  %s⚠ Failed to fetch logs for request %s: %v%s\n", ColorYellow, result.RequestID, t.maskSecrets(err.Error()), ColorReset)
+		return
+	}
+	limit := t.Logs.Limit
+	if limit == 0 {
+		limit = DefaultLogLimit
+	}
+	if len(lines) > limit {
+		lines = lines[len(lines)-limit:]
+	}
+	result.Logs = lines
+
+	fmt.Fprintf(t.out, "  %s↳ %d log lines for request %s%s\n", ColorCyan, len(lines), result.RequestID, ColorReset)
+	for _, line := range lines {
+		fmt.Fprintf(t.out, "    %s│ %s%s\n", ColorCyan, t.maskSecrets(line), ColorReset)
+	}
+}
+
+// queryLogs sends the log query for a request id and time window and returns the matching lines
+func (t *APITester) queryLogs(requestID string, start, end time.Time) ([]string, error) {
+	if start.IsZero() {
+		start = end
+	}
+	// Leave room for clock skew between this machine and the backend
+	start = start.Add(-time.Minute)
+	end = end.Add(time.Minute)
+	fill := strings.NewReplacer(
+		"{{request_id}}", requestID,
+		"{{start}}", start.UTC().Format(time.RFC3339),
+		"{{end}}", end.UTC().Format(time.RFC3339),
+		"{{start_ns}}", strconv.FormatInt(start.UnixNano(), 10),
+		"{{end_ns}}", strconv.FormatInt(end.UnixNano(), 10),
+	).Replace
+	render := func(value string) string { return t.replaceVariables(fill(value)) }
+
+	target, err := url.Parse(render(t.Logs.URL))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	query := target.Query()
+	for key, value := range t.Logs.Params {
+		query.Add(key, render(value))
+	}
+	target.RawQuery = query.Encode()
+
+	var body io.Reader
+	if t.Logs.Body != nil {
+		encoded, err := json.Marshal(t.Logs.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal body: %w", err)
+		}
+		// Fill placeholders inside the JSON strings, escaped for them
+		filled := placeholderPattern.ReplaceAllStringFunc(string(encoded), func(match string) string {
+			quoted, _ := json.Marshal(render(match))
+			return string(quoted[1 : len(quoted)-1])
+		})
+		body = strings.NewReader(filled)
+	}
+	method := strings.ToUpper(t.Logs.Method)
+	if method == "" {
+		method = http.MethodGet
+	}
+	if errs := t.takeResolveErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	ctx, cancel := context.WithTimeout(t.Context, LogQueryTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, method, target.String(), body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for key, value := range t.Logs.Headers {
+		req.Header.Set(key, render(value))
+	}
+	if errs := t.takeResolveErrors(); len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read answer: %w", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return nil, fmt.Errorf("log store answered HTTP %d", resp.StatusCode)
+	}
+	return t.logLines(data)
+}
+
+// logLines picks the log lines out of a log store's answer
+func (t *APITester) logLines(data []byte) ([]string, error) {
+	if t.Logs.Lines == "" {
+		var lines []string
+		for _, line := range strings.Split(string(bytes.TrimSpace(data)), "\n") {
+			if line = strings.TrimRight(line, "\r"); line != "" {
+				lines = append(lines, line)
+			}
+		}
+		return lines, nil
+	}
+
+	var answer interface{}
+	if err := t.unmarshalJSON(data, &answer); err != nil {
+		return nil, fmt.Errorf("failed to parse answer: %w", err)
+	}
+	value, err := lookupPath(answer, t.Logs.Lines)
+	if err != nil {
+		return nil, err
+	}
+	values, ok := value.([]interface{})
+	if !ok {
+		if value == nil {
+			return nil, nil
+		}
+		values = []interface{}{value}
+	}
+	lines := make([]string, 0, len(values))
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+		if text, ok := value.(string); ok {
+			lines = append(lines, text)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, string(encoded))
+	}
+	return lines, nil
+}
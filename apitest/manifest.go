@@ -0,0 +1,111 @@
+package apitest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+)
+
+// Where a variable's value came from, as recorded in the run manifest
+const (
+	SourceConfig  = "config"
+	SourceSet     = "-set"
+	SourceBuiltin = "built-in"
+)
+
+// runIDPattern keeps -run-id values usable in file names, bucket keys and environment variables
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// RunManifest records what a run was made of, so it can be reproduced and audited later
+type RunManifest struct {
+	RunID        string   `json:"run_id"`
+	ToolVersion  string   `json:"tool_version"`
+	Revision     string   `json:"revision,omitempty"`
+	GoVersion    string   `json:"go_version"`
+	Platform     string   `json:"platform"`
+	Args         []string `json:"args"`
+	ConfigSHA256 string   `json:"config_sha256"`
+	Environment  string   `json:"environment,omitempty"`
+	// Seed drives every random choice of the run, such as retry jitter; pass it to -seed to repeat them
+	Seed uint64 `json:"seed"`
+	// Variables maps each variable to where its last value came from: config, -set, built-in,
+	// or the test that extracted it
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+// SetSeed seeds the run's random choices; a zero seed picks a random one
+func (t *APITester) SetSeed(seed uint64) {
+	if seed == 0 {
+		seed = rand.Uint64()
+	}
+	t.Seed = seed
+	t.random = rand.New(rand.NewPCG(seed, seed))
+}
+
+// SetRunID replaces the generated run id, e.g. with the CI build's
+func (t *APITester) SetRunID(id string) error {
+	if !runIDPattern.MatchString(id) {
+		return fmt.Errorf("run id %q may only use letters, digits, '.', '_' and '-'", id)
+	}
+	t.RunID = id
+	return nil
+}
+
+// setVariable stores a variable and where its value came from
+func (t *APITester) setVariable(name string, value interface{}, source string) {
+	t.Variables[name] = value
+	if t.variableSources == nil {
+		t.variableSources = make(map[string]string)
+	}
+	t.variableSources[name] = source
+}
+
+// hashConfig records the digest of the config file as loaded
+func (t *APITester) hashConfig(content []byte) {
+	digest := sha256.Sum256(content)
+	t.configHash = hex.EncodeToString(digest[:])
+}
+
+// toolVersion returns the module version and VCS revision the binary was built from
+func toolVersion() (version, revision string) {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "unknown", ""
+	}
+	version = info.Main.Version
+	modified := false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision != "" && modified {
+		revision += "-dirty"
+	}
+	return version, revision
+}
+
+// manifest describes the current run
+func (t *APITester) manifest() *RunManifest {
+	version, revision := toolVersion()
+	return &RunManifest{
+		RunID:        t.RunID,
+		ToolVersion:  version,
+		Revision:     revision,
+		GoVersion:    runtime.Version(),
+		Platform:     runtime.GOOS + "/" + runtime.GOARCH,
+		Args:         t.maskStrings(os.Args[1:]),
+		ConfigSHA256: t.configHash,
+		Environment:  t.Environment,
+		Seed:         t.Seed,
+		Variables:    t.variableSources,
+	}
+}
@@ -0,0 +1,191 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// matcherFunc checks an actual value against a matcher's argument, returning failure messages
+// without the path prefix
+type matcherFunc func(t *APITester, arg, actual interface{}) []assertionError
+
+// matchers are the "$name" keys an expected object may use to check the value itself instead of
+// its fields, e.g. {"$length": 3}. Other keys starting with "$" are ordinary field names.
+var matchers = map[string]matcherFunc{
+	"$length":    lengthMatcher,
+	"$empty":     emptyMatcher,
+	"$sorted_by": sortedByMatcher,
+	"$unique":    uniqueMatcher,
+}
+
+// validateMatchers applies the matcher keys of an expected object to the actual value,
+// returning the remaining keys for the usual field-by-field validation
+func (t *APITester) validateMatchers(expected map[string]interface{}, actual interface{}, path string) (map[string]interface{}, []assertionError) {
+	var names []string
+	for key := range expected {
+		if _, ok := matchers[key]; ok {
+			names = append(names, key)
+		}
+	}
+	if len(names) == 0 {
+		return expected, nil
+	}
+	sort.Strings(names)
+
+	rest := make(map[string]interface{}, len(expected)-len(names))
+	for key, value := range expected {
+		if _, ok := matchers[key]; !ok {
+			rest[key] = value
+		}
+	}
+	label := path
+	if label == "" {
+		label = "(root)"
+	}
+	var errors []assertionError
+	for _, name := range names {
+		for _, err := range matchers[name](t, expected[name], actual) {
+			err.Message = fmt.Sprintf("%s: %s", label, err.Message)
+			errors = append(errors, err)
+		}
+	}
+	return rest, errors
+}
+
+// hasMatchers reports whether an expected object uses any matcher keys
+func hasMatchers(expected map[string]interface{}) bool {
+	for key := range expected {
+		if _, ok := matchers[key]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// countOf returns the number of items, keys or characters of an array, object or string
+func countOf(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case []interface{}:
+		return len(v), true
+	case map[string]interface{}:
+		return len(v), true
+	case string:
+		return utf8.RuneCountInString(v), true
+	}
+	return 0, false
+}
+
+// lengthMatcher requires an array, object or string to have exactly N items, keys or characters
+func lengthMatcher(_ *APITester, arg, actual interface{}) []assertionError {
+	want, err := toNumber(arg)
+	if err != nil || want != float64(int(want)) || want < 0 {
+		return []assertionError{{CategoryRequest, fmt.Sprintf("$length: Expected a whole number, got %v", arg)}}
+	}
+	count, ok := countOf(actual)
+	if !ok {
+		return []assertionError{{CategorySchema, fmt.Sprintf("$length: Expected array, object or string, got %s", formatJSONContext(actual, 0))}}
+	}
+	if count != int(want) {
+		return []assertionError{{CategoryBody, fmt.Sprintf("$length: Expected %d, got %d", int(want), count)}}
+	}
+	return nil
+}
+
+// emptyMatcher requires an array, object or string to be empty ("$empty": true) or not (false)
+func emptyMatcher(_ *APITester, arg, actual interface{}) []assertionError {
+	want, ok := arg.(bool)
+	if !ok {
+		return []assertionError{{CategoryRequest, fmt.Sprintf("$empty: Expected true or false, got %v", arg)}}
+	}
+	count, ok := countOf(actual)
+	if !ok {
+		return []assertionError{{CategorySchema, fmt.Sprintf("$empty: Expected array, object or string, got %s", formatJSONContext(actual, 0))}}
+	}
+	if want && count > 0 {
+		return []assertionError{{CategoryBody, fmt.Sprintf("$empty: Expected empty, got %d items", count)}}
+	}
+	if !want && count == 0 {
+		return []assertionError{{CategoryBody, "$empty: Expected not empty, got empty"}}
+	}
+	return nil
+}
+
+// uniqueMatcher requires the items of an array to be distinct: by a field ("$unique": "id"), by
+// several fields together ("tenant_id, id"), or as a whole ("$unique": true)
+func uniqueMatcher(_ *APITester, arg, actual interface{}) []assertionError {
+	var paths []string
+	switch v := arg.(type) {
+	case bool:
+		if !v {
+			return []assertionError{{CategoryRequest, "$unique: Expected true or a field name, got false"}}
+		}
+	case string:
+		for _, path := range strings.Split(v, ",") {
+			if path = strings.TrimSpace(path); path == "" {
+				return []assertionError{{CategoryRequest, fmt.Sprintf("$unique: Expected field names separated by commas, got %q", v)}}
+			}
+			paths = append(paths, path)
+		}
+	default:
+		return []assertionError{{CategoryRequest, fmt.Sprintf("$unique: Expected true or a field name, got %v", arg)}}
+	}
+	items, ok := actual.([]interface{})
+	if !ok {
+		return []assertionError{{CategorySchema, fmt.Sprintf("$unique: Expected array, got %s", formatJSONContext(actual, 0))}}
+	}
+
+	seen := make(map[string]int, len(items))
+	var first string
+	duplicates := 0
+	for i, item := range items {
+		values := []interface{}{item}
+		if paths != nil {
+			values = make([]interface{}, len(paths))
+			for j, path := range paths {
+				if values[j] = getNestedValue(item, path); values[j] == nil {
+					return []assertionError{{CategorySchema, fmt.Sprintf("$unique: Item %d has no %s", i, path)}}
+				}
+			}
+		}
+		key := uniqueKey(values)
+		earlier, repeated := seen[key]
+		if !repeated {
+			seen[key] = i
+			continue
+		}
+		duplicates++
+		switch {
+		case first != "":
+		case paths == nil:
+			first = fmt.Sprintf("item %d repeats item %d", i, earlier)
+		default:
+			shown := make([]string, len(paths))
+			for j, path := range paths {
+				shown[j] = path + " " + formatJSONContext(values[j], 0)
+			}
+			first = fmt.Sprintf("item %d repeats %s of item %d", i, strings.Join(shown, ", "), earlier)
+		}
+	}
+	if duplicates > 0 {
+		return []assertionError{{CategoryBody, fmt.Sprintf("$unique: Expected no duplicates, got %d; %s", duplicates, first)}}
+	}
+	return nil
+}
+
+// uniqueKey identifies values for $unique, treating numbers that are equal in value as the same
+// whatever their representation, e.g. 1 and 1.0
+func uniqueKey(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, value := range values {
+		if rat, ok := numberRat(value); ok {
+			parts[i] = rat.RatString()
+			continue
+		}
+		encoded, _ := json.Marshal(value)
+		parts[i] = string(encoded)
+	}
+	return strings.Join(parts, "\x00")
+}
@@ -0,0 +1,222 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// MatrixCellSeparator joins the values of a cell with several headers, e.g. "de/EUR"
+const MatrixCellSeparator = "/"
+
+// HeaderMatrix runs a test once per combination of header values. JSON accepts an object of
+// header names to values, or the name of one of the config's matrices.
+type HeaderMatrix struct {
+	Name    string
+	Headers map[string][]string
+}
+
+// UnmarshalJSON accepts "locales" or {"Accept-Language": ["en", "de"]}
+func (m *HeaderMatrix) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*m = HeaderMatrix{Name: name}
+		return nil
+	}
+	var headers map[string][]string
+	if err := json.Unmarshal(data, &headers); err != nil {
+		return fmt.Errorf("matrix must be a matrix name or an object of header names to values")
+	}
+	*m = HeaderMatrix{Headers: headers}
+	return nil
+}
+
+// MarshalJSON writes the matrix back in the form it was given
+func (m HeaderMatrix) MarshalJSON() ([]byte, error) {
+	if m.Name != "" {
+		return json.Marshal(m.Name)
+	}
+	return json.Marshal(m.Headers)
+}
+
+// matrixCell is one combination of header values
+type matrixCell struct {
+	label   string
+	headers map[string]string
+}
+
+// matrixCells returns every combination of a matrix's header values, headers in name order
+func matrixCells(headers map[string][]string) []matrixCell {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	cells := []matrixCell{{headers: map[string]string{}}}
+	for _, name := range names {
+		var next []matrixCell
+		for _, cell := range cells {
+			for _, value := range headers[name] {
+				combined := make(map[string]string, len(cell.headers)+1)
+				for key, val := range cell.headers {
+					combined[key] = val
+				}
+				combined[name] = value
+				label := value
+				if cell.label != "" {
+					label = cell.label + MatrixCellSeparator + value
+				}
+				next = append(next, matrixCell{label: label, headers: combined})
+			}
+		}
+		cells = next
+	}
+	return cells
+}
+
+// expandMatrix turns a test with a header matrix into one test per cell, grouped under the
+// original name. Each cell sets its headers and merges its matrix_expected entry, if any, into
+// the shared expected_response.
+func (t *APITester) expandMatrix(testCase TestCase, matrices map[string]map[string][]string) ([]TestCase, error) {
+	if testCase.Matrix == nil {
+		if testCase.MatrixExpected != nil {
+			return nil, fmt.Errorf("matrix_expected needs a matrix")
+		}
+		return []TestCase{testCase}, nil
+	}
+
+	headers := testCase.Matrix.Headers
+	if name := testCase.Matrix.Name; name != "" {
+		var ok bool
+		if headers, ok = matrices[name]; !ok {
+			names := make([]string, 0, len(matrices))
+			for name := range matrices {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("unknown matrix %q (available: %s)", name, strings.Join(names, ", "))
+		}
+	}
+	if len(headers) == 0 {
+		return nil, fmt.Errorf("matrix needs at least one header")
+	}
+	for name, values := range headers {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix header %s has no values", name)
+		}
+	}
+	cells := matrixCells(headers)
+	labels := make(map[string]bool, len(cells))
+	for _, cell := range cells {
+		labels[cell.label] = true
+	}
+	for label := range testCase.MatrixExpected {
+		if !labels[label] {
+			names := make([]string, 0, len(cells))
+			for _, cell := range cells {
+				names = append(names, cell.label)
+			}
+			return nil, fmt.Errorf("matrix_expected %q matches no matrix cell (cells: %s)", label, strings.Join(names, ", "))
+		}
+	}
+
+	group := testCase.Group
+	if group == "" {
+		group = testCase.TestCaseName
+	}
+	perCell := testCase.MatrixExpected
+	testCase.Matrix = nil
+	testCase.MatrixExpected = nil
+	template, err := json.Marshal(testCase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal test: %w", err)
+	}
+
+	expanded := make([]TestCase, 0, len(cells))
+	for _, cell := range cells {
+		var cellCase TestCase
+		if err := t.unmarshalJSON(template, &cellCase); err != nil {
+			return nil, fmt.Errorf("failed to copy test: %w", err)
+		}
+		if cellCase.Headers == nil {
+			cellCase.Headers = make(map[string]HeaderValues)
+		}
+		for name, value := range cell.headers {
+			// The cell's value replaces the test's own, whatever its case
+			for key := range cellCase.Headers {
+				if http.CanonicalHeaderKey(key) == http.CanonicalHeaderKey(name) {
+					delete(cellCase.Headers, key)
+				}
+			}
+			cellCase.Headers[name] = HeaderValues{value}
+		}
+		if override, ok := perCell[cell.label]; ok {
+			merged, _ := mergeExpected(cellCase.ExpectedResponse, override).(map[string]interface{})
+			cellCase.ExpectedResponse = merged
+		}
+		cellCase.Group = group
+		cellCase.TestCaseName = fmt.Sprintf("%s [%s]", testCase.TestCaseName, cell.label)
+		if testCase.ID != "" {
+			cellCase.ID = fmt.Sprintf("%s[%s]", testCase.ID, cell.label)
+		}
+		cellCase.matrixCell = cell.headers
+		expanded = append(expanded, cellCase)
+	}
+	return expanded, nil
+}
+
+// mergeExpected overlays a cell's expected values on the shared ones: objects merge key by key,
+// anything else is replaced
+func mergeExpected(shared, override interface{}) interface{} {
+	sharedMap, ok := shared.(map[string]interface{})
+	overrideMap, isMap := override.(map[string]interface{})
+	if !ok || !isMap {
+		return override
+	}
+	merged := make(map[string]interface{}, len(sharedMap)+len(overrideMap))
+	for key, value := range sharedMap {
+		merged[key] = value
+	}
+	for key, value := range overrideMap {
+		merged[key] = mergeExpected(sharedMap[key], value)
+	}
+	return merged
+}
+
+// matrixLabel returns the cell label of a result, in the header order its matrix uses
+func matrixLabel(cell map[string]string) string {
+	names := make([]string, 0, len(cell))
+	for name := range cell {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	values := make([]string, len(names))
+	for i, name := range names {
+		values[i] = cell[name]
+	}
+	return strings.Join(values, MatrixCellSeparator)
+}
+
+// formatMatrixCells renders the cells of a matrix group as "en ✓  de ✗  fr ✓"
+func formatMatrixCells(results []TestResult) string {
+	var cells []string
+	for _, result := range results {
+		if result.Matrix == nil {
+			continue
+		}
+		mark := ColorGreen + "✓"
+		switch result.Status {
+		case StatusFailed, StatusTimeout, StatusError:
+			mark = ColorRed + "✗"
+		case StatusFlaky:
+			mark = ColorYellow + "~"
+		case StatusSkipped:
+			mark = ColorYellow + "⊘"
+		}
+		cells = append(cells, fmt.Sprintf("%s %s%s", matrixLabel(result.Matrix), mark, ColorReset))
+	}
+	return strings.Join(cells, "  ")
+}
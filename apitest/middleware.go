@@ -0,0 +1,211 @@
+package apitest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Middleware wraps the transport every test request goes through, so cross-cutting behavior such as
+// tracing headers, metrics or fault injection can change requests and responses on the way
+type Middleware func(next http.RoundTripper) http.RoundTripper
+
+// RoundTripFunc adapts a function to the http.RoundTripper interface
+type RoundTripFunc func(req *http.Request) (*http.Response, error)
+
+// RoundTrip calls f(req)
+func (f RoundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// Use appends middleware to the chain; the first one added sees each request first and its response
+// last. The config's middleware runs after all middleware added in code.
+func (t *APITester) Use(middleware ...Middleware) {
+	t.middleware = append(t.middleware, middleware...)
+}
+
+// MiddlewareConfig is a middleware declared in the config, applied to the requests it matches
+type MiddlewareConfig struct {
+	Name string `json:"name"`
+	// Match limits the middleware to requests whose path matches this regular expression
+	Match   string   `json:"match"`
+	Methods []string `json:"methods"`
+	// RequestHeaders and ResponseHeaders are set on every matching request and response; an empty
+	// value removes the header. Request header values may use {{variables}}.
+	RequestHeaders  map[string]string `json:"request_headers"`
+	ResponseHeaders map[string]string `json:"response_headers"`
+	DelayMs         int               `json:"delay_ms"`
+	Fault           *FaultConfig      `json:"fault"`
+
+	match *regexp.Regexp
+}
+
+// FaultConfig fails a share of the matching requests without sending them
+type FaultConfig struct {
+	// Rate is the share of requests that fail, from 0 to 1
+	Rate float64 `json:"rate"`
+	// Status answers with this status and Body; Error fails the request with "refused", "reset"
+	// or "timeout" instead
+	Status int    `json:"status"`
+	Body   string `json:"body"`
+	Error  string `json:"error"`
+}
+
+// errInjectedFault marks a request failed by a fault middleware rather than the network
+var errInjectedFault = errors.New("injected fault")
+
+// faultErrors are the transport errors a fault can inject, matching what the real failure returns
+var faultErrors = map[string]error{
+	"refused": syscall.ECONNREFUSED,
+	"reset":   syscall.ECONNRESET,
+	"timeout": os.ErrDeadlineExceeded,
+}
+
+// loadMiddleware checks the config's middleware and compiles their path patterns
+func (t *APITester) loadMiddleware(configs []MiddlewareConfig) error {
+	t.configMiddleware = nil
+	for i := range configs {
+		config := &configs[i]
+		label := fmt.Sprintf("middleware %d", i+1)
+		if config.Name != "" {
+			label = fmt.Sprintf("middleware %q", config.Name)
+		} else {
+			config.Name = label
+		}
+		if config.Match != "" {
+			match, err := regexp.Compile(config.Match)
+			if err != nil {
+				return fmt.Errorf("%s: invalid match: %w", label, err)
+			}
+			config.match = match
+		}
+		if config.DelayMs < 0 {
+			return fmt.Errorf("%s: delay_ms must not be negative", label)
+		}
+		if fault := config.Fault; fault != nil {
+			if fault.Rate < 0 || fault.Rate > 1 {
+				return fmt.Errorf("%s: fault rate must be between 0 and 1", label)
+			}
+			if (fault.Status == 0) == (fault.Error == "") {
+				return fmt.Errorf("%s: fault needs either status or error", label)
+			}
+			if _, ok := faultErrors[fault.Error]; fault.Error != "" && !ok {
+				return fmt.Errorf("%s: fault error must be refused, reset or timeout, got %q", label, fault.Error)
+			}
+		}
+		t.configMiddleware = append(t.configMiddleware, t.configuredMiddleware(*config))
+	}
+	return nil
+}
+
+// configuredMiddleware turns a config middleware into a Middleware
+func (t *APITester) configuredMiddleware(config MiddlewareConfig) Middleware {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripFunc(func(req *http.Request) (*http.Response, error) {
+			if !config.matches(req) {
+				return next.RoundTrip(req)
+			}
+			if len(config.RequestHeaders) > 0 {
+				req = req.Clone(req.Context())
+				for name, value := range config.RequestHeaders {
+					if value == "" {
+						req.Header.Del(name)
+					} else {
+						req.Header.Set(name, t.replaceVariables(value))
+					}
+				}
+			}
+			if config.DelayMs > 0 {
+				select {
+				case <-time.After(time.Duration(config.DelayMs) * time.Millisecond):
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				}
+			}
+
+			var resp *http.Response
+			var err error
+			if fault := config.Fault; fault != nil && t.random.Float64() < fault.Rate {
+				resp, err = fault.inject(req)
+				fmt.Fprintf(t.out, "  %s⚡ Fault injected by %s: %s%s\n", ColorYellow, config.Name, fault.describe(), ColorReset)
+			} else {
+				resp, err = next.RoundTrip(req)
+			}
+			if err == nil {
+				for name, value := range config.ResponseHeaders {
+					if value == "" {
+						resp.Header.Del(name)
+					} else {
+						resp.Header.Set(name, value)
+					}
+				}
+			}
+			return resp, err
+		})
+	}
+}
+
+// matches reports whether a request's method and path are in the middleware's scope
+func (config MiddlewareConfig) matches(req *http.Request) bool {
+	if len(config.Methods) > 0 {
+		found := false
+		for _, method := range config.Methods {
+			found = found || strings.EqualFold(method, req.Method)
+		}
+		if !found {
+			return false
+		}
+	}
+	return config.match == nil || config.match.MatchString(req.URL.Path)
+}
+
+// inject answers a request with the fault instead of sending it
+func (f *FaultConfig) inject(req *http.Request) (*http.Response, error) {
+	if f.Error != "" {
+		return nil, fmt.Errorf("%w: %w", errInjectedFault, faultErrors[f.Error])
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", f.Status, http.StatusText(f.Status)),
+		StatusCode:    f.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(bytes.NewBufferString(f.Body)),
+		ContentLength: int64(len(f.Body)),
+		Request:       req,
+	}, nil
+}
+
+// describe names the injected failure for the console
+func (f *FaultConfig) describe() string {
+	if f.Error != "" {
+		return "connection " + f.Error
+	}
+	return fmt.Sprintf("HTTP %d", f.Status)
+}
+
+// middlewareClient returns the HTTP client with the middleware chain around its transport
+func (t *APITester) middlewareClient() *http.Client {
+	chain := append(append([]Middleware{}, t.middleware...), t.configMiddleware...)
+	if len(chain) == 0 {
+		return t.HTTPClient
+	}
+	transport := t.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		transport = chain[i](transport)
+	}
+	client := *t.HTTPClient
+	client.Transport = transport
+	return &client
+}
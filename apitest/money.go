@@ -0,0 +1,237 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MoneyMarkerPrefix starts an expected value compared as money, e.g. "{{money:10.50 USD}}".
+// A trailing "minor" reads bare actual amounts in minor units, e.g. cents.
+const MoneyMarkerPrefix = "{{money:"
+
+// moneyPattern matches an amount with an optional ISO code or symbol before or after it
+var moneyPattern = regexp.MustCompile(`^([A-Za-z]{3}|[$€£¥₹₩])?\s*([-+]?[0-9][0-9,_ ]*(?:\.[0-9]+)?)\s*([A-Za-z]{3}|[$€£¥₹₩])?$`)
+
+// currencySymbols maps the symbols money strings commonly use to their ISO codes
+var currencySymbols = map[string]string{"$": "USD", "€": "EUR", "£": "GBP", "¥": "JPY", "₹": "INR", "₩": "KRW"}
+
+// currencyExponents lists the currencies whose minor unit isn't a hundredth
+var currencyExponents = map[string]int{
+	"JPY": 0, "KRW": 0, "VND": 0, "CLP": 0, "ISK": 0, "UGX": 0, "XAF": 0, "XOF": 0,
+	"BHD": 3, "KWD": 3, "OMR": 3, "JOD": 3, "TND": 3, "LYD": 3, "IQD": 3,
+}
+
+// Object keys money amounts and currencies are read from, in order of preference
+var (
+	moneyAmountKeys   = []string{"amount", "value", "amount_value"}
+	moneyMinorKeys    = []string{"amount_minor", "minor_units", "amount_in_cents", "amount_cents", "cents", "amountMinor", "amountInCents"}
+	moneyCurrencyKeys = []string{"currency", "currency_code", "currencyCode"}
+)
+
+// money is an exact amount in major units; an empty currency isn't checked
+type money struct {
+	amount   *big.Rat
+	currency string
+}
+
+// String renders money for messages, with the currency's usual number of decimals
+func (m money) String() string {
+	digits := 2
+	if exponent, ok := currencyExponents[m.currency]; ok {
+		digits = exponent
+	}
+	text := m.amount.FloatString(digits)
+	// Keep every digit of amounts finer than the currency's minor unit
+	if !new(big.Rat).Mul(m.amount, new(big.Rat).SetInt(pow10(digits))).IsInt() {
+		text = strings.TrimRight(m.amount.FloatString(20), "0")
+	}
+	if m.currency != "" {
+		text += " " + m.currency
+	}
+	return text
+}
+
+// pow10 returns 10^n
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// isMoneyMarker reports whether an expected value is a "{{money:...}}" matcher
+func isMoneyMarker(value interface{}) bool {
+	text, ok := value.(string)
+	return ok && strings.HasPrefix(text, MoneyMarkerPrefix) && strings.HasSuffix(text, "}}")
+}
+
+// parseMoneyMarker reads "{{money:<amount> [<currency>] [minor]}}"
+func parseMoneyMarker(marker string) (expected money, minor bool, err error) {
+	spec := strings.TrimSpace(marker[len(MoneyMarkerPrefix) : len(marker)-2])
+	if rest, found := strings.CutSuffix(spec, " minor"); found {
+		spec, minor = strings.TrimSpace(rest), true
+	}
+	expected, err = parseMoneyString(spec)
+	if err != nil {
+		return money{}, false, fmt.Errorf("invalid money matcher %s: %w", marker, err)
+	}
+	return expected, minor, nil
+}
+
+// parseMoneyString reads amounts like "10.50 USD", "USD 10.50", "$1,234.50" or "10.5"
+func parseMoneyString(text string) (money, error) {
+	match := moneyPattern.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil || (match[1] != "" && match[3] != "") {
+		return money{}, fmt.Errorf("%q is not an amount with an optional currency", text)
+	}
+	digits := strings.NewReplacer(",", "", "_", "", " ", "").Replace(match[2])
+	amount, ok := new(big.Rat).SetString(digits)
+	if !ok {
+		return money{}, fmt.Errorf("%q is not an amount", match[2])
+	}
+	currency := match[1] + match[3]
+	if code, ok := currencySymbols[currency]; ok {
+		currency = code
+	}
+	return money{amount: amount, currency: strings.ToUpper(currency)}, nil
+}
+
+// moneyAmount reads a JSON number or numeric string exactly, so 10.1 stays 101/10
+func moneyAmount(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case float64:
+		return new(big.Rat).SetString(strconv.FormatFloat(v, 'f', -1, 64))
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	case string:
+		parsed, err := parseMoneyString(v)
+		if err != nil || parsed.currency != "" {
+			return nil, false
+		}
+		return parsed.amount, true
+	}
+	return nil, false
+}
+
+// toMajor converts an amount in minor units of a currency to major units
+func toMajor(amount *big.Rat, currency string) *big.Rat {
+	exponent, ok := currencyExponents[currency]
+	if !ok {
+		exponent = 2
+	}
+	return new(big.Rat).Quo(amount, new(big.Rat).SetInt(pow10(exponent)))
+}
+
+// parseMoneyValue reads an actual money value: a number, a string such as "10.50 USD", an object
+// with an amount (or minor-unit amount) and currency, or Google's {currency_code, units, nanos}.
+// Bare amounts are read in minor units when minor is set.
+func parseMoneyValue(value interface{}, minor bool, currency string) (money, error) {
+	if object, ok := value.(map[string]interface{}); ok {
+		return parseMoneyObject(object, minor)
+	}
+	if text, ok := value.(string); ok {
+		parsed, err := parseMoneyString(text)
+		if err != nil {
+			return money{}, fmt.Errorf("got %q", text)
+		}
+		if minor {
+			parsed.amount = toMajor(parsed.amount, firstNonEmpty(parsed.currency, currency))
+		}
+		return parsed, nil
+	}
+	amount, ok := moneyAmount(value)
+	if !ok {
+		return money{}, fmt.Errorf("got %s", formatJSONContext(value, 0))
+	}
+	if minor {
+		amount = toMajor(amount, currency)
+	}
+	return money{amount: amount}, nil
+}
+
+// parseMoneyObject reads the amount and currency fields of a money object
+func parseMoneyObject(object map[string]interface{}, minor bool) (money, error) {
+	var parsed money
+	for _, key := range moneyCurrencyKeys {
+		if code, ok := object[key].(string); ok {
+			parsed.currency = strings.ToUpper(code)
+			break
+		}
+	}
+
+	// Google's Money type splits the amount into whole units and nanos
+	if units, ok := object["units"]; ok {
+		whole, ok := moneyAmount(units)
+		if !ok {
+			return money{}, fmt.Errorf("got units that aren't a number")
+		}
+		nanos := new(big.Rat)
+		if value, ok := object["nanos"]; ok {
+			if nanos, ok = moneyAmount(value); !ok {
+				return money{}, fmt.Errorf("got nanos that aren't a number")
+			}
+		}
+		parsed.amount = whole.Add(whole, nanos.Quo(nanos, big.NewRat(1e9, 1)))
+		return parsed, nil
+	}
+	for _, key := range moneyMinorKeys {
+		if value, ok := object[key]; ok {
+			amount, ok := moneyAmount(value)
+			if !ok {
+				return money{}, fmt.Errorf("got a %s that isn't a number", key)
+			}
+			parsed.amount = toMajor(amount, parsed.currency)
+			return parsed, nil
+		}
+	}
+	for _, key := range moneyAmountKeys {
+		if value, ok := object[key]; ok {
+			amount, ok := moneyAmount(value)
+			if !ok {
+				return money{}, fmt.Errorf("got a %s that isn't a number", key)
+			}
+			if minor {
+				amount = toMajor(amount, parsed.currency)
+			}
+			parsed.amount = amount
+			return parsed, nil
+		}
+	}
+	return money{}, fmt.Errorf("got an object without any of %s",
+		strings.Join(append(append([]string{"units"}, moneyMinorKeys...), moneyAmountKeys...), ", "))
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// moneyMismatch compares an actual value with a money marker semantically, so "10.5", 10.50,
+// 1050 minor units and {"amount": "10.50", "currency": "usd"} all equal "{{money:10.50 USD}}".
+// It returns nil when they match.
+func moneyMismatch(marker string, actual interface{}) *assertionError {
+	expected, minor, err := parseMoneyMarker(marker)
+	if err != nil {
+		return &assertionError{CategoryRequest, err.Error()}
+	}
+	got, err := parseMoneyValue(actual, minor, expected.currency)
+	if err != nil {
+		return &assertionError{CategorySchema, fmt.Sprintf("Expected money %s, %v", expected, err)}
+	}
+	if expected.currency != "" && got.currency != "" && got.currency != expected.currency {
+		return &assertionError{CategoryBody, fmt.Sprintf("Expected %s, got %s", expected, got)}
+	}
+	if expected.amount.Cmp(got.amount) != 0 {
+		if got.currency == "" {
+			got.currency = expected.currency
+		}
+		return &assertionError{CategoryBody, fmt.Sprintf("Expected %s, got %s", expected, got)}
+	}
+	return nil
+}
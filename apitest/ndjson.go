@@ -0,0 +1,132 @@
+package apitest
+
+import (
+	"bytes"
+	"fmt"
+	"mime"
+	"sort"
+	"strconv"
+)
+
+// ndjsonMediaTypes are the content types parsed as one JSON value per line
+var ndjsonMediaTypes = map[string]bool{
+	"application/x-ndjson":     true,
+	"application/ndjson":       true,
+	"application/jsonl":        true,
+	"application/x-jsonlines":  true,
+	"application/jsonlines":    true,
+	"application/json-lines":   true,
+	"application/x-json-lines": true,
+}
+
+// maxNDJSONLineErrors caps how many failing lines an each assertion reports
+const maxNDJSONLineErrors = 10
+
+// NDJSONAssertion checks a newline-delimited JSON response line by line
+type NDJSONAssertion struct {
+	// Lines is the exact number of lines expected
+	Lines    *int `json:"lines"`
+	MinLines *int `json:"min_lines"`
+	MaxLines *int `json:"max_lines"`
+	// Each is matched against every line like expected_response
+	Each interface{} `json:"each"`
+}
+
+// isNDJSON reports whether a Content-Type header names a line-delimited JSON format
+func isNDJSON(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	return err == nil && ndjsonMediaTypes[mediaType]
+}
+
+// parseNDJSON decodes one JSON value per non-empty line
+func (t *APITester) parseNDJSON(body []byte) ([]interface{}, error) {
+	lines := []interface{}{}
+	for i, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var value interface{}
+		if err := t.unmarshalJSON(line, &value); err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		lines = append(lines, value)
+	}
+	return lines, nil
+}
+
+// validateNDJSON checks the line count and per-line expectations of an NDJSON response
+func (t *APITester) validateNDJSON(assertion *NDJSONAssertion, responseData interface{}) []assertionError {
+	lines, ok := responseData.([]interface{})
+	if !ok {
+		if body, isString := responseData.(string); isString {
+			if _, err := t.parseNDJSON([]byte(body)); err != nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("ndjson: invalid %v", err)}}
+			}
+		}
+		return []assertionError{{CategorySchema, fmt.Sprintf("ndjson: Expected NDJSON lines, got %T", responseData)}}
+	}
+
+	var errors []assertionError
+	count := len(lines)
+	if assertion.Lines != nil && count != *assertion.Lines {
+		errors = append(errors, assertionError{CategoryBody,
+			fmt.Sprintf("ndjson.lines: Expected %d lines, got %d", *assertion.Lines, count)})
+	}
+	if assertion.MinLines != nil && count < *assertion.MinLines {
+		errors = append(errors, assertionError{CategoryBody,
+			fmt.Sprintf("ndjson.min_lines: Expected at least %d lines, got %d", *assertion.MinLines, count)})
+	}
+	if assertion.MaxLines != nil && count > *assertion.MaxLines {
+		errors = append(errors, assertionError{CategoryBody,
+			fmt.Sprintf("ndjson.max_lines: Expected at most %d lines, got %d", *assertion.MaxLines, count)})
+	}
+
+	if assertion.Each == nil {
+		return errors
+	}
+	failing := 0
+	for i, line := range lines {
+		lineErrors := t.validate(assertion.Each, line, fmt.Sprintf("[%d]", i))
+		if len(lineErrors) == 0 {
+			continue
+		}
+		failing++
+		if failing <= maxNDJSONLineErrors {
+			errors = append(errors, lineErrors...)
+		}
+	}
+	if failing > maxNDJSONLineErrors {
+		errors = append(errors, assertionError{CategoryBody,
+			fmt.Sprintf("ndjson.each: %d more lines failed", failing-maxNDJSONLineErrors)})
+	}
+	return errors
+}
+
+// validateIndexed matches an expected object keyed by array indices ("0", "1", ...) against an array,
+// so expected_response can address lines of an NDJSON response the same way extract paths do
+func (t *APITester) validateIndexed(expected map[string]interface{}, actual []interface{}, path string) []assertionError {
+	keys := make([]string, 0, len(expected))
+	for key := range expected {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errors []assertionError
+	for _, key := range keys {
+		currentPath := fmt.Sprintf("%s[%s]", path, key)
+		index, err := strconv.Atoi(key)
+		if err != nil || index < 0 {
+			errors = append(errors, assertionError{CategorySchema,
+				fmt.Sprintf("%s: Expected an array index, got %q", currentPath, key)})
+			continue
+		}
+		if index >= len(actual) {
+			errors = append(errors, t.withContext(assertionError{CategorySchema,
+				fmt.Sprintf("%s: Index out of range", currentPath)}, path, actual))
+			continue
+		}
+		errors = append(errors, t.validate(expected[key], actual[index], currentPath)...)
+	}
+	return errors
+}
@@ -0,0 +1,46 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+)
+
+// JSON number modes selectable with -json-numbers
+const (
+	JSONNumbersFloat = "float" // decode numbers as float64 (integers above 2^53 lose precision)
+	JSONNumbersExact = "exact" // keep numbers as their literal text
+)
+
+// unmarshalJSON decodes JSON, keeping numbers exact as json.Number when -json-numbers exact is set
+func (t *APITester) unmarshalJSON(data []byte, target interface{}) error {
+	if !t.ExactNumbers {
+		return json.Unmarshal(data, target)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(target); err != nil {
+		return err
+	}
+	// Match json.Unmarshal, which rejects trailing data
+	if decoder.More() {
+		var extra interface{}
+		return decoder.Decode(&extra)
+	}
+	return nil
+}
+
+// numberRat returns a JSON number as an exact rational, whatever its representation
+func numberRat(value interface{}) (*big.Rat, bool) {
+	switch v := value.(type) {
+	case json.Number:
+		return new(big.Rat).SetString(v.String())
+	case float64:
+		r := new(big.Rat)
+		if r.SetFloat64(v) == nil {
+			return nil, false
+		}
+		return r, true
+	}
+	return nil, false
+}
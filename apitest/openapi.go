@@ -0,0 +1,284 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// openAPIMethods are the path item keys that describe operations
+var openAPIMethods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// OpenAPISpec is the subset of an OpenAPI 3 / Swagger 2 document needed for coverage
+type OpenAPISpec struct {
+	BasePath string `json:"basePath"`
+	Servers  []struct {
+		URL string `json:"url"`
+	} `json:"servers"`
+	Paths map[string]map[string]json.RawMessage `json:"paths"`
+}
+
+// OpenAPIOperation is a single method + path template with its documented responses
+type OpenAPIOperation struct {
+	Method    string
+	Path      string
+	Responses []string
+}
+
+// OperationCoverage records which documented status codes a run exercised for one operation
+type OperationCoverage struct {
+	Method         string   `json:"method"`
+	Path           string   `json:"path"`
+	Requests       int      `json:"requests"`
+	CoveredCodes   []string `json:"covered_status_codes"`
+	UncoveredCodes []string `json:"uncovered_status_codes"`
+}
+
+// CoverageReport summarizes how much of the OpenAPI spec the suite exercised
+type CoverageReport struct {
+	SpecFile           string              `json:"spec_file"`
+	OperationsTotal    int                 `json:"operations_total"`
+	OperationsCovered  int                 `json:"operations_covered"`
+	OperationCoverage  float64             `json:"operation_coverage"`
+	StatusCodesTotal   int                 `json:"status_codes_total"`
+	StatusCodesCovered int                 `json:"status_codes_covered"`
+	Operations         []OperationCoverage `json:"operations"`
+	Unmatched          []string            `json:"unmatched_requests,omitempty"`
+}
+
+// loadOpenAPISpec reads a JSON OpenAPI document and lists its operations
+func loadOpenAPISpec(path string) (*OpenAPISpec, []OpenAPIOperation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read OpenAPI spec: %w", err)
+	}
+
+	var spec OpenAPISpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, nil, fmt.Errorf("failed to parse OpenAPI spec (JSON required): %w", err)
+	}
+
+	var operations []OpenAPIOperation
+	for path, item := range spec.Paths {
+		for _, method := range openAPIMethods {
+			raw, ok := item[method]
+			if !ok {
+				continue
+			}
+			var op struct {
+				Responses map[string]json.RawMessage `json:"responses"`
+			}
+			if err := json.Unmarshal(raw, &op); err != nil {
+				return nil, nil, fmt.Errorf("invalid operation %s %s: %w", strings.ToUpper(method), path, err)
+			}
+			var codes []string
+			for code := range op.Responses {
+				codes = append(codes, code)
+			}
+			sort.Strings(codes)
+			operations = append(operations, OpenAPIOperation{
+				Method:    strings.ToUpper(method),
+				Path:      path,
+				Responses: codes,
+			})
+		}
+	}
+
+	sort.Slice(operations, func(i, j int) bool {
+		if operations[i].Path != operations[j].Path {
+			return operations[i].Path < operations[j].Path
+		}
+		return operations[i].Method < operations[j].Method
+	})
+	return &spec, operations, nil
+}
+
+// basePath returns the path prefix requests carry in front of the spec's path templates
+func (s *OpenAPISpec) basePath() string {
+	base := s.BasePath
+	if len(s.Servers) > 0 {
+		if parsed, err := url.Parse(s.Servers[0].URL); err == nil {
+			base = parsed.Path
+		}
+	}
+	return strings.TrimRight(base, "/")
+}
+
+// matchPathTemplate reports whether a request path matches an OpenAPI path template,
+// returning the number of literal segments so the most specific template can win
+func matchPathTemplate(template, path string) (bool, int) {
+	templateParts := strings.Split(strings.Trim(template, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(templateParts) != len(pathParts) {
+		return false, 0
+	}
+
+	literals := 0
+	for i, part := range templateParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return false, 0
+			}
+			continue
+		}
+		if part != pathParts[i] {
+			return false, 0
+		}
+		literals++
+	}
+	return true, literals
+}
+
+// statusCodeMatches reports whether a documented response key covers an actual status code
+func statusCodeMatches(documented string, status int) bool {
+	code := strconv.Itoa(status)
+	if documented == code {
+		return true
+	}
+	// Range keys such as "2XX"
+	upper := strings.ToUpper(documented)
+	return len(upper) == 3 && strings.HasSuffix(upper, "XX") && upper[0] == code[0]
+}
+
+// computeCoverage matches results against the spec operations
+func computeCoverage(specFile string, spec *OpenAPISpec, operations []OpenAPIOperation, results []TestResult) *CoverageReport {
+	report := &CoverageReport{SpecFile: specFile}
+	exercised := make([]map[string]bool, len(operations))
+	requests := make([]int, len(operations))
+	base := spec.basePath()
+
+	for _, result := range results {
+		// Tests that never reached the server don't exercise anything
+		if result.ResponseStatusCode == 0 {
+			continue
+		}
+		parsed, err := url.Parse(result.URL)
+		if err != nil {
+			continue
+		}
+		path := strings.TrimPrefix(parsed.Path, base)
+
+		best, bestLiterals := -1, -1
+		for i, op := range operations {
+			if op.Method != result.Method {
+				continue
+			}
+			if ok, literals := matchPathTemplate(op.Path, path); ok && literals > bestLiterals {
+				best, bestLiterals = i, literals
+			}
+		}
+		if best < 0 {
+			report.Unmatched = append(report.Unmatched, result.Method+" "+parsed.Path)
+			continue
+		}
+
+		requests[best]++
+		if exercised[best] == nil {
+			exercised[best] = make(map[string]bool)
+		}
+		matched := false
+		for _, code := range operations[best].Responses {
+			if statusCodeMatches(code, result.ResponseStatusCode) {
+				exercised[best][code] = true
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			exercised[best]["default"] = true
+		}
+	}
+
+	for i, op := range operations {
+		coverage := OperationCoverage{
+			Method:         op.Method,
+			Path:           op.Path,
+			Requests:       requests[i],
+			CoveredCodes:   []string{},
+			UncoveredCodes: []string{},
+		}
+		for _, code := range op.Responses {
+			if exercised[i][code] {
+				coverage.CoveredCodes = append(coverage.CoveredCodes, code)
+			} else {
+				coverage.UncoveredCodes = append(coverage.UncoveredCodes, code)
+			}
+		}
+
+		report.OperationsTotal++
+		if requests[i] > 0 {
+			report.OperationsCovered++
+		}
+		report.StatusCodesTotal += len(op.Responses)
+		report.StatusCodesCovered += len(coverage.CoveredCodes)
+		report.Operations = append(report.Operations, coverage)
+	}
+
+	if report.OperationsTotal > 0 {
+		report.OperationCoverage = float64(report.OperationsCovered) / float64(report.OperationsTotal) * 100
+	}
+	return report
+}
+
+// CheckOpenAPICoverage computes and prints coverage, returning whether it meets minCoverage
+func (t *APITester) CheckOpenAPICoverage(specFile string, spec *OpenAPISpec, operations []OpenAPIOperation, minCoverage float64) bool {
+	t.Coverage = computeCoverage(specFile, spec, operations, t.Results)
+	t.printCoverage(t.Coverage)
+
+	if t.Coverage.OperationCoverage < minCoverage {
+		fmt.Fprintf(t.out, "  %s✗ Operation coverage %.1f%% is below the minimum of %.1f%%%s\n",
+			ColorRed, t.Coverage.OperationCoverage, minCoverage, ColorReset)
+		fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+		return false
+	}
+	fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+	return true
+}
+
+// printCoverage prints the OpenAPI coverage summary
+func (t *APITester) printCoverage(report *CoverageReport) {
+	fmt.Fprintf(t.out, "%s  OpenAPI Coverage%s\n", ColorBold, ColorReset)
+	fmt.Fprintf(t.out, "  Operations:   %d/%d (%.1f%%)\n",
+		report.OperationsCovered, report.OperationsTotal, report.OperationCoverage)
+
+	statusRate := 0.0
+	if report.StatusCodesTotal > 0 {
+		statusRate = float64(report.StatusCodesCovered) / float64(report.StatusCodesTotal) * 100
+	}
+	fmt.Fprintf(t.out, "  Status codes: %d/%d (%.1f%%)\n",
+		report.StatusCodesCovered, report.StatusCodesTotal, statusRate)
+
+	var uncovered, untestedCodes []string
+	for _, op := range report.Operations {
+		if op.Requests == 0 {
+			uncovered = append(uncovered, fmt.Sprintf("%s %s", op.Method, op.Path))
+			continue
+		}
+		for _, code := range op.UncoveredCodes {
+			untestedCodes = append(untestedCodes, fmt.Sprintf("%s %s → %s", op.Method, op.Path, code))
+		}
+	}
+
+	if len(uncovered) > 0 {
+		fmt.Fprintf(t.out, "  %sUncovered operations:%s\n", ColorYellow, ColorReset)
+		for _, op := range uncovered {
+			fmt.Fprintf(t.out, "    %s• %s%s\n", ColorYellow, op, ColorReset)
+		}
+	}
+	if len(untestedCodes) > 0 {
+		fmt.Fprintf(t.out, "  %sUntested status codes:%s\n", ColorYellow, ColorReset)
+		for _, code := range untestedCodes {
+			fmt.Fprintf(t.out, "    %s• %s%s\n", ColorYellow, code, ColorReset)
+		}
+	}
+	if len(report.Unmatched) > 0 {
+		fmt.Fprintf(t.out, "  %sRequests not in spec:%s\n", ColorYellow, ColorReset)
+		for _, req := range report.Unmatched {
+			fmt.Fprintf(t.out, "    %s• %s%s\n", ColorYellow, req, ColorReset)
+		}
+	}
+}
@@ -0,0 +1,352 @@
+package apitest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// OpenAPIExampleGroup names the assertion group seeded from a documented response example
+const OpenAPIExampleGroup = "openapi example"
+
+// openAPIExample is the part of a media type object, schema or parameter that can carry an example
+type openAPIExample struct {
+	Example  interface{} `json:"example"`
+	Default  interface{} `json:"default"`
+	Examples map[string]struct {
+		Value interface{} `json:"value"`
+	} `json:"examples"`
+	Schema *openAPIExample `json:"schema"`
+}
+
+// value returns the documented example: the example itself, the first named example (by name,
+// so it's stable), or the schema's example. Defaults are used only when asked, for parameters.
+func (e *openAPIExample) value(withDefault bool) interface{} {
+	if e == nil {
+		return nil
+	}
+	if e.Example != nil {
+		return e.Example
+	}
+	names := make([]string, 0, len(e.Examples))
+	for name := range e.Examples {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := e.Examples[name].Value; value != nil {
+			return value
+		}
+	}
+	if withDefault && e.Default != nil {
+		return e.Default
+	}
+	return e.Schema.value(withDefault)
+}
+
+// openAPIParameter is a path, query, header or (Swagger 2) body parameter
+type openAPIParameter struct {
+	openAPIExample
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required"`
+}
+
+// openAPIResponse is a documented response; OpenAPI 3 keeps examples under content, Swagger 2
+// under examples by media type or in the schema
+type openAPIResponse struct {
+	Content  map[string]*openAPIExample `json:"content"`
+	Examples map[string]interface{}     `json:"examples"`
+	Schema   *openAPIExample            `json:"schema"`
+}
+
+// example returns the response's JSON example
+func (r openAPIResponse) example() interface{} {
+	if media := jsonMedia(r.Content); media != nil {
+		return media.value(false)
+	}
+	for mediaType, example := range r.Examples {
+		if strings.Contains(mediaType, "json") {
+			return example
+		}
+	}
+	return r.Schema.value(false)
+}
+
+// openAPIOperationDoc is the part of an operation a test is generated from
+type openAPIOperationDoc struct {
+	OperationID string             `json:"operationId"`
+	Summary     string             `json:"summary"`
+	Parameters  []openAPIParameter `json:"parameters"`
+	RequestBody *struct {
+		Content map[string]*openAPIExample `json:"content"`
+	} `json:"requestBody"`
+	Responses map[string]openAPIResponse `json:"responses"`
+}
+
+// jsonMedia picks the JSON media type of a content map
+func jsonMedia(content map[string]*openAPIExample) *openAPIExample {
+	if media, ok := content["application/json"]; ok {
+		return media
+	}
+	types := make([]string, 0, len(content))
+	for mediaType := range content {
+		types = append(types, mediaType)
+	}
+	sort.Strings(types)
+	for _, mediaType := range types {
+		if strings.Contains(mediaType, "json") {
+			return content[mediaType]
+		}
+	}
+	return nil
+}
+
+// generatedTest is a test written by `generate`; only the fields it sets are written
+type generatedTest struct {
+	Order              int               `json:"order"`
+	TestCaseName       string            `json:"test_case_name"`
+	API                string            `json:"api"`
+	Method             string            `json:"method"`
+	Params             map[string]string `json:"params,omitempty"`
+	Body               interface{}       `json:"body,omitempty"`
+	ExpectedStatusCode int               `json:"expected_status_code,omitempty"`
+	Assertions         []generatedGroup  `json:"assertions,omitempty"`
+	Snapshot           bool              `json:"snapshot,omitempty"`
+	Tags               []string          `json:"tags,omitempty"`
+
+	// example is the documented response example, written as the snapshot with -snapshots
+	example interface{}
+}
+
+// generatedGroup is the assertion group holding the checks seeded from a response example
+type generatedGroup struct {
+	Name             string                 `json:"name"`
+	Severity         string                 `json:"severity"`
+	ExpectedResponse map[string]interface{} `json:"expected_response"`
+}
+
+// generatedConfig is the config written by `generate`
+type generatedConfig struct {
+	Schema    string          `json:"$schema"`
+	TestCases []generatedTest `json:"test_case"`
+}
+
+// runGenerateCommand implements `generate`, which writes a starter config with one test per
+// OpenAPI operation, its expectations seeded from the documented examples
+func runGenerateCommand(args []string) int {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the config to file (default: stdout)")
+	severity := fs.String("examples", SeveritySoft, "Severity of the checks seeded from response examples: soft (warn on deviation) or hard")
+	snapshots := fs.Bool("snapshots", false, "Also write each response example as the test's snapshot")
+	snapshotDir := fs.String("snapshot-dir", DefaultSnapshotDir, "Directory to write seeded snapshots to")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s generate [-examples soft|hard] [-snapshots] [-o test_cases.json] <openapi.json>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) != 1 {
+		fmt.Fprintf(os.Stderr, "%sError: OpenAPI spec path required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	if *severity != SeveritySoft && *severity != SeverityHard {
+		fmt.Fprintf(os.Stderr, "%sError: -examples must be %s or %s%s\n\n", ColorRed, SeveritySoft, SeverityHard, ColorReset)
+		fs.Usage()
+		return 1
+	}
+
+	spec, operations, err := loadOpenAPISpec(paths[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	config, unresolved, err := generateTests(spec, operations, *severity)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+
+	seeded := 0
+	for i, test := range config.TestCases {
+		if len(test.Assertions) > 0 {
+			seeded++
+		}
+		if *snapshots && test.example != nil {
+			written, err := writeSeedSnapshot(*snapshotDir, test)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+				return 1
+			}
+			config.TestCases[i].Snapshot = written
+		}
+	}
+
+	data, err := json.MarshalIndent(config, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to marshal config: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	data = append(data, '\n')
+
+	// Progress goes to stderr so a config written to stdout stays clean
+	for _, message := range unresolved {
+		fmt.Fprintf(os.Stderr, "%s⚠ %s%s\n", ColorYellow, message, ColorReset)
+	}
+	fmt.Fprintf(os.Stderr, "%s✓ Generated %d tests, %d seeded from response examples%s\n",
+		ColorGreen, len(config.TestCases), seeded, ColorReset)
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write config: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Fprintf(os.Stderr, "%s✓ Config written to: %s%s\n", ColorGreen, *output, ColorReset)
+	return 0
+}
+
+// generateTests builds one test per operation, in the spec's path order. Path parameters without an
+// example or default stay as written and are returned for the user to fill in.
+func generateTests(spec *OpenAPISpec, operations []OpenAPIOperation, severity string) (generatedConfig, []string, error) {
+	config := generatedConfig{Schema: ConfigSchemaID}
+	var unresolved []string
+	for i, operation := range operations {
+		item := spec.Paths[operation.Path]
+		var doc openAPIOperationDoc
+		if err := json.Unmarshal(item[strings.ToLower(operation.Method)], &doc); err != nil {
+			return config, nil, fmt.Errorf("invalid operation %s %s: %w", operation.Method, operation.Path, err)
+		}
+		// Path-level parameters apply to every operation unless it redefines them
+		var shared []openAPIParameter
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &shared); err != nil {
+				return config, nil, fmt.Errorf("invalid parameters of %s: %w", operation.Path, err)
+			}
+		}
+
+		test := generatedTest{
+			Order:        i + 1,
+			TestCaseName: doc.Summary,
+			API:          spec.basePath() + operation.Path,
+			Method:       operation.Method,
+		}
+		if test.TestCaseName == "" {
+			test.TestCaseName = doc.OperationID
+		}
+		if test.TestCaseName == "" {
+			test.TestCaseName = operation.Method + " " + operation.Path
+		}
+
+		for _, param := range mergeParameters(shared, doc.Parameters) {
+			value := param.value(true)
+			switch param.In {
+			case "path":
+				if value == nil {
+					unresolved = append(unresolved, fmt.Sprintf("%s: no example for path parameter {%s}", test.TestCaseName, param.Name))
+					continue
+				}
+				test.API = strings.ReplaceAll(test.API, "{"+param.Name+"}", fmt.Sprintf("%v", value))
+			case "query":
+				if value != nil && param.Required {
+					if test.Params == nil {
+						test.Params = make(map[string]string)
+					}
+					test.Params[param.Name] = fmt.Sprintf("%v", value)
+				}
+			case "body":
+				test.Body = value
+			}
+		}
+		if doc.RequestBody != nil {
+			if media := jsonMedia(doc.RequestBody.Content); media != nil {
+				test.Body = media.value(false)
+			}
+		}
+
+		status, response := successResponse(doc.Responses)
+		test.ExpectedStatusCode = status
+		test.example = response.example()
+		if expected := exampleExpectation(test.example); expected != nil {
+			test.Assertions = []generatedGroup{{Name: OpenAPIExampleGroup, Severity: severity, ExpectedResponse: expected}}
+		}
+		config.TestCases = append(config.TestCases, test)
+	}
+	return config, unresolved, nil
+}
+
+// mergeParameters combines path-level and operation parameters, the operation's winning by name and location
+func mergeParameters(shared, own []openAPIParameter) []openAPIParameter {
+	merged := append([]openAPIParameter(nil), own...)
+	for _, param := range shared {
+		overridden := false
+		for _, ownParam := range own {
+			overridden = overridden || (ownParam.Name == param.Name && ownParam.In == param.In)
+		}
+		if !overridden {
+			merged = append(merged, param)
+		}
+	}
+	return merged
+}
+
+// successResponse picks the lowest documented 2xx status, or the lowest status when none is 2xx
+func successResponse(responses map[string]openAPIResponse) (int, openAPIResponse) {
+	best := 0
+	for code := range responses {
+		status, err := strconv.Atoi(code)
+		if err != nil {
+			continue
+		}
+		success := status >= 200 && status < 300
+		bestSuccess := best >= 200 && best < 300
+		if best == 0 || (success && !bestSuccess) || (success == bestSuccess && status < best) {
+			best = status
+		}
+	}
+	return best, responses[strconv.Itoa(best)]
+}
+
+// exampleExpectation turns a response example into an expected_response: objects as they are, and
+// arrays by their first element, since live lists rarely have the documented length
+func exampleExpectation(example interface{}) map[string]interface{} {
+	switch value := example.(type) {
+	case map[string]interface{}:
+		return value
+	case []interface{}:
+		if len(value) > 0 {
+			return map[string]interface{}{"0": value[0]}
+		}
+	}
+	return nil
+}
+
+// writeSeedSnapshot writes a test's response example as its snapshot, leaving recorded snapshots alone
+func writeSeedSnapshot(dir string, test generatedTest) (bool, error) {
+	tester := &APITester{SnapshotDir: dir}
+	path := tester.snapshotPath(TestCase{Order: test.Order, TestCaseName: test.TestCaseName})
+	if _, err := os.Stat(path); err == nil {
+		fmt.Fprintf(os.Stderr, "%s⚠ Snapshot %s already exists, not overwritten%s\n", ColorYellow, path, ColorReset)
+		return true, nil
+	}
+	data, err := marshalCanonical(canonicalize(test.example, "", nil))
+	if err != nil {
+		return false, fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, fmt.Errorf("failed to create snapshot dir: %w", err)
+	}
+	if err := os.WriteFile(path, data, DefaultFileMode); err != nil {
+		return false, fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return true, nil
+}
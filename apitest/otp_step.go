@@ -0,0 +1,235 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// OTPCatcherVariable holds the URL of the built-in OTP catcher
+const OTPCatcherVariable = "otp_catcher_url"
+
+// smsRecipientFields and smsTextFields are where SMS provider payloads carry the recipient and
+// message text (Twilio, Vonage, MessageBird and most generic gateways)
+var (
+	smsRecipientFields = []string{"to", "To", "recipient", "recipients", "phone", "destination", "msisdn"}
+	smsTextFields      = []string{"body", "Body", "text", "message", "content"}
+)
+
+// OTPStep waits for a one-time code sent by the API and exposes it for the next request
+type OTPStep struct {
+	// To is the recipient, matched as a substring with phone formatting ignored
+	To string `json:"to"`
+	// Pattern finds the code in the message text (default: 4 to 8 digits)
+	Pattern string `json:"pattern"`
+	// URL polls a provider's JSON message API instead of the built-in catcher
+	URL string `json:"url"`
+	// MessagesPath is where the message list sits in the provider response (default: the root)
+	MessagesPath   string `json:"messages_path"`
+	Timeout        int    `json:"timeout"`
+	PollIntervalMs int    `json:"poll_interval_ms"`
+}
+
+// smsMessage is a captured text message
+type smsMessage struct {
+	To   string
+	Text string
+}
+
+// StartOTPCatcher starts the built-in OTP catcher and exposes its URL as {{otp_catcher_url}}.
+// Point the API's SMS provider URL at it to capture verification codes.
+func (t *APITester) StartOTPCatcher(config CatcherConfig) error {
+	catcher, err := startCatcher(config)
+	if err != nil {
+		return fmt.Errorf("failed to start OTP catcher: %w", err)
+	}
+	t.otpCatcher = catcher
+	t.setVariable(OTPCatcherVariable, catcher.URL, SourceBuiltin)
+	fmt.Fprintf(t.out, "%s✓ OTP catcher listening at %s%s\n", ColorGreen, catcher.URL, ColorReset)
+	return nil
+}
+
+// otpStepTarget describes where the OTP step looks for codes
+func otpStepTarget(t *APITester, testCase TestCase) string {
+	if testCase.OTP == nil {
+		return ""
+	}
+	source := "catcher"
+	if testCase.OTP.URL != "" {
+		source = t.replaceVariables(testCase.OTP.URL)
+	}
+	if testCase.OTP.To != "" {
+		return fmt.Sprintf("to~%s via %s", t.replaceVariables(testCase.OTP.To), source)
+	}
+	return source
+}
+
+// runOTPStep polls for the newest message to the recipient received since the run started,
+// returning {"to", "text", "code"}
+func runOTPStep(t *APITester, testCase TestCase) (interface{}, []assertionError) {
+	step := testCase.OTP
+	if step == nil {
+		return nil, []assertionError{{CategoryRequest, "OTP step: \"otp\" config is required"}}
+	}
+	if step.URL == "" && t.otpCatcher == nil {
+		return nil, []assertionError{{CategoryRequest, "OTP step: set \"otp.url\" or configure \"otp_catcher\""}}
+	}
+
+	pattern := step.Pattern
+	if pattern == "" {
+		pattern = DefaultCodePattern
+	}
+	codePattern, err := regexp.Compile(t.replaceVariables(pattern))
+	if err != nil {
+		return nil, []assertionError{{CategoryRequest, fmt.Sprintf("OTP step: invalid pattern: %v", err)}}
+	}
+	to := t.replaceVariables(step.To)
+
+	timeout, interval := stepDurations(step.Timeout, step.PollIntervalMs)
+	var found *smsMessage
+	var code string
+	err = poll(t.Context, timeout, interval, func(ctx context.Context) error {
+		messages, err := t.fetchSMS(ctx, step)
+		if err != nil {
+			return err
+		}
+		// Newest first, so a resent code wins over an earlier one
+		for i := len(messages) - 1; i >= 0; i-- {
+			if !recipientMatches(messages[i].To, to) {
+				continue
+			}
+			if match := codePattern.FindString(messages[i].Text); match != "" {
+				found, code = &messages[i], match
+				return nil
+			}
+		}
+		if to != "" {
+			return fmt.Errorf("no message to %s with a code among %d received", to, len(messages))
+		}
+		return fmt.Errorf("no message with a code among %d received", len(messages))
+	})
+	if err != nil {
+		return nil, []assertionError{{CategoryTimeout, fmt.Sprintf("OTP: %v", err)}}
+	}
+	fmt.Fprintf(t.out, "  %s↳ Code %s sent to %s%s\n", ColorCyan, code, found.To, ColorReset)
+	return map[string]interface{}{"to": found.To, "text": found.Text, "code": code}, nil
+}
+
+// fetchSMS lists captured messages, oldest first
+func (t *APITester) fetchSMS(ctx context.Context, step *OTPStep) ([]smsMessage, error) {
+	if step.URL == "" {
+		var messages []smsMessage
+		for _, request := range t.otpCatcher.since(t.startedAt) {
+			if message, ok := parseSMSRequest(request); ok {
+				messages = append(messages, message)
+			}
+		}
+		return messages, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, t.replaceVariables(step.URL), nil)
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: HTTP %d", step.URL, resp.StatusCode)
+	}
+	var data interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return nil, fmt.Errorf("invalid provider response: %w", err)
+	}
+	if step.MessagesPath != "" {
+		data = getNestedValue(data, step.MessagesPath)
+	}
+	items, ok := data.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("provider response has no message list at %q", step.MessagesPath)
+	}
+
+	var messages []smsMessage
+	for _, item := range items {
+		if fields, ok := item.(map[string]interface{}); ok {
+			messages = append(messages, smsMessage{
+				To:   firstField(fields, smsRecipientFields),
+				Text: firstField(fields, smsTextFields),
+			})
+		}
+	}
+	return messages, nil
+}
+
+// parseSMSRequest reads the recipient and text of an SMS provider call, sent as JSON or as a form
+func parseSMSRequest(request caughtRequest) (smsMessage, bool) {
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(request.Body, &fields); err != nil {
+		form, err := url.ParseQuery(string(request.Body))
+		if err != nil {
+			return smsMessage{}, false
+		}
+		for key, values := range form {
+			fields[key] = strings.Join(values, ",")
+		}
+	}
+	// Some gateways (e.g. Vonage's legacy API) send everything in the query string
+	if query, err := url.ParseQuery(request.Query); err == nil {
+		for key, values := range query {
+			if _, ok := fields[key]; !ok {
+				fields[key] = strings.Join(values, ",")
+			}
+		}
+	}
+
+	message := smsMessage{To: firstField(fields, smsRecipientFields), Text: firstField(fields, smsTextFields)}
+	return message, message.Text != ""
+}
+
+// firstField returns the first of the named fields present, joining lists
+func firstField(fields map[string]interface{}, names []string) string {
+	for _, name := range names {
+		switch value := fields[name].(type) {
+		case string:
+			return value
+		case []interface{}:
+			parts := make([]string, len(value))
+			for i, part := range value {
+				parts[i] = fmt.Sprintf("%v", part)
+			}
+			return strings.Join(parts, ",")
+		case nil:
+			continue
+		default:
+			return fmt.Sprintf("%v", value)
+		}
+	}
+	return ""
+}
+
+// recipientMatches reports whether a recipient contains the wanted one, ignoring phone formatting
+func recipientMatches(recipient, wanted string) bool {
+	if strings.Contains(recipient, wanted) {
+		return true
+	}
+	digits := normalizePhone(wanted)
+	return digits != "" && strings.Contains(normalizePhone(recipient), digits)
+}
+
+// normalizePhone strips formatting from a phone number so "+1 (555) 010" matches "+1555010"
+func normalizePhone(phone string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '+' || (r >= '0' && r <= '9') {
+			return r
+		}
+		return -1
+	}, phone)
+}
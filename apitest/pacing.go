@@ -0,0 +1,132 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Adaptive pacing bounds
+const (
+	// PaceMinInterval is the spacing the first 429 sets; each further 429 doubles it
+	PaceMinInterval = 250 * time.Millisecond
+	PaceMaxInterval = 10 * time.Second
+	// PaceMaxWait caps a single wait, whatever Retry-After asks for
+	PaceMaxWait = 60 * time.Second
+	// PaceMaxResends is how often one request is resent after a 429 before the test fails on it
+	PaceMaxResends = 5
+	// PaceRecovery shortens the spacing by 1/PaceRecovery after every answer that isn't a 429
+	PaceRecovery = 10
+)
+
+// pacer spaces out all requests of a run once the API answers 429: the spacing grows on every 429
+// and shrinks slowly while requests go through, so the run settles on a rate the API accepts
+type pacer struct {
+	interval time.Duration
+	next     time.Time
+	// throttled counts the 429 answers absorbed, waited the time spent waiting for the pace
+	throttled int
+	waited    time.Duration
+	peak      time.Duration
+}
+
+// Throttling summarizes how much a run was rate limited
+type Throttling struct {
+	Responses      int     `json:"responses"`
+	WaitedMs       float64 `json:"waited_ms"`
+	PeakIntervalMs float64 `json:"peak_interval_ms"`
+	IntervalMs     float64 `json:"interval_ms"`
+}
+
+// EnablePacing makes 429 answers slow the whole run down instead of failing tests
+func (t *APITester) EnablePacing() {
+	t.pacer = &pacer{}
+}
+
+// wait blocks until the next request may go out
+func (p *pacer) wait(ctx context.Context) error {
+	if p == nil {
+		return nil
+	}
+	delay := time.Until(p.next)
+	if delay <= 0 {
+		return nil
+	}
+	p.waited += delay
+	select {
+	case <-time.After(delay):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// record adjusts the pace to an answer, reporting whether it was a 429
+func (p *pacer) record(resp *http.Response) bool {
+	if p == nil || resp == nil {
+		return false
+	}
+	now := time.Now()
+	if resp.StatusCode != http.StatusTooManyRequests {
+		p.interval -= p.interval / PaceRecovery
+		if p.interval < time.Millisecond {
+			p.interval = 0
+		}
+		p.next = now.Add(p.interval)
+		return false
+	}
+
+	p.throttled++
+	p.interval = min(max(p.interval*2, PaceMinInterval), PaceMaxInterval)
+	p.peak = max(p.peak, p.interval)
+	delay := p.interval
+	if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After"), now); ok && retryAfter > delay {
+		delay = min(retryAfter, PaceMaxWait)
+	}
+	p.next = now.Add(delay)
+	return true
+}
+
+// summary returns the run's throttling, or nil when it never got a 429
+func (p *pacer) summary() *Throttling {
+	if p == nil || p.throttled == 0 {
+		return nil
+	}
+	return &Throttling{
+		Responses:      p.throttled,
+		WaitedMs:       float64(p.waited.Milliseconds()),
+		PeakIntervalMs: float64(p.peak.Milliseconds()),
+		IntervalMs:     float64(p.interval.Milliseconds()),
+	}
+}
+
+// resendThrottled resends a request answered with 429 once the pace allows, up to PaceMaxResends
+// times, unless the test expects the 429. The last answer is returned like the first one.
+func (t *APITester) resendThrottled(testCase TestCase, result *TestResult,
+	resp *http.Response, responseTime time.Duration, trace *requestTrace, err error) (*http.Response, time.Duration, *requestTrace, error) {
+	if t.pacer == nil || testCase.ExpectedStatusCode == http.StatusTooManyRequests {
+		return resp, responseTime, trace, err
+	}
+	for resend := 1; err == nil && resp.StatusCode == http.StatusTooManyRequests && resend <= PaceMaxResends; resend++ {
+		// Drain the throttled response so its connection can be reused
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		result.Throttled++
+		fmt.Fprintf(t.out, "  %s⏸ Throttled (HTTP 429), resending in %dms; pacing all requests %dms apart%s\n",
+			ColorYellow, max(time.Until(t.pacer.next), 0).Milliseconds(), t.pacer.interval.Milliseconds(), ColorReset)
+		resp, responseTime, trace, err = t.sendAttempt(testCase, result.Method, result.URL)
+	}
+	return resp, responseTime, trace, err
+}
+
+// printThrottling prints the summary line of a throttled run
+func (t *APITester) printThrottling() {
+	throttling := t.pacer.summary()
+	if throttling == nil {
+		return
+	}
+	fmt.Fprintf(t.out, "  %sThrottling: %d responses with HTTP 429, waited %.1fs, pace peaked at one request per %.0fms%s\n",
+		ColorYellow, throttling.Responses, throttling.WaitedMs/1000, throttling.PeakIntervalMs, ColorReset)
+}
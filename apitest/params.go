@@ -0,0 +1,58 @@
+package apitest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// setFlags collects repeated -set name=value variable overrides
+type setFlags map[string]string
+
+// String renders the overrides as sorted name=value pairs
+func (s setFlags) String() string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + s[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set parses one name=value override
+func (s setFlags) Set(value string) error {
+	name, val, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("variable %q must be name=value", value)
+	}
+	if !isVariablePlaceholder(name) || strings.ContainsAny(name, "{}") {
+		return fmt.Errorf("variable name %q is not usable as a {{placeholder}}", name)
+	}
+	s[name] = val
+	return nil
+}
+
+// seedVariables fills the variables a run starts with: the config's variables, then the -set
+// overrides, which win. Tests extracting the same names replace them as they run.
+func (t *APITester) seedVariables(variables map[string]interface{}) {
+	t.Seeded = make(map[string]bool)
+	for name, value := range variables {
+		t.setVariable(name, value, SourceConfig)
+		t.Seeded[name] = true
+	}
+	if len(t.Params) == 0 {
+		return
+	}
+	names := make([]string, 0, len(t.Params))
+	for name, value := range t.Params {
+		t.setVariable(name, value, SourceSet)
+		t.Seeded[name] = true
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	fmt.Fprintf(t.out, "%s✓ Set %d variables from the command line: %s%s\n", ColorGreen, len(names), strings.Join(names, ", "), ColorReset)
+}
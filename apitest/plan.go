@@ -0,0 +1,212 @@
+package apitest
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Plan output formats
+const (
+	PlanFormatTree = "tree"
+	PlanFormatDOT  = "dot"
+)
+
+// planEdge is a variable a test takes from an earlier test
+type planEdge struct {
+	Producer int
+	Names    []string
+}
+
+// planStep is one test of the execution plan with the variables it provides and uses
+type planStep struct {
+	TestCase TestCase
+	Target   string
+	Provides []string
+	Uses     []planEdge
+	// External lists variables no earlier test provides, which are built-ins like {{callback_url}}
+	External []string
+}
+
+// ExecutionPlan is the resolved order of a suite, its dependency edges and its independent chains
+type ExecutionPlan struct {
+	Steps  []planStep
+	Chains [][]int
+}
+
+// runPlanCommand implements `plan`, which prints the execution plan of a config without running it
+func runPlanCommand(args []string) int {
+	fs := flag.NewFlagSet("plan", flag.ContinueOnError)
+	format := fs.String("format", PlanFormatTree, "Plan format ("+PlanFormatTree+", "+PlanFormatDOT+")")
+	output := fs.String("o", "", "Write the plan to file (default: stdout)")
+	keyFile := fs.String("key-file", os.Getenv(ConfigKeyEnv), "Key file of an encrypted config (default $"+ConfigKeyEnv+")")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s plan [-format tree|dot] [-o plan.dot] [-key-file k] <config.json>\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args)
+	if err != nil {
+		return 1
+	}
+	if len(paths) != 1 {
+		fmt.Fprintf(os.Stderr, "%sError: Config file path required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+	if *format != PlanFormatTree && *format != PlanFormatDOT {
+		fmt.Fprintf(os.Stderr, "%sError: -format must be %s or %s%s\n\n", ColorRed, PlanFormatTree, PlanFormatDOT, ColorReset)
+		fs.Usage()
+		return 1
+	}
+
+	// A plan never resolves secrets or runs commands, so placeholders are shown as written.
+	// Loading prints progress, which must not end up in a plan written to stdout.
+	tester := NewAPITester(paths[0], "", false)
+	tester.Resolvers = nil
+	if *keyFile != "" {
+		if tester.ConfigKey, err = LoadConfigKey(*keyFile); err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+	}
+	tester.out = os.Stderr
+	if err := tester.LoadConfig(); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+
+	plan := tester.BuildPlan()
+	var buf bytes.Buffer
+	if *format == PlanFormatDOT {
+		plan.writeDOT(&buf)
+	} else {
+		plan.writeTree(&buf, paths[0])
+	}
+
+	if *output == "" {
+		os.Stdout.Write(buf.Bytes())
+		return 0
+	}
+	if err := os.WriteFile(*output, buf.Bytes(), DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write plan: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Printf("%s✓ Plan written to: %s%s\n", ColorGreen, *output, ColorReset)
+	return 0
+}
+
+// BuildPlan resolves which earlier test provides each variable a test uses, the same way
+// sharding and -rerun-from do: the latest test before it that extracts the variable
+func (t *APITester) BuildPlan() ExecutionPlan {
+	plan := ExecutionPlan{Chains: dependencyChains(t.TestCases)}
+	producers := make(map[string]int)
+	ids := make(map[string]int)
+	for i, testCase := range t.TestCases {
+		step := planStep{TestCase: testCase, Target: testCase.API, Provides: producedVariables(testCase)}
+		if definition, ok := stepTypes[testCase.Type]; ok {
+			step.Target = definition.target(t, testCase)
+		}
+
+		edges := make(map[int][]string)
+		for _, name := range usedVariables(testCase) {
+			if !isVariablePlaceholder(name) || isLoopPlaceholder(testCase, name) {
+				continue
+			}
+			if producer, ok := producers[name]; ok {
+				edges[producer] = append(edges[producer], name)
+			} else {
+				step.External = append(step.External, name)
+			}
+		}
+		// Comparing against an earlier response shows as using it
+		for _, id := range responseReferences(testCase) {
+			if producer, ok := ids[id]; ok && !slices.Contains(edges[producer], "response") {
+				edges[producer] = append(edges[producer], "response")
+			}
+		}
+		for producer, names := range edges {
+			sort.Strings(names)
+			step.Uses = append(step.Uses, planEdge{Producer: producer, Names: names})
+		}
+		sort.Slice(step.Uses, func(a, b int) bool { return step.Uses[a].Producer < step.Uses[b].Producer })
+		sort.Strings(step.External)
+
+		for _, name := range step.Provides {
+			producers[name] = i
+		}
+		if testCase.ID != "" {
+			ids[testCase.ID] = i
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	return plan
+}
+
+// label names a test as it appears in run output
+func (s planStep) label() string {
+	return fmt.Sprintf("[%d] %s", s.TestCase.Order, s.TestCase.TestCaseName)
+}
+
+// request describes what a test calls
+func (s planStep) request() string {
+	if _, ok := stepTypes[s.TestCase.Type]; ok {
+		return strings.ToUpper(s.TestCase.Type) + " " + s.Target
+	}
+	return strings.ToUpper(s.TestCase.Method) + " " + s.Target
+}
+
+// writeTree renders the plan as one tree per independent chain
+func (p ExecutionPlan) writeTree(buf *bytes.Buffer, configPath string) {
+	fmt.Fprintf(buf, "Execution plan for %s: %d tests in %d independent groups\n", configPath, len(p.Steps), len(p.Chains))
+	for g, chain := range p.Chains {
+		if len(chain) == 1 {
+			fmt.Fprintf(buf, "\nGroup %d (1 test)\n", g+1)
+		} else {
+			fmt.Fprintf(buf, "\nGroup %d (%d tests)\n", g+1, len(chain))
+		}
+		for n, index := range chain {
+			step := p.Steps[index]
+			branch, indent := "├─", "│  "
+			if n == len(chain)-1 {
+				branch, indent = "└─", "   "
+			}
+			fmt.Fprintf(buf, "%s %s  %s\n", branch, step.label(), step.request())
+			if len(step.Provides) > 0 {
+				fmt.Fprintf(buf, "%s   provides: %s\n", indent, strings.Join(step.Provides, ", "))
+			}
+			for _, edge := range step.Uses {
+				fmt.Fprintf(buf, "%s   uses: %s ← %s\n", indent, strings.Join(edge.Names, ", "), p.Steps[edge.Producer].label())
+			}
+			if len(step.External) > 0 {
+				fmt.Fprintf(buf, "%s   external: %s\n", indent, strings.Join(step.External, ", "))
+			}
+		}
+	}
+	if len(p.Chains) > 1 {
+		fmt.Fprintf(buf, "\nGroups share no variables, so they can run in parallel (see -shard).\n")
+	}
+}
+
+// writeDOT renders the plan as a Graphviz digraph with one cluster per independent chain
+func (p ExecutionPlan) writeDOT(buf *bytes.Buffer) {
+	buf.WriteString("digraph plan {\n  rankdir=LR;\n  node [shape=box];\n")
+	for g, chain := range p.Chains {
+		fmt.Fprintf(buf, "  subgraph cluster_%d {\n    label=%q;\n", g+1, fmt.Sprintf("Group %d", g+1))
+		for _, index := range chain {
+			step := p.Steps[index]
+			fmt.Fprintf(buf, "    t%d [label=%q];\n", index, step.label()+"\n"+step.request())
+		}
+		buf.WriteString("  }\n")
+	}
+	for i, step := range p.Steps {
+		for _, edge := range step.Uses {
+			fmt.Fprintf(buf, "  t%d -> t%d [label=%q];\n", edge.Producer, i, strings.Join(edge.Names, ", "))
+		}
+	}
+	buf.WriteString("}\n")
+}
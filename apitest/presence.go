@@ -0,0 +1,42 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expected values that check whether a field is there rather than what it holds
+const (
+	// NullMarker requires the key to be present with a null value
+	NullMarker = "{{null}}"
+	// AbsentMarker requires the key to be missing; a null value doesn't count as missing
+	AbsentMarker = "{{absent}}"
+)
+
+// presenceMismatch explains how a field fails a null or absent expectation, or an expected value
+// whose key is missing; it returns "" when the field's presence doesn't fail the check
+func presenceMismatch(expected, actual interface{}, exists bool) string {
+	switch {
+	case expected == AbsentMarker && exists:
+		return fmt.Sprintf("Expected absent, got %s", formatJSONContext(actual, 0))
+	case expected == AbsentMarker:
+		return ""
+	case !exists && expected == NullMarker:
+		return "Expected null, key not found in response"
+	case !exists:
+		return "Key not found in response"
+	case expected == NullMarker && actual != nil:
+		return fmt.Sprintf("Expected null, got %s", formatJSONContext(actual, 0))
+	}
+	return ""
+}
+
+// pathFound reports whether a path exists in the data; JMESPath doesn't tell null from missing,
+// so for its expressions any non-null result counts as found
+func pathFound(data interface{}, path string, value interface{}) bool {
+	if strings.HasPrefix(path, JMESPathPrefix) {
+		return value != nil
+	}
+	_, found := nestedValue(data, path)
+	return found
+}
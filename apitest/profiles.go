@@ -0,0 +1,165 @@
+package apitest
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"sort"
+	"strings"
+)
+
+// AuthProfileNone disables the suite's default auth profile for one test
+const AuthProfileNone = "none"
+
+// DefaultAPIKeyHeader carries an auth profile's api_key unless it names another header or a query parameter
+const DefaultAPIKeyHeader = "X-API-Key"
+
+// AuthProfile is a named way of authenticating from the config's auth_profiles. Its values are
+// resolved on every request, so a token can come from {{env:...}} or an extracted variable.
+type AuthProfile struct {
+	Bearer string     `json:"bearer"`
+	Basic  *BasicAuth `json:"basic"`
+	// APIKey is sent in Header, or in the query parameter Query
+	APIKey  string                  `json:"api_key"`
+	Header  string                  `json:"header"`
+	Query   string                  `json:"query"`
+	Headers map[string]HeaderValues `json:"headers"`
+	Signing *SigningConfig          `json:"signing"`
+}
+
+// BasicAuth holds HTTP basic credentials
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// check rejects a profile that sets no credentials or two conflicting ones
+func (p *AuthProfile) check() error {
+	schemes := 0
+	for _, set := range []bool{p.Bearer != "", p.Basic != nil, p.APIKey != ""} {
+		if set {
+			schemes++
+		}
+	}
+	if schemes > 1 {
+		return fmt.Errorf("set only one of bearer, basic and api_key")
+	}
+	if schemes == 0 && len(p.Headers) == 0 && p.Signing == nil {
+		return fmt.Errorf("set bearer, basic, api_key, headers or signing")
+	}
+	if (p.Header != "" || p.Query != "") && p.APIKey == "" {
+		return fmt.Errorf("header and query only apply to api_key")
+	}
+	if p.Header != "" && p.Query != "" {
+		return fmt.Errorf("send api_key in a header or a query parameter, not both")
+	}
+	return nil
+}
+
+// applyProfiles resolves the header sets and auth profile a test references by name. The sets'
+// headers are added in order, a later set replacing an earlier one's header of the same name, and
+// the test's own headers replace both. A test without auth_profile uses the suite's default one.
+func applyProfiles(testCase *TestCase, headerSets map[string]map[string]HeaderValues, profiles map[string]*AuthProfile, defaultProfile string) error {
+	if len(testCase.HeaderSets) > 0 {
+		merged := make(map[string]HeaderValues)
+		// Names are compared canonically, so "x-tenant" in a set and "X-Tenant" in the test are one header
+		add := func(headers map[string]HeaderValues) {
+			for name, values := range headers {
+				for existing := range merged {
+					if textproto.CanonicalMIMEHeaderKey(existing) == textproto.CanonicalMIMEHeaderKey(name) {
+						delete(merged, existing)
+					}
+				}
+				merged[name] = values
+			}
+		}
+		for _, name := range testCase.HeaderSets {
+			set, ok := headerSets[name]
+			if !ok {
+				return fmt.Errorf("unknown header set %q (available: %s)", name, strings.Join(profileNames(headerSets), ", "))
+			}
+			add(set)
+		}
+		add(testCase.Headers)
+		testCase.Headers = merged
+	}
+
+	name := testCase.AuthProfile
+	if name == "" {
+		name = defaultProfile
+	}
+	if name == "" || name == AuthProfileNone {
+		return nil
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		return fmt.Errorf("unknown auth profile %q (available: %s)", name, strings.Join(profileNames(profiles), ", "))
+	}
+	testCase.auth = profile
+	return nil
+}
+
+// loadProfiles checks the config's auth profiles and the default one
+func loadProfiles(config Config) (map[string]*AuthProfile, error) {
+	profiles := make(map[string]*AuthProfile, len(config.AuthProfiles))
+	for name, profile := range config.AuthProfiles {
+		if name == AuthProfileNone {
+			return nil, fmt.Errorf("auth profile %q: the name is reserved for disabling auth", name)
+		}
+		if err := profile.check(); err != nil {
+			return nil, fmt.Errorf("auth profile %q: %w", name, err)
+		}
+		profiles[name] = &profile
+	}
+	if name := config.AuthProfile; name != "" && name != AuthProfileNone && profiles[name] == nil {
+		return nil, fmt.Errorf("unknown default auth profile %q (available: %s)", name, strings.Join(profileNames(profiles), ", "))
+	}
+	return profiles, nil
+}
+
+// applyAuth adds a test's auth profile to its request. Headers the test sets itself are kept, so
+// a test can still send a deliberately wrong token.
+func (t *APITester) applyAuth(profile *AuthProfile, req *http.Request) {
+	setHeader := func(name, value string) {
+		if headerValue(req.Header, name) == "" {
+			req.Header.Set(name, value)
+		}
+	}
+	for name, values := range t.replaceInHeaders(profile.Headers) {
+		if headerValue(req.Header, name) == "" {
+			for _, value := range values {
+				req.Header.Add(name, value)
+			}
+		}
+	}
+	switch {
+	case profile.Bearer != "":
+		setHeader("Authorization", "Bearer "+t.replaceVariables(profile.Bearer))
+	case profile.Basic != nil:
+		credentials := t.replaceVariables(profile.Basic.Username) + ":" + t.replaceVariables(profile.Basic.Password)
+		setHeader("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(credentials)))
+	case profile.APIKey != "" && profile.Query != "":
+		query := req.URL.Query()
+		if !query.Has(profile.Query) {
+			query.Set(profile.Query, t.replaceVariables(profile.APIKey))
+			req.URL.RawQuery = query.Encode()
+		}
+	case profile.APIKey != "":
+		header := profile.Header
+		if header == "" {
+			header = DefaultAPIKeyHeader
+		}
+		setHeader(header, t.replaceVariables(profile.APIKey))
+	}
+}
+
+// profileNames returns the sorted names of a config section, for error messages
+func profileNames[V any](section map[string]V) []string {
+	names := make([]string, 0, len(section))
+	for name := range section {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,116 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// DefaultReportFormat is used when no report format is given
+const DefaultReportFormat = "json"
+
+// LatencyBucketsSeconds are the histogram bucket bounds used for test latency metrics
+var LatencyBucketsSeconds = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// reportFormatter renders a test report into a file payload
+type reportFormatter func(report TestReport) ([]byte, error)
+
+// reportFormatters maps format names to their formatter
+var reportFormatters = map[string]reportFormatter{
+	"json":        formatJSONReport,
+	"junit":       formatJUnitReport,
+	"html":        formatHTMLReport,
+	"openmetrics": formatOpenMetricsReport,
+}
+
+// reportFormatNames returns the supported report format names in sorted order
+func reportFormatNames() []string {
+	names := make([]string, 0, len(reportFormatters))
+	for name := range reportFormatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// formatReport renders a report using the named format
+func formatReport(report TestReport, format string) ([]byte, error) {
+	formatter, ok := reportFormatters[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown report format %q (supported: %s)",
+			format, strings.Join(reportFormatNames(), ", "))
+	}
+	return formatter(report)
+}
+
+// formatJSONReport renders the report as indented JSON
+func formatJSONReport(report TestReport) ([]byte, error) {
+	jsonData, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal results: %w", err)
+	}
+	return jsonData, nil
+}
+
+// formatOpenMetricsReport renders per-test pass state and latency as OpenMetrics samples
+func formatOpenMetricsReport(report TestReport) ([]byte, error) {
+	var sb strings.Builder
+
+	sb.WriteString("# TYPE test_pass gauge\n")
+	sb.WriteString("# HELP test_pass Whether the test passed (1) or not (0).\n")
+	for _, result := range report.Results {
+		pass := 0
+		if result.Status == StatusPassed {
+			pass = 1
+		}
+		fmt.Fprintf(&sb, "test_pass{%s%s} %d\n", openMetricsLabels(result), formatLabels(report.Labels), pass)
+	}
+
+	sb.WriteString("# TYPE test_latency_seconds histogram\n")
+	sb.WriteString("# UNIT test_latency_seconds seconds\n")
+	sb.WriteString("# HELP test_latency_seconds Response time of the test request.\n")
+	for _, result := range report.Results {
+		// Tests that never got a response have no latency to record
+		if result.ResponseStatusCode == 0 {
+			continue
+		}
+
+		labels := openMetricsLabels(result) + formatLabels(report.Labels)
+		seconds := result.ResponseTimeMs / 1000
+		for _, bound := range LatencyBucketsSeconds {
+			count := 0
+			if seconds <= bound {
+				count = 1
+			}
+			fmt.Fprintf(&sb, "test_latency_seconds_bucket{%s,le=\"%g\"} %d\n", labels, bound, count)
+		}
+		fmt.Fprintf(&sb, "test_latency_seconds_bucket{%s,le=\"+Inf\"} 1\n", labels)
+		fmt.Fprintf(&sb, "test_latency_seconds_sum{%s} %g\n", labels, seconds)
+		fmt.Fprintf(&sb, "test_latency_seconds_count{%s} 1\n", labels)
+	}
+
+	sb.WriteString("# EOF\n")
+	return []byte(sb.String()), nil
+}
+
+// openMetricsLabels builds the label set identifying a test result; the id label keeps
+// series continuous when a test is renamed
+func openMetricsLabels(result TestResult) string {
+	tags := append([]string(nil), result.Tags...)
+	sort.Strings(tags)
+	labels := fmt.Sprintf("test=%s,tags=%s",
+		quoteLabelValue(result.TestCaseName), quoteLabelValue(strings.Join(tags, ",")))
+	if result.ID != "" {
+		labels = "id=" + quoteLabelValue(result.ID) + "," + labels
+	}
+	return labels
+}
+
+// quoteLabelValue escapes and quotes an OpenMetrics label value
+func quoteLabelValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
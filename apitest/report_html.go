@@ -0,0 +1,101 @@
+package apitest
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"strings"
+)
+
+// htmlReportTemplate is a self-contained page, so the report can be opened straight from a CI artifact
+var htmlReportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"lower":       strings.ToLower,
+	"join":        strings.Join,
+	"ownErrors":   ownErrors,
+	"failedSteps": failedSteps,
+	"ms":          formatMs,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>API test report: {{.ConfigFile}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 6px 10px; border-bottom: 1px solid #ddd; vertical-align: top; }
+th { background: #f5f5f5; }
+pre { margin: 0; white-space: pre-wrap; font-size: 12px; }
+.summary span { display: inline-block; margin-right: 1.5em; font-weight: bold; }
+.passed { color: #1a7f37; } .failed, .timeout, .error { color: #cf222e; } .flaky { color: #9a6700; } .skipped { color: #6e7781; } .logs { color: #57606a; }
+tr.step td { background: #fafafa; font-size: 13px; }
+</style>
+</head>
+<body>
+<h1>API test report</h1>
+<p>{{.ConfigFile}} &middot; {{.Timestamp}}{{if .BaseURL}} &middot; {{.BaseURL}}{{end}}{{if .RunID}} &middot; run {{.RunID}}{{end}}</p>
+<p class="summary">
+<span>Total: {{index .Summary "total"}}</span>
+<span class="passed">Passed: {{index .Summary "passed"}}</span>
+<span class="failed">Failed: {{index .Summary "failed"}}</span>
+<span class="flaky">Flaky: {{index .Summary "flaky"}}</span>
+<span class="skipped">Skipped: {{index .Summary "skipped"}}</span>
+{{- with index .Summary "timeout"}}
+<span class="timeout">Timed out: {{.}}</span>
+{{- end}}
+{{- with index .Summary "error"}}
+<span class="error">Errors: {{.}}</span>
+{{- end}}
+</p>
+{{- if .Labels}}
+<p>{{range $key, $value := .Labels}}<code>{{$key}}={{$value}}</code> {{end}}</p>
+{{- end}}
+{{- if .SuiteFailures}}
+<h2>Suite assertions</h2>
+<ul>{{range .SuiteFailures}}<li class="failed">{{.}}</li>{{end}}</ul>
+{{- end}}
+<table>
+<tr><th>#</th><th>Test</th><th>Request</th><th>Status</th><th>Time</th><th>Details</th></tr>
+{{- range .Results}}
+<tr>
+<td>{{.Order}}</td>
+<td>{{.TestCaseName}}{{if .Group}}<br><small>{{.Group}}</small>{{end}}</td>
+<td><code>{{.Method}} {{.URL}}</code>{{if .ResponseStatusCode}}<br>HTTP {{.ResponseStatusCode}}{{end}}</td>
+<td class="{{lower .Status}}">{{.Status}}</td>
+<td>{{ms .ResponseTimeMs}}</td>
+<td>
+{{- if .SkipReason}}{{.SkipReason}}{{end}}
+{{- with ownErrors .}}<pre class="failed">{{join . "\n"}}</pre>{{end}}
+{{- with failedSteps .}}<pre class="failed">Failed steps: {{join . ", "}}</pre>{{end}}
+{{- if .Hint}}<pre class="logs">Hint: {{.Hint}}</pre>{{end}}
+{{- if .FlakyErrors}}<pre class="flaky">{{join .FlakyErrors "\n"}}</pre>{{end}}
+{{- if .Logs}}<pre class="logs">{{join .Logs "\n"}}</pre>{{end}}
+{{- if .Warnings}}<pre class="flaky">{{join .Warnings "\n"}}</pre>{{end}}
+</td>
+</tr>
+{{- range .SideEffects}}
+<tr class="step">
+<td></td>
+<td>&#8627; {{.TestCaseName}}</td>
+<td><code>{{.Method}} {{.URL}}</code>{{if .ResponseStatusCode}}<br>HTTP {{.ResponseStatusCode}}{{end}}</td>
+<td class="{{lower .Status}}">{{.Status}}</td>
+<td>{{ms .ResponseTimeMs}}</td>
+<td>
+{{- if .SkipReason}}{{.SkipReason}}{{end}}
+{{- if .Errors}}<pre class="failed">{{join .Errors "\n"}}</pre>{{end}}
+</td>
+</tr>
+{{- end}}
+{{- end}}
+</table>
+</body>
+</html>
+`))
+
+// formatHTMLReport renders the report as a standalone HTML page
+func formatHTMLReport(report TestReport) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlReportTemplate.Execute(&buf, report); err != nil {
+		return nil, fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
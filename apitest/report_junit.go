@@ -0,0 +1,169 @@
+package apitest
+
+import (
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// junitTestSuites is the root of a JUnit XML report, as read by CI servers
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+// junitTestSuite holds the results of one run
+type junitTestSuite struct {
+	Name       string           `xml:"name,attr"`
+	Tests      int              `xml:"tests,attr"`
+	Failures   int              `xml:"failures,attr"`
+	Errors     int              `xml:"errors,attr"`
+	Skipped    int              `xml:"skipped,attr"`
+	Time       string           `xml:"time,attr"`
+	Timestamp  string           `xml:"timestamp,attr,omitempty"`
+	Properties *junitProperties `xml:"properties,omitempty"`
+	Cases      []junitTestCase  `xml:"testcase"`
+}
+
+// junitProperties holds the run labels, left out when there are none
+type junitProperties struct {
+	Property []junitProperty `xml:"property"`
+}
+
+// junitProperty is a run label
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+// junitTestCase is one result; failures carry the first error as their message and all of them as text
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+// junitMessage is a failure, error or skip reason
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// formatJUnitReport renders the report as JUnit XML. Tests are grouped by their data-driven group
+// or else the config file; flaky tests count as passed, with their first failure in system-out.
+// Tests that never reached the API are errors rather than failures, as JUnit tells the two apart.
+func formatJUnitReport(report TestReport) ([]byte, error) {
+	suiteName := strings.TrimSuffix(filepath.Base(report.ConfigFile), filepath.Ext(report.ConfigFile))
+	suite := junitTestSuite{Name: suiteName, Timestamp: report.Timestamp}
+	if len(report.Labels) > 0 {
+		properties := &junitProperties{}
+		for key, value := range report.Labels {
+			properties.Property = append(properties.Property, junitProperty{Name: key, Value: value})
+		}
+		sort.Slice(properties.Property, func(i, j int) bool { return properties.Property[i].Name < properties.Property[j].Name })
+		suite.Properties = properties
+	}
+
+	var totalSeconds float64
+	for _, result := range report.Results {
+		seconds := result.ResponseTimeMs / 1000
+		totalSeconds += seconds
+		testCase := junitTestCase{
+			Name:      fmt.Sprintf("[%d] %s", result.Order, result.TestCaseName),
+			ClassName: suiteName,
+			Time:      fmt.Sprintf("%.3f", seconds),
+		}
+		if result.Group != "" {
+			testCase.ClassName = suiteName + "." + result.Group
+		}
+
+		var out []string
+		switch result.Status {
+		case StatusFailed, StatusTimeout, StatusError:
+			// Side effect checks are testcases of their own, so only their names appear here
+			errors := ownErrors(result)
+			if steps := failedSteps(result); len(steps) > 0 {
+				errors = append(errors, "Failed steps: "+strings.Join(steps, ", "))
+			}
+			if result.Hint != "" {
+				errors = append(errors, "Hint: "+result.Hint)
+			}
+			message := ""
+			if len(errors) > 0 {
+				message = strings.SplitN(errors[0], "\n", 2)[0]
+			}
+			failure := &junitMessage{
+				Message: message,
+				Type:    strings.Join(result.Categories, ","),
+				Text:    strings.Join(errors, "\n"),
+			}
+			if result.Status == StatusError {
+				suite.Errors++
+				testCase.Error = failure
+			} else {
+				suite.Failures++
+				testCase.Failure = failure
+			}
+		case StatusSkipped:
+			suite.Skipped++
+			testCase.Skipped = &junitMessage{Message: result.SkipReason}
+		case StatusFlaky:
+			out = append(out, fmt.Sprintf("FLAKY: passed after %d reruns", result.Reruns))
+			out = append(out, result.FlakyErrors...)
+		}
+		for _, warning := range result.Warnings {
+			out = append(out, "WARNING: "+warning)
+		}
+		if len(result.Logs) > 0 {
+			out = append(out, fmt.Sprintf("LOGS for request %s:", result.RequestID))
+			out = append(out, result.Logs...)
+		}
+		testCase.SystemOut = strings.Join(out, "\n")
+		suite.Cases = append(suite.Cases, testCase)
+
+		for _, check := range result.SideEffects {
+			step := junitTestCase{
+				Name:      fmt.Sprintf("%s › %s", testCase.Name, check.TestCaseName),
+				ClassName: testCase.ClassName,
+				Time:      fmt.Sprintf("%.3f", check.ResponseTimeMs/1000),
+			}
+			totalSeconds += check.ResponseTimeMs / 1000
+			switch check.Status {
+			case StatusFailed, StatusTimeout:
+				suite.Failures++
+				step.Failure = junitStepMessage(check)
+			case StatusError:
+				suite.Errors++
+				step.Error = junitStepMessage(check)
+			case StatusSkipped:
+				suite.Skipped++
+				step.Skipped = &junitMessage{Message: check.SkipReason}
+			}
+			suite.Cases = append(suite.Cases, step)
+		}
+	}
+	suite.Tests = len(suite.Cases)
+	suite.Time = fmt.Sprintf("%.3f", totalSeconds)
+
+	data, err := xml.MarshalIndent(junitTestSuites{Suites: []junitTestSuite{suite}}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal JUnit report: %w", err)
+	}
+	return append([]byte(xml.Header), append(data, '\n')...), nil
+}
+
+// junitStepMessage is the failure or error of a side effect check
+func junitStepMessage(check TestResult) *junitMessage {
+	return &junitMessage{
+		Message: strings.SplitN(strings.Join(check.Errors, "\n"), "\n", 2)[0],
+		Type:    strings.Join(check.Categories, ","),
+		Text:    strings.Join(check.Errors, "\n"),
+	}
+}
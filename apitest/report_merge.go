@@ -0,0 +1,169 @@
+package apitest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runReportCommand dispatches the "report" subcommands and returns the exit code
+func runReportCommand(args []string) int {
+	if len(args) < 1 || args[0] != "merge" {
+		fmt.Fprintf(os.Stderr, "%sError: Unknown report command (expected: report merge)%s\n", ColorRed, ColorReset)
+		return 1
+	}
+
+	fs := flag.NewFlagSet("report merge", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the merged report to file (default: stdout)")
+	format := fs.String("format", DefaultReportFormat, "Merged report format ("+strings.Join(reportFormatNames(), ", ")+")")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s report merge [-o merged.json] [-format json] <report.json>...\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+
+	paths, err := parseInterleaved(fs, args[1:])
+	if err != nil {
+		return 1
+	}
+	if len(paths) == 0 {
+		fmt.Fprintf(os.Stderr, "%sError: At least one report file required%s\n\n", ColorRed, ColorReset)
+		fs.Usage()
+		return 1
+	}
+
+	var reports []TestReport
+	for _, path := range paths {
+		report, err := loadReport(path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			return 1
+		}
+		reports = append(reports, report)
+	}
+
+	data, err := formatReport(mergeReports(reports, paths), *format)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write merged report: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Printf("%s✓ Merged %d reports into: %s%s\n", ColorGreen, len(reports), *output, ColorReset)
+	return 0
+}
+
+// parseInterleaved parses flags that may appear before, between or after positional arguments
+func parseInterleaved(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
+
+// loadReport reads a previously exported JSON report
+func loadReport(path string) (TestReport, error) {
+	var report TestReport
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return report, fmt.Errorf("failed to read report file: %w", err)
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		return report, fmt.Errorf("failed to parse report %s: %w", path, err)
+	}
+	return report, nil
+}
+
+// resultKey identifies the same test across reports
+func resultKey(result TestResult) string {
+	if result.ID != "" {
+		return "id\x00" + result.ID
+	}
+	return fmt.Sprintf("%d\x00%s", result.Order, result.TestCaseName)
+}
+
+// mergeReports combines reports into one, keeping the most recent result for each test
+func mergeReports(reports []TestReport, sources []string) TestReport {
+	// Process oldest reports first so newer results overwrite older duplicates
+	indices := make([]int, len(reports))
+	for i := range indices {
+		indices[i] = i
+	}
+	sort.SliceStable(indices, func(i, j int) bool {
+		return reportTime(reports[indices[i]]).Before(reportTime(reports[indices[j]]))
+	})
+
+	latest := make(map[string]TestResult)
+	var keys []string
+	configFiles := make(map[string]bool)
+	baseURLs := make(map[string]bool)
+	for _, i := range indices {
+		for _, result := range reports[i].Results {
+			key := resultKey(result)
+			if _, seen := latest[key]; !seen {
+				keys = append(keys, key)
+			}
+			latest[key] = result
+		}
+		configFiles[reports[i].ConfigFile] = true
+		baseURLs[reports[i].BaseURL] = true
+	}
+
+	results := make([]TestResult, 0, len(keys))
+	for _, key := range keys {
+		results = append(results, latest[key])
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Order < results[j].Order
+	})
+
+	return TestReport{
+		Timestamp:  time.Now().Format(time.RFC3339),
+		ConfigFile: joinDistinct(configFiles),
+		BaseURL:    joinDistinct(baseURLs),
+		MergedFrom: sources,
+		Labels:     mergeLabels(reports),
+		Summary:    summaryMap(results),
+		Categories: countCategories(results),
+		Results:    results,
+	}
+}
+
+// reportTime parses a report timestamp, treating unparsable values as oldest
+func reportTime(report TestReport) time.Time {
+	parsed, err := time.Parse(time.RFC3339, report.Timestamp)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+// joinDistinct joins the non-empty values of a set in sorted order
+func joinDistinct(values map[string]bool) string {
+	var list []string
+	for value := range values {
+		if value != "" {
+			list = append(list, value)
+		}
+	}
+	sort.Strings(list)
+	return strings.Join(list, ",")
+}
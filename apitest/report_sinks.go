@@ -0,0 +1,210 @@
+package apitest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Report sinks that aren't files in a report format
+const (
+	SinkConsole = "console"
+	SinkWebhook = "webhook"
+)
+
+// WebhookTimeout bounds a webhook delivery, so a slow receiver can't hold up the exit code
+const WebhookTimeout = 30 * time.Second
+
+// ReportSink delivers the report of a run to one destination
+type ReportSink interface {
+	// Deliver sends the report, returning where it went for the log line
+	Deliver(report TestReport) (string, error)
+}
+
+// reportFlags collects repeated -report sink specs
+type reportFlags []string
+
+// String renders the sink specs comma-separated
+func (r *reportFlags) String() string {
+	return strings.Join(*r, ",")
+}
+
+// Set checks and adds one sink spec
+func (r *reportFlags) Set(value string) error {
+	if _, _, err := parseReportSpec(value); err != nil {
+		return err
+	}
+	*r = append(*r, value)
+	return nil
+}
+
+// isReportFormat reports whether a name is a report format, including the -template one
+func isReportFormat(name string) bool {
+	_, ok := reportFormatters[name]
+	return ok || name == TemplateReportFormat
+}
+
+// parseReportSpec splits a sink spec: <format>:<path> writes a file, console[:<format>] prints
+// to stdout (JSON by default), webhook:<url> posts the JSON report and s3:// or gs:// uploads it
+func parseReportSpec(spec string) (kind, target string, err error) {
+	kind, target, _ = strings.Cut(spec, ":")
+	switch {
+	case kind == SinkConsole:
+		if target == "" {
+			target = DefaultReportFormat
+		}
+		if !isReportFormat(target) {
+			return "", "", fmt.Errorf("report %q: unknown format %q", spec, target)
+		}
+	case kind == SinkWebhook:
+		if !strings.HasPrefix(target, "http://") && !strings.HasPrefix(target, "https://") {
+			return "", "", fmt.Errorf("report %q: webhook needs an http(s) URL, e.g. webhook:https://hooks.example.com/runs", spec)
+		}
+	case kind == SinkS3 || kind == SinkGCS:
+		if _, err := parseBucketSpec(spec); err != nil {
+			return "", "", err
+		}
+		target = spec
+	case isReportFormat(kind):
+		if target == "" {
+			return "", "", fmt.Errorf("report %q: needs a path, e.g. %s:results.out", spec, kind)
+		}
+	default:
+		return "", "", fmt.Errorf("report %q: unknown sink %q (available: %s, %s, %s, %s, %s, %s)", spec, kind,
+			strings.Join(reportFormatNames(), ", "), TemplateReportFormat, SinkConsole, SinkWebhook, SinkS3, SinkGCS)
+	}
+	return kind, target, nil
+}
+
+// newReportSink creates the sink a spec describes
+func (t *APITester) newReportSink(spec string) (ReportSink, error) {
+	kind, target, err := parseReportSpec(spec)
+	if err != nil {
+		return nil, err
+	}
+	switch kind {
+	case SinkConsole:
+		return consoleSink{tester: t, format: target}, nil
+	case SinkWebhook:
+		return webhookSink{tester: t, url: target}, nil
+	case SinkS3, SinkGCS:
+		location, err := parseBucketSpec(target)
+		if err != nil {
+			return nil, err
+		}
+		return &bucketSink{tester: t, location: location}, nil
+	}
+	return fileSink{tester: t, format: kind, path: target}, nil
+}
+
+// renderReport renders a report in a format, including the -template one
+func (t *APITester) renderReport(report TestReport, format string) ([]byte, error) {
+	if format == TemplateReportFormat {
+		return t.formatTemplateReport(report)
+	}
+	return formatReport(report, format)
+}
+
+// DeliverReport sends the report to every sink. A failing sink is reported but doesn't stop the
+// others, and doesn't change the outcome of the run.
+func (t *APITester) DeliverReport(sinks []ReportSink) {
+	if len(sinks) == 0 {
+		return
+	}
+	report := t.buildReport()
+	for _, sink := range sinks {
+		destination, err := sink.Deliver(report)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%sError: %v%s\n", ColorRed, err, ColorReset)
+			continue
+		}
+		fmt.Fprintf(t.out, "%s✓ Results exported to: %s%s\n", ColorGreen, destination, ColorReset)
+	}
+}
+
+// fileSink writes the report to a file in one format
+type fileSink struct {
+	tester *APITester
+	format string
+	path   string
+}
+
+// Deliver writes the report file
+func (s fileSink) Deliver(report TestReport) (string, error) {
+	data, err := s.tester.renderReport(report, s.format)
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(s.path, data, DefaultFileMode); err != nil {
+		return "", fmt.Errorf("failed to write results file: %w", err)
+	}
+	return s.path, nil
+}
+
+// consoleSink prints the report to stdout, e.g. for piping into another tool
+type consoleSink struct {
+	tester *APITester
+	format string
+}
+
+// Deliver prints the report
+func (s consoleSink) Deliver(report TestReport) (string, error) {
+	data, err := s.tester.renderReport(report, s.format)
+	if err != nil {
+		return "", err
+	}
+	os.Stdout.Write(data)
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		fmt.Println()
+	}
+	return "stdout", nil
+}
+
+// webhookSink posts the JSON report to a URL, which may use resolvers such as {{env:WEBHOOK_URL}}
+type webhookSink struct {
+	tester *APITester
+	url    string
+}
+
+// Deliver posts the report and requires a 2xx answer
+func (s webhookSink) Deliver(report TestReport) (string, error) {
+	data, err := formatJSONReport(report)
+	if err != nil {
+		return "", err
+	}
+	return s.tester.postWebhook(s.url, data)
+}
+
+// postWebhook posts JSON to a webhook URL after resolving its placeholders, and requires a 2xx
+// answer. Webhook URLs often embed a token, so the destination returned and errors only show the host.
+func (t *APITester) postWebhook(webhookURL string, data []byte) (string, error) {
+	target := t.resolvePlaceholders(webhookURL)
+	if errs := t.takeResolveErrors(); len(errs) > 0 {
+		return "", fmt.Errorf("failed to resolve webhook url: %s", strings.Join(errs, "; "))
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook url: %w", err)
+	}
+	destination := fmt.Sprintf("webhook %s://%s", parsed.Scheme, parsed.Host)
+
+	client := &http.Client{Timeout: WebhookTimeout}
+	resp, err := client.Post(target, "application/json", bytes.NewReader(data))
+	if err != nil {
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("failed to post to %s: %w", destination, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("%s answered HTTP %d", destination, resp.StatusCode)
+	}
+	return destination, nil
+}
@@ -0,0 +1,54 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// testCaseKey identifies a test case the same way resultKey identifies its result in a report
+func testCaseKey(testCase TestCase) string {
+	return resultKey(TestResult{ID: testCase.ID, Order: testCase.Order, TestCaseName: testCase.TestCaseName})
+}
+
+// ApplyRerunFrom keeps only the tests that failed or were skipped in a previous report, plus the
+// earlier tests producing the variables they use, so their chains still resolve
+func (t *APITester) ApplyRerunFrom(path string, report TestReport) {
+	failed := make(map[string]bool)
+	for _, result := range report.Results {
+		if isFailed(result.Status) || result.Status == StatusSkipped {
+			failed[resultKey(result)] = true
+		}
+	}
+
+	keep := make([]bool, len(t.TestCases))
+	rerun := 0
+	for i, testCase := range t.TestCases {
+		if failed[testCaseKey(testCase)] {
+			keep[i] = true
+			rerun++
+			delete(failed, testCaseKey(testCase))
+		}
+	}
+	includeDependencies(t.TestCases, keep)
+
+	var selected []TestCase
+	for i, testCase := range t.TestCases {
+		if keep[i] {
+			selected = append(selected, testCase)
+		}
+	}
+
+	if len(failed) > 0 {
+		var missing []string
+		for _, result := range report.Results {
+			if failed[resultKey(result)] {
+				missing = append(missing, result.TestCaseName)
+			}
+		}
+		fmt.Fprintf(t.out, "%s⚠ %d failed tests from %s are no longer in the config: %s%s\n",
+			ColorYellow, len(missing), path, strings.Join(missing, ", "), ColorReset)
+	}
+	fmt.Fprintf(t.out, "%s✓ Rerun from %s: running %d failed tests and %d dependencies of %d test cases%s\n",
+		ColorGreen, path, rerun, len(selected)-rerun, len(t.TestCases), ColorReset)
+	t.TestCases = selected
+}
@@ -0,0 +1,220 @@
+package apitest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// ResolverTimeout bounds how long a single external resolver may take
+const ResolverTimeout = 30 * time.Second
+
+// Resolver resolves the key of a {{prefix:key}} placeholder to its value
+type Resolver interface {
+	Resolve(key string) (string, error)
+}
+
+// ResolverFunc adapts a function to the Resolver interface
+type ResolverFunc func(key string) (string, error)
+
+// Resolve calls f(key)
+func (f ResolverFunc) Resolve(key string) (string, error) {
+	return f(key)
+}
+
+// resolvedValue caches the outcome of resolving one placeholder
+type resolvedValue struct {
+	value string
+	err   error
+}
+
+// defaultResolvers returns the built-in resolvers keyed by placeholder prefix
+func defaultResolvers() map[string]Resolver {
+	return map[string]Resolver{
+		"env":   ResolverFunc(resolveEnv),
+		"file":  ResolverFunc(resolveFile),
+		"exec":  ResolverFunc(resolveExec),
+		"vault": ResolverFunc(resolveVault),
+		"ssm":   ResolverFunc(resolveSSM),
+	}
+}
+
+// RegisterResolver adds or replaces the resolver for a placeholder prefix
+func (t *APITester) RegisterResolver(prefix string, resolver Resolver) {
+	t.Resolvers[prefix] = resolver
+}
+
+// resolverPlaceholder returns the first {{prefix:key}} placeholder in text whose prefix has a
+// registered resolver, or "" if there is none
+func (t *APITester) resolverPlaceholder(text string) string {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		if prefix, _, ok := strings.Cut(match[1], ":"); ok && t.Resolvers[prefix] != nil {
+			return match[0]
+		}
+	}
+	return ""
+}
+
+// resolvePlaceholders replaces {{prefix:key}} placeholders using the registered resolvers.
+// Each placeholder is resolved once per run; failures are recorded for the running test.
+func (t *APITester) resolvePlaceholders(input string) string {
+	return placeholderPattern.ReplaceAllStringFunc(input, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-2]
+		prefix, key, ok := strings.Cut(name, ":")
+		if !ok {
+			return placeholder
+		}
+		resolver, ok := t.Resolvers[prefix]
+		if !ok {
+			return placeholder
+		}
+
+		cached, ok := t.resolved[name]
+		if !ok {
+			value, err := resolver.Resolve(key)
+			cached = resolvedValue{value: value, err: err}
+			t.resolved[name] = cached
+		}
+		if cached.err != nil {
+			t.resolveErrors = append(t.resolveErrors, fmt.Sprintf("%s: %v", placeholder, cached.err))
+			return placeholder
+		}
+		return cached.value
+	})
+}
+
+// takeResolveErrors returns and clears the resolution errors recorded since the last call
+func (t *APITester) takeResolveErrors() []string {
+	errs := t.resolveErrors
+	t.resolveErrors = nil
+
+	// The same failing placeholder may appear in several fields
+	seen := make(map[string]bool)
+	var unique []string
+	for _, err := range errs {
+		if !seen[err] {
+			seen[err] = true
+			unique = append(unique, err)
+		}
+	}
+	return unique
+}
+
+// resolveEnv reads an environment variable
+func resolveEnv(key string) (string, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", key)
+	}
+	return value, nil
+}
+
+// resolveFile reads a file's contents without the trailing newline
+func resolveFile(key string) (string, error) {
+	data, err := os.ReadFile(expandHome(key))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// resolveExec runs a shell command and returns its trimmed stdout
+func resolveExec(key string) (string, error) {
+	return runCommand(key)
+}
+
+// runCommand runs a command line through the platform shell and returns its trimmed stdout
+func runCommand(command string, args ...string) (string, error) {
+	return runCommandTimeout(ResolverTimeout, command, args...)
+}
+
+// runCommandTimeout is runCommand with its own time limit, for commands slower than a resolver
+func runCommandTimeout(timeout time.Duration, command string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch {
+	case len(args) > 0:
+		cmd = exec.CommandContext(ctx, command, args...)
+	case runtime.GOOS == "windows":
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	default:
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+
+	output, err := cmd.Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", fmt.Errorf("%w: %s", err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// resolveVault reads a field from HashiCorp Vault using VAULT_ADDR and VAULT_TOKEN.
+// The key has the form "secret/data/app#field"; both KV v1 and v2 responses are supported.
+func resolveVault(key string) (string, error) {
+	path, field, ok := strings.Cut(key, "#")
+	if !ok || field == "" {
+		return "", fmt.Errorf("vault key must have the form <path>#<field>")
+	}
+	addr := os.Getenv("VAULT_ADDR")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+
+	req, err := http.NewRequest("GET", strings.TrimRight(addr, "/")+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", os.Getenv("VAULT_TOKEN"))
+
+	client := &http.Client{Timeout: ResolverTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("vault returned HTTP %d", resp.StatusCode)
+	}
+
+	var secret struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &secret); err != nil {
+		return "", fmt.Errorf("invalid vault response: %w", err)
+	}
+
+	// KV v2 nests the secret under data.data
+	data := secret.Data
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		data = nested
+	}
+	value, ok := data[field]
+	if !ok {
+		return "", fmt.Errorf("field %q not found in vault secret", field)
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// resolveSSM reads a decrypted AWS SSM parameter through the AWS CLI and its usual credentials
+func resolveSSM(key string) (string, error) {
+	return runCommand("aws", "ssm", "get-parameter",
+		"--name", key, "--with-decryption",
+		"--query", "Parameter.Value", "--output", "text")
+}
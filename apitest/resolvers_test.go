@@ -0,0 +1,53 @@
+package apitest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// recordingResolver records the keys it is asked to resolve
+type recordingResolver struct {
+	keys []string
+}
+
+// Resolve records key and resolves it to "resolved"
+func (r *recordingResolver) Resolve(key string) (string, error) {
+	r.keys = append(r.keys, key)
+	return "resolved", nil
+}
+
+func TestResolversIgnoreResponseValues(t *testing.T) {
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = append(received, r.Header.Get("X-Name"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name": "{{exec:touch /tmp/pwned}}", "items": ["a", "{{exec:touch /tmp/pwned}}"]}`))
+	}))
+	defer server.Close()
+
+	tester := newTestTester(t, server.URL, `{"variables": {"token": "{{exec:token}}"}, "test_case": [
+		{"test_case_name": "Get", "order": 1, "method": "GET", "api": "/",
+			"extract": {"name": "name", "items": "items"}},
+		{"test_case_name": "Use", "order": 2, "method": "GET", "api": "/",
+			"headers": {"X-Name": "{{name}}", "Authorization": "{{token}}"}},
+		{"test_case_name": "Loop {{item}}", "order": 3, "method": "GET", "api": "/", "for_each": "{{items}}",
+			"headers": {"X-Name": "{{item}}"}}
+	]}`)
+	exec := &recordingResolver{}
+	tester.RegisterResolver("exec", exec)
+	tester.RunAllTests()
+
+	// The config's own placeholder is resolved; the response's never are
+	if strings.Join(exec.keys, ",") != "token" {
+		t.Errorf("resolved %q, want only the config's token", exec.keys)
+	}
+	if len(received) < 2 || received[1] != "{{exec:touch /tmp/pwned}}" {
+		t.Errorf("extracted value was sent as %q, want it as written in the response", received)
+	}
+	loop := tester.Results[len(tester.Results)-1]
+	if loop.Status != StatusFailed || !strings.Contains(strings.Join(loop.Errors, "; "), "only resolved when written in the config") {
+		t.Errorf("for_each over response items: status %s, errors %q; want it refused", loop.Status, loop.Errors)
+	}
+}
@@ -0,0 +1,126 @@
+package apitest
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// errClientSaturated marks a request that wasn't sent because the tester reached its own file limit
+var errClientSaturated = errors.New("client saturated")
+
+// resourceGuard caps the open files of the tester itself, so a run that exhausts its own descriptors
+// fails its requests as client-side instead of reporting connection errors against the API
+type resourceGuard struct {
+	limit int
+	// peakFiles and peakSockets are the highest counts seen before a request, refused the requests
+	// not sent because the limit was reached
+	peakFiles   int
+	peakSockets int
+	refused     int
+}
+
+// ResourceUsage summarizes the tester's own file descriptor use and the requests it broke
+type ResourceUsage struct {
+	MaxOpenFiles  int `json:"max_open_files,omitempty"`
+	PeakOpenFiles int `json:"peak_open_files,omitempty"`
+	PeakSockets   int `json:"peak_sockets,omitempty"`
+	Refused       int `json:"refused,omitempty"`
+	// ClientErrors counts the failed tests whose requests broke on the client: refused by the cap,
+	// or out of descriptors or local ports
+	ClientErrors int `json:"client_errors,omitempty"`
+}
+
+// EnableResourceGuard fails requests without sending them once the tester has limit open files
+func (t *APITester) EnableResourceGuard(limit int) error {
+	if _, _, ok := openFiles(); !ok {
+		return fmt.Errorf("-max-open-files: open files can't be counted on this platform")
+	}
+	t.resources = &resourceGuard{limit: limit}
+	return nil
+}
+
+// checkResources records the open files before a request and refuses it at the limit. Idle
+// keep-alive connections are closed first, since they are the descriptors the tester can give back.
+func (t *APITester) checkResources() error {
+	g := t.resources
+	if g == nil {
+		return nil
+	}
+	files, sockets, _ := openFiles()
+	if files >= g.limit {
+		t.HTTPClient.CloseIdleConnections()
+		files, sockets, _ = openFiles()
+	}
+	g.peakFiles = max(g.peakFiles, files)
+	g.peakSockets = max(g.peakSockets, sockets)
+	if files >= g.limit {
+		g.refused++
+		return fmt.Errorf("%w: %d open files (%d sockets), at -max-open-files %d", errClientSaturated, files, sockets, g.limit)
+	}
+	return nil
+}
+
+// isClientSaturation reports whether a request failed on the tester's own resources rather than the API
+func isClientSaturation(err error) bool {
+	return errors.Is(err, errClientSaturated) || errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE) || errors.Is(err, syscall.EADDRNOTAVAIL)
+}
+
+// openFiles counts the open file descriptors of the process and how many of them are sockets;
+// ok is false where the platform doesn't list them
+func openFiles() (files, sockets int, ok bool) {
+	dir := "/proc/self/fd"
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		dir = "/dev/fd"
+		if entries, err = os.ReadDir(dir); err != nil {
+			return 0, 0, false
+		}
+	}
+	for _, entry := range entries {
+		if target, err := os.Readlink(filepath.Join(dir, entry.Name())); err == nil && strings.HasPrefix(target, "socket:") {
+			sockets++
+		}
+	}
+	// Listing the directory holds a descriptor of its own
+	return len(entries) - 1, sockets, true
+}
+
+// resourceUsage summarizes the run's file use and client-side failures, or nil when there is
+// nothing to report
+func (t *APITester) resourceUsage() *ResourceUsage {
+	usage := &ResourceUsage{}
+	if g := t.resources; g != nil {
+		usage.MaxOpenFiles = g.limit
+		usage.PeakOpenFiles = g.peakFiles
+		usage.PeakSockets = g.peakSockets
+		usage.Refused = g.refused
+	}
+	usage.ClientErrors = countCategories(t.Results)[CategoryClient]
+	if usage.MaxOpenFiles == 0 && usage.ClientErrors == 0 {
+		return nil
+	}
+	return usage
+}
+
+// printResourceUsage prints the summary line of the tester's own resource use
+func (t *APITester) printResourceUsage() {
+	usage := t.resourceUsage()
+	if usage == nil {
+		return
+	}
+	if usage.ClientErrors == 0 {
+		fmt.Fprintf(t.out, "  Open Files: peaked at %d of %d (%d sockets)\n", usage.PeakOpenFiles, usage.MaxOpenFiles, usage.PeakSockets)
+		return
+	}
+	fmt.Fprintf(t.out, "  %sClient Saturation: %d tests failed on the tester's own resources, not the API's",
+		ColorYellow, usage.ClientErrors)
+	if usage.MaxOpenFiles > 0 {
+		fmt.Fprintf(t.out, "; open files peaked at %d of %d, %d requests refused", usage.PeakOpenFiles, usage.MaxOpenFiles, usage.Refused)
+	}
+	fmt.Fprintf(t.out, "%s\n", ColorReset)
+}
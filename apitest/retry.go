@@ -0,0 +1,220 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Retry defaults, used for the fields a retry policy leaves out
+const (
+	DefaultRetryAttempts    = 3
+	DefaultRetryBaseDelayMs = 200
+	DefaultRetryMaxDelayMs  = 30000
+	DefaultRetryMultiplier  = 2.0
+)
+
+// defaultRetryStatuses are retried when a policy doesn't list its own
+var defaultRetryStatuses = []int{
+	http.StatusTooManyRequests,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// RetryPolicy resends a request that failed to connect, timed out or got a retryable status
+type RetryPolicy struct {
+	// Attempts is the total number of tries, including the first; 1 disables retries
+	Attempts int `json:"attempts"`
+	// On lists the statuses to retry; connection and DNS failures and timeouts are always retried
+	On          []int   `json:"on"`
+	BaseDelayMs int     `json:"base_delay_ms"`
+	MaxDelayMs  int     `json:"max_delay_ms"`
+	Multiplier  float64 `json:"multiplier"`
+	// Jitter randomizes each backoff delay between half and all of it; on by default
+	Jitter *bool `json:"jitter"`
+}
+
+// Attempt records one try of a retried request
+type Attempt struct {
+	Attempt        int     `json:"attempt"`
+	StatusCode     int     `json:"status_code,omitempty"`
+	ResponseTimeMs float64 `json:"response_time_ms"`
+	Error          string  `json:"error,omitempty"`
+	RetryAfter     string  `json:"retry_after,omitempty"`
+	// WaitMs is how long the next attempt waited after this one
+	WaitMs float64 `json:"wait_ms,omitempty"`
+}
+
+// retryFor returns the retry policy of a test: its own, or the suite's. A policy with a
+// single attempt disables retries for the test.
+func (t *APITester) retryFor(testCase TestCase) *RetryPolicy {
+	retry := t.Retry
+	if testCase.Retry != nil {
+		retry = testCase.Retry
+	}
+	if retry == nil || retry.attempts() <= 1 {
+		return nil
+	}
+	return retry
+}
+
+// attempts returns the total number of tries, defaulting when unset
+func (p *RetryPolicy) attempts() int {
+	if p.Attempts == 0 {
+		return DefaultRetryAttempts
+	}
+	return p.Attempts
+}
+
+// retries reports whether a response status should be retried
+func (p *RetryPolicy) retries(statusCode int) bool {
+	statuses := p.On
+	if statuses == nil {
+		statuses = defaultRetryStatuses
+	}
+	for _, status := range statuses {
+		if status == statusCode {
+			return true
+		}
+	}
+	return false
+}
+
+// backoff returns the delay before the given retry (1 for the first), growing exponentially up to
+// the cap, with jitter drawn from random
+func (p *RetryPolicy) backoff(retry int, random *rand.Rand) time.Duration {
+	base := float64(p.BaseDelayMs)
+	if p.BaseDelayMs == 0 {
+		base = DefaultRetryBaseDelayMs
+	}
+	multiplier := p.Multiplier
+	if multiplier == 0 {
+		multiplier = DefaultRetryMultiplier
+	}
+	delay := math.Min(base*math.Pow(multiplier, float64(retry-1)), float64(p.maxDelay().Milliseconds()))
+	if p.Jitter == nil || *p.Jitter {
+		delay = delay/2 + random.Float64()*delay/2
+	}
+	return time.Duration(delay * float64(time.Millisecond))
+}
+
+// maxDelay caps backoff delays and the Retry-After waits the policy accepts
+func (p *RetryPolicy) maxDelay() time.Duration {
+	if p.MaxDelayMs == 0 {
+		return DefaultRetryMaxDelayMs * time.Millisecond
+	}
+	return time.Duration(p.MaxDelayMs) * time.Millisecond
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		return time.Duration(max(seconds, 0)) * time.Second, true
+	}
+	date, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	return max(date.Sub(now), 0), true
+}
+
+// cancelOnClose releases a retried request's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the body and cancels the request context
+func (c cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// retryRequest retries a request whose first attempt already ran, recording every attempt on the result.
+// The returned response is the last one received; its body releases the attempt's context when closed.
+func (t *APITester) retryRequest(retry *RetryPolicy, testCase TestCase, result *TestResult,
+	resp *http.Response, responseTime time.Duration, trace *requestTrace, err error) (*http.Response, time.Duration, *requestTrace, error) {
+	total := retry.attempts()
+	for attempt := 1; ; attempt++ {
+		record := Attempt{Attempt: attempt, ResponseTimeMs: durationMs(responseTime)}
+		retryable := false
+		if err != nil {
+			record.Error = err.Error()
+			category := classifyRequestError(err)
+			retryable = (category == CategoryConnection || category == CategoryDNS || category == CategoryTimeout) && t.Context.Err() == nil
+		} else {
+			record.StatusCode = resp.StatusCode
+			record.RetryAfter = resp.Header.Get("Retry-After")
+			retryable = retry.retries(resp.StatusCode)
+		}
+		if !retryable || attempt == total {
+			result.Attempts = append(result.Attempts, record)
+			return resp, responseTime, trace, err
+		}
+
+		// The server's Retry-After wins over the backoff, unless it asks for a longer wait than the policy allows
+		wait := retry.backoff(attempt, t.random)
+		if delay, ok := parseRetryAfter(record.RetryAfter, time.Now()); ok {
+			if delay > retry.maxDelay() {
+				fmt.Fprintf(t.out, "  %s⚠ Retry-After %s exceeds max_delay_ms, not retrying%s\n", ColorYellow, delay, ColorReset)
+				result.Attempts = append(result.Attempts, record)
+				return resp, responseTime, trace, err
+			}
+			wait = delay
+		}
+		record.WaitMs = float64(wait.Milliseconds())
+		result.Attempts = append(result.Attempts, record)
+
+		outcome := fmt.Sprintf("HTTP %d", record.StatusCode)
+		if err != nil {
+			outcome = classifyRequestError(err)
+		} else {
+			// Drain the discarded response so its connection can be reused
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		fmt.Fprintf(t.out, "  %s↻ Retry %d/%d in %dms (%s)%s\n", ColorYellow, attempt, total-1, wait.Milliseconds(), outcome, ColorReset)
+
+		select {
+		case <-time.After(wait):
+		case <-t.Context.Done():
+			return nil, responseTime, trace, t.Context.Err()
+		}
+
+		resp, responseTime, trace, err = t.sendAttempt(testCase, result.Method, result.URL)
+	}
+}
+
+// sendAttempt builds and sends a fresh copy of a test's request, so bodies and signatures are regenerated
+func (t *APITester) sendAttempt(testCase TestCase, method, url string) (*http.Response, time.Duration, *requestTrace, error) {
+	ctx, cancel := t.requestContext(testCase)
+	bodyReader, err := t.prepareRequestBody(testCase, method)
+	if err != nil {
+		cancel()
+		return nil, 0, &requestTrace{}, err
+	}
+	req, err := t.createHTTPRequest(ctx, method, url, bodyReader, testCase)
+	if err != nil {
+		cancel()
+		return nil, 0, &requestTrace{}, err
+	}
+	resp, responseTime, trace, err := t.executeRequest(req)
+	if err != nil {
+		cancel()
+		return nil, responseTime, trace, err
+	}
+	resp.Body = cancelOnClose{resp.Body, cancel}
+	return resp, responseTime, trace, nil
+}
@@ -0,0 +1,169 @@
+package apitest
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ConfigSchemaID identifies the config JSON Schema
+const ConfigSchemaID = "https://github.com/pawatthir/auto-test-api/config.schema.json"
+
+// jsonSchema is the subset of JSON Schema (draft 2020-12) the config format needs
+type jsonSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	ID                   string                 `json:"$id,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Ref                  string                 `json:"$ref,omitempty"`
+	Type                 interface{}            `json:"type,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Properties           map[string]*jsonSchema `json:"properties,omitempty"`
+	AdditionalProperties interface{}            `json:"additionalProperties,omitempty"`
+	Items                *jsonSchema            `json:"items,omitempty"`
+	OneOf                []*jsonSchema          `json:"oneOf,omitempty"`
+	Defs                 map[string]*jsonSchema `json:"$defs,omitempty"`
+}
+
+// schemaOverrides describes types whose JSON form differs from their Go type
+var schemaOverrides = map[reflect.Type]func() *jsonSchema{
+	reflect.TypeOf(HeaderValues{}): func() *jsonSchema {
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "array", Items: &jsonSchema{Type: "string"}},
+		}}
+	},
+	reflect.TypeOf(ExtractRule{}): func() *jsonSchema {
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "object", AdditionalProperties: false, Properties: map[string]*jsonSchema{
+				"path":     {Type: "string"},
+				"secret":   {Type: "boolean"},
+				"required": {Type: "boolean"},
+			}},
+		}}
+	},
+	reflect.TypeOf(HeaderMatrix{}): func() *jsonSchema {
+		return &jsonSchema{OneOf: []*jsonSchema{
+			{Type: "string"},
+			{Type: "object", AdditionalProperties: &jsonSchema{Type: "array", Items: &jsonSchema{Type: "string"}}},
+		}}
+	},
+	reflect.TypeOf(LatencyLimit{}): func() *jsonSchema {
+		return &jsonSchema{OneOf: []*jsonSchema{{Type: "number"}, {Type: "string"}}}
+	},
+}
+
+// schemaBuilder collects struct definitions while walking the config types
+type schemaBuilder struct {
+	defs map[string]*jsonSchema
+}
+
+// buildConfigSchema derives the config JSON Schema from the Config type, so it can't drift from the loader
+func buildConfigSchema() *jsonSchema {
+	b := &schemaBuilder{defs: make(map[string]*jsonSchema)}
+	root := b.structSchema(reflect.TypeOf(Config{}))
+	root.Schema = "https://json-schema.org/draft/2020-12/schema"
+	root.ID = ConfigSchemaID
+	root.Title = "API tester config"
+	root.Defs = b.defs
+
+	// Unknown step types are rejected at load time, so offer the valid ones for completion
+	b.defs["TestCase"].Properties["type"].Enum = strings.Split(stepTypeNames(), ", ")
+	return root
+}
+
+// schemaFor returns the schema of a Go type, referencing named structs through $defs
+func (b *schemaBuilder) schemaFor(t reflect.Type) *jsonSchema {
+	if override, ok := schemaOverrides[t]; ok {
+		return override()
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return b.schemaFor(t.Elem())
+	case reflect.String:
+		return &jsonSchema{Type: "string"}
+	case reflect.Bool:
+		return &jsonSchema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &jsonSchema{Type: "integer"}
+	case reflect.Float32, reflect.Float64:
+		return &jsonSchema{Type: "number"}
+	case reflect.Slice, reflect.Array:
+		return &jsonSchema{Type: "array", Items: b.schemaFor(t.Elem())}
+	case reflect.Map:
+		// Maps decode from null as well, e.g. "5XX": null in expected_responses
+		return &jsonSchema{Type: []string{"object", "null"}, AdditionalProperties: b.schemaFor(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return b.structSchema(t)
+		}
+		if _, ok := b.defs[t.Name()]; !ok {
+			b.defs[t.Name()] = nil // placeholder stops recursion
+			b.defs[t.Name()] = b.structSchema(t)
+		}
+		return &jsonSchema{Ref: "#/$defs/" + t.Name()}
+	default:
+		// interface{} accepts any JSON value, including matchers
+		return &jsonSchema{}
+	}
+}
+
+// structSchema lists a struct's JSON fields; unknown keys are rejected so typos surface in the editor
+func (b *schemaBuilder) structSchema(t reflect.Type) *jsonSchema {
+	schema := &jsonSchema{
+		Type:                 "object",
+		Properties:           make(map[string]*jsonSchema),
+		AdditionalProperties: false,
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		schema.Properties[name] = b.schemaFor(field.Type)
+	}
+	return schema
+}
+
+// runSchemaCommand prints the config JSON Schema and returns the exit code
+func runSchemaCommand(args []string) int {
+	fs := flag.NewFlagSet("schema", flag.ContinueOnError)
+	output := fs.String("o", "", "Write the schema to file (default: stdout)")
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s schema [-o config.schema.json]\n\n", os.Args[0])
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 1
+	}
+
+	data, err := json.MarshalIndent(buildConfigSchema(), "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to marshal schema: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	data = append(data, '\n')
+
+	if *output == "" {
+		os.Stdout.Write(data)
+		return 0
+	}
+	if err := os.WriteFile(*output, data, DefaultFileMode); err != nil {
+		fmt.Fprintf(os.Stderr, "%sError: failed to write schema: %v%s\n", ColorRed, err, ColorReset)
+		return 1
+	}
+	fmt.Printf("%s✓ Schema written to: %s%s\n", ColorGreen, *output, ColorReset)
+	return 0
+}
@@ -0,0 +1,148 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// SecretMask replaces secret values in console output, reports and events
+const SecretMask = "********"
+
+// ExtractRule is one extract entry: a path, or an object marking the value secret or required
+type ExtractRule struct {
+	Path string `json:"path"`
+	// Secret masks the extracted value wherever it would be shown; it's still substituted as is
+	Secret bool `json:"secret,omitempty"`
+	// Required fails the test when the path is missing or null, instead of skipping the variable
+	Required bool `json:"required,omitempty"`
+}
+
+// UnmarshalJSON accepts a bare path or {"path": ..., "secret": true, "required": true}
+func (r *ExtractRule) UnmarshalJSON(data []byte) error {
+	var path string
+	if err := json.Unmarshal(data, &path); err == nil {
+		*r = ExtractRule{Path: path}
+		return nil
+	}
+	type rule ExtractRule
+	var parsed rule
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return fmt.Errorf("extract must be a path or {\"path\": ..., \"secret\": true, \"required\": true}")
+	}
+	if parsed.Path == "" {
+		return fmt.Errorf("extract entry is missing its path")
+	}
+	*r = ExtractRule(parsed)
+	return nil
+}
+
+// MarshalJSON writes plain rules back as bare paths
+func (r ExtractRule) MarshalJSON() ([]byte, error) {
+	if !r.Secret && !r.Required {
+		return json.Marshal(r.Path)
+	}
+	type rule ExtractRule
+	return json.Marshal(rule(r))
+}
+
+// addSecret records a secret variable's value for masking. Only strings are masked; masking a
+// number or boolean would blank out unrelated values that happen to be equal.
+func (t *APITester) addSecret(value interface{}) {
+	text, ok := value.(string)
+	if !ok || text == "" {
+		return
+	}
+	for _, secret := range t.secrets {
+		if secret == text {
+			return
+		}
+	}
+	t.secrets = append(t.secrets, text)
+	// Longer secrets first, so one containing another is masked whole
+	sort.SliceStable(t.secrets, func(i, j int) bool { return len(t.secrets[i]) > len(t.secrets[j]) })
+}
+
+// maskSecrets replaces every secret value in a text
+func (t *APITester) maskSecrets(text string) string {
+	for _, secret := range t.secrets {
+		text = strings.ReplaceAll(text, secret, SecretMask)
+	}
+	return text
+}
+
+// maskStrings masks secrets in each of a list of texts
+func (t *APITester) maskStrings(texts []string) []string {
+	if texts == nil {
+		return nil
+	}
+	masked := make([]string, len(texts))
+	for i, text := range texts {
+		masked[i] = t.maskSecrets(text)
+	}
+	return masked
+}
+
+// maskValue masks secrets in the strings of a decoded JSON value, copying rather than modifying it
+func (t *APITester) maskValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		return t.maskSecrets(v)
+	case map[string]interface{}:
+		masked := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			masked[key] = t.maskValue(item)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, item := range v {
+			masked[i] = t.maskValue(item)
+		}
+		return masked
+	}
+	return value
+}
+
+// maskResult returns a copy of a result with secret values masked, for display and reports
+func (t *APITester) maskResult(result TestResult) TestResult {
+	if len(t.secrets) == 0 {
+		return result
+	}
+	result.TestCaseName = t.maskSecrets(result.TestCaseName)
+	result.URL = t.maskSecrets(result.URL)
+	result.Errors = t.maskStrings(result.Errors)
+	result.Warnings = t.maskStrings(result.Warnings)
+	result.FlakyErrors = t.maskStrings(result.FlakyErrors)
+	result.Logs = t.maskStrings(result.Logs)
+	result.ResponseBody = t.maskValue(result.ResponseBody)
+	if result.Attempts != nil {
+		attempts := make([]Attempt, len(result.Attempts))
+		for i, attempt := range result.Attempts {
+			attempt.Error = t.maskSecrets(attempt.Error)
+			attempts[i] = attempt
+		}
+		result.Attempts = attempts
+	}
+	if result.SideEffects != nil {
+		sideEffects := make([]TestResult, len(result.SideEffects))
+		for i, sideEffect := range result.SideEffects {
+			sideEffects[i] = t.maskResult(sideEffect)
+		}
+		result.SideEffects = sideEffects
+	}
+	return result
+}
+
+// maskResults masks secret values in each result
+func (t *APITester) maskResults(results []TestResult) []TestResult {
+	if len(t.secrets) == 0 {
+		return results
+	}
+	masked := make([]TestResult, len(results))
+	for i, result := range results {
+		masked[i] = t.maskResult(result)
+	}
+	return masked
+}
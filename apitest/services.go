@@ -0,0 +1,114 @@
+package apitest
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// serviceFlags collects repeated -service name=url flags
+type serviceFlags map[string]string
+
+// String renders the services as sorted name=url pairs
+func (s serviceFlags) String() string {
+	names := make([]string, 0, len(s))
+	for name := range s {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = name + "=" + s[name]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// Set parses one name=url service
+func (s serviceFlags) Set(value string) error {
+	name, base, ok := strings.Cut(value, "=")
+	if !ok || name == "" {
+		return fmt.Errorf("service %q must be name=url", value)
+	}
+	if err := checkServiceURL(base); err != nil {
+		return fmt.Errorf("service %q: %w", name, err)
+	}
+	s[name] = strings.TrimRight(base, "/")
+	return nil
+}
+
+// checkServiceURL rejects a base URL that isn't http, https or unix. URLs with {{...}}
+// placeholders are left to checkResolvedBaseURL, once an HTTP test resolves them.
+func checkServiceURL(base string) error {
+	if strings.Contains(base, "{{") {
+		return nil
+	}
+	parsed, err := url.Parse(base)
+	if err != nil {
+		return fmt.Errorf("invalid base url: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" && !strings.HasPrefix(base, UnixScheme) {
+		return fmt.Errorf("base url %q must start with http://, https:// or %s", base, UnixScheme)
+	}
+	return nil
+}
+
+// loadServices combines the config's services with those set with -service, which win. The
+// result is a new map, so the -service flags are left as they were for a later run.
+func (t *APITester) loadServices(services map[string]string) error {
+	combined := make(map[string]string, len(services)+len(t.Services))
+	for name, base := range services {
+		if err := checkServiceURL(base); err != nil {
+			return fmt.Errorf("service %q: %w", name, err)
+		}
+		combined[name] = strings.TrimRight(base, "/")
+	}
+	for name, base := range t.Services {
+		combined[name] = base
+	}
+	t.Services = combined
+	return nil
+}
+
+// checkService rejects a test naming both a base URL and a service, or an unknown service
+func (t *APITester) checkService(testCase TestCase) error {
+	if testCase.BaseURL != "" && testCase.Service != "" {
+		return fmt.Errorf("set base_url or service, not both")
+	}
+	if testCase.BaseURL != "" {
+		return checkServiceURL(testCase.BaseURL)
+	}
+	if _, ok := t.Services[testCase.Service]; testCase.Service != "" && !ok {
+		return fmt.Errorf("unknown service %q (available: %s)", testCase.Service, strings.Join(profileNames(t.Services), ", "))
+	}
+	return nil
+}
+
+// checkResolvedBaseURL checks the URL of an HTTP test whose base_url or service URL has {{...}}
+// placeholders, which loading the config couldn't. resolved is the test's URL, which starts with
+// the resolved base unless the api is absolute.
+func (t *APITester) checkResolvedBaseURL(testCase TestCase, resolved string) error {
+	base := testCase.BaseURL
+	if testCase.Service != "" {
+		base = t.Services[testCase.Service]
+	}
+	if !strings.Contains(base, "{{") {
+		return nil
+	}
+	if err := checkServiceURL(resolved); err != nil {
+		return fmt.Errorf("%s resolved to an invalid URL: %w", base, err)
+	}
+	return nil
+}
+
+// baseURLFor returns the base URL a test's relative api is joined to: its own base_url, its
+// service's, or the run's
+func (t *APITester) baseURLFor(testCase TestCase) string {
+	switch {
+	case testCase.BaseURL != "":
+		return strings.TrimRight(t.replaceVariables(testCase.BaseURL), "/")
+	case testCase.Service != "":
+		return strings.TrimRight(t.replaceVariables(t.Services[testCase.Service]), "/")
+	}
+	return t.BaseURL
+}
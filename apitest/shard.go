@@ -0,0 +1,82 @@
+package apitest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseShard parses a shard spec such as "2/5" into a 1-based index and a count
+func parseShard(spec string) (index, count int, err error) {
+	parts := strings.Split(spec, "/")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid shard %q: expected <index>/<count>", spec)
+	}
+
+	index, err = strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard index %q", parts[0])
+	}
+	count, err = strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid shard count %q", parts[1])
+	}
+	if count < 1 || index < 1 || index > count {
+		return 0, 0, fmt.Errorf("invalid shard %q: index must be between 1 and count", spec)
+	}
+	return index, count, nil
+}
+
+// assignShards distributes dependency chains across shards, balancing the number of tests.
+// The assignment only depends on the chains, so every worker computes the same partition.
+func assignShards(chains [][]int, count int) []int {
+	bySize := make([]int, len(chains))
+	for i := range bySize {
+		bySize[i] = i
+	}
+	sort.SliceStable(bySize, func(i, j int) bool {
+		return len(chains[bySize[i]]) > len(chains[bySize[j]])
+	})
+
+	loads := make([]int, count)
+	assignment := make([]int, len(chains))
+	for _, chain := range bySize {
+		target := 0
+		for shard := 1; shard < count; shard++ {
+			if loads[shard] < loads[target] {
+				target = shard
+			}
+		}
+		assignment[chain] = target
+		loads[target] += len(chains[chain])
+	}
+	return assignment
+}
+
+// ApplyShard keeps only the test cases belonging to the given 1-based shard
+func (t *APITester) ApplyShard(index, count int) {
+	chains := dependencyChains(t.TestCases)
+	assignment := assignShards(chains, count)
+
+	keep := make([]bool, len(t.TestCases))
+	for chain, shard := range assignment {
+		if shard == index-1 {
+			for _, i := range chains[chain] {
+				keep[i] = true
+			}
+		}
+	}
+
+	var selected []TestCase
+	for i, testCase := range t.TestCases {
+		if keep[i] {
+			selected = append(selected, testCase)
+		}
+	}
+
+	fmt.Fprintf(t.out, "%s✓ Shard %d/%d: running %d of %d test cases%s\n",
+		ColorGreen, index, count, len(selected), len(t.TestCases), ColorReset)
+	t.TestCases = selected
+	t.Shard = fmt.Sprintf("%d/%d", index, count)
+}
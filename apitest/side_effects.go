@@ -0,0 +1,140 @@
+package apitest
+
+import "fmt"
+
+// SideEffect runs a reusable verification after a test's request, e.g. checking that an order
+// reached the database or that a message was queued
+type SideEffect struct {
+	// Verify names a template under the config's "verifications"
+	Verify string `json:"verify"`
+	// Name labels the check in output and reports; it defaults to the template name
+	Name string `json:"name"`
+	// With binds template variables for this check, e.g. {"order_id": "{{order_id}}"}
+	With map[string]interface{} `json:"with"`
+}
+
+// checkSideEffects validates the verification templates and the side effects referencing them
+func (t *APITester) checkSideEffects(testCase TestCase) error {
+	for i, effect := range testCase.SideEffects {
+		if _, ok := t.Verifications[effect.Verify]; !ok {
+			return fmt.Errorf("side_effects[%d]: unknown verification %q", i, effect.Verify)
+		}
+	}
+	return nil
+}
+
+// loadVerifications checks the verification templates of the config
+func (t *APITester) loadVerifications(verifications map[string]TestCase) error {
+	for name, template := range verifications {
+		if len(template.SideEffects) > 0 {
+			return fmt.Errorf("verification %q: templates can't have side_effects", name)
+		}
+		if _, ok := stepTypes[template.Type]; !ok && template.Type != "" && template.Type != "http" {
+			return fmt.Errorf("verification %q: unknown type %q (available: %s)", name, template.Type, stepTypeNames())
+		}
+		if template.ExpectedResponseFile != "" {
+			expected, err := t.loadExpectedResponseFile(template)
+			if err != nil {
+				return fmt.Errorf("verification %q: %w", name, err)
+			}
+			template.ExpectedResponse = expected
+			verifications[name] = template
+		}
+	}
+	t.Verifications = verifications
+	return nil
+}
+
+// verifySideEffects runs a test's side effect checks, recording each as a nested result;
+// a failed check fails the test
+func (t *APITester) verifySideEffects(testCase TestCase, result *TestResult) {
+	for _, effect := range testCase.SideEffects {
+		if t.Context.Err() != nil {
+			return
+		}
+		label := effect.Name
+		if label == "" {
+			label = effect.Verify
+		}
+		check := t.runSideEffect(testCase, effect, label)
+		result.SideEffects = append(result.SideEffects, check)
+		for _, err := range check.Errors {
+			result.addError(CategorySideEffect, sideEffectError(check.TestCaseName, err))
+		}
+	}
+}
+
+// sideEffectError is how a failed check's error appears among its test's errors
+func sideEffectError(label, err string) string {
+	return fmt.Sprintf("Side effect %s: %s", label, err)
+}
+
+// ownErrors returns a result's errors without the ones copied from its side effect checks,
+// for reports that show each check as a step of its own
+func ownErrors(result TestResult) []string {
+	if len(result.SideEffects) == 0 {
+		return result.Errors
+	}
+	copied := make(map[string]bool)
+	for _, check := range result.SideEffects {
+		for _, err := range check.Errors {
+			copied[sideEffectError(check.TestCaseName, err)] = true
+		}
+	}
+	var own []string
+	for _, err := range result.Errors {
+		if !copied[err] {
+			own = append(own, err)
+		}
+	}
+	return own
+}
+
+// failedSteps names the side effect checks of a result that failed
+func failedSteps(result TestResult) []string {
+	var names []string
+	for _, check := range result.SideEffects {
+		if isFailed(check.Status) {
+			names = append(names, check.TestCaseName)
+		}
+	}
+	return names
+}
+
+// runSideEffect runs one verification template with its variables bound, restoring them afterwards
+func (t *APITester) runSideEffect(testCase TestCase, effect SideEffect, label string) TestResult {
+	type binding struct {
+		value  interface{}
+		exists bool
+	}
+	previous := make(map[string]binding, len(effect.With))
+	bound := make(map[string]interface{}, len(effect.With))
+	for name, value := range effect.With {
+		bound[name] = t.replaceInInterface(value)
+	}
+	for name, value := range bound {
+		old, exists := t.Variables[name]
+		previous[name] = binding{old, exists}
+		t.Variables[name] = value
+	}
+	defer func() {
+		for name, old := range previous {
+			if old.exists {
+				t.Variables[name] = old.value
+			} else {
+				delete(t.Variables, name)
+			}
+		}
+	}()
+
+	check := t.Verifications[effect.Verify]
+	check.TestCaseName = label
+	check.Order = testCase.Order
+	check.ID = ""
+	// Templates are generic, so their expectations take the bound values too
+	if check.ExpectedResponse != nil {
+		check.ExpectedResponse = t.replaceInInterface(check.ExpectedResponse).(map[string]interface{})
+	}
+	fmt.Fprintf(t.out, "  %s↳ Verifying side effect: %s%s\n", ColorCyan, label, ColorReset)
+	return t.RunTest(check)
+}
@@ -0,0 +1,40 @@
+package apitest
+
+import (
+	"fmt"
+)
+
+// checkSizeLimits rejects negative size limits
+func checkSizeLimits(testCase TestCase) error {
+	if testCase.MaxRequestBytes < 0 || testCase.MaxResponseBytes < 0 {
+		return fmt.Errorf("max_request_bytes and max_response_bytes must not be negative")
+	}
+	return nil
+}
+
+// transferredBytes is the size of a response body as it came over the wire
+func transferredBytes(result *TestResult) int {
+	if result.CompressedBytes > 0 {
+		return result.CompressedBytes
+	}
+	return result.ResponseBytes
+}
+
+// validateSizes checks the request and response bodies against the test's byte budgets
+func validateSizes(testCase TestCase, result *TestResult) []assertionError {
+	var errors []assertionError
+	if testCase.MaxRequestBytes > 0 && result.RequestBytes > testCase.MaxRequestBytes {
+		errors = append(errors, assertionError{CategorySize,
+			fmt.Sprintf("Request size: Expected at most %d bytes, got %d", testCase.MaxRequestBytes, result.RequestBytes)})
+	}
+	if testCase.MaxResponseBytes > 0 {
+		if size := transferredBytes(result); size > testCase.MaxResponseBytes {
+			message := fmt.Sprintf("Response size: Expected at most %d bytes, got %d", testCase.MaxResponseBytes, size)
+			if result.CompressedBytes > 0 {
+				message += fmt.Sprintf(" (%s, %d decoded)", result.ContentEncoding, result.ResponseBytes)
+			}
+			errors = append(errors, assertionError{CategorySize, message})
+		}
+	}
+	return errors
+}
@@ -0,0 +1,51 @@
+package apitest
+
+import (
+	"fmt"
+)
+
+// Test priorities; tests without one are PriorityNormal
+const (
+	PriorityCritical = "critical"
+	PriorityHigh     = "high"
+	PriorityNormal   = "normal"
+	PriorityLow      = "low"
+)
+
+// checkPriority rejects priorities other than the known levels
+func checkPriority(priority string) error {
+	switch priority {
+	case "", PriorityCritical, PriorityHigh, PriorityNormal, PriorityLow:
+		return nil
+	}
+	return fmt.Errorf("priority must be %s, %s, %s or %s, got %q",
+		PriorityCritical, PriorityHigh, PriorityNormal, PriorityLow, priority)
+}
+
+// ApplySmoke keeps only the critical tests plus the earlier tests producing the variables they
+// use, so a smoke run needs no config of its own
+func (t *APITester) ApplySmoke() {
+	keep := make([]bool, len(t.TestCases))
+	critical := 0
+	for i, testCase := range t.TestCases {
+		if testCase.Priority == PriorityCritical {
+			keep[i] = true
+			critical++
+		}
+	}
+	includeDependencies(t.TestCases, keep)
+
+	var selected []TestCase
+	for i, testCase := range t.TestCases {
+		if keep[i] {
+			selected = append(selected, testCase)
+		}
+	}
+
+	if critical == 0 {
+		fmt.Fprintf(t.out, "%s⚠ -smoke: no test has \"priority\": %q%s\n", ColorYellow, PriorityCritical, ColorReset)
+	}
+	fmt.Fprintf(t.out, "%s✓ Smoke: running %d critical tests and %d dependencies of %d test cases%s\n",
+		ColorGreen, critical, len(selected)-critical, len(t.TestCases), ColorReset)
+	t.TestCases = selected
+}
@@ -0,0 +1,234 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultSnapshotDir is where snapshots are stored unless -snapshot-dir is given
+const DefaultSnapshotDir = "snapshots"
+
+// maxSnapshotDiffs caps how many differences are reported for one snapshot mismatch
+const maxSnapshotDiffs = 10
+
+// slugInvalidChars matches characters replaced when turning names into file names
+var slugInvalidChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// slugify turns a test name into a file-name-safe identifier
+func slugify(name string) string {
+	return strings.Trim(slugInvalidChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// snapshotPath returns the snapshot file of a test case, named after its id when it has one
+// so renaming or reordering the test keeps its snapshot
+func (t *APITester) snapshotPath(testCase TestCase) string {
+	if testCase.ID != "" {
+		return filepath.Join(t.SnapshotDir, slugify(testCase.ID)+".json")
+	}
+	return filepath.Join(t.SnapshotDir, fmt.Sprintf("%d_%s.json", testCase.Order, slugify(testCase.TestCaseName)))
+}
+
+// canonicalize normalizes a JSON value so semantically identical payloads compare equal:
+// numbers are reduced to one representation and arrays listed in sortKeys are sorted by that key.
+// Object keys need no work since they are always written in sorted order.
+func canonicalize(value interface{}, path string, sortKeys map[string]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, val := range v {
+			result[key] = canonicalize(val, joinPath(path, key), sortKeys)
+		}
+		return result
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, val := range v {
+			result[i] = canonicalize(val, path, sortKeys)
+		}
+		if key, ok := sortKeys[path]; ok {
+			sort.SliceStable(result, func(i, j int) bool {
+				return lessCanonical(sortValue(result[i], key), sortValue(result[j], key))
+			})
+		}
+		return result
+
+	case float64:
+		return canonicalNumber(strconv.FormatFloat(v, 'g', -1, 64))
+
+	case json.Number:
+		return canonicalNumber(v.String())
+
+	default:
+		return value
+	}
+}
+
+// canonicalNumber parses a number literal into its shortest float representation,
+// writing integral values below 1e21 without an exponent. Integer literals are kept exact.
+func canonicalNumber(literal string) interface{} {
+	if integer, ok := new(big.Int).SetString(literal, 10); ok {
+		return json.Number(integer.String())
+	}
+	f, err := strconv.ParseFloat(literal, 64)
+	if err != nil {
+		return json.Number(literal)
+	}
+	if f == math.Trunc(f) && math.Abs(f) < 1e21 {
+		return json.Number(strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return json.Number(strconv.FormatFloat(f, 'g', -1, 64))
+}
+
+// joinPath appends a key to a dot path
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+// sortValue returns the value an array element is sorted by; an empty key sorts by the element itself
+func sortValue(element interface{}, key string) interface{} {
+	if key == "" || key == "." {
+		return element
+	}
+	return getNestedValue(element, key)
+}
+
+// lessCanonical orders two canonical values, numerically when both are numbers
+func lessCanonical(a, b interface{}) bool {
+	an, aok := a.(json.Number)
+	bn, bok := b.(json.Number)
+	if aok && bok {
+		af, _ := an.Float64()
+		bf, _ := bn.Float64()
+		return af < bf
+	}
+	return fmt.Sprintf("%v", a) < fmt.Sprintf("%v", b)
+}
+
+// marshalCanonical renders a canonical value as indented JSON
+func marshalCanonical(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// diffCanonical lists the paths where two canonical values differ
+func diffCanonical(expected, actual interface{}, path string, diffs *[]string) {
+	if len(*diffs) >= maxSnapshotDiffs {
+		return
+	}
+	label := path
+	if label == "" {
+		label = "(root)"
+	}
+
+	switch exp := expected.(type) {
+	case map[string]interface{}:
+		act, ok := actual.(map[string]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: Expected object, got %s", label, formatJSONContext(actual, 0)))
+			return
+		}
+		keys := make([]string, 0, len(exp)+len(act))
+		for key := range exp {
+			keys = append(keys, key)
+		}
+		for key := range act {
+			if _, ok := exp[key]; !ok {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			expVal, inExp := exp[key]
+			actVal, inAct := act[key]
+			switch {
+			case !inAct:
+				*diffs = append(*diffs, fmt.Sprintf("%s: Removed (was %s)", joinPath(path, key), formatJSONContext(expVal, 0)))
+			case !inExp:
+				*diffs = append(*diffs, fmt.Sprintf("%s: Added %s", joinPath(path, key), formatJSONContext(actVal, 0)))
+			default:
+				diffCanonical(expVal, actVal, joinPath(path, key), diffs)
+			}
+		}
+
+	case []interface{}:
+		act, ok := actual.([]interface{})
+		if !ok {
+			*diffs = append(*diffs, fmt.Sprintf("%s: Expected array, got %s", label, formatJSONContext(actual, 0)))
+			return
+		}
+		if len(exp) != len(act) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: Expected %d items, got %d", label, len(exp), len(act)))
+		}
+		for i := 0; i < len(exp) && i < len(act); i++ {
+			diffCanonical(exp[i], act[i], fmt.Sprintf("%s[%d]", path, i), diffs)
+		}
+
+	default:
+		expJSON, _ := json.Marshal(expected)
+		actJSON, _ := json.Marshal(actual)
+		if !bytes.Equal(expJSON, actJSON) {
+			*diffs = append(*diffs, fmt.Sprintf("%s: Expected %s, got %s", label, expJSON, actJSON))
+		}
+	}
+}
+
+// compareSnapshot checks the response against the stored snapshot, creating it when missing.
+// Secret values are masked on both sides, so they're never written to a snapshot or a diff.
+func (t *APITester) compareSnapshot(testCase TestCase, responseData interface{}) []assertionError {
+	path := t.snapshotPath(testCase)
+	actual := canonicalize(t.maskValue(responseData), "", testCase.SnapshotSort)
+	actualJSON, err := marshalCanonical(actual)
+	if err != nil {
+		return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot: failed to encode response: %v", err)}}
+	}
+
+	stored, err := os.ReadFile(path)
+	if os.IsNotExist(err) || t.UpdateSnapshots {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot: %v", err)}}
+		}
+		if err := os.WriteFile(path, actualJSON, DefaultFileMode); err != nil {
+			return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot: %v", err)}}
+		}
+		fmt.Fprintf(t.out, "  %s↳ Snapshot written: %s%s\n", ColorCyan, path, ColorReset)
+		return nil
+	}
+	if err != nil {
+		return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot: %v", err)}}
+	}
+
+	var expected interface{}
+	decoder := json.NewDecoder(bytes.NewReader(stored))
+	decoder.UseNumber()
+	if err := decoder.Decode(&expected); err != nil {
+		return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot: invalid snapshot %s: %v", path, err)}}
+	}
+	// Re-canonicalize so snapshots written before a sort key was added still compare cleanly
+	expected = canonicalize(t.maskValue(expected), "", testCase.SnapshotSort)
+
+	var diffs []string
+	diffCanonical(expected, actual, "", &diffs)
+	if len(diffs) == 0 {
+		return nil
+	}
+	return []assertionError{{CategorySnapshot, fmt.Sprintf("Snapshot mismatch (%s):\n%s",
+		path, strings.Join(diffs, "\n"))}}
+}
@@ -0,0 +1,46 @@
+package apitest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSnapshotMasksSecrets(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"token": "s3cr3t-token", "session": {"owner": "ada", "token": "s3cr3t-token"}}`))
+	}))
+	defer server.Close()
+
+	tester := newTestTester(t, server.URL, `{"test_case": [
+		{"test_case_name": "Login", "order": 1, "method": "POST", "api": "/login",
+			"extract": {"token": {"path": "token", "secret": true}}},
+		{"test_case_name": "Session", "id": "session", "order": 2, "method": "GET", "api": "/session", "snapshot": true}
+	]}`)
+	tester.SnapshotDir = t.TempDir()
+	tester.RunAllTests()
+	for _, result := range tester.Results {
+		if result.Status != StatusPassed {
+			t.Fatalf("%s: %s %v", result.TestCaseName, result.Status, result.Errors)
+		}
+	}
+
+	stored, err := os.ReadFile(filepath.Join(tester.SnapshotDir, "session.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(stored), "s3cr3t-token") || !strings.Contains(string(stored), SecretMask) {
+		t.Errorf("snapshot holds the secret:\n%s", stored)
+	}
+
+	// The masked snapshot still matches on the next run
+	tester.Results = nil
+	tester.RunAllTests()
+	if result := tester.Results[1]; result.Status != StatusPassed {
+		t.Errorf("second run: %s %v", result.Status, result.Errors)
+	}
+}
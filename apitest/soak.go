@@ -0,0 +1,193 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// SoakSample records the outcome of one suite iteration during a soak run
+type SoakSample struct {
+	Iteration    int     `json:"iteration"`
+	Timestamp    string  `json:"timestamp"`
+	Total        int     `json:"total"`
+	Failed       int     `json:"failed"`
+	ErrorRate    float64 `json:"error_rate"`
+	AvgLatencyMs float64 `json:"avg_latency_ms"`
+	MaxLatencyMs float64 `json:"max_latency_ms"`
+	// NewConnections counts the connections dialed during the iteration, to spot keep-alive churn
+	NewConnections    int `json:"new_connections"`
+	ReusedConnections int `json:"reused_connections"`
+	// OpenFiles is the tester's own open file count after the iteration, to spot descriptor leaks;
+	// ClientErrors the failures caused by the tester running out of files or ports
+	OpenFiles    int `json:"open_files,omitempty"`
+	ClientErrors int `json:"client_errors,omitempty"`
+	Skipped      int `json:"skipped,omitempty"`
+
+	// timedMs and timed sum the latencies behind AvgLatencyMs, so iterations can be averaged exactly
+	timedMs float64
+	timed   int
+}
+
+// SoakReport is the time series of a soak run plus drift between its start and end
+type SoakReport struct {
+	Duration          string       `json:"duration"`
+	Interval          string       `json:"interval"`
+	Iterations        int          `json:"iterations"`
+	Total             int          `json:"total"`
+	Failed            int          `json:"failed"`
+	ErrorRate         float64      `json:"error_rate"`
+	StartAvgLatencyMs float64      `json:"start_avg_latency_ms"`
+	EndAvgLatencyMs   float64      `json:"end_avg_latency_ms"`
+	LatencyDriftMs    float64      `json:"latency_drift_ms"`
+	StartErrorRate    float64      `json:"start_error_rate"`
+	EndErrorRate      float64      `json:"end_error_rate"`
+	Samples           []SoakSample `json:"samples"`
+
+	// skipped and avgLatencyMs cover every iteration, for the suite assertions
+	skipped      int
+	avgLatencyMs float64
+}
+
+// soakWindowFraction is the share of samples averaged at each end to measure drift
+const soakWindowFraction = 0.1
+
+// RunSoak repeatedly runs the suite every interval until duration has elapsed
+func (t *APITester) RunSoak(duration, interval time.Duration) {
+	t.Soak = &SoakReport{Duration: duration.String(), Interval: interval.String()}
+	deadline := time.Now().Add(duration)
+
+	for iteration := 1; ; iteration++ {
+		started := time.Now()
+		// Each iteration gives hosts that were down a fresh chance
+		if t.breaker != nil {
+			t.EnableCircuitBreaker(t.breaker.threshold)
+		}
+		t.RunAllTests()
+		if t.Context.Err() != nil {
+			break
+		}
+
+		sample := t.soakSample(iteration, started)
+		t.Soak.Samples = append(t.Soak.Samples, sample)
+		clientErrors := ""
+		if sample.ClientErrors > 0 {
+			clientErrors = fmt.Sprintf(" (%d client-side)", sample.ClientErrors)
+		}
+		fmt.Fprintf(t.out, "\n%s⟳ Soak iteration %d: %d/%d failed%s, avg %s, max %s, %d new connections, %d open files%s\n",
+			ColorCyan, iteration, sample.Failed, sample.Total, clientErrors, formatMs(sample.AvgLatencyMs), formatMs(sample.MaxLatencyMs),
+			sample.NewConnections, sample.OpenFiles, ColorReset)
+
+		next := started.Add(interval)
+		if !next.Before(deadline) {
+			break
+		}
+		select {
+		case <-time.After(time.Until(next)):
+		case <-t.Context.Done():
+		}
+		if t.Context.Err() != nil {
+			break
+		}
+	}
+
+	t.Soak.summarize()
+}
+
+// soakSample summarizes the results of the iteration that just finished
+func (t *APITester) soakSample(iteration int, started time.Time) SoakSample {
+	total, _, failed := t.calculateSummary()
+	sample := SoakSample{
+		Iteration:    iteration,
+		Timestamp:    started.Format(time.RFC3339),
+		Total:        total,
+		Failed:       failed,
+		AvgLatencyMs: t.calculateAverageResponseTime(),
+	}
+	sample.NewConnections, sample.ReusedConnections = countConnections(t.Results)
+	sample.OpenFiles, _, _ = openFiles()
+	sample.ClientErrors = countCategories(t.Results)[CategoryClient]
+	sample.Skipped = countStatus(t.Results, StatusSkipped)
+	if total > 0 {
+		sample.ErrorRate = float64(failed) / float64(total) * 100
+	}
+	for _, result := range t.Results {
+		if result.ResponseTimeMs > sample.MaxLatencyMs {
+			sample.MaxLatencyMs = result.ResponseTimeMs
+		}
+		if result.ResponseTimeMs > 0 {
+			sample.timedMs += result.ResponseTimeMs
+			sample.timed++
+		}
+	}
+	return sample
+}
+
+// summarize computes totals and compares the first and last windows of samples
+func (r *SoakReport) summarize() {
+	r.Iterations = len(r.Samples)
+	var timedMs float64
+	timed := 0
+	for _, sample := range r.Samples {
+		r.Total += sample.Total
+		r.Failed += sample.Failed
+		r.skipped += sample.Skipped
+		timedMs += sample.timedMs
+		timed += sample.timed
+	}
+	if timed > 0 {
+		r.avgLatencyMs = timedMs / float64(timed)
+	}
+	if r.Total > 0 {
+		r.ErrorRate = float64(r.Failed) / float64(r.Total) * 100
+	}
+	// Cancelling during the first iteration leaves no samples to compare
+	if len(r.Samples) == 0 {
+		return
+	}
+
+	window := int(float64(len(r.Samples)) * soakWindowFraction)
+	if window < 1 {
+		window = 1
+	}
+	r.StartAvgLatencyMs, r.StartErrorRate = averageSamples(r.Samples[:window])
+	r.EndAvgLatencyMs, r.EndErrorRate = averageSamples(r.Samples[len(r.Samples)-window:])
+	r.LatencyDriftMs = r.EndAvgLatencyMs - r.StartAvgLatencyMs
+}
+
+// averageSamples returns the mean latency and error rate of a window of samples
+func averageSamples(samples []SoakSample) (latencyMs, errorRate float64) {
+	for _, sample := range samples {
+		latencyMs += sample.AvgLatencyMs
+		errorRate += sample.ErrorRate
+	}
+	count := float64(len(samples))
+	return latencyMs / count, errorRate / count
+}
+
+// PrintSoakSummary prints the soak totals and drift
+func (t *APITester) PrintSoakSummary() {
+	r := t.Soak
+	fmt.Fprintf(t.out, "%s  Soak Summary (%s every %s)%s\n", ColorBold, r.Duration, r.Interval, ColorReset)
+	fmt.Fprintf(t.out, "  Iterations: %d\n", r.Iterations)
+	fmt.Fprintf(t.out, "  Requests:   %d (%d failed, %.1f%% error rate)\n", r.Total, r.Failed, r.ErrorRate)
+	if r.Iterations == 0 {
+		fmt.Fprintf(t.out, "  %sNo iteration finished, so there is no drift to report%s\n", ColorYellow, ColorReset)
+		fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+		return
+	}
+
+	color := ColorGreen
+	if r.LatencyDriftMs > 0 {
+		color = ColorYellow
+	}
+	fmt.Fprintf(t.out, "  %sLatency drift: %+.0fms (%.0fms → %.0fms)%s\n",
+		color, r.LatencyDriftMs, r.StartAvgLatencyMs, r.EndAvgLatencyMs, ColorReset)
+
+	color = ColorGreen
+	if r.EndErrorRate > r.StartErrorRate {
+		color = ColorRed
+	}
+	fmt.Fprintf(t.out, "  %sError rate: %.1f%% → %.1f%%%s\n", color, r.StartErrorRate, r.EndErrorRate, ColorReset)
+	fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+}
@@ -0,0 +1,138 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// sortKey is one field of a $sorted_by spec and its direction
+type sortKey struct {
+	path       string
+	descending bool
+}
+
+// parseSortKeys parses a spec such as "created_at desc" or "last_name, id desc"; later keys
+// order items whose earlier keys are equal, and the direction defaults to asc
+func parseSortKeys(spec string) ([]sortKey, error) {
+	var keys []sortKey
+	for _, part := range strings.Split(spec, ",") {
+		fields := strings.Fields(part)
+		if len(fields) == 0 || len(fields) > 2 {
+			return nil, fmt.Errorf("expected \"field\" or \"field asc|desc\", got %q", strings.TrimSpace(part))
+		}
+		key := sortKey{path: fields[0]}
+		if len(fields) == 2 {
+			switch strings.ToLower(fields[1]) {
+			case "asc":
+			case "desc":
+				key.descending = true
+			default:
+				return nil, fmt.Errorf("direction of %s must be asc or desc, got %q", fields[0], fields[1])
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// String renders a key as it is written in a spec
+func (k sortKey) String() string {
+	if k.descending {
+		return k.path + " desc"
+	}
+	return k.path + " asc"
+}
+
+// sortedByMatcher requires an array of objects to be ordered by one or more fields, e.g.
+// "$sorted_by": "created_at desc". Equal neighbours are allowed, so it doesn't need a unique key.
+func sortedByMatcher(_ *APITester, arg, actual interface{}) []assertionError {
+	spec, ok := arg.(string)
+	if !ok {
+		return []assertionError{{CategoryRequest, fmt.Sprintf("$sorted_by: Expected a string such as \"created_at desc\", got %v", arg)}}
+	}
+	keys, err := parseSortKeys(spec)
+	if err != nil {
+		return []assertionError{{CategoryRequest, fmt.Sprintf("$sorted_by: %v", err)}}
+	}
+	items, ok := actual.([]interface{})
+	if !ok {
+		return []assertionError{{CategorySchema, fmt.Sprintf("$sorted_by: Expected array, got %s", formatJSONContext(actual, 0))}}
+	}
+
+	for i := 1; i < len(items); i++ {
+		for _, key := range keys {
+			before, after := getNestedValue(items[i-1], key.path), getNestedValue(items[i], key.path)
+			if before == nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("$sorted_by: Item %d has no %s", i-1, key.path)}}
+			}
+			if after == nil {
+				return []assertionError{{CategorySchema, fmt.Sprintf("$sorted_by: Item %d has no %s", i, key.path)}}
+			}
+			order, ok := compareSortValues(before, after)
+			if !ok {
+				return []assertionError{{CategorySchema, fmt.Sprintf("$sorted_by: Can't order %s %s of item %d and %s of item %d",
+					key.path, formatJSONContext(before, 0), i-1, formatJSONContext(after, 0), i)}}
+			}
+			if key.descending {
+				order = -order
+			}
+			if order < 0 {
+				break
+			}
+			if order > 0 {
+				return []assertionError{{CategoryBody, fmt.Sprintf("$sorted_by: Expected %s, but item %d has %s %s after item %d's %s",
+					sortKeysString(keys), i, key.path, formatJSONContext(after, 0), i-1, formatJSONContext(before, 0))}}
+			}
+		}
+	}
+	return nil
+}
+
+// sortKeysString renders keys as a spec, with their directions spelled out
+func sortKeysString(keys []sortKey) string {
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key.String()
+	}
+	return strings.Join(parts, ", ")
+}
+
+// compareSortValues orders two values of a sort key: numbers by value, strings that are both
+// RFC 3339 timestamps by instant, so offsets don't matter, other strings bytewise, and false
+// before true. Values of different types can't be ordered.
+func compareSortValues(a, b interface{}) (int, bool) {
+	if x, ok := numberRat(a); ok {
+		y, ok := numberRat(b)
+		if !ok {
+			return 0, false
+		}
+		return x.Cmp(y), true
+	}
+	switch x := a.(type) {
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		if first, err := time.Parse(time.RFC3339Nano, x); err == nil {
+			if second, err := time.Parse(time.RFC3339Nano, y); err == nil {
+				return first.Compare(second), true
+			}
+		}
+		return strings.Compare(x, y), true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
@@ -0,0 +1,121 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// DefaultStepTimeout is how long polling steps wait unless the step sets a timeout
+const DefaultStepTimeout = 30 * time.Second
+
+// DefaultPollInterval is the delay between attempts of polling steps
+const DefaultPollInterval = time.Second
+
+// stepType executes a test that isn't a single HTTP request. The data it returns is checked
+// against expected_response and assert_if, and extracted from, like a response body.
+type stepType struct {
+	// target describes what the step operates on, shown in place of the request URL
+	target func(t *APITester, testCase TestCase) string
+	run    func(t *APITester, testCase TestCase) (interface{}, []assertionError)
+}
+
+// stepTypes lists the supported values of a test's "type" field besides "http"
+var stepTypes = map[string]stepType{
+	"file":      {target: fileStepTarget, run: runFileStep},
+	"redis":     {target: cacheStepTarget, run: runCacheStep},
+	"memcached": {target: cacheStepTarget, run: runCacheStep},
+	"email":     {target: emailStepTarget, run: runEmailStep},
+	"otp":       {target: otpStepTarget, run: runOTPStep},
+	"callback":  {target: callbackStepTarget, run: runCallbackStep},
+	"command":   {target: commandStepTarget, run: runCommandStep},
+	"jsonrpc":   {target: jsonrpcStepTarget, run: runJSONRPCStep},
+	"connect":   {target: connectStepTarget, run: runConnectStep},
+	"compute":   {target: computeStepTarget, run: runComputeStep},
+}
+
+// stepTypeNames returns the sorted step type names, for error messages
+func stepTypeNames() string {
+	names := []string{"http"}
+	for name := range stepTypes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// runStep executes a non-HTTP step and validates its data
+func (t *APITester) runStep(step stepType, testCase TestCase, result *TestResult) {
+	startTime := time.Now()
+	data, errors := step.run(t, testCase)
+	elapsed := time.Since(startTime)
+	result.ResponseTimeMs = durationMs(elapsed)
+	result.ResponseTimeNs = elapsed.Nanoseconds()
+
+	for _, resolveErr := range t.takeResolveErrors() {
+		result.addError(CategoryRequest, resolveErr)
+	}
+	result.addAssertionErrors(errors)
+
+	if data != nil {
+		result.ResponseBody = data
+		_, extractErrs := t.extractVariables(testCase, nil, data)
+		for _, extractErr := range extractErrs {
+			result.addError(CategoryExtraction, extractErr)
+		}
+		if testCase.ExpectedResponse != nil {
+			result.addAssertionErrors(t.validate(testCase.ExpectedResponse, data, ""))
+			if testCase.StrictBody {
+				result.addAssertionErrors(t.unexpectedFields(testCase.ExpectedResponse, data, ""))
+			}
+		}
+		result.addAssertionErrors(t.validateConditional(testCase, data))
+	}
+	t.verifySideEffects(testCase, result)
+
+	if len(result.Errors) > 0 {
+		result.Status = StatusFailed
+	} else {
+		result.Status = StatusPassed
+	}
+	t.printTestResult(*result)
+}
+
+// poll calls attempt until it succeeds, the timeout passes or ctx is cancelled, returning the last error
+func poll(ctx context.Context, timeout, interval time.Duration, attempt func(ctx context.Context) error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		err := attempt(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("%w (%v)", err, ctx.Err())
+		}
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return fmt.Errorf("%w (gave up after %s)", err, timeout)
+		}
+		timer := time.NewTimer(min(interval, remaining))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("%w (%v)", err, ctx.Err())
+		}
+	}
+}
+
+// stepDurations returns a step's timeout and poll interval, applying the defaults
+func stepDurations(timeoutSeconds, intervalMs int) (time.Duration, time.Duration) {
+	timeout, interval := DefaultStepTimeout, DefaultPollInterval
+	if timeoutSeconds > 0 {
+		timeout = time.Duration(timeoutSeconds) * time.Second
+	}
+	if intervalMs > 0 {
+		interval = time.Duration(intervalMs) * time.Millisecond
+	}
+	return timeout, interval
+}
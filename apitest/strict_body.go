@@ -0,0 +1,71 @@
+package apitest
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// unexpectedFields reports what the actual body has beyond the expected one: fields the expected
+// objects don't mention and items past the end of expected arrays. Objects queried with
+// JMESPath keys or checked by matchers are skipped, since the fields those cover aren't known.
+func (t *APITester) unexpectedFields(expected, actual interface{}, path string) []assertionError {
+	var errors []assertionError
+	switch expectedValue := expected.(type) {
+	case map[string]interface{}:
+		if hasMatchers(expectedValue) {
+			return nil
+		}
+		for key := range expectedValue {
+			if strings.HasPrefix(key, JMESPathPrefix) {
+				return nil
+			}
+		}
+		if actualArray, isArray := actual.([]interface{}); isArray {
+			for key, expVal := range expectedValue {
+				if index, err := strconv.Atoi(key); err == nil && index >= 0 && index < len(actualArray) {
+					errors = append(errors, t.unexpectedFields(expVal, actualArray[index], fmt.Sprintf("%s[%s]", path, key))...)
+				}
+			}
+			return errors
+		}
+		actualMap, ok := actual.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+
+		keys := make([]string, 0, len(actualMap))
+		for key := range actualMap {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			currentPath := key
+			if path != "" {
+				currentPath = path + "." + key
+			}
+			_, expVal, mentioned := lookupKey(expectedValue, key, t.MatchKeys)
+			if !mentioned {
+				errors = append(errors, assertionError{CategorySchema,
+					fmt.Sprintf("%s: Unexpected field (strict_body), got %s", currentPath, formatJSONContext(actualMap[key], 0))})
+				continue
+			}
+			errors = append(errors, t.unexpectedFields(expVal, actualMap[key], currentPath)...)
+		}
+
+	case []interface{}:
+		actualArray, ok := actual.([]interface{})
+		if !ok {
+			return nil
+		}
+		if len(actualArray) > len(expectedValue) {
+			errors = append(errors, assertionError{CategorySchema,
+				fmt.Sprintf("%s: Expected %d items (strict_body), got %d", path, len(expectedValue), len(actualArray))})
+		}
+		for i := 0; i < len(expectedValue) && i < len(actualArray); i++ {
+			errors = append(errors, t.unexpectedFields(expectedValue[i], actualArray[i], fmt.Sprintf("%s[%d]", path, i))...)
+		}
+	}
+	return errors
+}
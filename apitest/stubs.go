@@ -0,0 +1,144 @@
+package apitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// StubAdminTimeout bounds each call to the stub server's admin API
+const StubAdminTimeout = 10 * time.Second
+
+// StubConfig declares stub mappings pushed to a WireMock-compatible admin API before the run
+// and removed after it, so tests control how the API's dependencies behave
+type StubConfig struct {
+	// AdminURL is the admin API root, e.g. http://localhost:8080/__admin
+	AdminURL string                   `json:"admin_url"`
+	Mappings []map[string]interface{} `json:"mappings"`
+	// MappingFiles hold one mapping each, or a WireMock export with a "mappings" array
+	MappingFiles []string `json:"mapping_files"`
+}
+
+// loadStubs reads the mapping files of the stub config into its mappings
+func (t *APITester) loadStubs(stubs *StubConfig) error {
+	if stubs == nil {
+		return nil
+	}
+	if stubs.AdminURL == "" {
+		return fmt.Errorf("stubs: admin_url is required")
+	}
+	for _, file := range stubs.MappingFiles {
+		path := t.configRelativePath(file)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("stubs: failed to read mapping file: %w", err)
+		}
+		var mapping map[string]interface{}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			return fmt.Errorf("stubs: failed to parse mapping file %s: %w", path, err)
+		}
+		exported, ok := mapping["mappings"].([]interface{})
+		if !ok {
+			stubs.Mappings = append(stubs.Mappings, mapping)
+			continue
+		}
+		for i, entry := range exported {
+			object, ok := entry.(map[string]interface{})
+			if !ok {
+				return fmt.Errorf("stubs: mapping %d of %s is not an object", i, path)
+			}
+			stubs.Mappings = append(stubs.Mappings, object)
+		}
+	}
+	return nil
+}
+
+// stubAdmin calls the stub server's admin API, decoding a JSON reply into out when given
+func (t *APITester) stubAdmin(method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, strings.TrimRight(t.Stubs.AdminURL, "/")+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: StubAdminTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	reply, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("admin API returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(reply)))
+	}
+	if out != nil {
+		return json.Unmarshal(reply, out)
+	}
+	return nil
+}
+
+// PushStubs registers the suite's stub mappings, tagged with the run ID so leftovers of a killed
+// run can be found. If any mapping is rejected, the ones already pushed are removed again.
+func (t *APITester) PushStubs() error {
+	if t.Stubs == nil || len(t.Stubs.Mappings) == 0 {
+		return nil
+	}
+	for i, mapping := range t.Stubs.Mappings {
+		resolved := t.replaceInInterface(mapping).(map[string]interface{})
+		if errs := t.takeResolveErrors(); len(errs) > 0 {
+			t.RemoveStubs()
+			return fmt.Errorf("stub mapping %d: %s", i+1, strings.Join(errs, "; "))
+		}
+		metadata, _ := resolved["metadata"].(map[string]interface{})
+		if metadata == nil {
+			metadata = make(map[string]interface{})
+		}
+		metadata["apitest_run_id"] = t.RunID
+		resolved["metadata"] = metadata
+
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := t.stubAdmin(http.MethodPost, "/mappings", resolved, &created); err != nil {
+			t.RemoveStubs()
+			return fmt.Errorf("failed to push stub mapping %d: %w", i+1, err)
+		}
+		t.stubIDs = append(t.stubIDs, created.ID)
+	}
+	fmt.Fprintf(t.out, "%s✓ Pushed %d stub mappings to %s%s\n", ColorGreen, len(t.stubIDs), t.Stubs.AdminURL, ColorReset)
+	return nil
+}
+
+// RemoveStubs deletes the mappings pushed by this run; failures are only warned about
+func (t *APITester) RemoveStubs() {
+	if len(t.stubIDs) == 0 {
+		return
+	}
+	removed := 0
+	for _, id := range t.stubIDs {
+		if err := t.stubAdmin(http.MethodDelete, "/mappings/"+id, nil, nil); err != nil {
+			fmt.Fprintf(t.out, "%s⚠ Failed to remove stub mapping %s: %v%s\n", ColorYellow, id, err, ColorReset)
+			continue
+		}
+		removed++
+	}
+	t.stubIDs = nil
+	fmt.Fprintf(t.out, "%s✓ Removed %d stub mappings%s\n", ColorGreen, removed, ColorReset)
+}
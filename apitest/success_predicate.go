@@ -0,0 +1,52 @@
+package apitest
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// checkSuccessWhen rejects a success_when that doesn't parse, or that competes with the statuses
+// expected_status_code and expected_responses accept
+func checkSuccessWhen(testCase TestCase) error {
+	if testCase.SuccessWhen == "" {
+		return nil
+	}
+	if testCase.ExpectedStatusCode != 0 || len(testCase.ExpectedResponses) > 0 {
+		return fmt.Errorf("success_when decides which statuses pass, so it can't be combined with expected_status_code or expected_responses")
+	}
+	_, err := compileJMESPath(testCase.SuccessWhen)
+	return err
+}
+
+// successDocument is what a success_when expression is evaluated against: the status code, the
+// decoded body and the headers by lower-case name
+func successDocument(resp *http.Response, responseData interface{}) map[string]interface{} {
+	headers := make(map[string]interface{}, len(resp.Header))
+	for name, values := range resp.Header {
+		headers[strings.ToLower(name)] = strings.Join(values, ", ")
+	}
+	return map[string]interface{}{
+		"status":  float64(resp.StatusCode),
+		"body":    responseData,
+		"headers": headers,
+	}
+}
+
+// validateSuccessWhen checks a response against the test's success_when, a JMESPath expression
+// over status, body and headers, such as "status == `200` && body.status == 'ok' || status == `202`".
+// The response passes when the result is truthy: not false, null or empty.
+func validateSuccessWhen(testCase TestCase, resp *http.Response, responseData interface{}) []assertionError {
+	if testCase.SuccessWhen == "" {
+		return nil
+	}
+	value, err := searchJMESPath(testCase.SuccessWhen, successDocument(resp, responseData))
+	if err != nil {
+		return []assertionError{{CategoryStatus, fmt.Sprintf("success_when: %v", err)}}
+	}
+	if !jpTruthy(value) {
+		return []assertionError{{CategoryStatus, fmt.Sprintf("success_when: %s is %s for HTTP %d with body %s",
+			testCase.SuccessWhen, formatJSONContext(value, 0), resp.StatusCode, formatJSONContext(responseData, 1))}}
+	}
+	return nil
+}
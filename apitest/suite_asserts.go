@@ -0,0 +1,65 @@
+package apitest
+
+import (
+	"fmt"
+	"strings"
+)
+
+// SuiteAsserts defines acceptance criteria evaluated over the whole run
+type SuiteAsserts struct {
+	MaxFailed       *int     `json:"max_failed"`
+	MaxAvgLatencyMs *float64 `json:"max_avg_latency_ms"`
+	MinPassRate     *float64 `json:"min_pass_rate"`
+}
+
+// evaluateSuiteAsserts returns a message for every suite assertion the results violate
+func (t *APITester) evaluateSuiteAsserts() []string {
+	asserts := t.SuiteAsserts
+	total, _, failed := t.calculateSummary()
+	skipped := countStatus(t.Results, StatusSkipped)
+	avg := t.calculateAverageResponseTime()
+	// t.Results only holds a soak run's last iteration, so a soak is judged on all of them
+	if t.Soak != nil && t.Soak.Iterations > 0 {
+		total, failed, skipped, avg = t.Soak.Total, t.Soak.Failed, t.Soak.skipped, t.Soak.avgLatencyMs
+	}
+	var failures []string
+
+	if asserts.MaxFailed != nil && failed > *asserts.MaxFailed {
+		failures = append(failures, fmt.Sprintf("max_failed: Expected at most %d failed, got %d",
+			*asserts.MaxFailed, failed))
+	}
+
+	if asserts.MaxAvgLatencyMs != nil {
+		if avg > *asserts.MaxAvgLatencyMs {
+			failures = append(failures, fmt.Sprintf("max_avg_latency_ms: Expected at most %gms, got %s",
+				*asserts.MaxAvgLatencyMs, formatMs(avg)))
+		}
+	}
+
+	if asserts.MinPassRate != nil {
+		passRate := calculatePassRate(total-skipped, failed)
+		if passRate < *asserts.MinPassRate {
+			failures = append(failures, fmt.Sprintf("min_pass_rate: Expected at least %.1f%%, got %.1f%%",
+				*asserts.MinPassRate, passRate))
+		}
+	}
+
+	return failures
+}
+
+// CheckSuiteAsserts evaluates and prints the suite assertions, returning whether all held
+func (t *APITester) CheckSuiteAsserts() bool {
+	t.SuiteFailures = t.evaluateSuiteAsserts()
+
+	fmt.Fprintf(t.out, "%s  Suite Assertions%s\n", ColorBold, ColorReset)
+	if len(t.SuiteFailures) == 0 {
+		fmt.Fprintf(t.out, "  %s✓ All suite assertions passed%s\n", ColorGreen, ColorReset)
+	} else {
+		for _, failure := range t.SuiteFailures {
+			fmt.Fprintf(t.out, "  %s✗ %s%s\n", ColorRed, failure, ColorReset)
+		}
+	}
+	fmt.Fprintf(t.out, "%s\n", strings.Repeat("=", SeparatorLength))
+
+	return len(t.SuiteFailures) == 0
+}
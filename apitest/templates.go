@@ -0,0 +1,107 @@
+package apitest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+)
+
+// TemplateReportFormat renders the "report" template of the -template file
+const TemplateReportFormat = "template"
+
+// Console templates that may be defined to replace the built-in output
+const (
+	TemplateRunHeader  = "run_header"
+	TemplateTestHeader = "test_header"
+	TemplatePassed     = "test_passed"
+	TemplateFailed     = "test_failed"
+	TemplateSummary    = "summary"
+	TemplateReport     = "report"
+)
+
+// SummaryView is the data model of the summary template
+type SummaryView struct {
+	TestReport
+	Total             int
+	Passed            int
+	Failed            int
+	Flaky             int
+	Skipped           int
+	PassRate          float64
+	AvgResponseTimeMs float64
+}
+
+// templateColors maps color names usable with the color function to their escape codes
+var templateColors = map[string]string{
+	"reset":  ColorReset,
+	"red":    ColorRed,
+	"green":  ColorGreen,
+	"yellow": ColorYellow,
+	"blue":   ColorBlue,
+	"cyan":   ColorCyan,
+	"bold":   ColorBold,
+}
+
+// templateFuncs are the helpers available to output templates
+var templateFuncs = template.FuncMap{
+	"color": func(name string) (string, error) {
+		code, ok := templateColors[name]
+		if !ok {
+			return "", fmt.Errorf("unknown color %q", name)
+		}
+		return code, nil
+	},
+	"join":   strings.Join,
+	"upper":  strings.ToUpper,
+	"lower":  strings.ToLower,
+	"repeat": strings.Repeat,
+	"indent": func(spaces int, s string) string {
+		return strings.ReplaceAll(s, "\n", "\n"+strings.Repeat(" ", spaces))
+	},
+	"ms": formatMs,
+	"percent": func(value float64) string {
+		return fmt.Sprintf("%.1f%%", value)
+	},
+}
+
+// LoadOutputTemplates parses a template file whose named templates replace console and report text
+func LoadOutputTemplates(path string) (*template.Template, error) {
+	data, err := os.ReadFile(expandHome(path))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template file: %w", err)
+	}
+	tmpl, err := template.New("output").Funcs(templateFuncs).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template file %s: %w", path, err)
+	}
+	return tmpl, nil
+}
+
+// renderTemplate prints the named template when it's defined, returning false so the
+// caller falls back to the built-in output when it isn't or rendering fails
+func (t *APITester) renderTemplate(name string, data interface{}) bool {
+	if t.Templates == nil || t.Templates.Lookup(name) == nil {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := t.Templates.ExecuteTemplate(&buf, name, data); err != nil {
+		fmt.Fprintf(t.out, "%s⚠ template %s: %v%s\n", ColorYellow, name, err, ColorReset)
+		return false
+	}
+	fmt.Fprint(t.out, buf.String())
+	return true
+}
+
+// formatTemplateReport renders the report through the "report" template
+func (t *APITester) formatTemplateReport(report TestReport) ([]byte, error) {
+	if t.Templates == nil || t.Templates.Lookup(TemplateReport) == nil {
+		return nil, fmt.Errorf("-format %s needs a %q template in the -template file", TemplateReportFormat, TemplateReport)
+	}
+	var buf bytes.Buffer
+	if err := t.Templates.ExecuteTemplate(&buf, TemplateReport, report); err != nil {
+		return nil, fmt.Errorf("failed to render report template: %w", err)
+	}
+	return buf.Bytes(), nil
+}
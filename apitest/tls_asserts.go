@@ -0,0 +1,107 @@
+package apitest
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// tlsVersions maps the accepted min_version spellings to protocol versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// TLSAssertion checks the TLS connection a response arrived on
+type TLSAssertion struct {
+	// MinVersion is the oldest acceptable negotiated version: 1.0, 1.1, 1.2 or 1.3
+	MinVersion string `json:"min_version"`
+	// Ciphers lists the acceptable cipher suites by IANA name; empty accepts any
+	Ciphers []string `json:"ciphers"`
+}
+
+// tlsFor returns the TLS assertion of a test: its own, or the suite's. An empty assertion
+// ("tls": {}) turns the checks off, e.g. for a plain HTTP test in a TLS suite.
+func (t *APITester) tlsFor(testCase TestCase) *TLSAssertion {
+	assertion := t.TLS
+	if testCase.TLS != nil {
+		assertion = testCase.TLS
+	}
+	if assertion == nil || (assertion.MinVersion == "" && len(assertion.Ciphers) == 0) {
+		return nil
+	}
+	return assertion
+}
+
+// check validates the version and cipher names of the assertion, so typos fail at load time
+func (a *TLSAssertion) check() error {
+	if a == nil {
+		return nil
+	}
+	if _, ok := tlsVersions[normalizeTLSVersion(a.MinVersion)]; !ok && a.MinVersion != "" {
+		return fmt.Errorf("tls.min_version %q must be one of 1.0, 1.1, 1.2, 1.3", a.MinVersion)
+	}
+	known := make(map[string]bool)
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		known[suite.Name] = true
+	}
+	for _, cipher := range a.Ciphers {
+		if !known[cipher] {
+			return fmt.Errorf("tls.ciphers: unknown cipher suite %q", cipher)
+		}
+	}
+	return nil
+}
+
+// normalizeTLSVersion accepts "1.2", "TLS1.2" and "TLS 1.2"
+func normalizeTLSVersion(version string) string {
+	version = strings.TrimSpace(strings.ToUpper(version))
+	version = strings.TrimPrefix(version, "TLS")
+	return strings.TrimSpace(strings.TrimPrefix(version, "V"))
+}
+
+// recordTLS stores the negotiated TLS version and cipher suite on the result
+func recordTLS(result *TestResult, resp *http.Response) {
+	if resp.TLS == nil {
+		return
+	}
+	result.TLSVersion = tls.VersionName(resp.TLS.Version)
+	result.TLSCipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+}
+
+// validateTLS checks the negotiated version and cipher suite against the assertion
+func validateTLS(assertion *TLSAssertion, resp *http.Response) []assertionError {
+	if assertion == nil {
+		return nil
+	}
+	if resp.TLS == nil {
+		return []assertionError{{CategoryTLS, "TLS: Expected a TLS connection, got plain HTTP"}}
+	}
+
+	var errors []assertionError
+	if assertion.MinVersion != "" {
+		minimum := tlsVersions[normalizeTLSVersion(assertion.MinVersion)]
+		if resp.TLS.Version < minimum {
+			errors = append(errors, assertionError{CategoryTLS, fmt.Sprintf("TLS version: Expected at least %s, got %s",
+				tls.VersionName(minimum), tls.VersionName(resp.TLS.Version))})
+		}
+	}
+	if len(assertion.Ciphers) > 0 {
+		cipher := tls.CipherSuiteName(resp.TLS.CipherSuite)
+		allowed := false
+		for _, name := range assertion.Ciphers {
+			allowed = allowed || name == cipher
+		}
+		if !allowed {
+			sorted := append([]string(nil), assertion.Ciphers...)
+			sort.Strings(sorted)
+			errors = append(errors, assertionError{CategoryTLS, fmt.Sprintf("TLS cipher: Expected one of %s, got %s",
+				strings.Join(sorted, ", "), cipher)})
+		}
+	}
+	return errors
+}
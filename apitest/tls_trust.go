@@ -0,0 +1,171 @@
+package apitest
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// SPKIPinPrefix starts every pin: the base64 SHA-256 of a certificate's SubjectPublicKeyInfo,
+// the form OkHttp and Android network security configs use
+const SPKIPinPrefix = "sha256/"
+
+// errHostTrust marks connections refused by a host's ca_file or pins
+var errHostTrust = errors.New("untrusted certificate")
+
+// HostTLS sets how the certificates of one host are trusted
+type HostTLS struct {
+	// CAFile is a PEM bundle trusted instead of the system roots, relative to the config file
+	CAFile string `json:"ca_file"`
+	// Pins lists accepted sha256/<base64> SPKI hashes; one of them must match a certificate in the chain
+	Pins []string `json:"pins"`
+}
+
+// hostTrust is a host's CA certificates and pins
+type hostTrust struct {
+	cas  []*x509.Certificate
+	pins []string
+}
+
+// loadHostTrust reads each host's CA bundle and checks its pins
+func (t *APITester) loadHostTrust(hosts map[string]HostTLS) (map[string]hostTrust, error) {
+	trust := make(map[string]hostTrust, len(hosts))
+	for host, config := range hosts {
+		if strings.ContainsAny(host, "/:") || net.ParseIP(host) != nil {
+			return nil, fmt.Errorf("tls_hosts: %q must be a host name, without scheme or port and not an IP address", host)
+		}
+		var entry hostTrust
+		if config.CAFile != "" {
+			cas, err := readCertificates(t.configRelativePath(config.CAFile))
+			if err != nil {
+				return nil, fmt.Errorf("tls_hosts %s: %w", host, err)
+			}
+			entry.cas = cas
+		}
+		for _, pin := range config.Pins {
+			hash, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(pin, SPKIPinPrefix))
+			if !strings.HasPrefix(pin, SPKIPinPrefix) || err != nil || len(hash) != sha256.Size {
+				return nil, fmt.Errorf("tls_hosts %s: pin %q must be %s followed by a base64 SHA-256 hash", host, pin, SPKIPinPrefix)
+			}
+			entry.pins = append(entry.pins, pin)
+		}
+		trust[strings.ToLower(host)] = entry
+	}
+	return trust, nil
+}
+
+// readCertificates reads the certificates of a PEM bundle
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ca_file: %w", err)
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse ca_file %s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("ca_file %s holds no PEM certificates", path)
+	}
+	return certs, nil
+}
+
+// spkiPin returns the pin of a certificate's public key
+func spkiPin(cert *x509.Certificate) string {
+	hash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return SPKIPinPrefix + base64.StdEncoding.EncodeToString(hash[:])
+}
+
+// EnableHostTrust adds the hosts' CAs to the trusted roots and checks each host's connections
+// against its own CAs and pins. Go verifies chains and names as usual against the combined roots,
+// since a tls.Config has a single root pool; the per-host checks then run on the verified chains.
+func (t *APITester) EnableHostTrust(hosts map[string]HostTLS) error {
+	trust, err := t.loadHostTrust(hosts)
+	if err != nil {
+		return err
+	}
+	roots, err := x509.SystemCertPool()
+	if err != nil {
+		roots = x509.NewCertPool()
+	}
+	for _, entry := range trust {
+		for _, ca := range entry.cas {
+			roots.AddCert(ca)
+		}
+	}
+
+	transport := t.baseTransport()
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{}
+	}
+	transport.TLSClientConfig.RootCAs = roots
+	transport.TLSClientConfig.VerifyConnection = func(state tls.ConnectionState) error {
+		entry, ok := trust[strings.ToLower(state.ServerName)]
+		if !ok {
+			return nil
+		}
+		return entry.verify(state)
+	}
+	fmt.Fprintf(t.out, "%s✓ Custom certificate trust for %d hosts%s\n", ColorGreen, len(trust), ColorReset)
+	return nil
+}
+
+// verify requires a verified chain ending in one of the host's CAs, when it has any, with a
+// certificate matching one of its pins, when it has any
+func (h hostTrust) verify(state tls.ConnectionState) error {
+	var seen []string
+	for _, chain := range state.VerifiedChains {
+		if !h.trusts(chain[len(chain)-1]) {
+			continue
+		}
+		if len(h.pins) == 0 {
+			return nil
+		}
+		for _, cert := range chain {
+			pin := spkiPin(cert)
+			for _, expected := range h.pins {
+				if pin == expected {
+					return nil
+				}
+			}
+			seen = append(seen, fmt.Sprintf("%s (%s)", pin, cert.Subject.CommonName))
+		}
+	}
+	if seen == nil {
+		return fmt.Errorf("%w for %s: not issued by its ca_file", errHostTrust, state.ServerName)
+	}
+	return fmt.Errorf("%w for %s: SPKI pin mismatch, expected one of %s, got %s", errHostTrust, state.ServerName,
+		strings.Join(h.pins, ", "), strings.Join(seen, ", "))
+}
+
+// trusts reports whether a chain's root is one of the host's CAs; hosts without a ca_file trust the system roots
+func (h hostTrust) trusts(root *x509.Certificate) bool {
+	if len(h.cas) == 0 {
+		return true
+	}
+	for _, ca := range h.cas {
+		if ca.Equal(root) {
+			return true
+		}
+	}
+	return false
+}
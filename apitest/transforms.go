@@ -0,0 +1,324 @@
+package apitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// transformPattern matches one pipeline stage such as "upper" or "sum(.price)"
+var transformPattern = regexp.MustCompile(`^(\w+)(?:\((.*)\))?$`)
+
+// transformFunc applies a transform to a value; arg is the text inside the parentheses
+type transformFunc func(value interface{}, arg string) (interface{}, error)
+
+// extractTransforms lists the transforms usable in extraction expressions
+var extractTransforms = map[string]transformFunc{
+	"upper":  stringTransform(strings.ToUpper),
+	"lower":  stringTransform(strings.ToLower),
+	"trim":   stringTransform(strings.TrimSpace),
+	"length": lengthTransform,
+	"first":  firstTransform,
+	"last":   lastTransform,
+	"map":    mapTransform,
+	"join":   joinTransform,
+	"sum":    numbersTransform(sumOf),
+	"min":    numbersTransform(minOf),
+	"max":    numbersTransform(maxOf),
+	"number": numberTransform,
+	"string": func(value interface{}, _ string) (interface{}, error) { return fmt.Sprintf("%v", value), nil },
+}
+
+// noValueError is an extraction path that is missing or null in the response, which only fails
+// the test when the extract entry is required
+type noValueError string
+
+// Error returns the message
+func (e noValueError) Error() string {
+	return string(e)
+}
+
+// extractValue evaluates an extraction expression: a response path optionally followed by
+// transforms separated by "|", e.g. "data.items | sum(.price)" or "data.id | upper". A
+// "jmespath:" expression is evaluated as a whole, its pipes being JMESPath's own.
+func extractValue(data interface{}, expression string) (interface{}, error) {
+	if strings.HasPrefix(expression, JMESPathPrefix) {
+		value, err := lookupPath(data, expression)
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			return nil, noValueError(fmt.Sprintf("No value at %s", expression))
+		}
+		return value, nil
+	}
+
+	stages := strings.Split(expression, "|")
+	path := strings.TrimSpace(stages[0])
+
+	var value interface{}
+	if wildcard := strings.ReplaceAll(path, "[*]", ".*"); slices.Contains(strings.Split(wildcard, "."), "*") {
+		value = wildcardValues(data, wildcard)
+	} else {
+		value = getNestedValue(data, path)
+	}
+	if value == nil {
+		return nil, noValueError(fmt.Sprintf("No value at %s", path))
+	}
+
+	for _, stage := range stages[1:] {
+		stage = strings.TrimSpace(stage)
+		match := transformPattern.FindStringSubmatch(stage)
+		if match == nil {
+			return nil, fmt.Errorf("invalid transform %q", stage)
+		}
+		transform, ok := extractTransforms[match[1]]
+		if !ok {
+			return nil, fmt.Errorf("unknown transform %q (available: %s)", match[1], transformNames())
+		}
+
+		var err error
+		value, err = transform(value, unquote(strings.TrimSpace(match[2])))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", match[1], err)
+		}
+	}
+	return value, nil
+}
+
+// wildcardValues collects the values at a path with "*" segments, e.g. "data.items.*.id", into an
+// array. Items lacking the rest of the path are left out; nil means nothing before the first "*" exists.
+func wildcardValues(data interface{}, path string) interface{} {
+	prefix, rest, _ := strings.Cut(path, "*")
+	parent := data
+	if prefix = strings.TrimSuffix(prefix, "."); prefix != "" {
+		var ok bool
+		if parent, ok = nestedValue(data, prefix); !ok {
+			return nil
+		}
+	}
+
+	var items []interface{}
+	switch v := parent.(type) {
+	case []interface{}:
+		items = v
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			items = append(items, v[key])
+		}
+	default:
+		return nil
+	}
+
+	rest = strings.TrimPrefix(rest, ".")
+	values := []interface{}{}
+	for _, item := range items {
+		switch {
+		case rest == "":
+			values = append(values, item)
+		case strings.Contains(rest, "*"):
+			// Nested wildcards flatten into one array
+			if nested, ok := wildcardValues(item, rest).([]interface{}); ok {
+				values = append(values, nested...)
+			}
+		default:
+			if value, ok := nestedValue(item, rest); ok {
+				values = append(values, value)
+			}
+		}
+	}
+	return values
+}
+
+// transformNames returns the sorted names of the available transforms
+func transformNames() string {
+	names := make([]string, 0, len(extractTransforms))
+	for name := range extractTransforms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+// unquote strips surrounding double quotes from a transform argument
+func unquote(arg string) string {
+	if s, err := strconv.Unquote(arg); err == nil {
+		return s
+	}
+	return arg
+}
+
+// fieldOf returns the value at a ".field" path of an element, or the element itself for an empty path
+func fieldOf(element interface{}, field string) interface{} {
+	field = strings.TrimPrefix(field, ".")
+	if field == "" {
+		return element
+	}
+	return getNestedValue(element, field)
+}
+
+// asArray returns the value as an array or an error naming what it was instead
+func asArray(value interface{}) ([]interface{}, error) {
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected an array, got %s", formatJSONContext(value, 0))
+	}
+	return array, nil
+}
+
+// toNumber converts a JSON number or numeric string to float64
+func toNumber(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(strings.TrimSpace(v), 64)
+	default:
+		return 0, fmt.Errorf("expected a number, got %s", formatJSONContext(value, 0))
+	}
+}
+
+// stringTransform adapts a string function to a transform
+func stringTransform(fn func(string) string) transformFunc {
+	return func(value interface{}, _ string) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected a string, got %s", formatJSONContext(value, 0))
+		}
+		return fn(s), nil
+	}
+}
+
+// lengthTransform returns the number of characters, items or keys
+func lengthTransform(value interface{}, _ string) (interface{}, error) {
+	switch v := value.(type) {
+	case string:
+		return float64(len([]rune(v))), nil
+	case []interface{}:
+		return float64(len(v)), nil
+	case map[string]interface{}:
+		return float64(len(v)), nil
+	default:
+		return nil, fmt.Errorf("expected a string, array or object, got %s", formatJSONContext(value, 0))
+	}
+}
+
+// firstTransform returns the first item of an array
+func firstTransform(value interface{}, _ string) (interface{}, error) {
+	array, err := asArray(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(array) == 0 {
+		return nil, fmt.Errorf("array is empty")
+	}
+	return array[0], nil
+}
+
+// lastTransform returns the last item of an array
+func lastTransform(value interface{}, _ string) (interface{}, error) {
+	array, err := asArray(value)
+	if err != nil {
+		return nil, err
+	}
+	if len(array) == 0 {
+		return nil, fmt.Errorf("array is empty")
+	}
+	return array[len(array)-1], nil
+}
+
+// mapTransform replaces each array item with one of its fields
+func mapTransform(value interface{}, field string) (interface{}, error) {
+	array, err := asArray(value)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]interface{}, len(array))
+	for i, element := range array {
+		result[i] = fieldOf(element, field)
+	}
+	return result, nil
+}
+
+// joinTransform joins array items into a string, separated by the argument (default ",")
+func joinTransform(value interface{}, sep string) (interface{}, error) {
+	array, err := asArray(value)
+	if err != nil {
+		return nil, err
+	}
+	if sep == "" {
+		sep = ","
+	}
+	parts := make([]string, len(array))
+	for i, element := range array {
+		parts[i] = fmt.Sprintf("%v", element)
+	}
+	return strings.Join(parts, sep), nil
+}
+
+// numberTransform converts a numeric string to a number
+func numberTransform(value interface{}, _ string) (interface{}, error) {
+	return toNumber(value)
+}
+
+// numbersTransform adapts an aggregate over numbers to a transform; the optional
+// argument selects a field of each array item, e.g. sum(.price)
+func numbersTransform(aggregate func([]float64) (float64, error)) transformFunc {
+	return func(value interface{}, field string) (interface{}, error) {
+		array, err := asArray(value)
+		if err != nil {
+			return nil, err
+		}
+		numbers := make([]float64, len(array))
+		for i, element := range array {
+			if numbers[i], err = toNumber(fieldOf(element, field)); err != nil {
+				return nil, fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		return aggregate(numbers)
+	}
+}
+
+// sumOf adds up numbers
+func sumOf(numbers []float64) (float64, error) {
+	total := 0.0
+	for _, n := range numbers {
+		total += n
+	}
+	return total, nil
+}
+
+// minOf returns the smallest number
+func minOf(numbers []float64) (float64, error) {
+	if len(numbers) == 0 {
+		return 0, fmt.Errorf("array is empty")
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		result = min(result, n)
+	}
+	return result, nil
+}
+
+// maxOf returns the largest number
+func maxOf(numbers []float64) (float64, error) {
+	if len(numbers) == 0 {
+		return 0, fmt.Errorf("array is empty")
+	}
+	result := numbers[0]
+	for _, n := range numbers[1:] {
+		result = max(result, n)
+	}
+	return result, nil
+}
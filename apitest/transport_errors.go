@@ -0,0 +1,66 @@
+package apitest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// checkExpectTransportError rejects expectations about a response on a test whose request should
+// get none
+func checkExpectTransportError(testCase TestCase) error {
+	if testCase.ExpectTransportError == "" {
+		return nil
+	}
+	if _, isStep := stepTypes[testCase.Type]; isStep {
+		return fmt.Errorf("expect_transport_error only applies to HTTP tests")
+	}
+	if testCase.ExpectedStatusCode != 0 || testCase.ExpectedResponse != nil || len(testCase.ExpectedResponses) > 0 ||
+		testCase.SuccessWhen != "" || len(testCase.Extract) > 0 {
+		return fmt.Errorf("expect_transport_error expects no response, so it can't be combined with expected_status_code, expected_response, expected_responses, success_when or extract")
+	}
+	return nil
+}
+
+// transportErrorMatches reports whether a request error is the expected one: its message contains
+// the expected text, ignoring case, or its failure category is the text, e.g. "timeout". The
+// request's URL is left out of the message, so it can't match by accident.
+func transportErrorMatches(err error, expected string) bool {
+	message := err.Error()
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		message = urlErr.Err.Error()
+	}
+	expected = strings.ToLower(expected)
+	return strings.Contains(strings.ToLower(message), expected) || classifyRequestError(err) == expected
+}
+
+// checkTransportError finishes a test with expect_transport_error: it passes when the request
+// failed with the expected error, and fails in the transport-mismatch category when it got a
+// response or failed differently, e.g. timed out on a dropped packet instead of being refused
+func (t *APITester) checkTransportError(testCase TestCase, result *TestResult, resp *http.Response, err error) {
+	expected := testCase.ExpectTransportError
+	switch {
+	case err == nil:
+		result.ResponseStatusCode = resp.StatusCode
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		result.addError(CategoryTransport, fmt.Sprintf("Transport error: Expected %q, but the request got HTTP %d", expected, resp.StatusCode))
+	case !transportErrorMatches(err, expected):
+		result.TransportError = err.Error()
+		result.addError(CategoryTransport, fmt.Sprintf("Transport error: Expected %q, got %v", expected, err))
+	default:
+		result.TransportError = err.Error()
+		fmt.Fprintf(t.out, "  %s↳ Failed as expected: %s%s\n", ColorCyan, t.maskSecrets(err.Error()), ColorReset)
+	}
+
+	if len(result.Errors) > 0 {
+		result.Status = StatusFailed
+	} else {
+		result.Status = StatusPassed
+	}
+	t.printTestResult(*result)
+}
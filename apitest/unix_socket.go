@@ -0,0 +1,118 @@
+package apitest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+// UnixScheme prefixes URLs served over a Unix domain socket, e.g. unix:///var/run/app.sock/health
+const UnixScheme = "unix://"
+
+// unixSocketTransport sends unix:// requests over their socket and everything else to the next transport
+type unixSocketTransport struct {
+	next http.RoundTripper
+	// base is the socket of a unix:// base URL, which tells it apart from the HTTP path after it
+	base string
+
+	mu      sync.Mutex
+	sockets map[string]*http.Transport
+}
+
+// usesUnixSockets reports whether the base URL, a service or any test targets a Unix socket
+func (t *APITester) usesUnixSockets() bool {
+	if strings.HasPrefix(t.BaseURL, UnixScheme) {
+		return true
+	}
+	for _, base := range t.Services {
+		if strings.HasPrefix(base, UnixScheme) {
+			return true
+		}
+	}
+	for _, testCase := range t.TestCases {
+		if strings.HasPrefix(testCase.API, UnixScheme) || strings.HasPrefix(testCase.BaseURL, UnixScheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// enableUnixSockets routes unix:// requests over their socket, ahead of any other transport
+func (t *APITester) enableUnixSockets() {
+	if _, ok := t.HTTPClient.Transport.(*unixSocketTransport); ok {
+		return
+	}
+	next := t.HTTPClient.Transport
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	base := ""
+	if strings.HasPrefix(t.BaseURL, UnixScheme) {
+		base = strings.TrimPrefix(t.BaseURL, UnixScheme)
+	}
+	t.HTTPClient.Transport = &unixSocketTransport{next: next, base: base, sockets: make(map[string]*http.Transport)}
+}
+
+// splitUnixPath splits the path of a unix:// URL into the socket and the HTTP path after it. Without
+// a unix:// base URL to go by, the socket is the first existing socket file along the path.
+func (u *unixSocketTransport) splitUnixPath(path string) (string, string, error) {
+	if u.base != "" && strings.HasPrefix(path, u.base) && (len(path) == len(u.base) || path[len(u.base)] == '/') {
+		return u.base, path[len(u.base):], nil
+	}
+	for i := 1; i <= len(path); i++ {
+		if i < len(path) && path[i] != '/' {
+			continue
+		}
+		if info, err := os.Stat(path[:i]); err == nil && info.Mode()&os.ModeSocket != 0 {
+			return path[:i], path[i:], nil
+		}
+	}
+	return "", "", fmt.Errorf("no unix socket found in %s", path)
+}
+
+// RoundTrip sends a unix:// request as plain HTTP over its socket
+func (u *unixSocketTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme != "unix" {
+		return u.next.RoundTrip(req)
+	}
+	socket, path, err := u.splitUnixPath(req.URL.Path)
+	if err != nil {
+		return nil, err
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	// The socket replaces the host, so the request goes out with Host: localhost like curl's --unix-socket
+	out := req.Clone(req.Context())
+	out.URL.Scheme = "http"
+	out.URL.Host = "localhost"
+	out.URL.Path = path
+	out.URL.RawPath = ""
+	if out.Host == "" {
+		out.Host = "localhost"
+	}
+	return u.socketTransport(socket).RoundTrip(out)
+}
+
+// socketTransport returns the pooled transport dialing one socket
+func (u *unixSocketTransport) socketTransport(socket string) *http.Transport {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	transport, ok := u.sockets[socket]
+	if !ok {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = nil
+		dialer := &net.Dialer{}
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		}
+		u.sockets[socket] = transport
+	}
+	return transport
+}
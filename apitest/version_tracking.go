@@ -0,0 +1,151 @@
+package apitest
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// versionTracker follows the server version a response header reports across a run, so a
+// deployment rolling out underneath a long run shows up instead of explaining failures away
+type versionTracker struct {
+	header string
+
+	mu       sync.Mutex
+	versions []ServerVersion
+	current  string
+	changes  int
+}
+
+// ServerVersion is one value of the version header and when the run saw it
+type ServerVersion struct {
+	Version   string `json:"version"`
+	FirstTest string `json:"first_test"`
+	FirstSeen string `json:"first_seen"`
+	LastTest  string `json:"last_test"`
+	LastSeen  string `json:"last_seen"`
+	Responses int    `json:"responses"`
+}
+
+// ServerVersions is the report's record of the version header
+type ServerVersions struct {
+	Header   string          `json:"header"`
+	Versions []ServerVersion `json:"versions"`
+	// Changes counts the responses whose version differed from the one before, e.g. 2 for a
+	// rollout that was rolled back
+	Changes int `json:"changes"`
+}
+
+// TrackVersionHeader records the server version each response reports in a header, such as
+// X-App-Version, on its result and in the report, and warns when it changes mid-run
+func (t *APITester) TrackVersionHeader(header string) {
+	t.versions = &versionTracker{header: header}
+}
+
+// record notes the version of a test's response on its result, printing a warning to out if it
+// differs from the previous response's
+func (v *versionTracker) record(out io.Writer, result *TestResult, resp *http.Response) {
+	if v == nil {
+		return
+	}
+	version := strings.TrimSpace(resp.Header.Get(v.header))
+	if version == "" {
+		return
+	}
+	result.ServerVersion = version
+	now := time.Now().UTC().Format(time.RFC3339)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.current != "" && version != v.current {
+		v.changes++
+		fmt.Fprintf(out, "  %s⚠ %s changed from %s to %s mid-run; a deployment may be underway%s\n",
+			ColorYellow, v.header, v.current, version, ColorReset)
+	}
+	v.current = version
+	for i := range v.versions {
+		if v.versions[i].Version == version {
+			v.versions[i].LastTest, v.versions[i].LastSeen = result.TestCaseName, now
+			v.versions[i].Responses++
+			return
+		}
+	}
+	v.versions = append(v.versions, ServerVersion{
+		Version:   version,
+		FirstTest: result.TestCaseName,
+		FirstSeen: now,
+		LastTest:  result.TestCaseName,
+		LastSeen:  now,
+		Responses: 1,
+	})
+}
+
+// merge adds the versions a distributed agent saw. Times are RFC 3339 in UTC, so they compare as
+// strings, and each agent's changes are counted since every agent sees a rollout separately.
+func (v *versionTracker) merge(other *ServerVersions) {
+	if v == nil || other == nil {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.changes += other.Changes
+	for _, seen := range other.Versions {
+		found := false
+		for i := range v.versions {
+			existing := &v.versions[i]
+			if existing.Version != seen.Version {
+				continue
+			}
+			found = true
+			existing.Responses += seen.Responses
+			if seen.FirstSeen < existing.FirstSeen {
+				existing.FirstTest, existing.FirstSeen = seen.FirstTest, seen.FirstSeen
+			}
+			if seen.LastSeen > existing.LastSeen {
+				existing.LastTest, existing.LastSeen = seen.LastTest, seen.LastSeen
+			}
+		}
+		if !found {
+			v.versions = append(v.versions, seen)
+		}
+	}
+}
+
+// summary returns the report section, or nil when not tracking
+func (v *versionTracker) summary() *ServerVersions {
+	if v == nil {
+		return nil
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return &ServerVersions{Header: v.header, Versions: append([]ServerVersion{}, v.versions...), Changes: v.changes}
+}
+
+// printServerVersions adds the versions seen to the summary, in yellow if the version changed
+func (t *APITester) printServerVersions() {
+	versions := t.versions.summary()
+	if versions == nil {
+		return
+	}
+	if len(versions.Versions) == 0 {
+		fmt.Fprintf(t.out, "  %sServer Version: no response had a %s header%s\n", ColorYellow, versions.Header, ColorReset)
+		return
+	}
+	if versions.Changes == 0 {
+		fmt.Fprintf(t.out, "  Server Version: %s\n", versions.Versions[0].Version)
+		return
+	}
+	names := make([]string, len(versions.Versions))
+	for i, version := range versions.Versions {
+		names[i] = version.Version
+	}
+	times := fmt.Sprintf("%d times", versions.Changes)
+	if versions.Changes == 1 {
+		times = "once"
+	}
+	fmt.Fprintf(t.out, "  %sServer Versions: %s (changed %s mid-run, first at %q)%s\n",
+		ColorYellow, strings.Join(names, " → "), times, versions.Versions[1].FirstTest, ColorReset)
+}
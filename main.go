@@ -0,0 +1,8 @@
+// Command api_tester runs the API test suites described in readme.md
+package main
+
+import "github.com/pawatthir/auto-test-api/apitest"
+
+func main() {
+	apitest.Main()
+}